@@ -42,7 +42,10 @@ func main() {
 	defer redisClient.Close()
 
 	// Setup Gin router
-	router := api.SetupRouter(cfg, db, redisClient, zapLogger)
+	router, taskQueue := api.SetupRouter(cfg, db, redisClient, zapLogger)
+
+	// Start background workers
+	taskQueue.Start()
 
 	// HTTP server configuration
 	srv := &http.Server{
@@ -76,5 +79,8 @@ func main() {
 		log.Fatalf("Server forced to shutdown: %v", err)
 	}
 
+	// Running tasks are put back in the queue for the next start
+	taskQueue.Stop()
+
 	zap.L().Info("Server exited gracefully")
 }
@@ -3,6 +3,7 @@ package handlers
 import (
 	"account/internal/business/services"
 	"account/internal/data/repository"
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -150,3 +151,98 @@ func (h *AccountHandler) DeleteAccount(c *gin.Context) {
 
 	c.JSON(http.StatusNoContent, nil)
 }
+
+// GetAliases lists the names an account is recognised by in imported files
+func (h *AccountHandler) GetAliases(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid account id"})
+		return
+	}
+
+	aliases, err := h.accountService.GetAliases(id, userID)
+	if err != nil {
+		h.writeAliasError(c, "Failed to get account aliases", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, aliases)
+}
+
+// AddAlias maps another name in imported files to an account
+func (h *AccountHandler) AddAlias(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid account id"})
+		return
+	}
+
+	var req services.AccountAliasRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	alias, err := h.accountService.AddAlias(id, userID, &req)
+	if err != nil {
+		h.writeAliasError(c, "Failed to add account alias", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, alias)
+}
+
+func (h *AccountHandler) DeleteAlias(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid account id"})
+		return
+	}
+
+	aliasID, err := uuid.Parse(c.Param("alias_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid alias id"})
+		return
+	}
+
+	if err := h.accountService.DeleteAlias(id, aliasID, userID); err != nil {
+		h.writeAliasError(c, "Failed to delete account alias", err)
+		return
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
+
+func (h *AccountHandler) writeAliasError(c *gin.Context, msg string, err error) {
+	switch {
+	case errors.Is(err, repository.ErrAccountNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "account not found"})
+	case errors.Is(err, repository.ErrAccountAliasNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "account alias not found"})
+	case errors.Is(err, repository.ErrAccountAliasExists):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidAccountAlias):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		h.logger.Error(msg, zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+	}
+}
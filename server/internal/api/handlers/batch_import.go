@@ -2,12 +2,15 @@ package handlers
 
 import (
 	"encoding/base64"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
-	"time"
+	"strings"
 
 	"account/internal/business/models"
 	"account/internal/business/services"
+	"account/internal/data/repository"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"go.uber.org/zap"
@@ -16,24 +19,30 @@ import (
 // BatchImportHandler handles batch import HTTP requests
 type BatchImportHandler struct {
 	batchService *services.BatchImportService
+	limits       services.UploadLimits
 	logger       *zap.Logger
 }
 
 // NewBatchImportHandler creates a new BatchImportHandler
-func NewBatchImportHandler(batchService *services.BatchImportService, logger *zap.Logger) *BatchImportHandler {
+func NewBatchImportHandler(batchService *services.BatchImportService, limits services.UploadLimits, logger *zap.Logger) *BatchImportHandler {
 	return &BatchImportHandler{
 		batchService: batchService,
+		limits:       limits,
 		logger:       logger,
 	}
 }
 
+// multipartOverhead allows for part headers and form fields on top of the files
+const multipartOverhead = 1 << 20
+
 // CreateBatchImportRequest represents the request to create a batch import
 type CreateBatchImportRequest struct {
 	Files []struct {
-		Source   string `json:"source" binding:"required,oneof=alipay wechat jd bank generic"`
+		Source   string `json:"source" binding:"required,oneof=alipay wechat jd bank generic ofx qif camt053 mt940 auto"`
 		FileName string `json:"file_name" binding:"required"`
-		Content  string `json:"content" binding:"required"` // base64 encoded
-	} `json:"files" binding:"required,min=1,max=20"`
+		Content  string `json:"content" binding:"required"` // base64 encoded, may be a ZIP archive
+		Password string `json:"password"`                   // unzip password of encrypted archives
+	} `json:"files" binding:"required,min=1"`
 }
 
 // CreateBatchImportResponse represents the response after creating a batch import
@@ -44,7 +53,8 @@ type CreateBatchImportResponse struct {
 	FileCount int    `json:"file_count"`
 }
 
-// CreateBatchImport creates a new batch import job
+// CreateBatchImport creates a new batch import job. Files are sent either as
+// multipart/form-data or base64 encoded in a JSON body.
 func (h *BatchImportHandler) CreateBatchImport(c *gin.Context) {
 	userID, err := h.getUserID(c)
 	if err != nil {
@@ -52,17 +62,35 @@ func (h *BatchImportHandler) CreateBatchImport(c *gin.Context) {
 		return
 	}
 
+	if c.ContentType() == "multipart/form-data" {
+		h.createMultipartBatchImport(c, userID)
+		return
+	}
+
+	// base64 takes four bytes for every three
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.limits.MaxTotalSize/3*4+multipartOverhead)
+
 	var req CreateBatchImportRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": h.totalSizeMessage()})
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if len(req.Files) > h.limits.MaxFiles {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("at most %d files can be uploaded at once", h.limits.MaxFiles)})
+		return
+	}
 
 	// Validate and decode files
 	files := make([]services.FileUpload, 0, len(req.Files))
+	var total int64
 	for _, f := range req.Files {
 		// Validate source
-		if !isValidSource(f.Source) {
+		if f.Source != services.SourceAuto && !isValidSource(f.Source) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid source: %s", f.Source)})
 			return
 		}
@@ -79,33 +107,172 @@ func (h *BatchImportHandler) CreateBatchImport(c *gin.Context) {
 			return
 		}
 
-		// Decode base64 to validate
-		_, err := base64.StdEncoding.DecodeString(f.Content)
+		// Decode base64 to validate and measure it, without keeping the result
+		size, err := io.Copy(io.Discard, base64.NewDecoder(base64.StdEncoding, strings.NewReader(f.Content)))
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid base64 content for file %s: %v", f.FileName, err)})
 			return
 		}
+		if size > h.limits.MaxFileSize {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": h.fileSizeMessage(f.FileName)})
+			return
+		}
+		if total += size; total > h.limits.MaxTotalSize {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": h.totalSizeMessage()})
+			return
+		}
 
 		files = append(files, services.FileUpload{
 			Source:   f.Source,
 			FileName: f.FileName,
 			Content:  f.Content,
+			Password: f.Password,
 		})
 	}
 
-	// Create batch job
+	h.createBatchJob(c, userID, files)
+}
+
+// createMultipartBatchImport creates a batch job from a multipart/form-data
+// upload. Every file part is spooled to disk as it arrives, so the request is
+// never held in memory. Repeated "sources" and "passwords" fields give, in file
+// order, the source ("auto" when missing) and archive password of each file.
+func (h *BatchImportHandler) createMultipartBatchImport(c *gin.Context, userID uuid.UUID) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.limits.MaxTotalSize+multipartOverhead)
+
+	reader, err := c.Request.MultipartReader()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid multipart upload"})
+		return
+	}
+
+	var files []services.FileUpload
+	var sources, passwords []string
+	var total int64
+	fail := func(status int, message string) {
+		services.RemoveUploads(files)
+		c.JSON(status, gin.H{"error": message})
+	}
+
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				fail(http.StatusRequestEntityTooLarge, h.totalSizeMessage())
+				return
+			}
+			fail(http.StatusBadRequest, "invalid multipart upload")
+			return
+		}
+
+		switch {
+		case part.FileName() != "":
+			if len(files) >= h.limits.MaxFiles {
+				part.Close()
+				fail(http.StatusRequestEntityTooLarge, fmt.Sprintf("at most %d files can be uploaded at once", h.limits.MaxFiles))
+				return
+			}
+			limit := h.limits.MaxFileSize
+			if remaining := h.limits.MaxTotalSize - total; remaining < limit {
+				limit = remaining
+			}
+			path, size, err := services.SpoolUpload(h.limits.Dir, part, limit)
+			part.Close()
+			if err != nil {
+				var tooLarge *http.MaxBytesError
+				switch {
+				case errors.Is(err, services.ErrUploadTooLarge) && limit < h.limits.MaxFileSize, errors.As(err, &tooLarge):
+					fail(http.StatusRequestEntityTooLarge, h.totalSizeMessage())
+				case errors.Is(err, services.ErrUploadTooLarge):
+					fail(http.StatusRequestEntityTooLarge, h.fileSizeMessage(part.FileName()))
+				default:
+					h.logger.Error("Failed to store uploaded file", zap.Error(err))
+					fail(http.StatusInternalServerError, "failed to read file")
+				}
+				return
+			}
+			total += size
+			files = append(files, services.FileUpload{FileName: part.FileName(), Path: path})
+		case part.FormName() == "sources" || part.FormName() == "passwords":
+			value, err := io.ReadAll(io.LimitReader(part, 1024))
+			part.Close()
+			if err != nil {
+				fail(http.StatusBadRequest, "invalid multipart upload")
+				return
+			}
+			if part.FormName() == "sources" {
+				sources = append(sources, strings.TrimSpace(string(value)))
+			} else {
+				passwords = append(passwords, string(value))
+			}
+		default:
+			part.Close()
+		}
+	}
+
+	if len(files) == 0 {
+		fail(http.StatusBadRequest, "no file uploaded")
+		return
+	}
+	for i := range files {
+		files[i].Source = services.SourceAuto
+		if i < len(sources) && sources[i] != "" {
+			files[i].Source = sources[i]
+		}
+		if files[i].Source != services.SourceAuto && !isValidSource(files[i].Source) {
+			fail(http.StatusBadRequest, fmt.Sprintf("invalid source: %s", files[i].Source))
+			return
+		}
+		if i < len(passwords) {
+			files[i].Password = passwords[i]
+		}
+	}
+
+	h.createBatchJob(c, userID, files)
+}
+
+func (h *BatchImportHandler) fileSizeMessage(fileName string) string {
+	return fmt.Sprintf("file %s is larger than %d MB", fileName, h.limits.MaxFileSize>>20)
+}
+
+func (h *BatchImportHandler) totalSizeMessage() string {
+	return fmt.Sprintf("the upload is larger than %d MB in total", h.limits.MaxTotalSize>>20)
+}
+
+// createBatchJob creates the job and writes the response; shared with ZIP uploads to /import/upload
+func (h *BatchImportHandler) createBatchJob(c *gin.Context, userID uuid.UUID, files []services.FileUpload) {
 	job, err := h.batchService.CreateBatchJob(userID, files)
 	if err != nil {
-		h.logger.Error("Failed to create batch job", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create batch job"})
+		if errors.Is(err, services.ErrBatchJobNotQueued) {
+			h.logger.Error("Failed to queue batch job", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to queue batch import job"})
+			return
+		}
+		// Otherwise creating a job only fails on unreadable uploads (bad base64, unsupported type, bad archive, wrong password, too large once extracted)
+		if !errors.Is(err, services.ErrZipPasswordRequired) && !errors.Is(err, services.ErrZipWrongPassword) {
+			h.logger.Warn("Failed to create batch job", zap.Error(err))
+		}
+		status, message := http.StatusBadRequest, err.Error()
+		switch {
+		case errors.Is(err, services.ErrUnsupportedFileType):
+			status = http.StatusUnsupportedMediaType
+		case errors.Is(err, services.ErrUploadTooLarge):
+			// Archive members count against the total size once extracted
+			status, message = http.StatusRequestEntityTooLarge, h.totalSizeMessage()
+		}
+		c.JSON(status, gin.H{"error": message})
 		return
 	}
 
 	c.JSON(http.StatusCreated, CreateBatchImportResponse{
 		JobID:     job.ID.String(),
 		Status:    string(job.Status),
-		Message:   "Batch import job created successfully. Processing started in background.",
-		FileCount: len(files),
+		Message:   "Batch import job created successfully. Processing is queued in background.",
+		FileCount: job.TotalFiles,
 	})
 }
 
@@ -113,7 +280,9 @@ func (h *BatchImportHandler) CreateBatchImport(c *gin.Context) {
 func isValidSource(source string) bool {
 	switch models.ImportSource(source) {
 	case models.ImportSourceAlipay, models.ImportSourceWeChat, models.ImportSourceJD,
-		models.ImportSourceBank, models.ImportSourceGeneric:
+		models.ImportSourceBank, models.ImportSourceGeneric,
+		models.ImportSourceOFX, models.ImportSourceQIF,
+		models.ImportSourceCamt053, models.ImportSourceMT940:
 		return true
 	}
 	return false
@@ -147,11 +316,8 @@ func (h *BatchImportHandler) ListBatchImports(c *gin.Context) {
 		return
 	}
 
-	// TODO: In a real implementation, this would fetch from database
-	// For now, return empty list
-	_ = userID // Mark as used
 	c.JSON(http.StatusOK, ListBatchImportsResponse{
-		Jobs: []models.BatchImportJob{},
+		Jobs: h.batchService.ListBatchJobs(userID),
 	})
 }
 
@@ -176,34 +342,91 @@ func (h *BatchImportHandler) GetBatchImportStatus(c *gin.Context) {
 		return
 	}
 
-	// TODO: In a real implementation, fetch job from database
-	// For now, return a dummy job
-	job := models.BatchImportJob{
-		ID:        jobID,
-		UserID:    userID,
-		Status:    models.BatchImportStatusReadyToImport,
-		TotalFiles: 2,
-		ParsedFiles: 2,
-		TotalTransactions: 10,
-		ValidTransactions: 10,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+	detail, err := h.batchService.GetBatchJob(userID, jobID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "batch import job not found"})
+		return
 	}
 
-	progress := services.CalculateProgress(&job)
+	progress := services.CalculateProgress(&detail.Job)
 
 	c.JSON(http.StatusOK, GetBatchImportStatusResponse{
-		Job:      job,
+		Job:      detail.Job,
 		Progress: progress,
 	})
 }
 
+// GetBatchImportProgress gets the latest progress report of a batch import job,
+// the same report that is pushed over /ws/sync as import_progress
+func (h *BatchImportHandler) GetBatchImportProgress(c *gin.Context) {
+	userID, err := h.getUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	jobID, err := uuid.Parse(c.Param("job_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid job_id"})
+		return
+	}
+
+	progress, err := h.batchService.GetBatchProgress(userID, jobID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "batch import job not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, progress)
+}
+
+// DownloadFileDiagnostics returns the rows of a batch file that were skipped or
+// only partly parsed as a CSV file
+func (h *BatchImportHandler) DownloadFileDiagnostics(c *gin.Context) {
+	userID, err := h.getUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	jobID, err := uuid.Parse(c.Param("job_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid job_id"})
+		return
+	}
+	fileID, err := uuid.Parse(c.Param("file_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid file_id"})
+		return
+	}
+
+	file, err := h.batchService.GetFileDiagnosticsFile(userID, jobID, fileID)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrBatchJobNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "batch import job not found"})
+		case errors.Is(err, services.ErrBatchFileNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "batch import file not found"})
+		default:
+			h.logger.Error("Failed to write parse diagnostics", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		}
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", file.FileName))
+	c.Data(http.StatusOK, file.ContentType, file.Content)
+}
+
 // GetBatchImportPreviewResponse represents the response for getting batch import preview
 type GetBatchImportPreviewResponse struct {
 	Job              models.BatchImportJob       `json:"job"`
 	Files            []models.BatchImportFile    `json:"files"`
 	TransferMatches  []models.TransferMatch      `json:"transfer_matches"`
+	TransferCandidates []models.TransferCandidates `json:"transfer_candidates"` // 每笔转账的候选对方
+	TransferGroups   []models.TransferGroupMatch `json:"transfer_groups"`     // 一对多/多对一转账建议
 	AccountHints     []models.AccountHint        `json:"account_hints"`
+	Duplicates       []models.CrossSourceDuplicate `json:"duplicates"`
 }
 
 // GetBatchImportPreview gets the preview of a batch import job
@@ -215,25 +438,228 @@ func (h *BatchImportHandler) GetBatchImportPreview(c *gin.Context) {
 	}
 
 	jobIDStr := c.Param("job_id")
-	_, err = uuid.Parse(jobIDStr)
+	jobID, err := uuid.Parse(jobIDStr)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid job_id"})
 		return
 	}
 
-	// TODO: In a real implementation, fetch preview data from database
-	// For now, return empty preview
-	_ = userID // Mark as used for now
+	detail, err := h.batchService.GetBatchJob(userID, jobID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "batch import job not found"})
+		return
+	}
+	if detail.AccountHints == nil {
+		detail.AccountHints = []models.AccountHint{}
+	}
+	if detail.Duplicates == nil {
+		detail.Duplicates = []models.CrossSourceDuplicate{}
+	}
+	if detail.TransferMatches == nil {
+		detail.TransferMatches = []models.TransferMatch{}
+	}
+	if detail.TransferCandidates == nil {
+		detail.TransferCandidates = []models.TransferCandidates{}
+	}
+
 	c.JSON(http.StatusOK, GetBatchImportPreviewResponse{
-		Job: models.BatchImportJob{
-			UserID: userID,
-		},
-		Files:           []models.BatchImportFile{},
-		TransferMatches: []models.TransferMatch{},
-		AccountHints:    []models.AccountHint{},
+		Job:             detail.Job,
+		Files:           detail.Files,
+		TransferMatches: detail.TransferMatches,
+		TransferCandidates: detail.TransferCandidates,
+		TransferGroups:  detail.TransferGroups,
+		AccountHints:    detail.AccountHints,
+		Duplicates:      detail.Duplicates,
 	})
 }
 
+// ResolveDuplicateRequest represents the user's decision for a cross-source duplicate
+type ResolveDuplicateRequest struct {
+	Decision models.DuplicateDecision `json:"decision" binding:"required,oneof=merge keep_both"`
+}
+
+// ResolveDuplicate merges a payment app record with its bank statement row, or keeps both
+func (h *BatchImportHandler) ResolveDuplicate(c *gin.Context) {
+	userID, err := h.getUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	jobID, err := uuid.Parse(c.Param("job_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid job_id"})
+		return
+	}
+	duplicateID, err := uuid.Parse(c.Param("duplicate_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid duplicate_id"})
+		return
+	}
+
+	var req ResolveDuplicateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	dup, err := h.batchService.ResolveDuplicate(userID, jobID, duplicateID, req.Decision)
+	switch {
+	case errors.Is(err, services.ErrBatchJobNotFound), errors.Is(err, services.ErrDuplicatePairNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, services.ErrBatchJobNotReady):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		h.logger.Error("Failed to resolve duplicate", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to resolve duplicate"})
+		return
+	}
+
+	c.JSON(http.StatusOK, dup)
+}
+
+// ConfirmTransferMatch imports the rows of a transfer match and links them,
+// or links an imported row to the ledger transaction it was matched with
+func (h *BatchImportHandler) ConfirmTransferMatch(c *gin.Context) {
+	userID, err := h.getUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	jobID, err := uuid.Parse(c.Param("job_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid job_id"})
+		return
+	}
+	matchID, err := uuid.Parse(c.Param("match_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid match_id"})
+		return
+	}
+
+	var req services.ConfirmTransferMatchRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	match, err := h.batchService.ConfirmTransferMatch(userID, jobID, matchID, &req)
+	switch {
+	case errors.Is(err, services.ErrBatchJobNotFound), errors.Is(err, services.ErrTransferMatchNotFound),
+		errors.Is(err, repository.ErrTransactionNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, services.ErrBatchJobNotReady), errors.Is(err, services.ErrTransferMatchConfirmed),
+		errors.Is(err, repository.ErrTransferAlreadyLinked), errors.Is(err, repository.ErrDuplicateExternalID):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, services.ErrTransferAccountRequired), errors.Is(err, services.ErrInvalidTransferLink),
+		errors.Is(err, repository.ErrAccountNotFound):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		h.logger.Error("Failed to confirm transfer match", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to confirm transfer match"})
+		return
+	}
+
+	c.JSON(http.StatusOK, match)
+}
+
+// RejectTransferMatch drops a wrong transfer match so that its rows are imported
+// on their own; the matcher learns from the rejection
+func (h *BatchImportHandler) RejectTransferMatch(c *gin.Context) {
+	userID, err := h.getUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	jobID, err := uuid.Parse(c.Param("job_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid job_id"})
+		return
+	}
+	matchID, err := uuid.Parse(c.Param("match_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid match_id"})
+		return
+	}
+
+	err = h.batchService.RejectTransferMatch(userID, jobID, matchID)
+	switch {
+	case errors.Is(err, services.ErrBatchJobNotFound), errors.Is(err, services.ErrTransferMatchNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, services.ErrBatchJobNotReady), errors.Is(err, services.ErrTransferMatchConfirmed):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		h.logger.Error("Failed to reject transfer match", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to reject transfer match"})
+		return
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// ConfirmTransferGroup imports the rows of a one-to-many or many-to-one transfer
+// and links them, with any ledger transactions of the group, into one transfer
+func (h *BatchImportHandler) ConfirmTransferGroup(c *gin.Context) {
+	userID, err := h.getUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	jobID, err := uuid.Parse(c.Param("job_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid job_id"})
+		return
+	}
+	groupID, err := uuid.Parse(c.Param("group_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid group_id"})
+		return
+	}
+
+	var req services.ConfirmTransferMatchRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	group, err := h.batchService.ConfirmTransferGroup(userID, jobID, groupID, &req)
+	switch {
+	case errors.Is(err, services.ErrBatchJobNotFound), errors.Is(err, services.ErrTransferGroupNotFound),
+		errors.Is(err, repository.ErrTransactionNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, services.ErrBatchJobNotReady), errors.Is(err, services.ErrTransferMatchConfirmed),
+		errors.Is(err, services.ErrTransferGroupUnavailable), errors.Is(err, repository.ErrTransferAlreadyLinked),
+		errors.Is(err, repository.ErrDuplicateExternalID):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, services.ErrTransferAccountRequired), errors.Is(err, services.ErrInvalidTransferLink),
+		errors.Is(err, repository.ErrAccountNotFound):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		h.logger.Error("Failed to confirm transfer group", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to confirm transfer group"})
+		return
+	}
+
+	c.JSON(http.StatusOK, group)
+}
+
 // ExecuteBatchImportRequest represents the request to execute a batch import
 type ExecuteBatchImportRequest struct {
 	SelectedAccountIDs map[string]string `json:"selected_account_ids"` // file_index:account_id
@@ -267,7 +693,14 @@ func (h *BatchImportHandler) ExecuteBatchImport(c *gin.Context) {
 		req.SelectedAccountIDs,
 		req.ConfirmedMatchIDs,
 	)
-	if err != nil {
+	switch {
+	case errors.Is(err, services.ErrBatchJobNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, services.ErrBatchJobNotReady):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
 		h.logger.Error("Failed to execute batch import", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to execute batch import"})
 		return
@@ -278,19 +711,27 @@ func (h *BatchImportHandler) ExecuteBatchImport(c *gin.Context) {
 
 // DeleteBatchImport deletes a batch import job
 func (h *BatchImportHandler) DeleteBatchImport(c *gin.Context) {
-	_, err := h.getUserID(c)
+	userID, err := h.getUserID(c)
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
 		return
 	}
 
 	jobIDStr := c.Param("job_id")
-	_, err = uuid.Parse(jobIDStr)
+	jobID, err := uuid.Parse(jobIDStr)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid job_id"})
 		return
 	}
 
-	// TODO: In a real implementation, delete from database
+	if err := h.batchService.DeleteBatchJob(userID, jobID); err != nil {
+		if errors.Is(err, services.ErrBatchJobNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "batch import job not found"})
+			return
+		}
+		h.logger.Error("Failed to cancel batch import job", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to cancel batch import job"})
+		return
+	}
 	c.JSON(http.StatusOK, gin.H{"message": "Batch import job deleted successfully"})
 }
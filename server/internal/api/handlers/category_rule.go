@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"account/internal/business/services"
+	"account/internal/data/repository"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+type CategoryRuleHandler struct {
+	ruleService *services.CategoryRuleService
+	logger      *zap.Logger
+}
+
+func NewCategoryRuleHandler(ruleService *services.CategoryRuleService, logger *zap.Logger) *CategoryRuleHandler {
+	return &CategoryRuleHandler{
+		ruleService: ruleService,
+		logger:      logger,
+	}
+}
+
+func (h *CategoryRuleHandler) CreateRule(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req services.CategoryRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rule, err := h.ruleService.CreateRule(userID, &req)
+	if err != nil {
+		h.writeError(c, "Failed to create category rule", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, rule)
+}
+
+func (h *CategoryRuleHandler) GetRule(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid rule id"})
+		return
+	}
+
+	rule, err := h.ruleService.GetRule(id, userID)
+	if err != nil {
+		h.writeError(c, "Failed to get category rule", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, rule)
+}
+
+func (h *CategoryRuleHandler) GetAllRules(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	rules, err := h.ruleService.GetAllRules(userID)
+	if err != nil {
+		h.writeError(c, "Failed to get category rules", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, rules)
+}
+
+func (h *CategoryRuleHandler) UpdateRule(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid rule id"})
+		return
+	}
+
+	var req services.CategoryRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rule, err := h.ruleService.UpdateRule(id, userID, &req)
+	if err != nil {
+		h.writeError(c, "Failed to update category rule", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, rule)
+}
+
+func (h *CategoryRuleHandler) DeleteRule(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid rule id"})
+		return
+	}
+
+	if err := h.ruleService.DeleteRule(id, userID); err != nil {
+		h.writeError(c, "Failed to delete category rule", err)
+		return
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// ReorderRules sets the evaluation order of the user's rules
+func (h *CategoryRuleHandler) ReorderRules(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req services.ReorderCategoryRulesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rules, err := h.ruleService.ReorderRules(userID, &req)
+	if err != nil {
+		h.writeError(c, "Failed to reorder category rules", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, rules)
+}
+
+// TestRule dry-runs an unsaved rule against the user's transaction history
+func (h *CategoryRuleHandler) TestRule(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req services.CategoryRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := h.ruleService.TestRule(userID, &req)
+	if err != nil {
+		h.writeError(c, "Failed to test category rule", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+func (h *CategoryRuleHandler) writeError(c *gin.Context, msg string, err error) {
+	switch {
+	case errors.Is(err, repository.ErrCategoryRuleNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "category rule not found"})
+	case errors.Is(err, services.ErrInvalidCategoryRule):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		h.logger.Error(msg, zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+	}
+}
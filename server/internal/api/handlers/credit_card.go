@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"account/internal/business/services"
+	"account/internal/data/repository"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+type CreditCardHandler struct {
+	creditCardService *services.CreditCardService
+	logger            *zap.Logger
+}
+
+func NewCreditCardHandler(creditCardService *services.CreditCardService, logger *zap.Logger) *CreditCardHandler {
+	return &CreditCardHandler{
+		creditCardService: creditCardService,
+		logger:            logger,
+	}
+}
+
+// SaveBill records a credit card statement's bill amount and due date
+func (h *CreditCardHandler) SaveBill(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid account id"})
+		return
+	}
+
+	var req services.SaveBillRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	bill, err := h.creditCardService.SaveBill(userID, id, &req)
+	if err != nil {
+		h.writeError(c, "Failed to save credit card bill", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, bill)
+}
+
+// GetBills lists a credit account's bills and how much of each has been repaid
+func (h *CreditCardHandler) GetBills(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid account id"})
+		return
+	}
+
+	bills, err := h.creditCardService.GetBills(userID, id)
+	if err != nil {
+		h.writeError(c, "Failed to get credit card bills", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, bills)
+}
+
+func (h *CreditCardHandler) DeleteBill(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid account id"})
+		return
+	}
+
+	billID, err := uuid.Parse(c.Param("bill_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid bill id"})
+		return
+	}
+
+	if err := h.creditCardService.DeleteBill(userID, id, billID); err != nil {
+		h.writeError(c, "Failed to delete credit card bill", err)
+		return
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
+
+func (h *CreditCardHandler) writeError(c *gin.Context, msg string, err error) {
+	switch {
+	case errors.Is(err, repository.ErrAccountNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "account not found"})
+	case errors.Is(err, repository.ErrCreditCardBillNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "credit card bill not found"})
+	case errors.Is(err, services.ErrNotCreditAccount), errors.Is(err, services.ErrInvalidCreditCardBill):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		h.logger.Error(msg, zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+	}
+}
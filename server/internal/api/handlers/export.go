@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"account/internal/business/services"
+	"account/internal/data/repository"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// ExportHandler handles export-related HTTP requests
+type ExportHandler struct {
+	exportService *services.ExportService
+	logger        *zap.Logger
+}
+
+// NewExportHandler creates a new ExportHandler
+func NewExportHandler(exportService *services.ExportService, logger *zap.Logger) *ExportHandler {
+	return &ExportHandler{
+		exportService: exportService,
+		logger:        logger,
+	}
+}
+
+// ExportAccount downloads an account's transactions as OFX or QIF
+func (h *ExportHandler) ExportAccount(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	accountID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid account id"})
+		return
+	}
+
+	format := services.ExportFormat(c.DefaultQuery("format", string(services.ExportFormatOFX)))
+	if format != services.ExportFormatOFX && format != services.ExportFormatQIF {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be ofx or qif"})
+		return
+	}
+
+	var start, end time.Time
+	var parseErr error
+
+	startStr := c.Query("start_date")
+	if startStr != "" {
+		start, parseErr = time.Parse(time.RFC3339, startStr)
+		if parseErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid start_date format, use RFC3339"})
+			return
+		}
+	} else {
+		start = time.Unix(0, 0)
+	}
+
+	endStr := c.Query("end_date")
+	if endStr != "" {
+		end, parseErr = time.Parse(time.RFC3339, endStr)
+		if parseErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid end_date format, use RFC3339"})
+			return
+		}
+	} else {
+		end = time.Now()
+	}
+
+	file, err := h.exportService.ExportAccount(userID, &services.ExportRequest{
+		AccountID: accountID,
+		Format:    format,
+		StartDate: start,
+		EndDate:   end,
+	})
+	if err != nil {
+		if err == repository.ErrAccountNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "account not found"})
+			return
+		}
+		h.logger.Error("Failed to export account", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", file.FileName))
+	c.Data(http.StatusOK, file.ContentType, file.Content)
+}
@@ -3,10 +3,13 @@ package handlers
 import (
 	"account/internal/business/models"
 	"account/internal/business/services"
-	"bytes"
-	"io"
+	"account/internal/data/repository"
+	"errors"
+	"fmt"
 	"net/http"
 	"path/filepath"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -16,20 +19,25 @@ import (
 // ImportHandler handles import-related HTTP requests
 type ImportHandler struct {
 	importService *services.ImportService
+	batchHandler  *BatchImportHandler
 	logger        *zap.Logger
 }
 
-// NewImportHandler creates a new ImportHandler
-func NewImportHandler(importService *services.ImportService, logger *zap.Logger) *ImportHandler {
+// NewImportHandler creates a new ImportHandler. ZIP uploads are handed over
+// to the batch import handler, since an archive may hold several bills.
+func NewImportHandler(importService *services.ImportService, batchHandler *BatchImportHandler, logger *zap.Logger) *ImportHandler {
 	return &ImportHandler{
 		importService: importService,
+		batchHandler:  batchHandler,
 		logger:        logger,
 	}
 }
 
-// UploadAndParseRequest is the request for uploading and parsing a file
+// UploadAndParseRequest is the request for uploading and parsing a file.
+// Source may be omitted (or "auto") to detect it from the content.
 type UploadAndParseRequest struct {
-	Source string `form:"source" binding:"required,oneof=alipay wechat bank generic"`
+	Source   string `form:"source" binding:"omitempty,oneof=alipay wechat bank generic ofx qif camt053 mt940 auto"`
+	Password string `form:"password"` // unzip password of encrypted ZIP archives
 }
 
 // UploadAndParse handles file upload and initial parsing
@@ -55,22 +63,59 @@ func (h *ImportHandler) UploadAndParse(c *gin.Context) {
 	}
 	defer file.Close()
 
-	// Read file content
-	content, err := io.ReadAll(file)
-	if err != nil {
-		h.logger.Error("Failed to read uploaded file", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read file"})
+	// The file is parsed as it is read; only its start is looked at up front
+	reader := services.NewBillReader(file)
+	magic, _ := reader.Peek(512)
+	if err := services.SniffUpload(header.Filename, magic); err != nil {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
 		return
 	}
 
+	// ZIP archives (Alipay/WeChat bill downloads) become a batch job with one file per member
+	if services.IsZipArchive(magic) {
+		path, _, err := services.SpoolUpload(h.batchHandler.limits.Dir, reader, h.batchHandler.limits.MaxFileSize)
+		if err != nil {
+			if errors.Is(err, services.ErrUploadTooLarge) {
+				c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": h.batchHandler.fileSizeMessage(header.Filename)})
+				return
+			}
+			h.logger.Error("Failed to read uploaded file", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read file"})
+			return
+		}
+		h.batchHandler.createBatchJob(c, userID, []services.FileUpload{{
+			Source:   req.Source,
+			FileName: header.Filename,
+			Path:     path,
+			Password: req.Password,
+		}})
+		return
+	}
+
+	if req.Source == "" || req.Source == services.SourceAuto {
+		head, err := services.PeekBillHead(reader)
+		if err != nil {
+			h.logger.Error("Failed to read uploaded file", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read file"})
+			return
+		}
+		source, ok := services.DetectImportSource(header.Filename, head)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unable to detect the file source, please choose one"})
+			return
+		}
+		req.Source = string(source)
+	}
+
 	// Parse the file
 	parseReq := &services.ParseRequest{
 		Source:   models.ImportSource(req.Source),
 		FileName: header.Filename,
-		File:     bytes.NewReader(content),
+		File:     reader,
+		Size:     header.Size,
 	}
 
-	preview, err := h.importService.ParseFile(userID, parseReq)
+	preview, err := h.importService.CreatePreview(userID, parseReq)
 	if err != nil {
 		h.logger.Error("Failed to parse file", zap.Error(err), zap.String("source", req.Source))
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -80,13 +125,15 @@ func (h *ImportHandler) UploadAndParse(c *gin.Context) {
 	c.JSON(http.StatusOK, preview)
 }
 
-// ExecuteImportRequest is the request to execute import
+// ExecuteImportRequest is the request to execute import. The rows are taken
+// from the stored preview, with the edits made through the preview endpoints.
+// Version, if given, must be the preview version the client last read.
 type ExecuteImportRequest struct {
-	JobID        string                  `json:"job_id" binding:"required"`
-	Transactions []models.ParsedTransaction `json:"transactions" binding:"required"`
+	JobID   string `json:"job_id" binding:"required"`
+	Version int    `json:"version"`
 }
 
-// ExecuteImport executes the import with user-selected mappings
+// ExecuteImport imports the rows of a stored preview
 func (h *ImportHandler) ExecuteImport(c *gin.Context) {
 	userID, err := getUserID(c)
 	if err != nil {
@@ -107,26 +154,342 @@ func (h *ImportHandler) ExecuteImport(c *gin.Context) {
 		return
 	}
 
-	// Build execute request
-	executeReq := &services.ExecuteImportRequest{
-		JobID:        jobUUID,
-		Transactions: req.Transactions,
+	// Execute import
+	result, err := h.importService.ExecuteImport(userID, &services.ExecuteImportRequest{JobID: jobUUID, Version: req.Version})
+	if errors.Is(err, repository.ErrImportJobRolledBack) {
+		c.JSON(http.StatusConflict, gin.H{"error": "this import has been rolled back, please upload the file again"})
+		return
+	}
+	if err != nil {
+		h.writePreviewError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// GetPreview returns a stored preview
+func (h *ImportHandler) GetPreview(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
 	}
 
-	// Execute import
-	result, err := h.importService.ExecuteImport(userID, executeReq)
+	jobID, err := parseUUID(c.Param("job_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid job_id"})
+		return
+	}
+
+	preview, err := h.importService.GetPreview(userID, jobID)
+	if err != nil {
+		h.writePreviewError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, preview)
+}
+
+// DownloadPreviewDiagnostics returns the rows of a preview's file that were
+// skipped or only partly parsed as a CSV file, so that they can be fixed and
+// uploaded again
+func (h *ImportHandler) DownloadPreviewDiagnostics(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	jobID, err := parseUUID(c.Param("job_id"))
 	if err != nil {
-		h.logger.Error("Failed to execute import", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid job_id"})
+		return
+	}
+
+	file, err := h.importService.GetPreviewDiagnosticsFile(userID, jobID)
+	if err != nil {
+		h.writePreviewError(c, err)
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", file.FileName))
+	c.Data(http.StatusOK, file.ContentType, file.Content)
+}
+
+// UpdatePreviewRowRequest edits one preview row. Version, if given, must be the
+// preview version the client last read.
+type UpdatePreviewRowRequest struct {
+	services.ImportPreviewEdit
+	Version int `json:"version"`
+}
+
+// UpdatePreviewRow changes the account, category, note or include flag of one row
+func (h *ImportHandler) UpdatePreviewRow(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	jobID, err := parseUUID(c.Param("job_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid job_id"})
+		return
+	}
+	lineNumber, err := strconv.Atoi(c.Param("line_number"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid line_number"})
+		return
+	}
+
+	var req UpdatePreviewRowRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.ImportPreviewEdit.IsEmpty() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "nothing to change"})
+		return
+	}
+
+	result, err := h.importService.UpdatePreviewRow(userID, jobID, lineNumber, &req.ImportPreviewEdit, req.Version)
+	if err != nil {
+		h.writePreviewError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, result)
 }
 
+// UpdatePreviewRowsRequest edits every preview row the filter selects
+type UpdatePreviewRowsRequest struct {
+	Filter  services.ImportPreviewFilter `json:"filter"`
+	Changes services.ImportPreviewEdit   `json:"changes"`
+	Version int                          `json:"version"`
+}
+
+// UpdatePreviewRows changes the account, category, note or include flag of a group of rows
+func (h *ImportHandler) UpdatePreviewRows(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	jobID, err := parseUUID(c.Param("job_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid job_id"})
+		return
+	}
+
+	var req UpdatePreviewRowsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Changes.IsEmpty() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "nothing to change"})
+		return
+	}
+
+	result, err := h.importService.UpdatePreviewRows(userID, jobID, &req.Filter, &req.Changes, req.Version)
+	if err != nil {
+		h.writePreviewError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// DiscardPreview deletes a stored preview without importing it
+func (h *ImportHandler) DiscardPreview(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	jobID, err := parseUUID(c.Param("job_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid job_id"})
+		return
+	}
+
+	if err := h.importService.DiscardPreview(userID, jobID); err != nil {
+		h.writePreviewError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
+
+func (h *ImportHandler) writePreviewError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, repository.ErrImportPreviewNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "import preview not found or expired"})
+	case errors.Is(err, services.ErrPreviewRowNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "preview row not found"})
+	case errors.Is(err, repository.ErrImportPreviewExecuted):
+		c.JSON(http.StatusConflict, gin.H{"error": "this preview has already been imported"})
+	case errors.Is(err, repository.ErrImportPreviewConflict):
+		c.JSON(http.StatusConflict, gin.H{"error": "the preview was changed by another request, please reload it"})
+	case errors.Is(err, repository.ErrAccountNotFound):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid account"})
+	case errors.Is(err, repository.ErrCategoryNotFound):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid category"})
+	case errors.Is(err, services.ErrPreviewRowNotImportable), errors.Is(err, services.ErrPreviewNoteTooLong):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		h.logger.Error("Failed to handle import preview", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+	}
+}
+
+// ImportHistoryRequest pages through the import history
+type ImportHistoryRequest struct {
+	Limit  int `form:"limit,default=50"`
+	Offset int `form:"offset,default=0"`
+}
+
+// GetImportHistory lists the user's executed imports, newest first
+func (h *ImportHandler) GetImportHistory(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req ImportHistoryRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		req.Limit = 50
+		req.Offset = 0
+	}
+
+	jobs, err := h.importService.GetImportHistory(userID, req.Limit, req.Offset)
+	if err != nil {
+		h.logger.Error("Failed to get import history", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"jobs": jobs})
+}
+
+// GetImportJob returns one import of the import history
+func (h *ImportHandler) GetImportJob(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	jobID, err := parseUUID(c.Param("job_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid job_id"})
+		return
+	}
+
+	job, err := h.importService.GetImportJob(userID, jobID)
+	if err != nil {
+		h.writeImportJobError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+// GetImportCoverage returns, per account and source, the periods the user's
+// imports covered and the periods that were never imported
+func (h *ImportHandler) GetImportCoverage(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	req := &services.ImportCoverageRequest{Source: models.ImportSource(c.Query("source"))}
+
+	if startStr := c.Query("start_date"); startStr != "" {
+		if req.From, err = time.Parse(time.RFC3339, startStr); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid start_date format, use RFC3339"})
+			return
+		}
+	}
+	if endStr := c.Query("end_date"); endStr != "" {
+		if req.To, err = time.Parse(time.RFC3339, endStr); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid end_date format, use RFC3339"})
+			return
+		}
+	}
+	if !req.From.IsZero() && !req.To.IsZero() && req.To.Before(req.From) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "end_date is before start_date"})
+		return
+	}
+	if accountStr := c.Query("account_id"); accountStr != "" {
+		accountID, err := parseUUID(accountStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid account_id"})
+			return
+		}
+		req.AccountID = &accountID
+	}
+	if gapStr := c.Query("min_gap_days"); gapStr != "" {
+		if req.MinGapDays, err = strconv.Atoi(gapStr); err != nil || req.MinGapDays < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "min_gap_days must be a positive number"})
+			return
+		}
+	}
+
+	report, err := h.importService.GetImportCoverage(userID, req)
+	if err != nil {
+		h.logger.Error("Failed to get import coverage", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// RollbackImport undoes an import: its transactions are deleted, transfers they
+// belong to are unlinked and account balances are restored
+func (h *ImportHandler) RollbackImport(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	jobID, err := parseUUID(c.Param("job_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid job_id"})
+		return
+	}
+
+	rollback, err := h.importService.RollbackImport(userID, jobID)
+	if err != nil {
+		h.writeImportJobError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, rollback)
+}
+
+func (h *ImportHandler) writeImportJobError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, repository.ErrImportJobNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "import job not found"})
+	case errors.Is(err, repository.ErrImportJobRolledBack):
+		c.JSON(http.StatusConflict, gin.H{"error": "import job has already been rolled back"})
+	default:
+		h.logger.Error("Failed to handle import job", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+	}
+}
+
 // ParseTemplateRequest requests a template for a specific source
 type ParseTemplateRequest struct {
-	Source string `form:"source" binding:"required,oneof=alipay wechat bank generic"`
+	Source string `form:"source" binding:"required,oneof=alipay wechat bank generic ofx qif camt053 mt940"`
 }
 
 // GetTemplateInfo returns information about the expected file format
@@ -172,6 +535,38 @@ func (h *ImportHandler) GetTemplateInfo(c *gin.Context) {
 			"optional_columns": []string{"description", "note", "category", "account"},
 			"file_extensions": []string{".csv"},
 		}
+	case "ofx":
+		templateInfo = map[string]interface{}{
+			"source": "ofx",
+			"description": "OFX/QFX statement (OFX 1.x SGML or 2.x XML). Offered by most foreign banks and brokers (e.g. HSBC, Wise, Interactive Brokers).",
+			"required_columns": []string{"STMTTRN/DTPOSTED", "STMTTRN/TRNAMT"},
+			"optional_columns": []string{"FITID", "NAME", "MEMO", "CURDEF", "LEDGERBAL"},
+			"file_extensions": []string{".ofx", ".qfx"},
+		}
+	case "qif":
+		templateInfo = map[string]interface{}{
+			"source": "qif",
+			"description": "Quicken Interchange Format. Transactions use the currency of the selected account.",
+			"required_columns": []string{"D (date)", "T (amount)"},
+			"optional_columns": []string{"P (payee)", "M (memo)", "L (category)", "N (number)"},
+			"file_extensions": []string{".qif"},
+		}
+	case "camt053":
+		templateInfo = map[string]interface{}{
+			"source": "camt053",
+			"description": "ISO 20022 camt.053 end-of-day statement (XML). Opening and closing balances are checked against the entries.",
+			"required_columns": []string{"Ntry/Amt", "Ntry/CdtDbtInd", "Ntry/BookgDt"},
+			"optional_columns": []string{"ValDt", "AcctSvcrRef", "RltdPties", "RmtInf/Ustrd", "Bal (OPBD/CLBD)"},
+			"file_extensions": []string{".xml"},
+		}
+	case "mt940":
+		templateInfo = map[string]interface{}{
+			"source": "mt940",
+			"description": "SWIFT MT940 customer statement. Opening and closing balances are checked against the entries.",
+			"required_columns": []string{":25: (account)", ":60F: (opening balance)", ":61: (statement line)"},
+			"optional_columns": []string{":86: (information to account owner)", ":62F: (closing balance)"},
+			"file_extensions": []string{".sta", ".mt940", ".txt"},
+		}
 	default:
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid source"})
 		return
@@ -207,6 +602,30 @@ func (h *ImportHandler) GetSupportedSources(c *gin.Context) {
 			"description": "导入通用CSV格式",
 			"icon":        "file",
 		},
+		{
+			"id":          "ofx",
+			"name":        "OFX/QFX",
+			"description": "导入OFX/QFX对账单（境外银行、券商）",
+			"icon":        "bank",
+		},
+		{
+			"id":          "qif",
+			"name":        "QIF",
+			"description": "导入Quicken QIF文件",
+			"icon":        "file",
+		},
+		{
+			"id":          "camt053",
+			"name":        "camt.053",
+			"description": "导入ISO 20022 camt.053日终对账单",
+			"icon":        "bank",
+		},
+		{
+			"id":          "mt940",
+			"name":        "MT940",
+			"description": "导入SWIFT MT940对账单",
+			"icon":        "bank",
+		},
 	}
 
 	c.JSON(http.StatusOK, gin.H{"sources": sources})
@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"account/internal/business/services"
+	"account/internal/data/repository"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+type PayeeHandler struct {
+	payeeService *services.PayeeService
+	logger       *zap.Logger
+}
+
+func NewPayeeHandler(payeeService *services.PayeeService, logger *zap.Logger) *PayeeHandler {
+	return &PayeeHandler{
+		payeeService: payeeService,
+		logger:       logger,
+	}
+}
+
+func (h *PayeeHandler) CreatePayee(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req services.CreatePayeeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	payee, err := h.payeeService.CreatePayee(userID, &req)
+	if err != nil {
+		h.writeError(c, "Failed to create payee", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, payee)
+}
+
+func (h *PayeeHandler) GetPayee(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payee id"})
+		return
+	}
+
+	payee, err := h.payeeService.GetPayee(id, userID)
+	if err != nil {
+		h.writeError(c, "Failed to get payee", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, payee)
+}
+
+func (h *PayeeHandler) GetAllPayees(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	payees, err := h.payeeService.GetAllPayees(userID)
+	if err != nil {
+		h.writeError(c, "Failed to get payees", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, payees)
+}
+
+func (h *PayeeHandler) UpdatePayee(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payee id"})
+		return
+	}
+
+	var req services.UpdatePayeeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	payee, err := h.payeeService.UpdatePayee(id, userID, &req)
+	if err != nil {
+		h.writeError(c, "Failed to update payee", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, payee)
+}
+
+func (h *PayeeHandler) DeletePayee(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payee id"})
+		return
+	}
+
+	if err := h.payeeService.DeletePayee(id, userID); err != nil {
+		h.writeError(c, "Failed to delete payee", err)
+		return
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// AddAlias maps another counterparty spelling to a payee
+func (h *PayeeHandler) AddAlias(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payee id"})
+		return
+	}
+
+	var req services.PayeeAliasRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	alias, err := h.payeeService.AddAlias(id, userID, &req)
+	if err != nil {
+		h.writeError(c, "Failed to add payee alias", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, alias)
+}
+
+func (h *PayeeHandler) DeleteAlias(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payee id"})
+		return
+	}
+
+	aliasID, err := uuid.Parse(c.Param("alias_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid alias id"})
+		return
+	}
+
+	if err := h.payeeService.DeleteAlias(id, aliasID, userID); err != nil {
+		h.writeError(c, "Failed to delete payee alias", err)
+		return
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
+
+func (h *PayeeHandler) writeError(c *gin.Context, msg string, err error) {
+	switch {
+	case errors.Is(err, repository.ErrPayeeNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "payee not found"})
+	case errors.Is(err, repository.ErrPayeeAliasNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "payee alias not found"})
+	case errors.Is(err, repository.ErrPayeeNameExists), errors.Is(err, repository.ErrPayeeAliasExists):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidPayee):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		h.logger.Error(msg, zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+	}
+}
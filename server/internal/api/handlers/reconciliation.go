@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"account/internal/business/services"
+	"account/internal/data/repository"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+type ReconciliationHandler struct {
+	reconciliationService *services.ReconciliationService
+	logger                *zap.Logger
+}
+
+func NewReconciliationHandler(reconciliationService *services.ReconciliationService, logger *zap.Logger) *ReconciliationHandler {
+	return &ReconciliationHandler{
+		reconciliationService: reconciliationService,
+		logger:                logger,
+	}
+}
+
+// Reconcile compares a statement's closing balance with the account's ledger
+func (h *ReconciliationHandler) Reconcile(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid account id"})
+		return
+	}
+
+	var req services.ReconcileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := h.reconciliationService.Reconcile(userID, id, &req)
+	if err != nil {
+		h.writeError(c, "Failed to reconcile account", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// GetBalanceAssertions lists the account's reconciled balances and whether they still hold
+func (h *ReconciliationHandler) GetBalanceAssertions(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid account id"})
+		return
+	}
+
+	assertions, err := h.reconciliationService.GetAssertions(userID, id)
+	if err != nil {
+		h.writeError(c, "Failed to get balance assertions", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, assertions)
+}
+
+func (h *ReconciliationHandler) DeleteBalanceAssertion(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid account id"})
+		return
+	}
+
+	assertionID, err := uuid.Parse(c.Param("assertion_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid balance assertion id"})
+		return
+	}
+
+	if err := h.reconciliationService.DeleteAssertion(userID, id, assertionID); err != nil {
+		h.writeError(c, "Failed to delete balance assertion", err)
+		return
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
+
+func (h *ReconciliationHandler) writeError(c *gin.Context, msg string, err error) {
+	switch {
+	case errors.Is(err, repository.ErrAccountNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "account not found"})
+	case errors.Is(err, repository.ErrBalanceAssertionNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "balance assertion not found"})
+	default:
+		h.logger.Error(msg, zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+	}
+}
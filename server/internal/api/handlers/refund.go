@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"account/internal/business/services"
+	"account/internal/data/repository"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+type RefundHandler struct {
+	refundService *services.RefundService
+	logger        *zap.Logger
+}
+
+func NewRefundHandler(refundService *services.RefundService, logger *zap.Logger) *RefundHandler {
+	return &RefundHandler{
+		refundService: refundService,
+		logger:        logger,
+	}
+}
+
+// GetRefunds returns a purchase with its refunds and the amount still refundable
+func (h *RefundHandler) GetRefunds(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid transaction id"})
+		return
+	}
+
+	summary, err := h.refundService.GetRefundSummary(userID, id)
+	if err != nil {
+		h.writeError(c, "Failed to get refunds", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, summary)
+}
+
+// LinkRefund links the refund transaction in the path to a purchase
+func (h *RefundHandler) LinkRefund(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid transaction id"})
+		return
+	}
+
+	var req services.LinkRefundRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	summary, err := h.refundService.LinkRefund(userID, id, req.PurchaseID)
+	if err != nil {
+		h.writeError(c, "Failed to link refund", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, summary)
+}
+
+func (h *RefundHandler) UnlinkRefund(c *gin.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid transaction id"})
+		return
+	}
+
+	if err := h.refundService.UnlinkRefund(userID, id); err != nil {
+		h.writeError(c, "Failed to unlink refund", err)
+		return
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
+
+func (h *RefundHandler) writeError(c *gin.Context, msg string, err error) {
+	switch {
+	case errors.Is(err, repository.ErrTransactionNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "transaction not found"})
+	case errors.Is(err, repository.ErrRefundLinkNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "refund link not found"})
+	case errors.Is(err, services.ErrInvalidRefundLink):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		h.logger.Error(msg, zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+	}
+}
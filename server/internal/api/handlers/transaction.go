@@ -86,6 +86,12 @@ func (h *TransactionHandler) GetAllTransactions(c *gin.Context) {
 		req.Limit = 50
 		req.Offset = 0
 	}
+	if req.PayeeID != "" {
+		if _, err := uuid.Parse(req.PayeeID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payee id"})
+			return
+		}
+	}
 
 	transactions, err := h.transactionService.GetAllTransactions(userID, &req)
 	if err != nil {
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"account/internal/business/services"
 	"account/internal/sync"
 	"account/pkg/auth"
 	"encoding/json"
@@ -36,6 +37,7 @@ var upgrader = websocket.Upgrader{
 
 type WebSocketHandler struct {
 	syncNotifier *sync.SyncNotifier
+	events       *sync.EventNotifier
 	tokenMgr     *auth.TokenManager
 	logger       *zap.Logger
 	mu           stdsync.Mutex
@@ -47,6 +49,7 @@ type WebSocketConnection struct {
 	userID   uuid.UUID
 	deviceID string
 	send     chan []byte
+	events   <-chan sync.Event
 }
 
 // WebSocket message types
@@ -54,6 +57,8 @@ const (
 	MessageTypeSyncAvailable = "sync_available"
 	MessageTypePing          = "ping"
 	MessageTypePong          = "pong"
+	// MessageTypeImportProgress carries a models.BatchImportProgress report
+	MessageTypeImportProgress = services.EventTypeImportProgress
 )
 
 type WebSocketMessage struct {
@@ -67,11 +72,13 @@ type SyncAvailableData struct {
 
 func NewWebSocketHandler(
 	syncNotifier *sync.SyncNotifier,
+	events *sync.EventNotifier,
 	tokenMgr *auth.TokenManager,
 	logger *zap.Logger,
 ) *WebSocketHandler {
 	return &WebSocketHandler{
 		syncNotifier: syncNotifier,
+		events:       events,
 		tokenMgr:     tokenMgr,
 		logger:       logger,
 		connections:  make(map[uuid.UUID]map[string]*WebSocketConnection),
@@ -108,6 +115,7 @@ func (h *WebSocketHandler) HandleWebSocket(c *gin.Context) {
 		userID:   claims.UserID,
 		deviceID: deviceID,
 		send:     make(chan []byte, 256),
+		events:   h.events.Subscribe(claims.UserID, deviceID),
 	}
 
 	// Register connection
@@ -151,6 +159,7 @@ func (h *WebSocketHandler) unregisterConnection(conn *WebSocketConnection) {
 	}
 
 	h.syncNotifier.Unsubscribe(conn.userID, conn.deviceID)
+	h.events.Unsubscribe(conn.userID, conn.deviceID, conn.events)
 }
 
 func (c *WebSocketConnection) readPump(h *WebSocketHandler, syncCh <-chan struct{}) {
@@ -210,6 +219,9 @@ func (c *WebSocketConnection) writePump() {
 		ticker.Stop()
 		_ = c.conn.Close()
 	}()
+	// c.events is read by unregisterConnection on the read side, so the closed
+	// channel is dropped from a local copy only
+	events := c.events
 
 	for {
 		select {
@@ -237,6 +249,21 @@ func (c *WebSocketConnection) writePump() {
 				return
 			}
 
+		case event, ok := <-events:
+			if !ok {
+				// Replaced by a newer connection of the device
+				events = nil
+				continue
+			}
+			data, err := json.Marshal(WebSocketMessage{Type: event.Type, Data: event.Data})
+			if err != nil {
+				continue
+			}
+			_ = c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				return
+			}
+
 		case <-ticker.C:
 			_ = c.conn.SetWriteDeadline(time.Now().Add(writeWait))
 			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
@@ -17,7 +17,9 @@ import (
 	"go.uber.org/zap"
 )
 
-func SetupRouter(cfg *config.Config, db *sqlx.DB, redis *redis.Client, logger *zap.Logger) *gin.Engine {
+// SetupRouter builds the router and the task queue that runs background work;
+// the caller starts and stops the queue
+func SetupRouter(cfg *config.Config, db *sqlx.DB, redis *redis.Client, logger *zap.Logger) (*gin.Engine, *services.TaskQueue) {
 	if cfg.Server.Mode == "release" {
 		gin.SetMode(gin.ReleaseMode)
 	}
@@ -33,28 +35,58 @@ func SetupRouter(cfg *config.Config, db *sqlx.DB, redis *redis.Client, logger *z
 	accountRepo := repository.NewAccountRepository(db)
 	categoryRepo := repository.NewCategoryRepository(db)
 	transactionRepo := repository.NewTransactionRepository(db)
+	duplicateDecisionRepo := repository.NewDuplicateDecisionRepository(db)
+	categoryRuleRepo := repository.NewCategoryRuleRepository(db)
+	payeeRepo := repository.NewPayeeRepository(db)
+	refundLinkRepo := repository.NewRefundLinkRepository(db)
+	balanceAssertionRepo := repository.NewBalanceAssertionRepository(db)
+	transferLinkRepo := repository.NewTransferLinkRepository(db)
+	creditCardBillRepo := repository.NewCreditCardBillRepository(db)
+	importJobRepo := repository.NewImportJobRepository(db)
+	importPreviewRepo := repository.NewImportPreviewRepository(db)
+	importCoverageRepo := repository.NewImportCoverageRepository(db)
 	syncRepo := repository.NewSyncRepository(db, accountRepo, categoryRepo, transactionRepo)
+	taskRepo := repository.NewTaskRepository(db)
 
 	// Initialize sync engine
 	syncEngine := sync.NewSyncEngine(syncRepo, accountRepo, categoryRepo, transactionRepo, logger)
+	eventNotifier := sync.NewEventNotifier()
 
 	// Initialize services
+	taskQueue := services.NewTaskQueue(taskRepo, taskQueueConfig(cfg.Tasks), logger)
+	categorySuggester := services.NewCategorySuggester(transactionRepo, logger)
+	refundService := services.NewRefundService(transactionRepo, refundLinkRepo)
+	reconciliationService := services.NewReconciliationService(transactionRepo, accountRepo, balanceAssertionRepo, transferLinkRepo, logger)
+	transferService := services.NewTransferService(transactionRepo, transferLinkRepo, categoryRepo, cfg.Import.FeeCategory, logger)
+	creditCardService := services.NewCreditCardService(accountRepo, creditCardBillRepo)
 	accountService := services.NewAccountService(accountRepo, categoryRepo)
 	categoryService := services.NewCategoryService(categoryRepo)
-	transactionService := services.NewTransactionService(transactionRepo, accountRepo, categoryRepo)
+	transactionService := services.NewTransactionService(transactionRepo, accountRepo, categoryRepo, payeeRepo, categoryRuleRepo, categorySuggester, reconciliationService)
 	syncService := services.NewSyncService(syncRepo, accountRepo, categoryRepo, transactionRepo)
-	importService := services.NewImportService(transactionRepo, accountRepo, categoryRepo, logger)
-	batchImportService := services.NewBatchImportService(importService, accountRepo, transactionRepo, categoryRepo, logger)
+	importService := services.NewImportService(transactionRepo, accountRepo, categoryRepo, payeeRepo, duplicateDecisionRepo, categoryRuleRepo, importJobRepo, importPreviewRepo, transferLinkRepo, importCoverageRepo, refundService, reconciliationService, categorySuggester, time.Duration(cfg.Import.PreviewTTLHours)*time.Hour, logger)
+	batchImportService := services.NewBatchImportService(importService, accountRepo, transactionRepo, categoryRepo, duplicateDecisionRepo, transferService, creditCardService, transferMatchConfig(cfg.Import), taskQueue, uploadLimits(cfg.Import), eventNotifier, logger)
+	if err := batchImportService.RestoreBatchJobs(); err != nil {
+		logger.Warn("Failed to restore batch import jobs", zap.Error(err))
+	}
+	exportService := services.NewExportService(transactionRepo, accountRepo, categoryRepo, transferLinkRepo)
+	categoryRuleService := services.NewCategoryRuleService(categoryRuleRepo, categoryRepo, accountRepo, transactionRepo)
+	payeeService := services.NewPayeeService(payeeRepo)
 
 	// Initialize handlers
 	authHandler := handlers.NewAuthHandler(userRepo, categoryRepo, tokenMgr, logger)
 	accountHandler := handlers.NewAccountHandler(accountService, logger)
 	categoryHandler := handlers.NewCategoryHandler(categoryService, logger)
+	categoryRuleHandler := handlers.NewCategoryRuleHandler(categoryRuleService, logger)
+	payeeHandler := handlers.NewPayeeHandler(payeeService, logger)
+	refundHandler := handlers.NewRefundHandler(refundService, logger)
+	reconciliationHandler := handlers.NewReconciliationHandler(reconciliationService, logger)
+	creditCardHandler := handlers.NewCreditCardHandler(creditCardService, logger)
 	transactionHandler := handlers.NewTransactionHandler(transactionService, logger)
 	syncHandler := handlers.NewSyncHandler(syncService, logger)
-	importHandler := handlers.NewImportHandler(importService, logger)
-	batchImportHandler := handlers.NewBatchImportHandler(batchImportService, logger)
-	wsHandler := handlers.NewWebSocketHandler(syncEngine.GetNotifier(), tokenMgr, logger)
+	batchImportHandler := handlers.NewBatchImportHandler(batchImportService, uploadLimits(cfg.Import), logger)
+	importHandler := handlers.NewImportHandler(importService, batchImportHandler, logger)
+	exportHandler := handlers.NewExportHandler(exportService, logger)
+	wsHandler := handlers.NewWebSocketHandler(syncEngine.GetNotifier(), eventNotifier, tokenMgr, logger)
 
 	authMiddleware := middleware.NewAuthMiddleware(tokenMgr)
 
@@ -105,6 +137,16 @@ func SetupRouter(cfg *config.Config, db *sqlx.DB, redis *redis.Client, logger *z
 				accounts.GET("/:id", accountHandler.GetAccount)
 				accounts.PUT("/:id", accountHandler.UpdateAccount)
 				accounts.DELETE("/:id", accountHandler.DeleteAccount)
+				accounts.GET("/:id/aliases", accountHandler.GetAliases)
+				accounts.POST("/:id/aliases", accountHandler.AddAlias)
+				accounts.DELETE("/:id/aliases/:alias_id", accountHandler.DeleteAlias)
+				accounts.GET("/:id/export", exportHandler.ExportAccount)
+				accounts.POST("/:id/reconcile", reconciliationHandler.Reconcile)
+				accounts.GET("/:id/balance-assertions", reconciliationHandler.GetBalanceAssertions)
+				accounts.DELETE("/:id/balance-assertions/:assertion_id", reconciliationHandler.DeleteBalanceAssertion)
+				accounts.POST("/:id/bills", creditCardHandler.SaveBill)
+				accounts.GET("/:id/bills", creditCardHandler.GetBills)
+				accounts.DELETE("/:id/bills/:bill_id", creditCardHandler.DeleteBill)
 			}
 
 			// Category endpoints
@@ -118,6 +160,30 @@ func SetupRouter(cfg *config.Config, db *sqlx.DB, redis *redis.Client, logger *z
 				categories.DELETE("/:id", categoryHandler.DeleteCategory)
 			}
 
+			// Category rule endpoints
+			categoryRules := protected.Group("/category-rules")
+			{
+				categoryRules.POST("", categoryRuleHandler.CreateRule)
+				categoryRules.GET("", categoryRuleHandler.GetAllRules)
+				categoryRules.POST("/test", categoryRuleHandler.TestRule)
+				categoryRules.PUT("/order", categoryRuleHandler.ReorderRules)
+				categoryRules.GET("/:id", categoryRuleHandler.GetRule)
+				categoryRules.PUT("/:id", categoryRuleHandler.UpdateRule)
+				categoryRules.DELETE("/:id", categoryRuleHandler.DeleteRule)
+			}
+
+			// Payee endpoints
+			payees := protected.Group("/payees")
+			{
+				payees.POST("", payeeHandler.CreatePayee)
+				payees.GET("", payeeHandler.GetAllPayees)
+				payees.GET("/:id", payeeHandler.GetPayee)
+				payees.PUT("/:id", payeeHandler.UpdatePayee)
+				payees.DELETE("/:id", payeeHandler.DeletePayee)
+				payees.POST("/:id/aliases", payeeHandler.AddAlias)
+				payees.DELETE("/:id/aliases/:alias_id", payeeHandler.DeleteAlias)
+			}
+
 			// Transaction endpoints
 			transactions := protected.Group("/transactions")
 			{
@@ -129,6 +195,9 @@ func SetupRouter(cfg *config.Config, db *sqlx.DB, redis *redis.Client, logger *z
 				transactions.GET("/:id", transactionHandler.GetTransaction)
 				transactions.PUT("/:id", transactionHandler.UpdateTransaction)
 				transactions.DELETE("/:id", transactionHandler.DeleteTransaction)
+				transactions.GET("/:id/refunds", refundHandler.GetRefunds)
+				transactions.PUT("/:id/refund-link", refundHandler.LinkRefund)
+				transactions.DELETE("/:id/refund-link", refundHandler.UnlinkRefund)
 			}
 
 			// Sync endpoints
@@ -143,6 +212,15 @@ func SetupRouter(cfg *config.Config, db *sqlx.DB, redis *redis.Client, logger *z
 			{
 				importGroup.POST("/upload", importHandler.UploadAndParse)
 				importGroup.POST("/execute", importHandler.ExecuteImport)
+				importGroup.GET("/previews/:job_id", importHandler.GetPreview)
+				importGroup.GET("/previews/:job_id/diagnostics", importHandler.DownloadPreviewDiagnostics)
+				importGroup.PATCH("/previews/:job_id/rows", importHandler.UpdatePreviewRows)
+				importGroup.PATCH("/previews/:job_id/rows/:line_number", importHandler.UpdatePreviewRow)
+				importGroup.DELETE("/previews/:job_id", importHandler.DiscardPreview)
+				importGroup.GET("/jobs", importHandler.GetImportHistory)
+				importGroup.GET("/jobs/:job_id", importHandler.GetImportJob)
+				importGroup.GET("/coverage", importHandler.GetImportCoverage)
+				importGroup.POST("/jobs/:job_id/rollback", importHandler.RollbackImport)
 			}
 
 			// Batch import endpoints
@@ -151,7 +229,13 @@ func SetupRouter(cfg *config.Config, db *sqlx.DB, redis *redis.Client, logger *z
 				batchImportGroup.POST("", batchImportHandler.CreateBatchImport)
 				batchImportGroup.GET("", batchImportHandler.ListBatchImports)
 				batchImportGroup.GET("/:job_id", batchImportHandler.GetBatchImportStatus)
+				batchImportGroup.GET("/:job_id/progress", batchImportHandler.GetBatchImportProgress)
 				batchImportGroup.GET("/:job_id/preview", batchImportHandler.GetBatchImportPreview)
+				batchImportGroup.GET("/:job_id/files/:file_id/diagnostics", batchImportHandler.DownloadFileDiagnostics)
+				batchImportGroup.POST("/:job_id/duplicates/:duplicate_id/decision", batchImportHandler.ResolveDuplicate)
+				batchImportGroup.POST("/:job_id/transfer-matches/:match_id/confirm", batchImportHandler.ConfirmTransferMatch)
+				batchImportGroup.POST("/:job_id/transfer-matches/:match_id/reject", batchImportHandler.RejectTransferMatch)
+				batchImportGroup.POST("/:job_id/transfer-groups/:group_id/confirm", batchImportHandler.ConfirmTransferGroup)
 				batchImportGroup.POST("/:job_id/execute", batchImportHandler.ExecuteBatchImport)
 				batchImportGroup.DELETE("/:job_id", batchImportHandler.DeleteBatchImport)
 			}
@@ -161,5 +245,69 @@ func SetupRouter(cfg *config.Config, db *sqlx.DB, redis *redis.Client, logger *z
 	// WebSocket endpoint (doesn't use standard auth middleware, token in query)
 	router.GET("/ws/sync", wsHandler.HandleWebSocket)
 
-	return router
+	return router, taskQueue
+}
+
+// transferMatchConfig applies the configured transfer matching thresholds over
+// the defaults; unset values keep their default
+func transferMatchConfig(cfg config.ImportConfig) services.TransferMatchConfig {
+	matchConfig := services.DefaultTransferMatchConfig()
+	if cfg.TransferTimeWindowHours > 0 {
+		matchConfig.TimeWindow = time.Duration(cfg.TransferTimeWindowHours) * time.Hour
+	}
+	if cfg.TransferMaxAmountDifference > 0 {
+		matchConfig.MaxAmountDifference = cfg.TransferMaxAmountDifference
+	}
+	if cfg.TransferMinConfidence > 0 {
+		matchConfig.MinConfidence = cfg.TransferMinConfidence
+	}
+	if cfg.TransferCandidateConfidence > 0 {
+		matchConfig.CandidateConfidence = cfg.TransferCandidateConfidence
+	}
+	if cfg.TransferMaxCandidates > 0 {
+		matchConfig.MaxCandidates = cfg.TransferMaxCandidates
+	}
+	if cfg.TransferMaxGroupSize > 0 {
+		matchConfig.MaxGroupSize = cfg.TransferMaxGroupSize
+	}
+	return matchConfig
+}
+
+// taskQueueConfig applies the configured task queue settings over the defaults
+func taskQueueConfig(cfg config.TasksConfig) services.TaskQueueConfig {
+	queueConfig := services.DefaultTaskQueueConfig()
+	if cfg.Workers > 0 {
+		queueConfig.Workers = cfg.Workers
+	}
+	if cfg.PerUserLimit > 0 {
+		queueConfig.PerUserLimit = cfg.PerUserLimit
+	}
+	if cfg.MaxAttempts > 0 {
+		queueConfig.MaxAttempts = cfg.MaxAttempts
+	}
+	if cfg.LeaseSeconds > 0 {
+		queueConfig.Lease = time.Duration(cfg.LeaseSeconds) * time.Second
+	}
+	if cfg.RetryBaseSeconds > 0 {
+		queueConfig.RetryBase = time.Duration(cfg.RetryBaseSeconds) * time.Second
+	}
+	if cfg.RetryMaxSeconds > 0 {
+		queueConfig.RetryMax = time.Duration(cfg.RetryMaxSeconds) * time.Second
+	}
+	return queueConfig
+}
+
+func uploadLimits(cfg config.ImportConfig) services.UploadLimits {
+	limits := services.DefaultUploadLimits()
+	limits.Dir = cfg.UploadDir
+	if cfg.UploadMaxFileMB > 0 {
+		limits.MaxFileSize = int64(cfg.UploadMaxFileMB) << 20
+	}
+	if cfg.UploadMaxTotalMB > 0 {
+		limits.MaxTotalSize = int64(cfg.UploadMaxTotalMB) << 20
+	}
+	if cfg.UploadMaxFiles > 0 {
+		limits.MaxFiles = cfg.UploadMaxFiles
+	}
+	return limits
 }
@@ -35,6 +35,26 @@ type Account struct {
 	IsDeleted      bool        `db:"is_deleted" json:"is_deleted"`
 }
 
+// AccountAliasSource tells how an account alias was added
+type AccountAliasSource string
+
+const (
+	AccountAliasManual  AccountAliasSource = "manual"  // 用户通过接口添加
+	AccountAliasLearned AccountAliasSource = "learned" // 从导入预览中用户选择的账户学到
+)
+
+// AccountAlias is a name under which an account appears in imported files
+type AccountAlias struct {
+	ID        uuid.UUID          `db:"id" json:"id"`
+	UserID    uuid.UUID          `db:"user_id" json:"user_id"`
+	AccountID uuid.UUID          `db:"account_id" json:"account_id"`
+	Alias     string             `db:"alias" json:"alias"`
+	AliasKey  string             `db:"alias_key" json:"-"` // 规整后的匹配键
+	Source    AccountAliasSource `db:"source" json:"source"`
+	CreatedAt time.Time          `db:"created_at" json:"created_at"`
+	UpdatedAt time.Time          `db:"updated_at" json:"updated_at"`
+}
+
 func (a *AccountType) Scan(value interface{}) error {
 	str, ok := value.(string)
 	if !ok {
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TaskStatus represents the status of a background task
+type TaskStatus string
+
+const (
+	TaskStatusQueued    TaskStatus = "queued"
+	TaskStatusRunning   TaskStatus = "running"
+	TaskStatusSucceeded TaskStatus = "succeeded"
+	TaskStatusFailed    TaskStatus = "failed" // 重试次数用尽或不可重试的错误
+	TaskStatusCancelled TaskStatus = "cancelled"
+)
+
+// BackgroundTask is a unit of work in the durable task queue. Payload is the
+// JSON input of the task, decoded by the handler registered for its Kind.
+type BackgroundTask struct {
+	ID          uuid.UUID  `db:"id" json:"id"`
+	UserID      uuid.UUID  `db:"user_id" json:"user_id"`
+	Kind        string     `db:"kind" json:"kind"`
+	Payload     []byte     `db:"payload" json:"-"`
+	Status      TaskStatus `db:"status" json:"status"`
+	Attempts    int        `db:"attempts" json:"attempts"` // 已开始的次数，含当前这次
+	MaxAttempts int        `db:"max_attempts" json:"max_attempts"`
+	RunAt       time.Time  `db:"run_at" json:"run_at"`
+	LockedUntil *time.Time `db:"locked_until" json:"locked_until,omitempty"`
+	ErrorMsg    string     `db:"error_msg" json:"error_msg,omitempty"`
+	Result      []byte     `db:"result" json:"-"` // 处理方保存的 JSON 结果，没有时为 nil
+	CreatedAt   time.Time  `db:"created_at" json:"created_at"`
+	UpdatedAt   time.Time  `db:"updated_at" json:"updated_at"`
+}
+
+// LastAttempt tells whether a failure of the running attempt fails the task for good
+func (t *BackgroundTask) LastAttempt() bool {
+	return t.Attempts >= t.MaxAttempts
+}
@@ -40,6 +40,8 @@ const (
 	MatchTypeTransfer   MatchType = "transfer"
 	MatchTypeNoteMerge  MatchType = "note_merge"
 	MatchTypeAccountLink MatchType = "account_link"
+	MatchTypeDuplicate   MatchType = "cross_source_duplicate"
+	MatchTypeRepayment   MatchType = "credit_card_repayment"
 )
 
 // BatchImportJob represents a batch import job for multiple files
@@ -53,11 +55,63 @@ type BatchImportJob struct {
 	ValidTransactions   int               `db:"valid_transactions" json:"valid_transactions"`
 	MatchPairs          int               `db:"match_pairs" json:"match_pairs"`
 	AutoCreatedAccounts int               `db:"auto_created_accounts" json:"auto_created_accounts"`
+	// Accounts the job created; nil until account creation has run
+	AutoCreatedAccountIDs []uuid.UUID     `db:"-" json:"auto_created_account_ids"`
 	ErrorMsg            string            `db:"error_msg" json:"error_msg,omitempty"`
+	DuplicatePairs      int               `db:"-" json:"duplicate_pairs"`
+	AutoConfirmedPairs  int               `db:"-" json:"auto_confirmed_pairs"`
+	BalanceChecks       []StatementBalanceCheck `db:"-" json:"balance_checks,omitempty"`
 	CreatedAt           time.Time         `db:"created_at" json:"created_at"`
 	UpdatedAt           time.Time         `db:"updated_at" json:"updated_at"`
 }
 
+// BatchImportEvent names the step of a batch job a progress report was sent for
+type BatchImportEvent string
+
+const (
+	BatchImportEventStarted         BatchImportEvent = "started"
+	BatchImportEventFileStarted     BatchImportEvent = "file_started"
+	BatchImportEventRowsProcessed   BatchImportEvent = "rows_processed"
+	BatchImportEventFileParsed      BatchImportEvent = "file_parsed"
+	BatchImportEventFileFailed      BatchImportEvent = "file_failed"
+	BatchImportEventDuplicatesFound BatchImportEvent = "duplicates_found"
+	BatchImportEventAccountsCreated BatchImportEvent = "accounts_created"
+	BatchImportEventMatchesFound    BatchImportEvent = "matches_found"
+	BatchImportEventError           BatchImportEvent = "error" // 不中断任务的错误
+	BatchImportEventCompleted       BatchImportEvent = "completed"
+	BatchImportEventFailed          BatchImportEvent = "failed"
+)
+
+// BatchImportProgress reports in detail how far a batch job has come. It is
+// pushed to the user's devices at every step and returned when polled.
+type BatchImportProgress struct {
+	JobID               uuid.UUID          `json:"job_id"`
+	Event               BatchImportEvent   `json:"event"`
+	Message             string             `json:"message,omitempty"`
+	Status              BatchImportStatus  `json:"status"`
+	PercentComplete     float64            `json:"percent_complete"`
+	TotalFiles          int                `json:"total_files"`
+	ParsedFiles         int                `json:"parsed_files"`
+	FailedFiles         int                `json:"failed_files"`
+	CurrentFile         string             `json:"current_file,omitempty"`
+	CurrentFileIndex    int                `json:"current_file_index,omitempty"` // 从 1 开始，第几个文件
+	BytesRead           int64              `json:"bytes_read"`
+	TotalBytes          int64              `json:"total_bytes"`
+	RowsProcessed       int                `json:"rows_processed"`
+	DuplicatePairs      int                `json:"duplicate_pairs"`
+	MatchPairs          int                `json:"match_pairs"`
+	AutoConfirmedPairs  int                `json:"auto_confirmed_pairs"`
+	AutoCreatedAccounts int                `json:"auto_created_accounts"`
+	Errors              []BatchImportError `json:"errors"`
+	UpdatedAt           time.Time          `json:"updated_at"`
+}
+
+// BatchImportError is an error of a batch job, of one file when FileName is set
+type BatchImportError struct {
+	FileName string `json:"file_name,omitempty"`
+	Message  string `json:"message"`
+}
+
 // BatchImportFile represents a single file within a batch import job
 type BatchImportFile struct {
 	ID              uuid.UUID         `db:"id" json:"id"`
@@ -68,10 +122,23 @@ type BatchImportFile struct {
 	ParsedContent   []ParsedTransaction `db:"parsed_content" json:"parsed_content"`
 	AccountHints    []AccountHint     `db:"account_hints" json:"account_hints"`
 	ParseErrors     []string          `db:"parse_errors" json:"parse_errors,omitempty"`
+	Diagnostics     []ParseDiagnostic `db:"-" json:"diagnostics,omitempty"` // 被跳过或部分解析的记录
+	// Parse progress, updated as the file is read
+	BytesRead       int64             `db:"-" json:"bytes_read"`
+	TotalBytes      int64             `db:"-" json:"total_bytes"`
+	ParsedRows      int               `db:"-" json:"parsed_rows"`
 	CreatedAt       time.Time         `db:"created_at" json:"created_at"`
 	UpdatedAt       time.Time         `db:"updated_at" json:"updated_at"`
 }
 
+// BalanceType distinguishes opening and closing statement balances
+type BalanceType string
+
+const (
+	BalanceTypeOpening BalanceType = "opening"
+	BalanceTypeClosing BalanceType = "closing"
+)
+
 // AccountHint represents account information extracted from a parsed file
 type AccountHint struct {
 	Source          ImportSource `json:"source"`
@@ -81,22 +148,193 @@ type AccountHint struct {
 	CardType        string       `json:"card_type"`
 	AccountType     AccountType  `json:"account_type"`
 	Balance         float64      `json:"balance"`
+	BalanceDate     *time.Time   `json:"balance_date,omitempty"` // 余额截止日期
+	BalanceType     BalanceType  `json:"balance_type,omitempty"` // 期初/期末余额
+	Currency        string       `json:"currency,omitempty"`
 	FoundInFile     string       `json:"found_in_file"`
 }
 
+// StatementBalanceCheck is the result of checking a statement's opening balance
+// plus its entries against its closing balance
+type StatementBalanceCheck struct {
+	AccountNumber   string     `json:"account_number"`
+	Currency        string     `json:"currency,omitempty"`
+	OpeningBalance  float64    `json:"opening_balance"`
+	ClosingBalance  float64    `json:"closing_balance"`
+	ComputedClosing float64    `json:"computed_closing"`
+	Difference      float64    `json:"difference"`
+	Reconciled      bool       `json:"reconciled"`
+	ClosingDate     *time.Time `json:"closing_date,omitempty"`
+	FoundInFile     string     `json:"found_in_file"`
+}
+
 // TransferMatch represents a matched transfer between two transactions
 type TransferMatch struct {
+	ID              uuid.UUID         `json:"id"`
+	JobID           uuid.UUID         `json:"job_id"`
+	FromFileID      uuid.UUID         `json:"from_file_id"`
+	FromTransaction ParsedTransaction `json:"from_transaction"`
+	ToFileID        uuid.UUID         `json:"to_file_id"`
+	ToTransaction   ParsedTransaction `json:"to_transaction"`
+	MatchType       MatchType         `json:"match_type"`
+	Confidence      float64           `json:"confidence"`
+	MatchFactors    []string          `json:"match_factors"`
+	UserConfirmed   bool              `json:"user_confirmed"`
+	CreatedAt       time.Time         `json:"created_at"`
+
+	// How each kind of evidence added up to the confidence. Matches whose
+	// confidence reached the user's learned threshold are flagged AutoConfirmed
+	// during analysis and confirmed when the batch is executed, unless the
+	// user rejects them first.
+	Scores         MatchScores      `json:"scores"`
+	ScoreBreakdown []ScoreComponent `json:"score_breakdown,omitempty"`
+	AutoConfirmed  bool             `json:"auto_confirmed"`
+
+	// Set for a side already in the ledger (its file ID is then uuid.Nil),
+	// and for imported rows once the match is confirmed
+	FromTransactionID *uuid.UUID `json:"from_transaction_id,omitempty"`
+	ToTransactionID   *uuid.UUID `json:"to_transaction_id,omitempty"`
+
+	// Fee is how much more left the source account than arrived; it is split off
+	// as an expense of its own when the match is confirmed
+	Fee              float64    `json:"fee,omitempty"`
+	FeeTransactionID *uuid.UUID `json:"fee_transaction_id,omitempty"`
+
+	// For a credit card repayment, the bill cycle it pays. When the card's side
+	// is not in the batch or the ledger (ToFileID is uuid.Nil and ToTransactionID
+	// is nil), ToTransaction is created on the credit account on confirmation.
+	Repayment *BillRepayment `json:"repayment,omitempty"`
+}
+
+// MatchScores is how strongly each kind of evidence supports a transfer match,
+// each from 0 (none) to 1 (conclusive)
+type MatchScores struct {
+	TailNumber float64 `db:"tail_number_score" json:"tail_number"`
+	Amount     float64 `db:"amount_score" json:"amount"`
+	Time       float64 `db:"time_score" json:"time"`
+	Name       float64 `db:"name_score" json:"name"`
+}
+
+// MatchWeights is the share of each kind of evidence in a match's confidence;
+// the weights add up to 1
+type MatchWeights struct {
+	TailNumber float64 `json:"tail_number"`
+	Amount     float64 `json:"amount"`
+	Time       float64 `json:"time"`
+	Name       float64 `json:"name"`
+}
+
+// Confidence weighs the scores into a confidence from 0 to 1
+func (w MatchWeights) Confidence(s MatchScores) float64 {
+	return w.TailNumber*s.TailNumber + w.Amount*s.Amount + w.Time*s.Time + w.Name*s.Name
+}
+
+// Explain lists what each kind of evidence contributed to the confidence
+func (w MatchWeights) Explain(s MatchScores) []ScoreComponent {
+	component := func(factor string, score, weight float64) ScoreComponent {
+		return ScoreComponent{Factor: factor, Score: score, Weight: weight, Contribution: score * weight}
+	}
+	return []ScoreComponent{
+		component("tail_number", s.TailNumber, w.TailNumber),
+		component("amount", s.Amount, w.Amount),
+		component("time", s.Time, w.Time),
+		component("name", s.Name, w.Name),
+	}
+}
+
+// ScoreComponent is one kind of evidence's part in a transfer match's confidence
+type ScoreComponent struct {
+	Factor       string  `json:"factor"` // tail_number, amount, time, name
+	Score        float64 `json:"score"`
+	Weight       float64 `json:"weight"`
+	Contribution float64 `json:"contribution"`
+}
+
+// TransferGroupMatch is a proposed transfer with one transaction on one side and
+// several on the other, such as a salary split over several accounts or several
+// transfers collected into one deposit
+type TransferGroupMatch struct {
+	ID            uuid.UUID          `json:"id"`
+	JobID         uuid.UUID          `json:"job_id"`
+	Outs          []TransferGroupLeg `json:"outs"`
+	Ins           []TransferGroupLeg `json:"ins"`
+	Confidence    float64            `json:"confidence"`
+	MatchFactors  []string           `json:"match_factors"`
+	UserConfirmed bool               `json:"user_confirmed"`
+	LinkGroupID   *uuid.UUID         `json:"link_group_id,omitempty"` // 确认后各条转账腿共用的分组ID
+	CreatedAt     time.Time          `json:"created_at"`
+}
+
+// TransferGroupLeg is one transaction of a transfer group
+type TransferGroupLeg struct {
+	FileID        uuid.UUID         `json:"file_id"` // uuid.Nil for a ledger transaction
+	LineNumber    int               `json:"line_number"`
+	Transaction   ParsedTransaction `json:"transaction"`
+	TransactionID *uuid.UUID        `json:"transaction_id,omitempty"` // 账本中的交易，或确认后导入的交易
+}
+
+// Clone copies the group so that its legs are not shared
+func (g TransferGroupMatch) Clone() TransferGroupMatch {
+	g.Outs = append([]TransferGroupLeg(nil), g.Outs...)
+	g.Ins = append([]TransferGroupLeg(nil), g.Ins...)
+	return g
+}
+
+// TransferCandidates lists the possible counterparts of one transfer row, best first,
+// so that the user can re-pair transfers the matcher got wrong
+type TransferCandidates struct {
+	FileID     uuid.UUID           `json:"file_id"`
+	LineNumber int                 `json:"line_number"`
+	Direction  string              `json:"direction"` // out / in
+	Candidates []TransferCandidate `json:"candidates"`
+}
+
+// TransferCandidate is a possible counterpart of a transfer row
+type TransferCandidate struct {
+	FileID          uuid.UUID        `json:"file_id"`
+	LineNumber      int              `json:"line_number"`
+	TransactionID   *uuid.UUID       `json:"transaction_id,omitempty"` // 账本中已有的交易
+	TransactionDate time.Time        `json:"transaction_date"`
+	Amount          float64          `json:"amount"`
+	Note            string           `json:"note"`
+	Confidence      float64          `json:"confidence"`
+	MatchFactors    []string         `json:"match_factors"`
+	ScoreBreakdown  []ScoreComponent `json:"score_breakdown,omitempty"`
+	Selected        bool             `json:"selected"` // 当前配对
+}
+
+// DuplicateDecision is the user's choice for a cross-source duplicate pair
+type DuplicateDecision string
+
+const (
+	DuplicateDecisionMerge    DuplicateDecision = "merge"     // 合并为一条：平台详情 + 银行账户
+	DuplicateDecisionKeepBoth DuplicateDecision = "keep_both" // 确认不是同一笔，两条都保留
+)
+
+// CrossSourceDuplicate is one purchase found in both a payment app bill
+// (Alipay/WeChat/JD) and the statement of the bank card that funded it
+type CrossSourceDuplicate struct {
 	ID              uuid.UUID           `json:"id"`
-	JobID           uuid.UUID           `json:"job_id"`
-	FromFileID      uuid.UUID           `json:"from_file_id"`
-	FromTransaction ParsedTransaction   `json:"from_transaction"`
-	ToFileID        uuid.UUID           `json:"to_file_id"`
-	ToTransaction   ParsedTransaction   `json:"to_transaction"`
-	MatchType       MatchType           `json:"match_type"`
+	AppTransaction  ParsedTransaction   `json:"app_transaction"`
+	BankTransaction ParsedTransaction   `json:"bank_transaction"`
 	Confidence      float64             `json:"confidence"`
 	MatchFactors    []string            `json:"match_factors"`
-	UserConfirmed   bool                `json:"user_confirmed"`
-	CreatedAt       time.Time           `json:"created_at"`
+	Merged          ParsedTransaction   `json:"merged"` // 建议保留的记录
+	NoteMerge       NoteMergeSuggestion `json:"note_merge"`
+	Decision        DuplicateDecision   `json:"decision,omitempty"`
+}
+
+// ImportDuplicateDecision is a recorded duplicate decision, keyed by the
+// external IDs of both rows so that later imports of either file respect it
+type ImportDuplicateDecision struct {
+	ID             uuid.UUID         `db:"id" json:"id"`
+	UserID         uuid.UUID         `db:"user_id" json:"user_id"`
+	AppSource      ImportSource      `db:"app_source" json:"app_source"`
+	AppExternalID  string            `db:"app_external_id" json:"app_external_id"`
+	BankSource     ImportSource      `db:"bank_source" json:"bank_source"`
+	BankExternalID string            `db:"bank_external_id" json:"bank_external_id"`
+	Decision       DuplicateDecision `db:"decision" json:"decision"`
+	CreatedAt      time.Time         `db:"created_at" json:"created_at"`
 }
 
 // NoteMergeSuggestion represents a suggestion for merging notes
@@ -0,0 +1,109 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RuleMatchMode controls how the text conditions of a rule are compared
+type RuleMatchMode string
+
+const (
+	RuleMatchContains RuleMatchMode = "contains" // 包含（不区分大小写）
+	RuleMatchExact    RuleMatchMode = "exact"    // 完全相同（不区分大小写）
+	RuleMatchRegex    RuleMatchMode = "regex"    // 正则表达式
+)
+
+// CategoryRule is a user-defined rule that categorises transactions on import
+// and, on request, on manual entry. Rules are evaluated in ascending priority.
+type CategoryRule struct {
+	ID         uuid.UUID      `db:"id" json:"id"`
+	UserID     uuid.UUID      `db:"user_id" json:"user_id"`
+	Name       string         `db:"name" json:"name"`
+	Priority   int            `db:"priority" json:"priority"`
+	Enabled    bool           `db:"enabled" json:"enabled"`
+	MatchMode  RuleMatchMode  `db:"match_mode" json:"match_mode"`
+	Conditions RuleConditions `db:"conditions" json:"conditions"`
+	Actions    RuleActions    `db:"actions" json:"actions"`
+	CreatedAt  time.Time      `db:"created_at" json:"created_at"`
+	UpdatedAt  time.Time      `db:"updated_at" json:"updated_at"`
+	IsDeleted  bool           `db:"is_deleted" json:"is_deleted"`
+}
+
+// RuleConditions are combined with AND; empty conditions are ignored
+type RuleConditions struct {
+	Counterparty    string          `json:"counterparty,omitempty"`
+	Note            string          `json:"note,omitempty"`
+	MinAmount       *float64        `json:"min_amount,omitempty"`
+	MaxAmount       *float64        `json:"max_amount,omitempty"`
+	TransactionType TransactionType `json:"transaction_type,omitempty"`
+	Sources         []ImportSource  `json:"sources,omitempty"`
+	AccountID       *uuid.UUID      `json:"account_id,omitempty"`
+	AccountName     string          `json:"account_name,omitempty"` // 账单中的支付方式/账户名
+	RawCategory     string          `json:"raw_category,omitempty"` // 支付宝“交易分类”等原始分类
+}
+
+// RuleActions are applied when all conditions match
+type RuleActions struct {
+	CategoryID *uuid.UUID `json:"category_id,omitempty"`
+	SetNote    string     `json:"set_note,omitempty"` // 支持 {note} 与 {counterparty} 占位符
+	AccountID  *uuid.UUID `json:"account_id,omitempty"`
+}
+
+// IsEmpty reports whether no condition is set; such a rule would match everything
+func (c RuleConditions) IsEmpty() bool {
+	return c.Counterparty == "" && c.Note == "" && c.MinAmount == nil && c.MaxAmount == nil &&
+		c.TransactionType == "" && len(c.Sources) == 0 && c.AccountID == nil &&
+		c.AccountName == "" && c.RawCategory == ""
+}
+
+// IsEmpty reports whether the rule has nothing to do
+func (a RuleActions) IsEmpty() bool {
+	return a.CategoryID == nil && a.SetNote == "" && a.AccountID == nil
+}
+
+func (c *RuleConditions) Scan(value interface{}) error {
+	return scanJSON(value, c)
+}
+
+func (c RuleConditions) Value() (driver.Value, error) {
+	return json.Marshal(c)
+}
+
+func (a *RuleActions) Scan(value interface{}) error {
+	return scanJSON(value, a)
+}
+
+func (a RuleActions) Value() (driver.Value, error) {
+	return json.Marshal(a)
+}
+
+func (m *RuleMatchMode) Scan(value interface{}) error {
+	str, ok := value.(string)
+	if !ok {
+		return fmt.Errorf("invalid rule match mode: %v", value)
+	}
+	*m = RuleMatchMode(str)
+	return nil
+}
+
+func (m RuleMatchMode) Value() (driver.Value, error) {
+	return string(m), nil
+}
+
+// scanJSON decodes a JSONB column
+func scanJSON(value interface{}, dest interface{}) error {
+	switch v := value.(type) {
+	case []byte:
+		return json.Unmarshal(v, dest)
+	case string:
+		return json.Unmarshal([]byte(v), dest)
+	case nil:
+		return nil
+	}
+	return fmt.Errorf("invalid json value: %v", value)
+}
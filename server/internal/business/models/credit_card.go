@@ -0,0 +1,56 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CreditCardBillStatus tells how much of a bill has been repaid
+type CreditCardBillStatus string
+
+const (
+	CreditCardBillUnpaid  CreditCardBillStatus = "unpaid"
+	CreditCardBillPartial CreditCardBillStatus = "partial" // 已还但不足最低还款额
+	CreditCardBillMinimum CreditCardBillStatus = "minimum" // 已还最低还款额
+	CreditCardBillPaid    CreditCardBillStatus = "paid"    // 已全额还款
+)
+
+// CreditCardBill is a credit card statement: the amount owed for a billing cycle.
+// The cycle ends on the statement date and is repaid until the due date.
+type CreditCardBill struct {
+	ID               uuid.UUID            `db:"id" json:"id"`
+	UserID           uuid.UUID            `db:"user_id" json:"user_id"`
+	AccountID        uuid.UUID            `db:"account_id" json:"account_id"`
+	StatementDate    time.Time            `db:"statement_date" json:"statement_date"`       // 账单日
+	DueDate          time.Time            `db:"due_date" json:"due_date"`                   // 到期还款日
+	StatementBalance float64              `db:"statement_balance" json:"statement_balance"` // 本期应还
+	MinimumPayment   float64              `db:"minimum_payment" json:"minimum_payment"`     // 最低还款额
+	PaidAmount       float64              `db:"paid_amount" json:"paid_amount"`             // 已还金额
+	Status           CreditCardBillStatus `db:"-" json:"status"`
+	CreatedAt        time.Time            `db:"created_at" json:"created_at"`
+	UpdatedAt        time.Time            `db:"updated_at" json:"updated_at"`
+}
+
+// RepaymentKind classifies a repayment against the bill it pays
+type RepaymentKind string
+
+const (
+	RepaymentFull    RepaymentKind = "full"    // 还清本期应还
+	RepaymentMinimum RepaymentKind = "minimum" // 达到最低还款额
+	RepaymentPartial RepaymentKind = "partial" // 不足最低还款额
+)
+
+// BillRepayment is a repayment transaction matched to the bill cycle it pays
+type BillRepayment struct {
+	BillID           uuid.UUID     `json:"bill_id"`
+	AccountID        uuid.UUID     `json:"account_id"`
+	StatementDate    time.Time     `json:"statement_date"`
+	DueDate          time.Time     `json:"due_date"`
+	StatementBalance float64       `json:"statement_balance"`
+	MinimumPayment   float64       `json:"minimum_payment"`
+	PaidBefore       float64       `json:"paid_before"` // 本笔之前已还金额
+	Amount           float64       `json:"amount"`
+	Kind             RepaymentKind `json:"kind"`
+	Overdue          bool          `json:"overdue"` // 晚于到期还款日
+}
@@ -1,6 +1,9 @@
 package models
 
 import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
@@ -15,33 +18,48 @@ const (
 	ImportSourceJD       ImportSource = "jd"
 	ImportSourceBank     ImportSource = "bank"
 	ImportSourceGeneric  ImportSource = "generic"
+	ImportSourceOFX      ImportSource = "ofx" // OFX 1.x (SGML) / 2.x (XML)，含 QFX
+	ImportSourceQIF      ImportSource = "qif"
+	ImportSourceCamt053  ImportSource = "camt053" // ISO 20022 camt.053 XML
+	ImportSourceMT940    ImportSource = "mt940"   // SWIFT MT940
 )
 
 // ImportStatus represents the status of an import job
 type ImportStatus string
 
 const (
-	ImportStatusPending   ImportStatus = "pending"
-	ImportStatusParsing   ImportStatus = "parsing"
-	ImportStatusPreview   ImportStatus = "preview"
-	ImportStatusImporting ImportStatus = "importing"
-	ImportStatusCompleted ImportStatus = "completed"
-	ImportStatusFailed    ImportStatus = "failed"
+	ImportStatusPending    ImportStatus = "pending"
+	ImportStatusParsing    ImportStatus = "parsing"
+	ImportStatusPreview    ImportStatus = "preview"
+	ImportStatusImporting  ImportStatus = "importing"
+	ImportStatusCompleted  ImportStatus = "completed"
+	ImportStatusFailed     ImportStatus = "failed"
+	ImportStatusRolledBack ImportStatus = "rolled_back" // 已撤销，交易已删除
+)
+
+// ImportJobKind tells whether an import job came from a single file or a batch
+type ImportJobKind string
+
+const (
+	ImportJobKindFile  ImportJobKind = "file"
+	ImportJobKindBatch ImportJobKind = "batch"
 )
 
 // ImportJob represents a file import job
 type ImportJob struct {
-	ID          uuid.UUID       `db:"id" json:"id"`
-	UserID      uuid.UUID       `db:"user_id" json:"user_id"`
-	Source      ImportSource    `db:"source" json:"source"`
-	FileName    string          `db:"file_name" json:"file_name"`
-	FileSize    int64           `db:"file_size" json:"file_size"`
-	Status      ImportStatus    `db:"status" json:"status"`
-	TotalRows   int             `db:"total_rows" json:"total_rows"`
-	ImportedRows int            `db:"imported_rows" json:"imported_rows"`
-	ErrorMsg    string          `db:"error_msg" json:"error_msg,omitempty"`
-	CreatedAt   time.Time       `db:"created_at" json:"created_at"`
-	UpdatedAt   time.Time       `db:"updated_at" json:"updated_at"`
+	ID           uuid.UUID     `db:"id" json:"id"`
+	UserID       uuid.UUID     `db:"user_id" json:"user_id"`
+	Kind         ImportJobKind `db:"kind" json:"kind"`
+	Source       ImportSource  `db:"source" json:"source"`
+	FileName     string        `db:"file_name" json:"file_name"`
+	FileSize     int64         `db:"file_size" json:"file_size"`
+	Status       ImportStatus  `db:"status" json:"status"`
+	TotalRows    int           `db:"total_rows" json:"total_rows"`
+	ImportedRows int           `db:"imported_rows" json:"imported_rows"`
+	ErrorMsg     string        `db:"error_msg" json:"error_msg,omitempty"`
+	CreatedAt    time.Time     `db:"created_at" json:"created_at"`
+	UpdatedAt    time.Time     `db:"updated_at" json:"updated_at"`
+	RolledBackAt *time.Time    `db:"rolled_back_at" json:"rolled_back_at,omitempty"`
 }
 
 // ParsedTransaction represents a transaction parsed from an imported file
@@ -51,6 +69,7 @@ type ParsedTransaction struct {
 
 	// Parsed fields
 	TransactionDate time.Time       `json:"transaction_date"`
+	ValueDate       *time.Time      `json:"value_date,omitempty"` // 起息日（与记账日不同时）
 	Type            TransactionType `json:"type"`
 	Amount          float64         `json:"amount"`
 	Currency        string          `json:"currency"`
@@ -61,19 +80,42 @@ type ParsedTransaction struct {
 	AccountNumber   string `json:"account_number,omitempty"`
 	Counterparty    string `json:"counterparty,omitempty"`
 
+	// Payee resolved from Counterparty; PayeeID is nil for payees created on import
+	PayeeID         *uuid.UUID `json:"payee_id,omitempty"`
+	PayeeName       string     `json:"payee_name,omitempty"`
+
 	// Category matching hints
 	CategoryHint    string `json:"category_hint,omitempty"`
 
+	// Stable identifier from the source file (e.g. OFX FITID), used as duplicate key
+	ExternalID      string `json:"external_id,omitempty"`
+
+	// Refund detection: OrderID is shared by a purchase and its refunds.
+	// A refund is linked either to another row of the same file or to a stored purchase.
+	IsRefund              bool       `json:"is_refund,omitempty"`
+	OrderID               string     `json:"order_id,omitempty"`
+	RefundOfLineNumber    int        `json:"refund_of_line_number,omitempty"`
+	RefundOfTransactionID *uuid.UUID `json:"refund_of_transaction_id,omitempty"`
+
 	// Metadata
 	Source          ImportSource `json:"source"`
 	LineNumber      int          `json:"line_number"`
 	IsDuplicate     bool         `json:"is_duplicate"`
 	CanBeImported   bool         `json:"can_be_imported"`
 	ImportWarning   string       `json:"import_warning,omitempty"`
+	InCoveredPeriod bool         `json:"in_covered_period,omitempty"` // 日期落在该账户已导入过的期间内
 
 	// Selected account/category for import (set by user in preview)
 	SelectedAccountID  *uuid.UUID `json:"selected_account_id,omitempty"`
 	SelectedCategoryID *uuid.UUID `json:"selected_category_id,omitempty"`
+	// Account filled in by an alias or rule; a different selection is the user's own
+	SuggestedAccountID *uuid.UUID `json:"suggested_account_id,omitempty"`
+
+	// Category rules that set the category, note or account of this row
+	AppliedRuleIDs []uuid.UUID `json:"applied_rule_ids,omitempty"`
+
+	// Top categories learned from the user's history, best first
+	CategorySuggestions []CategorySuggestion `json:"category_suggestions,omitempty"`
 
 	// === 批量导入新增字段 ===
 
@@ -106,16 +148,84 @@ type ParsedTransaction struct {
 	HasNoteMerge        bool    `json:"has_note_merge,omitempty"`          // 是否有备注合并
 }
 
-// ImportPreview represents the preview data before actual import
+// CategorySuggestion is a learned category with its posterior probability
+type CategorySuggestion struct {
+	CategoryID uuid.UUID `json:"category_id"`
+	Confidence float64   `json:"confidence"`
+}
+
+// ImportPreviewStatus tells whether a stored preview was imported already
+type ImportPreviewStatus string
+
+const (
+	ImportPreviewPending  ImportPreviewStatus = "pending"
+	ImportPreviewExecuted ImportPreviewStatus = "executed"
+)
+
+// ImportPreview represents the preview data before actual import. Previews are
+// stored server-side under their job ID, edited row by row and imported by ID.
 type ImportPreview struct {
-	JobID           uuid.UUID           `json:"job_id"`
-	Source          ImportSource        `json:"source"`
-	TotalRows       int                 `json:"total_rows"`
-	ValidRows       int                 `json:"valid_rows"`
-	DuplicateRows   int                 `json:"duplicate_rows"`
-	Transactions    []ParsedTransaction `json:"transactions"`
-	AccountSuggestions map[string][]Account `json:"account_suggestions,omitempty"` // key: account name hint
-	Categories      []Category          `json:"categories,omitempty"`
+	JobID           uuid.UUID           `db:"id" json:"job_id"`
+	UserID          uuid.UUID           `db:"user_id" json:"-"`
+	Source          ImportSource        `db:"source" json:"source"`
+	FileName        string              `db:"file_name" json:"file_name,omitempty"`
+	Status          ImportPreviewStatus `db:"status" json:"status,omitempty"`
+	TotalRows       int                 `db:"-" json:"total_rows"`
+	ValidRows       int                 `db:"-" json:"valid_rows"`
+	DuplicateRows   int                 `db:"-" json:"duplicate_rows"`
+	Transactions    ParsedTransactions  `db:"transactions" json:"transactions"`
+	AccountSuggestions map[string][]Account `db:"-" json:"account_suggestions,omitempty"` // key: account name hint
+	AccountHints    AccountHints        `db:"account_hints" json:"account_hints,omitempty"` // statement-level hints (e.g. ledger balance)
+	Diagnostics     ParseDiagnostics    `db:"diagnostics" json:"diagnostics,omitempty"` // 被跳过或部分解析的记录
+	RejectedRows    int                 `db:"-" json:"rejected_rows"`
+	Categories      []Category          `db:"-" json:"categories,omitempty"`
+	Coverage        *PreviewCoverage    `db:"-" json:"coverage,omitempty"`
+	Version         int                 `db:"version" json:"version,omitempty"` // 每次修改加一，防止并发修改互相覆盖
+	CreatedAt       time.Time           `db:"created_at" json:"created_at,omitempty"`
+	UpdatedAt       time.Time           `db:"updated_at" json:"updated_at,omitempty"`
+	ExpiresAt       time.Time           `db:"expires_at" json:"expires_at,omitempty"`
+}
+
+// Recount refreshes the row counts after the rows changed
+func (p *ImportPreview) Recount() {
+	p.TotalRows, p.ValidRows, p.DuplicateRows, p.RejectedRows = len(p.Transactions), 0, 0, 0
+	for _, tx := range p.Transactions {
+		if tx.CanBeImported {
+			p.ValidRows++
+		}
+		if tx.IsDuplicate {
+			p.DuplicateRows++
+		}
+	}
+	p.RejectedRows = p.Diagnostics.Rejected()
+}
+
+// ParsedTransactions are the rows of a stored preview, kept as JSONB
+type ParsedTransactions []ParsedTransaction
+
+func (p *ParsedTransactions) Scan(value interface{}) error {
+	return scanJSON(value, p)
+}
+
+func (p ParsedTransactions) Value() (driver.Value, error) {
+	if p == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal(p)
+}
+
+// AccountHints are the statement-level hints of a stored preview, kept as JSONB
+type AccountHints []AccountHint
+
+func (h *AccountHints) Scan(value interface{}) error {
+	return scanJSON(value, h)
+}
+
+func (h AccountHints) Value() (driver.Value, error) {
+	if h == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal(h)
 }
 
 // ImportResult represents the result of an import operation
@@ -127,6 +237,7 @@ type ImportResult struct {
 	FailedRows      int             `json:"failed_rows"`
 	ImportedIDs     []uuid.UUID     `json:"imported_ids,omitempty"`
 	Errors          []ImportError    `json:"errors,omitempty"`
+	Reconciliations []ReconciliationResult `json:"reconciliations,omitempty"` // 导入后与对账单余额的核对结果
 }
 
 // ImportError represents an error during import
@@ -134,3 +245,129 @@ type ImportError struct {
 	LineNumber int    `json:"line_number"`
 	Error      string `json:"error"`
 }
+
+// ParseDiagnostic reports a record of a bill that was skipped, or that was kept
+// with a field left empty because it could not be read. LineNumber is the line
+// of the file the record starts on, not a row number of the preview.
+type ParseDiagnostic struct {
+	LineNumber int      `json:"line_number"`
+	Record     []string `json:"record"`          // 原始记录的各列
+	Field      string   `json:"field,omitempty"` // 出错的列，空表示整条记录
+	Value      string   `json:"value,omitempty"`
+	Reason     string   `json:"reason"`
+	Skipped    bool     `json:"skipped"` // 记录未导入；否则只是该列被忽略
+}
+
+func (d ParseDiagnostic) String() string {
+	if d.Field == "" {
+		return fmt.Sprintf("line %d: %s", d.LineNumber, d.Reason)
+	}
+	return fmt.Sprintf("line %d: %s %q: %s", d.LineNumber, d.Field, d.Value, d.Reason)
+}
+
+// ParseDiagnostics are the diagnostics of a stored preview, kept as JSONB
+type ParseDiagnostics []ParseDiagnostic
+
+func (d *ParseDiagnostics) Scan(value interface{}) error {
+	return scanJSON(value, d)
+}
+
+// Rejected counts the records that were skipped; a record may have several diagnostics
+func (d ParseDiagnostics) Rejected() int {
+	lines := make(map[int]bool)
+	for _, diag := range d {
+		if diag.Skipped {
+			lines[diag.LineNumber] = true
+		}
+	}
+	return len(lines)
+}
+
+func (d ParseDiagnostics) Value() (driver.Value, error) {
+	if d == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal(d)
+}
+
+// ImportRollback lists what undoing an import job changes: the job's transactions
+// (and the fees split off their transfers) are deleted, transfer links involving
+// them are removed, ledger transactions they were linked with become income or
+// expense again, and account balances lose the effect of the deleted rows.
+type ImportRollback struct {
+	JobID                  uuid.UUID              `json:"job_id"`
+	DeletedTransactionIDs  []uuid.UUID            `json:"deleted_transaction_ids"`
+	RemovedTransferLinkIDs []uuid.UUID            `json:"removed_transfer_link_ids"`
+	RestoredTransactions   []RestoredTransaction  `json:"restored_transactions"`
+	BalanceChanges         []AccountBalanceChange `json:"balance_changes"`
+}
+
+// RestoredTransaction is a ledger transaction that stops being half of a transfer
+type RestoredTransaction struct {
+	TransactionID uuid.UUID       `json:"transaction_id"`
+	Type          TransactionType `json:"type"`
+	Amount        float64         `json:"amount"` // 转出方并回拆出的手续费
+}
+
+// AccountBalanceChange is how much an account's balance moves
+type AccountBalanceChange struct {
+	AccountID uuid.UUID `json:"account_id"`
+	Change    float64   `json:"change"`
+}
+
+// ImportCoveragePeriod is the date range one import covered for one account and
+// source. Dates are days; EndDate is included.
+type ImportCoveragePeriod struct {
+	ID          uuid.UUID    `db:"id" json:"id"`
+	UserID      uuid.UUID    `db:"user_id" json:"-"`
+	AccountID   uuid.UUID    `db:"account_id" json:"account_id"`
+	AccountName string       `db:"account_name" json:"account_name,omitempty"`
+	Source      ImportSource `db:"source" json:"source"`
+	ImportJobID uuid.UUID    `db:"import_job_id" json:"import_job_id"`
+	StartDate   time.Time    `db:"start_date" json:"start_date"`
+	EndDate     time.Time    `db:"end_date" json:"end_date"`
+	RowCount    int          `db:"row_count" json:"row_count"`
+	CreatedAt   time.Time    `db:"created_at" json:"created_at"`
+}
+
+// CoveredRange is a run of days covered by one or more imports. More than one
+// import job means the imports overlapped.
+type CoveredRange struct {
+	Start        time.Time   `json:"start"`
+	End          time.Time   `json:"end"`
+	ImportJobIDs []uuid.UUID `json:"import_job_ids"`
+}
+
+// MissingRange is a run of days no import covered
+type MissingRange struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	Days  int       `json:"days"`
+}
+
+// AccountCoverage is the import coverage of one account from one source
+type AccountCoverage struct {
+	AccountID   uuid.UUID      `json:"account_id"`
+	AccountName string         `json:"account_name,omitempty"`
+	Source      ImportSource   `json:"source"`
+	Covered     []CoveredRange `json:"covered"`
+	Missing     []MissingRange `json:"missing"`
+	LastCovered time.Time      `json:"last_covered"` // 最近一次覆盖到的日期
+}
+
+// ImportCoverageReport lists the covered and missing periods of every account
+// and source between From and To
+type ImportCoverageReport struct {
+	From     time.Time         `json:"from"`
+	To       time.Time         `json:"to"`
+	Accounts []AccountCoverage `json:"accounts"`
+}
+
+// PreviewCoverage tells how a preview overlaps what was imported before: rows
+// dated inside an already covered period are either duplicates or new rows
+// the earlier import did not have
+type PreviewCoverage struct {
+	Overlaps    []CoveredRange `json:"overlaps"`     // 与本文件日期重叠的已覆盖期间
+	CoveredRows int            `json:"covered_rows"` // 落在已覆盖期间内的行
+	NewRows     int            `json:"new_rows"`     // 其中不是重复的行
+}
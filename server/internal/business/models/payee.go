@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Payee is a real merchant or person that counterparty strings resolve to
+type Payee struct {
+	ID        uuid.UUID    `db:"id" json:"id"`
+	UserID    uuid.UUID    `db:"user_id" json:"user_id"`
+	Name      string       `db:"name" json:"name"`
+	Aliases   []PayeeAlias `db:"-" json:"aliases"`
+	CreatedAt time.Time    `db:"created_at" json:"created_at"`
+	UpdatedAt time.Time    `db:"updated_at" json:"updated_at"`
+	IsDeleted bool         `db:"is_deleted" json:"is_deleted"`
+}
+
+// PayeeAlias is a user-defined counterparty spelling of a payee
+type PayeeAlias struct {
+	ID        uuid.UUID `db:"id" json:"id"`
+	UserID    uuid.UUID `db:"user_id" json:"user_id"`
+	PayeeID   uuid.UUID `db:"payee_id" json:"payee_id"`
+	Alias     string    `db:"alias" json:"alias"`
+	AliasKey  string    `db:"alias_key" json:"-"` // 规整后的匹配键
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// BalanceAssertionStatus tells whether the ledger still matches a reconciled statement balance
+type BalanceAssertionStatus string
+
+const (
+	BalanceAssertionReconciled BalanceAssertionStatus = "reconciled"
+	BalanceAssertionBroken     BalanceAssertionStatus = "broken" // 之后的修改改变了该日的账面余额
+)
+
+// BalanceAssertion records that an account's ledger balance matched a statement at the end of a day
+type BalanceAssertion struct {
+	ID            uuid.UUID              `db:"id" json:"id"`
+	UserID        uuid.UUID              `db:"user_id" json:"user_id"`
+	AccountID     uuid.UUID              `db:"account_id" json:"account_id"`
+	BalanceDate   time.Time              `db:"balance_date" json:"balance_date"`
+	Balance       float64                `db:"balance" json:"balance"`               // 对账单余额
+	LedgerBalance float64                `db:"ledger_balance" json:"ledger_balance"` // 最近一次检查时的账面余额
+	Status        BalanceAssertionStatus `db:"status" json:"status"`
+	CreatedAt     time.Time              `db:"created_at" json:"created_at"`
+	CheckedAt     time.Time              `db:"checked_at" json:"checked_at"`
+}
+
+// CulpritKind is the kind of ledger error that may explain a balance difference
+type CulpritKind string
+
+const (
+	CulpritMissing   CulpritKind = "missing"    // 对账单有、账本没有
+	CulpritDuplicate CulpritKind = "duplicate"  // 账本中重复记录
+	CulpritExtra     CulpritKind = "extra"      // 账本有、对账单没有
+	CulpritMisSigned CulpritKind = "mis_signed" // 收支方向记反
+)
+
+// ReconciliationCulprit is a row that may explain a balance difference.
+// Impact is how much fixing it would change the ledger balance.
+type ReconciliationCulprit struct {
+	Kind               CulpritKind        `json:"kind"`
+	Transaction        *Transaction       `json:"transaction,omitempty"`
+	StatementRow       *ParsedTransaction `json:"statement_row,omitempty"`
+	Impact             float64            `json:"impact"`
+	ExplainsDifference bool               `json:"explains_difference"`
+}
+
+// ReconciliationResult compares a statement's closing balance with the ledger
+type ReconciliationResult struct {
+	AccountID        uuid.UUID               `json:"account_id"`
+	BalanceDate      time.Time               `json:"balance_date"`
+	StatementBalance float64                 `json:"statement_balance"`
+	LedgerBalance    float64                 `json:"ledger_balance"`
+	Difference       float64                 `json:"difference"` // 对账单余额 - 账面余额
+	Reconciled       bool                    `json:"reconciled"`
+	Culprits         []ReconciliationCulprit `json:"culprits"`
+	Assertion        *BalanceAssertion       `json:"assertion,omitempty"`
+}
@@ -21,11 +21,16 @@ type Transaction struct {
 	UserID          uuid.UUID         `db:"user_id" json:"user_id"`
 	AccountID       uuid.UUID         `db:"account_id" json:"account_id"`
 	CategoryID      *uuid.UUID        `db:"category_id" json:"category_id"`
+	PayeeID         *uuid.UUID        `db:"payee_id" json:"payee_id,omitempty"` // 规整后的商户
 	Type            TransactionType   `db:"type" json:"type"`
 	Amount          float64           `db:"amount" json:"amount"`
 	Currency        string            `db:"currency" json:"currency"`
 	Note            string            `db:"note" json:"note"`
 	TransactionDate time.Time         `db:"transaction_date" json:"transaction_date"`
+	ImportSource    string            `db:"import_source" json:"import_source,omitempty"` // 导入来源
+	ExternalID      string            `db:"external_id" json:"external_id,omitempty"`     // 来源中的唯一ID（交易号/流水号）
+	OrderID         string            `db:"order_id" json:"order_id,omitempty"`           // 商户订单号，退款与原订单相同
+	ImportJobID     *uuid.UUID        `db:"import_job_id" json:"import_job_id,omitempty"` // 导入任务，用于整批回滚
 	CreatedAt       time.Time         `db:"created_at" json:"created_at"`
 	UpdatedAt       time.Time         `db:"updated_at" json:"updated_at"`
 	LastModifiedAt  time.Time         `db:"last_modified_at" json:"last_modified_at"`
@@ -46,9 +51,44 @@ func (t TransactionType) Value() (driver.Value, error) {
 	return string(t), nil
 }
 
+// TransferLink pairs the outgoing and incoming halves of a transfer between two accounts
 type TransferLink struct {
-	ID                uuid.UUID `db:"id" json:"id"`
-	FromTransactionID uuid.UUID `db:"from_transaction_id" json:"from_transaction_id"`
-	ToTransactionID   uuid.UUID `db:"to_transaction_id" json:"to_transaction_id"`
-	CreatedAt         time.Time `db:"created_at" json:"created_at"`
+	ID                uuid.UUID  `db:"id" json:"id"`
+	UserID            uuid.UUID  `db:"user_id" json:"user_id"`
+	FromTransactionID uuid.UUID  `db:"from_transaction_id" json:"from_transaction_id"`
+	ToTransactionID   uuid.UUID  `db:"to_transaction_id" json:"to_transaction_id"`
+	FeeTransactionID  *uuid.UUID `db:"fee_transaction_id" json:"fee_transaction_id,omitempty"` // 拆出的手续费支出
+	GroupID           *uuid.UUID `db:"group_id" json:"group_id,omitempty"`                     // 一对多/多对一转账的各条腿共用
+	CreatedAt         time.Time  `db:"created_at" json:"created_at"`
+}
+
+// TransferFee is a fee observed on a confirmed transfer between two banks
+type TransferFee struct {
+	ID        uuid.UUID `db:"id" json:"id"`
+	UserID    uuid.UUID `db:"user_id" json:"user_id"`
+	FromAccountID uuid.UUID `db:"from_account_id" json:"from_account_id"`
+	ToAccountID   uuid.UUID `db:"to_account_id" json:"to_account_id"`
+	Amount        float64   `db:"amount" json:"amount"` // 到账金额
+	Fee           float64   `db:"fee" json:"fee"`
+	CreatedAt     time.Time `db:"created_at" json:"created_at"`
+}
+
+// TransferMatchFeedback is a user's verdict on a proposed transfer match, with
+// the evidence the match was scored on
+type TransferMatchFeedback struct {
+	ID     uuid.UUID `db:"id" json:"id"`
+	UserID uuid.UUID `db:"user_id" json:"user_id"`
+	MatchScores
+	Confidence float64   `db:"confidence" json:"confidence"`
+	Accepted   bool      `db:"accepted" json:"accepted"`
+	CreatedAt  time.Time `db:"created_at" json:"created_at"`
+}
+
+// RefundLink links a refund (income) to the purchase (expense) it returns money for
+type RefundLink struct {
+	ID                    uuid.UUID `db:"id" json:"id"`
+	UserID                uuid.UUID `db:"user_id" json:"user_id"`
+	PurchaseTransactionID uuid.UUID `db:"purchase_transaction_id" json:"purchase_transaction_id"`
+	RefundTransactionID   uuid.UUID `db:"refund_transaction_id" json:"refund_transaction_id"`
+	CreatedAt             time.Time `db:"created_at" json:"created_at"`
 }
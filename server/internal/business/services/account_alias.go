@@ -0,0 +1,122 @@
+package services
+
+import (
+	"strings"
+
+	"account/internal/business/models"
+	"account/internal/data/repository"
+	"github.com/google/uuid"
+)
+
+// AccountAliasResolver 按别名把账单中的账户名称解析到已有账户。
+// 账户自身的名称也算别名，但用户添加或从预览中学到的别名优先
+type AccountAliasResolver struct {
+	byKey map[string]*models.Account
+}
+
+// NewAccountAliasResolver 用账户及其别名创建解析器，已删除账户的别名会被忽略
+func NewAccountAliasResolver(accounts []models.Account, aliases []models.AccountAlias) *AccountAliasResolver {
+	r := &AccountAliasResolver{byKey: make(map[string]*models.Account)}
+	byID := make(map[uuid.UUID]*models.Account, len(accounts))
+	for i := range accounts {
+		account := &accounts[i]
+		if account.IsDeleted {
+			continue
+		}
+		byID[account.ID] = account
+		r.Add(account)
+	}
+	for _, alias := range aliases {
+		if account, ok := byID[alias.AccountID]; ok {
+			r.addAlias(alias.Alias, account)
+		}
+	}
+	return r
+}
+
+// Add 把新建的账户按其名称加入解析器，不覆盖已有别名
+func (r *AccountAliasResolver) Add(account *models.Account) {
+	for _, key := range []string{
+		NormalizeAccountAliasKey(AccountAliasLabel(account.Name, account.TailNumber)),
+		NormalizeAccountAliasKey(account.Name),
+	} {
+		if _, ok := r.byKey[key]; !ok && key != "" {
+			r.byKey[key] = account
+		}
+	}
+}
+
+// addAlias 把一个别名指向账户
+func (r *AccountAliasResolver) addAlias(alias string, account *models.Account) {
+	if key := NormalizeAccountAliasKey(alias); key != "" {
+		r.byKey[key] = account
+	}
+}
+
+// Resolve 返回账单中名为 name、尾号为 tail 的账户；先按带尾号的名称找，
+// 再按名称本身找，但尾号与账户记录的尾号不同时不算
+func (r *AccountAliasResolver) Resolve(name, tail string) *models.Account {
+	if strings.TrimSpace(name) == "" {
+		return nil
+	}
+	if account, ok := r.byKey[NormalizeAccountAliasKey(AccountAliasLabel(name, tail))]; ok {
+		return account
+	}
+	account, ok := r.byKey[NormalizeAccountAliasKey(name)]
+	if !ok {
+		return nil
+	}
+	if tail = normalizeTailNumber(tail); tail != "" && account.TailNumber != "" && normalizeTailNumber(account.TailNumber) != tail {
+		return nil
+	}
+	return account
+}
+
+// ApplyToParsed 为还没有选定账户的交易填入别名对应的账户
+func (r *AccountAliasResolver) ApplyToParsed(tx *models.ParsedTransaction) {
+	if tx.SelectedAccountID != nil {
+		return
+	}
+	if account := r.Resolve(tx.AccountName, tx.ParsedAccountNumber); account != nil {
+		id, suggested := account.ID, account.ID
+		tx.SelectedAccountID = &id
+		tx.SuggestedAccountID = &suggested
+	}
+}
+
+// AccountAliasLabel 账单中账户的完整称呼：名称里没有尾号时补上尾号，
+// 以免同一家银行的两张卡被当成一个账户
+func AccountAliasLabel(name, tail string) string {
+	name = strings.TrimSpace(name)
+	tail = normalizeTailNumber(tail)
+	if name == "" || tail == "" || strings.Contains(NormalizeAccountAliasKey(name), tail) {
+		return name
+	}
+	return name + "(" + tail + ")"
+}
+
+// NormalizeAccountAliasKey 别名的匹配键：忽略大小写、全半角、空格和标点
+func NormalizeAccountAliasKey(s string) string {
+	return NormalizePayeeKey(s)
+}
+
+// hintAccountName 账户线索在账单中的名称
+func hintAccountName(hint models.AccountHint) string {
+	if hint.AccountName != "" {
+		return hint.AccountName
+	}
+	return hint.BankName + hint.CardType
+}
+
+// loadAccountAliasResolver returns a resolver with the user's accounts and aliases
+func loadAccountAliasResolver(accountRepo *repository.AccountRepository, userID uuid.UUID) (*AccountAliasResolver, error) {
+	accounts, err := accountRepo.GetAll(userID)
+	if err != nil {
+		return nil, err
+	}
+	aliases, err := accountRepo.GetAliases(userID)
+	if err != nil {
+		return nil, err
+	}
+	return NewAccountAliasResolver(accounts, aliases), nil
+}
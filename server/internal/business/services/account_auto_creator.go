@@ -32,6 +32,13 @@ func (c *AccountAutoCreator) CreateAccountsFromHints(
 	// 去重处理：相同账户线索只处理一次
 	seenHints := make(map[string]bool)
 
+	// 用户确认过的名称先按别名解析，解析不到再比较相似度
+	aliases, err := c.accountRepo.GetAliases(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	resolver := NewAccountAliasResolver(existingAccounts, aliases)
+
 	for _, hint := range hints {
 		// 生成唯一标识
 		hintKey := c.generateHintKey(hint)
@@ -41,7 +48,10 @@ func (c *AccountAutoCreator) CreateAccountsFromHints(
 		seenHints[hintKey] = true
 
 		// 检查是否已存在相似账户
-		existing := c.findSimilarAccount(hint, existingAccounts)
+		existing := resolver.Resolve(hintAccountName(hint), hint.AccountNumber)
+		if existing == nil {
+			existing = c.findSimilarAccount(hint, existingAccounts)
+		}
 		if existing != nil {
 			// 更新现有账户信息（如余额）
 			c.updateExistingAccount(existing, hint)
@@ -55,8 +65,8 @@ func (c *AccountAutoCreator) CreateAccountsFromHints(
 			newAccount.UserID,
 			newAccount.Name,
 			newAccount.Type,
-			newAccount.Currency,
 			newAccount.TailNumber,
+			newAccount.Currency,
 			newAccount.Balance,
 		)
 		if err != nil {
@@ -65,6 +75,20 @@ func (c *AccountAutoCreator) CreateAccountsFromHints(
 
 		createdAccounts = append(createdAccounts, *created)
 		existingAccounts = append(existingAccounts, *created) // 更新已存在列表
+		resolver.Add(created)
+
+		// 账单中的名称与新账户名称不同时记为别名，下次导入直接解析到该账户
+		label := AccountAliasLabel(hintAccountName(hint), hint.AccountNumber)
+		if key := NormalizeAccountAliasKey(label); key != "" && resolver.Resolve(hintAccountName(hint), hint.AccountNumber) == nil {
+			if err := c.accountRepo.LearnAlias(&models.AccountAlias{
+				UserID:    userID,
+				AccountID: created.ID,
+				Alias:     label,
+				AliasKey:  key,
+			}); err == nil {
+				resolver.addAlias(label, created)
+			}
+		}
 	}
 
 	return createdAccounts, skippedAccounts, nil
@@ -239,13 +263,18 @@ func (c *AccountAutoCreator) buildAccountFromHint(
 		name = "未知账户"
 	}
 
+	currency := hint.Currency
+	if currency == "" {
+		currency = "CNY"
+	}
+
 	return models.Account{
 		ID:             uuid.New(),
 		UserID:         userID,
 		Name:           name,
 		Type:           accountType,
 		TailNumber:     normalizeTailNumber(hint.AccountNumber),
-		Currency:       "CNY",
+		Currency:       currency,
 		Balance:        hint.Balance,
 		IsDeleted:      false,
 	}
@@ -3,7 +3,9 @@ package services
 import (
 	"account/internal/business/models"
 	"account/internal/data/repository"
+	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/google/uuid"
 )
@@ -105,6 +107,46 @@ func (s *AccountService) DeleteAccount(id uuid.UUID, userID uuid.UUID) error {
 	return s.accountRepo.Delete(id, userID)
 }
 
+// ErrInvalidAccountAlias is returned for aliases without letters or digits
+var ErrInvalidAccountAlias = errors.New("invalid account alias: alias must contain letters or digits")
+
+type AccountAliasRequest struct {
+	Alias string `json:"alias" binding:"required"`
+}
+
+// GetAliases lists the names the account is recognised by in imported files
+func (s *AccountService) GetAliases(accountID uuid.UUID, userID uuid.UUID) ([]models.AccountAlias, error) {
+	if _, err := s.accountRepo.GetByID(accountID, userID); err != nil {
+		return nil, err
+	}
+	return s.accountRepo.GetAliasesByAccount(accountID, userID)
+}
+
+// AddAlias makes imports resolve another name, such as "招行信用卡" or "CMB 1234",
+// to the account. An alias learned for another account is moved to this one.
+func (s *AccountService) AddAlias(accountID uuid.UUID, userID uuid.UUID, req *AccountAliasRequest) (*models.AccountAlias, error) {
+	alias := strings.TrimSpace(req.Alias)
+	key := NormalizeAccountAliasKey(alias)
+	if key == "" {
+		return nil, ErrInvalidAccountAlias
+	}
+
+	if _, err := s.accountRepo.GetByID(accountID, userID); err != nil {
+		return nil, err
+	}
+
+	return s.accountRepo.AddAlias(&models.AccountAlias{
+		UserID:    userID,
+		AccountID: accountID,
+		Alias:     alias,
+		AliasKey:  key,
+	})
+}
+
+func (s *AccountService) DeleteAlias(accountID uuid.UUID, aliasID uuid.UUID, userID uuid.UUID) error {
+	return s.accountRepo.DeleteAlias(aliasID, accountID, userID)
+}
+
 func isValidAccountType(t models.AccountType) bool {
 	switch t {
 	case models.AccountTypeBank, models.AccountTypeCash, models.AccountTypeAlipay,
@@ -1,12 +1,21 @@
 package services
 
 import (
-	"encoding/base64"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"path"
+	"sort"
 	"strings"
+	stdsync "sync"
 	"time"
 
 	"account/internal/business/models"
 	"account/internal/data/repository"
+	"account/internal/sync"
 	"github.com/google/uuid"
 	"go.uber.org/zap"
 )
@@ -17,125 +26,724 @@ type BatchImportService struct {
 	accountRepo       *repository.AccountRepository
 	transactionRepo   *repository.TransactionRepository
 	categoryRepo      *repository.CategoryRepository
+	decisionRepo      *repository.DuplicateDecisionRepository
+	transferService   *TransferService
+	creditCardService *CreditCardService
+	matchConfig       TransferMatchConfig
+	queue             *TaskQueue
+	uploads           UploadLimits
+	events            *sync.EventNotifier
 	logger            *zap.Logger
+
+	// Batch jobs are processed by the task queue and kept in memory while they are reviewed
+	mu   stdsync.RWMutex
+	jobs map[uuid.UUID]*BatchJobDetail
+}
+
+// BatchJobDetail is a snapshot of a batch job with its parse and analysis results
+type BatchJobDetail struct {
+	Job                models.BatchImportJob         `json:"job"`
+	Files              []models.BatchImportFile      `json:"files"`
+	AccountHints       []models.AccountHint          `json:"account_hints"`
+	Duplicates         []models.CrossSourceDuplicate `json:"duplicates"`
+	TransferMatches    []models.TransferMatch        `json:"transfer_matches"`
+	TransferCandidates []models.TransferCandidates   `json:"transfer_candidates"`
+	TransferGroups     []models.TransferGroupMatch   `json:"transfer_groups"`
+	// Progress is the latest progress report, as pushed to the user's devices
+	Progress *models.BatchImportProgress `json:"progress,omitempty"`
+
+	// uploads are the spooled files of the job, removed once it has been processed
+	uploads []FileUpload
 }
 
+var (
+	// ErrBatchJobNotFound is returned when a batch job does not exist or belongs to another user
+	ErrBatchJobNotFound = errors.New("batch import job not found")
+	// ErrBatchJobNotQueued is returned when a batch job could not be stored for processing
+	ErrBatchJobNotQueued = errors.New("failed to queue batch import job")
+	// ErrBatchFileNotFound is returned for a file that is not part of the batch job
+	ErrBatchFileNotFound = errors.New("batch import file not found")
+	// ErrBatchJobNotReady is returned when a job is changed before its analysis has finished
+	ErrBatchJobNotReady = errors.New("batch import job is not ready for review")
+	// ErrDuplicatePairNotFound is returned for an unknown cross-source duplicate
+	ErrDuplicatePairNotFound = errors.New("duplicate pair not found")
+	// ErrTransferMatchNotFound is returned for an unknown transfer match
+	ErrTransferMatchNotFound = errors.New("transfer match not found")
+	// ErrTransferMatchConfirmed is returned when a transfer match is confirmed twice
+	ErrTransferMatchConfirmed = errors.New("transfer match is already confirmed")
+	// ErrTransferAccountRequired is returned when a row to import has no account
+	ErrTransferAccountRequired = errors.New("an account is required for each imported side of the transfer")
+	// ErrTransferGroupNotFound is returned for an unknown one-to-many or many-to-one transfer
+	ErrTransferGroupNotFound = errors.New("transfer group not found")
+	// ErrTransferGroupUnavailable is returned when a leg of a group was already imported with another transfer
+	ErrTransferGroupUnavailable = errors.New("a transaction of the transfer group is already part of another transfer")
+)
+
+// SourceAuto asks the batch import to detect the source of each file
+const SourceAuto = "auto"
+
 // NewBatchImportService creates a new BatchImportService
 func NewBatchImportService(
 	importService *ImportService,
 	accountRepo *repository.AccountRepository,
 	transactionRepo *repository.TransactionRepository,
 	categoryRepo *repository.CategoryRepository,
+	decisionRepo *repository.DuplicateDecisionRepository,
+	transferService *TransferService,
+	creditCardService *CreditCardService,
+	matchConfig TransferMatchConfig,
+	queue *TaskQueue,
+	uploads UploadLimits,
+	events *sync.EventNotifier,
 	logger *zap.Logger,
 ) *BatchImportService {
-	return &BatchImportService{
+	s := &BatchImportService{
 		importService:     importService,
 		accountRepo:       accountRepo,
 		transactionRepo:   transactionRepo,
 		categoryRepo:      categoryRepo,
+		decisionRepo:      decisionRepo,
+		transferService:   transferService,
+		creditCardService: creditCardService,
+		matchConfig:       matchConfig,
+		queue:             queue,
+		uploads:           uploads,
+		events:            events,
 		logger:            logger,
+		jobs:              make(map[uuid.UUID]*BatchJobDetail),
 	}
+	queue.Register(BatchImportTaskKind, s.runBatchImportTask)
+	return s
 }
 
-// FileUpload represents a file to be uploaded
+// FileUpload represents a file to be uploaded. ZIP archives are expanded
+// into one file per member; Password unlocks encrypted archives.
 type FileUpload struct {
-	Source   string `json:"source"`
+	Source   string `json:"source"` // import source or "auto"
 	FileName string `json:"file_name"`
 	Content  string `json:"content"` // base64 encoded
+	Password string `json:"password,omitempty"`
+	// Multipart uploads are spooled to Path instead of carrying Content; the
+	// file is removed once the batch job has been processed
+	Path string `json:"-"`
 }
 
-// CreateBatchJob creates a new batch import job
+// CreateBatchJob creates a new batch import job and queues it for processing.
+// Archives are expanded up front so that a missing or wrong password is
+// reported to the caller; every file is then spooled to the upload directory,
+// where it waits for the job even across a restart of the server.
 func (s *BatchImportService) CreateBatchJob(userID uuid.UUID, files []FileUpload) (*models.BatchImportJob, error) {
-	job := &models.BatchImportJob{
-		ID:              uuid.New(),
-		UserID:          userID,
-		Status:          models.BatchImportStatusPending,
-		TotalFiles:      len(files),
-		CreatedAt:       time.Now(),
-		UpdatedAt:       time.Now(),
+	uploads := files
+	files, err := expandArchives(files, s.uploads)
+	if err != nil {
+		RemoveUploads(uploads)
+		return nil, err
+	}
+	if err := spoolContents(s.uploads.Dir, files); err != nil {
+		RemoveUploads(files)
+		return nil, fmt.Errorf("%w: %v", ErrBatchJobNotQueued, err)
+	}
+
+	payload := newBatchImportPayload(files)
+	state := newBatchJobDetail(uuid.New(), userID, payload)
+	s.saveJob(state)
+
+	if err := s.queue.Enqueue(state.Job.ID, userID, BatchImportTaskKind, payload); err != nil {
+		s.mu.Lock()
+		delete(s.jobs, state.Job.ID)
+		s.mu.Unlock()
+		RemoveUploads(files)
+		return nil, fmt.Errorf("%w: %v", ErrBatchJobNotQueued, err)
+	}
+
+	return &state.Job, nil
+}
+
+// expandArchives replaces every ZIP upload by its members and resolves "auto"
+// sources. Members are converted to UTF-8, since Alipay and bank CSV exports
+// are GBK encoded, and spooled to the upload directory one at a time; they
+// count against the total upload size in place of their archive. Plain bills
+// are only looked at here and read in full when parsed. On error the members
+// already spooled are removed again.
+func expandArchives(files []FileUpload, limits UploadLimits) ([]FileUpload, error) {
+	remaining := limits.MaxTotalSize
+	for i := range files {
+		remaining -= files[i].size()
+	}
+
+	expanded := make([]FileUpload, 0, len(files))
+	for _, f := range files {
+		content, isArchive, err := inspectUpload(&f)
+		if err != nil {
+			RemoveUploads(expanded)
+			return nil, err
+		}
+		if !isArchive {
+			expanded = append(expanded, f)
+			continue
+		}
+
+		remaining += f.size()
+		err = WalkZip(content, f.Password, func(m ArchiveMember) error {
+			text := NormalizeBillText(m.Content)
+			name := path.Join(f.FileName, m.Name)
+			spooled, n, err := SpoolUpload(limits.Dir, bytes.NewReader(text), remaining)
+			if errors.Is(err, ErrUploadTooLarge) {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			if err != nil {
+				return fmt.Errorf("%w: %v", ErrBatchJobNotQueued, err)
+			}
+			remaining -= n
+			expanded = append(expanded, FileUpload{
+				Source:   detectSource(m.Name, text, f.Source),
+				FileName: name,
+				Path:     spooled,
+			})
+			return nil
+		})
+		if err != nil {
+			RemoveUploads(expanded)
+			return nil, fmt.Errorf("%s: %w", f.FileName, err)
+		}
+		// The archive itself is no longer needed once its members are out
+		f.remove()
+	}
+	return expanded, nil
+}
+
+// inspectUpload sniffs the type of an upload and detects the source of a plain
+// bill from its first bytes. The content of a ZIP archive is returned whole.
+func inspectUpload(f *FileUpload) ([]byte, bool, error) {
+	content, err := f.open()
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to open file %s: %w", f.FileName, err)
+	}
+	defer content.Close()
+
+	reader := NewBillReader(content)
+	head, err := reader.Peek(512)
+	if err != nil && err != io.EOF {
+		return nil, false, fmt.Errorf("invalid content for file %s: %w", f.FileName, err)
+	}
+	if err := SniffUpload(f.FileName, head); err != nil {
+		return nil, false, err
+	}
+
+	if IsZipArchive(head) {
+		data, err := io.ReadAll(reader)
+		if err != nil {
+			return nil, false, fmt.Errorf("invalid content for file %s: %w", f.FileName, err)
+		}
+		return data, true, nil
+	}
+
+	if f.Source == "" || f.Source == SourceAuto {
+		text, err := PeekBillHead(reader)
+		if err != nil {
+			return nil, false, fmt.Errorf("invalid content for file %s: %w", f.FileName, err)
+		}
+		f.Source = detectSource(f.FileName, text, f.Source)
+	}
+	return nil, false, nil
+}
+
+// detectSource detects the source of a file, falling back to the source
+// given for the upload. An empty result marks the file as undetectable.
+func detectSource(fileName string, content []byte, fallback string) string {
+	if source, ok := DetectImportSource(fileName, content); ok {
+		return string(source)
+	}
+	if fallback == SourceAuto {
+		return ""
+	}
+	return fallback
+}
+
+// saveJob stores a snapshot of the job state
+func (s *BatchImportService) saveJob(state *BatchJobDetail) {
+	snapshot := state.clone()
+	s.mu.Lock()
+	s.jobs[state.Job.ID] = snapshot
+	s.mu.Unlock()
+}
+
+// persistJob stores the job state as the result of its task, so that the job
+// is restored with it after a restart. Failures are only logged: the job
+// goes on in memory.
+func (s *BatchImportService) persistJob(state *BatchJobDetail) {
+	if err := s.queue.SaveResult(state.Job.ID, state.Job.UserID, state); err != nil {
+		s.logger.Warn("Failed to store batch import job",
+			zap.String("job_id", state.Job.ID.String()), zap.Error(err))
+	}
+}
+
+// clone copies the job state so that the stored snapshot and the copy being
+// worked on do not share slices
+func (d *BatchJobDetail) clone() *BatchJobDetail {
+	c := &BatchJobDetail{
+		Job:          d.Job,
+		Files:        append([]models.BatchImportFile(nil), d.Files...),
+		AccountHints: append([]models.AccountHint(nil), d.AccountHints...),
+		Duplicates:   append([]models.CrossSourceDuplicate(nil), d.Duplicates...),
+
+		TransferMatches:    append([]models.TransferMatch(nil), d.TransferMatches...),
+		TransferCandidates: append([]models.TransferCandidates(nil), d.TransferCandidates...),
+		TransferGroups:     make([]models.TransferGroupMatch, len(d.TransferGroups)),
+		Progress:           d.Progress, // reports are never changed once built
+		uploads:            d.uploads,  // nor are the uploads
+	}
+	for i, g := range d.TransferGroups {
+		c.TransferGroups[i] = g.Clone()
+	}
+	c.Job.BalanceChecks = append([]models.StatementBalanceCheck(nil), d.Job.BalanceChecks...)
+	for i := range c.Files {
+		c.Files[i].ParsedContent = append([]models.ParsedTransaction(nil), d.Files[i].ParsedContent...)
+	}
+	return c
+}
+
+// GetBatchJob returns a snapshot of a batch job
+func (s *BatchImportService) GetBatchJob(userID, jobID uuid.UUID) (*BatchJobDetail, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	state, ok := s.jobs[jobID]
+	if !ok || state.Job.UserID != userID {
+		return nil, ErrBatchJobNotFound
+	}
+	return state.clone(), nil
+}
+
+// GetFileDiagnosticsFile returns the records of a batch file that were skipped
+// or only partly read, as a CSV file
+func (s *BatchImportService) GetFileDiagnosticsFile(userID, jobID, fileID uuid.UUID) (*ExportFile, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	state, ok := s.jobs[jobID]
+	if !ok || state.Job.UserID != userID {
+		return nil, ErrBatchJobNotFound
+	}
+	for _, f := range state.Files {
+		if f.ID == fileID {
+			return ParseDiagnosticsFile(f.FileName, f.Diagnostics)
+		}
+	}
+	return nil, ErrBatchFileNotFound
+}
+
+// ListBatchJobs returns the user's batch jobs, newest first
+func (s *BatchImportService) ListBatchJobs(userID uuid.UUID) []models.BatchImportJob {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	jobs := make([]models.BatchImportJob, 0)
+	for _, state := range s.jobs {
+		if state.Job.UserID == userID {
+			jobs = append(jobs, state.Job)
+		}
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].CreatedAt.After(jobs[j].CreatedAt) })
+	return jobs
+}
+
+// DeleteBatchJob removes a batch job, cancelling it if it is still queued or
+// being processed
+func (s *BatchImportService) DeleteBatchJob(userID, jobID uuid.UUID) error {
+	s.mu.Lock()
+	state, ok := s.jobs[jobID]
+	if !ok || state.Job.UserID != userID {
+		s.mu.Unlock()
+		return ErrBatchJobNotFound
 	}
+	delete(s.jobs, jobID)
+	s.mu.Unlock()
 
-	// Start processing in background
-	go s.processBatchJob(job, files)
+	// A job that was processed already has no task left to cancel
+	if err := s.queue.Cancel(jobID, userID); err != nil && !errors.Is(err, repository.ErrTaskNotFound) {
+		return err
+	}
+	// Nor is it restored after a restart
+	if err := s.queue.Delete(jobID, userID); err != nil && !errors.Is(err, repository.ErrTaskNotFound) {
+		return err
+	}
+	RemoveUploads(state.uploads)
+	return nil
+}
 
-	return job, nil
+// parseUpload parses one file of the batch, reporting progress on the job as
+// the file is read. Parsing stops when ctx is cancelled.
+func (s *BatchImportService) parseUpload(ctx context.Context, state *BatchJobDetail, batchFile *models.BatchImportFile, file *FileUpload) (*models.ImportPreview, error) {
+	content, err := file.open()
+	if err != nil {
+		return nil, err
+	}
+	defer content.Close()
+
+	return s.importService.ParseFile(state.Job.UserID, &ParseRequest{
+		Source:   models.ImportSource(file.Source),
+		FileName: file.FileName,
+		File:     &contextReader{ctx: ctx, r: content},
+		Size:     file.size(),
+		Progress: func(p ParseProgress) {
+			batchFile.BytesRead, batchFile.TotalBytes, batchFile.ParsedRows = p.BytesRead, p.TotalBytes, p.Rows
+			s.publishProgress(state, models.BatchImportEventRowsProcessed, "")
+		},
+	})
 }
 
-// processBatchJob processes all files in the batch
-func (s *BatchImportService) processBatchJob(job *models.BatchImportJob, files []FileUpload) {
+// processBatchJob processes all files in the batch. A file that cannot be
+// parsed fails on its own; an error is returned when the job as a whole
+// failed or ctx was cancelled.
+func (s *BatchImportService) processBatchJob(ctx context.Context, state *BatchJobDetail, files []FileUpload) error {
+	job := &state.Job
+	batchFiles := state.Files
 	job.Status = models.BatchImportStatusParsing
-	job.UpdatedAt = time.Now()
+	s.publishProgress(state, models.BatchImportEventStarted, "")
 
 	var allTransactions []models.ParsedTransaction
 	var allAccountHints []models.AccountHint
 
 	// Process each file
-	for _, file := range files {
+	for i, file := range files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		s.logger.Info("Processing file", zap.String("filename", file.FileName))
+		batchFile := &batchFiles[i]
+		batchFile.Status = models.FileImportStatusParsing
+		s.publishProgress(state, models.BatchImportEventFileStarted, file.FileName)
 
-		// Decode base64 content
-		content, err := base64.StdEncoding.DecodeString(file.Content)
-		if err != nil {
-			s.logger.Error("Failed to decode file content", zap.Error(err))
+		if file.Source == "" {
+			batchFile.Status = models.FileImportStatusFailed
+			batchFile.ParseErrors = append(batchFile.ParseErrors, "unable to detect the source of this file")
+			s.publishProgress(state, models.BatchImportEventFileFailed, file.FileName)
 			continue
 		}
 
-		// Parse file
-		source := models.ImportSource(file.Source)
-		parseReq := &ParseRequest{
-			Source:   source,
-			FileName: file.FileName,
-			File:     strings.NewReader(string(content)),
+		// Parse file as it is read
+		preview, err := s.parseUpload(ctx, state, batchFile, &file)
+		if ctx.Err() != nil {
+			return ctx.Err()
 		}
-
-		preview, err := s.importService.ParseFile(job.UserID, parseReq)
 		if err != nil {
 			s.logger.Error("Failed to parse file", zap.Error(err))
+			batchFile.Status = models.FileImportStatusFailed
+			batchFile.ParseErrors = append(batchFile.ParseErrors, err.Error())
+			s.publishProgress(state, models.BatchImportEventFileFailed, file.FileName)
 			continue
 		}
 
+		// Rows the parser skipped or only partly read
+		batchFile.Diagnostics = preview.Diagnostics
+		for _, d := range preview.Diagnostics {
+			batchFile.ParseErrors = append(batchFile.ParseErrors, d.String())
+		}
+
 		// Collect transactions
 		for _, tx := range preview.Transactions {
 			tx.BatchJobID = &job.ID
+			tx.BatchFileID = &batchFile.ID
 			allTransactions = append(allTransactions, tx)
+			batchFile.ParsedContent = append(batchFile.ParsedContent, tx)
 		}
 
 		// Extract account hints
 		hints := s.extractAccountHints(preview.Transactions, file.FileName)
 		allAccountHints = append(allAccountHints, hints...)
+		for _, hint := range preview.AccountHints {
+			hint.FoundInFile = file.FileName
+			// Opening balances are only used for reconciliation; accounts are created with the closing balance
+			if hint.BalanceType != models.BalanceTypeOpening {
+				allAccountHints = append(allAccountHints, hint)
+			}
+		}
 
+		// Reconcile statement balances against the parsed entries
+		for _, check := range reconcileStatementBalances(preview.AccountHints, preview.Transactions) {
+			check.FoundInFile = file.FileName
+			if !check.Reconciled {
+				s.logger.Warn("Statement balance does not reconcile",
+					zap.String("filename", file.FileName),
+					zap.String("account", check.AccountNumber),
+					zap.Float64("difference", check.Difference),
+				)
+			}
+			job.BalanceChecks = append(job.BalanceChecks, check)
+		}
+
+		batchFile.AccountHints = append(hints, preview.AccountHints...)
+		batchFile.Status = models.FileImportStatusParsed
+		batchFile.UpdatedAt = time.Now()
 		job.ParsedFiles++
+		s.publishProgress(state, models.BatchImportEventFileParsed, file.FileName)
 	}
 
+	if job.ParsedFiles == 0 {
+		return Permanent(errors.New("no file could be parsed"))
+	}
 	job.TotalTransactions = len(allTransactions)
-	job.ValidTransactions = len(allTransactions)
 
 	// Analyze and match
 	job.Status = models.BatchImportStatusAnalyzing
-	job.UpdatedAt = time.Now()
 
-	// Find transfer matches
-	matchResult := s.findTransferMatches(allTransactions)
-	job.MatchPairs = len(matchResult.Matches)
+	// The same purchase in a payment app bill and in the bank statement of the card that paid it
+	state.Duplicates = NewDuplicateDetector().FindDuplicates(allTransactions)
+	s.applyRecordedDecisions(job.UserID, state)
+	job.DuplicatePairs = len(state.Duplicates)
+	job.ValidTransactions = countImportable(batchFiles)
+	s.publishProgress(state, models.BatchImportEventDuplicatesFound, "")
 
-	// Auto-create accounts
+	// Auto-create accounts, then point the rows at the accounts their names
+	// resolve to, including the ones just created. Accounts are created once
+	// per job: a retry finds the ones an earlier attempt created.
 	job.Status = models.BatchImportStatusMatching
-	autoCreated, _, err := s.autoCreateAccounts(job.UserID, allAccountHints)
-	if err != nil {
-		s.logger.Error("Failed to auto-create accounts", zap.Error(err))
+	if job.AutoCreatedAccountIDs == nil {
+		autoCreated, _, err := s.autoCreateAccounts(job.UserID, allAccountHints)
+		if err != nil {
+			return fmt.Errorf("failed to auto-create accounts: %w", err)
+		}
+		job.AutoCreatedAccountIDs = make([]uuid.UUID, len(autoCreated))
+		for i, account := range autoCreated {
+			job.AutoCreatedAccountIDs[i] = account.ID
+		}
+		s.persistJob(state)
+	}
+	job.AutoCreatedAccounts = len(job.AutoCreatedAccountIDs)
+	s.publishProgress(state, models.BatchImportEventAccountsCreated, "")
+	if resolver, err := loadAccountAliasResolver(s.accountRepo, job.UserID); err != nil {
+		s.logger.Warn("Failed to load account aliases", zap.Error(err))
+	} else {
+		for i := range batchFiles {
+			for j := range batchFiles[i].ParsedContent {
+				resolver.ApplyToParsed(&batchFiles[i].ParsedContent[j])
+			}
+		}
+	}
+	// Flag rows dated inside periods already imported for their account
+	for i := range batchFiles {
+		s.importService.markCoveredRows(job.UserID, batchFiles[i].ParsedContent)
 	}
-	job.AutoCreatedAccounts = len(autoCreated)
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// Find transfer matches, weighing the evidence as the user's earlier decisions taught
+	model := s.matchModel(job.UserID)
+	state.TransferMatches, state.TransferCandidates, state.TransferGroups = s.findTransferMatches(job.UserID, job.ID, batchFiles, model)
+	job.MatchPairs = len(state.TransferMatches)
+
+	// Matches the user would confirm anyway are flagged, to be confirmed on execution
+	job.AutoConfirmedPairs = s.autoConfirmTransfers(state, model.AutoConfirm)
+	job.ValidTransactions = countImportable(batchFiles)
+	s.publishProgress(state, models.BatchImportEventMatchesFound, "")
 
 	// Mark as ready to import
 	job.Status = models.BatchImportStatusReadyToImport
-	job.UpdatedAt = time.Now()
+	job.ErrorMsg = ""
+	for i := range batchFiles {
+		if batchFiles[i].Status == models.FileImportStatusParsed {
+			batchFiles[i].Status = models.FileImportStatusAnalyzed
+		}
+	}
+	state.AccountHints = allAccountHints
+	s.publishProgress(state, models.BatchImportEventCompleted, "")
 
 	s.logger.Info("Batch job processing completed",
 		zap.String("job_id", job.ID.String()),
 		zap.Int("transactions", job.TotalTransactions),
 		zap.Int("matches", job.MatchPairs),
+		zap.Int("duplicates", job.DuplicatePairs),
 		zap.Int("auto_created_accounts", job.AutoCreatedAccounts),
 	)
+	return nil
+}
+
+// applyRecordedDecisions applies the decisions the user made for the same
+// pairs in earlier imports
+func (s *BatchImportService) applyRecordedDecisions(userID uuid.UUID, state *BatchJobDetail) {
+	idsBySource := make(map[models.ImportSource][]string)
+	for _, dup := range state.Duplicates {
+		if bank := dup.BankTransaction; bank.ExternalID != "" {
+			idsBySource[bank.Source] = append(idsBySource[bank.Source], bank.ExternalID)
+		}
+	}
+
+	recorded := make(map[string]models.DuplicateDecision)
+	for source, ids := range idsBySource {
+		decisions, err := s.decisionRepo.GetByBankExternalIDs(userID, string(source), ids)
+		if err != nil {
+			s.logger.Warn("Failed to load duplicate decisions", zap.Error(err))
+			continue
+		}
+		for _, d := range decisions {
+			recorded[duplicateDecisionKey(d.AppSource, d.AppExternalID, d.BankSource, d.BankExternalID)] = d.Decision
+		}
+	}
+
+	for i := range state.Duplicates {
+		dup := &state.Duplicates[i]
+		app, bank := dup.AppTransaction, dup.BankTransaction
+		if decision, ok := recorded[duplicateDecisionKey(app.Source, app.ExternalID, bank.Source, bank.ExternalID)]; ok {
+			dup.Decision = decision
+			applyDuplicateDecision(state.Files, dup)
+		}
+	}
+}
+
+func duplicateDecisionKey(appSource models.ImportSource, appID string, bankSource models.ImportSource, bankID string) string {
+	return strings.Join([]string{string(appSource), appID, string(bankSource), bankID}, "|")
+}
+
+// ResolveDuplicate records the user's decision for a cross-source duplicate.
+// Merging keeps the app record, completed with the bank account, and drops
+// the bank row; keeping both restores the rows as parsed. Decisions for rows
+// with stable external IDs are stored so that later imports respect them.
+func (s *BatchImportService) ResolveDuplicate(userID, jobID, duplicateID uuid.UUID, decision models.DuplicateDecision) (*models.CrossSourceDuplicate, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, ok := s.jobs[jobID]
+	if !ok || state.Job.UserID != userID {
+		return nil, ErrBatchJobNotFound
+	}
+	if state.Job.Status != models.BatchImportStatusReadyToImport {
+		return nil, ErrBatchJobNotReady
+	}
+
+	var dup *models.CrossSourceDuplicate
+	for i := range state.Duplicates {
+		if state.Duplicates[i].ID == duplicateID {
+			dup = &state.Duplicates[i]
+			break
+		}
+	}
+	if dup == nil {
+		return nil, ErrDuplicatePairNotFound
+	}
+
+	app, bank := dup.AppTransaction, dup.BankTransaction
+	if app.ExternalID != "" && bank.ExternalID != "" {
+		err := s.decisionRepo.Save(&models.ImportDuplicateDecision{
+			UserID:         userID,
+			AppSource:      app.Source,
+			AppExternalID:  app.ExternalID,
+			BankSource:     bank.Source,
+			BankExternalID: bank.ExternalID,
+			Decision:       decision,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	dup.Decision = decision
+	applyDuplicateDecision(state.Files, dup)
+	state.Job.ValidTransactions = countImportable(state.Files)
+	state.Job.UpdatedAt = time.Now()
+	s.persistJob(state)
+
+	resolved := *dup
+	return &resolved, nil
+}
+
+// applyDuplicateDecision updates the parsed rows of a duplicate pair in place
+func applyDuplicateDecision(files []models.BatchImportFile, dup *models.CrossSourceDuplicate) {
+	app, bank := dup.AppTransaction, dup.BankTransaction
+	if dup.Decision == models.DuplicateDecisionMerge {
+		app = dup.Merged
+		bank.IsDuplicate = true
+		bank.CanBeImported = false
+		bank.ImportWarning = mergedDuplicateWarning(app.Source)
+	}
+
+	for i := range files {
+		for j := range files[i].ParsedContent {
+			row := &files[i].ParsedContent[j]
+			switch {
+			case sameParsedRow(*row, dup.AppTransaction):
+				*row = app
+			case sameParsedRow(*row, dup.BankTransaction):
+				*row = bank
+			}
+		}
+	}
+}
+
+// sameParsedRow reports whether two parsed transactions are the same row of the same batch file
+func sameParsedRow(a, b models.ParsedTransaction) bool {
+	return a.BatchFileID != nil && b.BatchFileID != nil && *a.BatchFileID == *b.BatchFileID && a.LineNumber == b.LineNumber
+}
+
+// countImportable counts the rows that can still be imported
+func countImportable(files []models.BatchImportFile) int {
+	count := 0
+	for _, f := range files {
+		for _, tx := range f.ParsedContent {
+			if tx.CanBeImported {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// reconcileStatementBalances checks opening balance + entries = closing balance
+// for every account of a statement that reports both balances. Each closing
+// balance is paired with the latest opening balance before it, so multi-day
+// files (one statement per day) are checked day by day.
+func reconcileStatementBalances(hints []models.AccountHint, transactions []models.ParsedTransaction) []models.StatementBalanceCheck {
+	var checks []models.StatementBalanceCheck
+	opening := make(map[string]models.AccountHint)
+
+	for _, hint := range hints {
+		key := hint.AccountNumber + "|" + hint.Currency
+		switch hint.BalanceType {
+		case models.BalanceTypeOpening:
+			opening[key] = hint
+		case models.BalanceTypeClosing:
+			open, ok := opening[key]
+			if !ok {
+				continue
+			}
+			delete(opening, key)
+
+			computed := open.Balance
+			for _, tx := range transactions {
+				if tx.ParsedAccountNumber != hint.AccountNumber || (tx.Currency != "" && hint.Currency != "" && tx.Currency != hint.Currency) {
+					continue
+				}
+				day := tx.TransactionDate.Format("2006-01-02")
+				if open.BalanceDate != nil && day < open.BalanceDate.Format("2006-01-02") {
+					continue
+				}
+				if hint.BalanceDate != nil && day > hint.BalanceDate.Format("2006-01-02") {
+					continue
+				}
+				if tx.Type == models.TransactionTypeIncome {
+					computed += tx.Amount
+				} else {
+					computed -= tx.Amount
+				}
+			}
+
+			difference := math.Round((hint.Balance-computed)*100) / 100
+			checks = append(checks, models.StatementBalanceCheck{
+				AccountNumber:   hint.AccountNumber,
+				Currency:        hint.Currency,
+				OpeningBalance:  open.Balance,
+				ClosingBalance:  hint.Balance,
+				ComputedClosing: math.Round(computed*100) / 100,
+				Difference:      difference,
+				Reconciled:      difference == 0,
+				ClosingDate:     hint.BalanceDate,
+			})
+		}
+	}
+
+	return checks
 }
 
 // extractAccountHints extracts account hints from transactions
@@ -170,91 +778,843 @@ func (s *BatchImportService) extractAccountHints(transactions []models.ParsedTra
 	return hints
 }
 
-// findTransferMatches finds transfer matches across all transactions
-func (s *BatchImportService) findTransferMatches(transactions []models.ParsedTransaction) *MatchResult {
-	var outTxs, inTxs []TransactionInfo
+// findTransferMatches pairs transfers out with transfers in across all files
+// and the ledger, and flags the paired rows. Candidates are kept for manual
+// re-pairing, and one-to-many or many-to-one groupings of the remaining rows
+// are proposed for confirmation.
+func (s *BatchImportService) findTransferMatches(userID, jobID uuid.UUID, batchFiles []models.BatchImportFile, model MatchModel) ([]models.TransferMatch, []models.TransferCandidates, []models.TransferGroupMatch) {
+	matcher := NewTransferMatcherWithConfig(s.matchConfig).WithModel(model)
+	if patterns, err := s.transferService.FeePatterns(userID); err != nil {
+		s.logger.Warn("Failed to load transfer fee patterns", zap.Error(err))
+	} else {
+		matcher.WithFeePatterns(patterns)
+	}
+
+	files := make([]FileTransactions, 0, len(batchFiles)+1)
+	fileIndex := make(map[uuid.UUID]int, len(batchFiles))
+	for i, f := range batchFiles {
+		fileIndex[f.ID] = i
+		files = append(files, FileTransactions{
+			FileID:       f.ID,
+			Source:       f.Source,
+			FileName:     f.FileName,
+			Transactions: f.ParsedContent,
+		})
+	}
+
+	// The other half of a transfer may have been imported with an earlier statement
+	ledger, ledgerRows, err := s.loadLedgerTransfers(userID, matcher, batchFiles)
+	if err != nil {
+		s.logger.Warn("Failed to load ledger transactions for transfer matching", zap.Error(err))
+	}
+	if len(ledger) > 0 {
+		files = append(files, FileTransactions{FileID: uuid.Nil, Existing: true, Transactions: ledgerRows})
+	}
+
+	result := matcher.FindMatches(files)
+
+	row := func(info TransactionInfo) (*models.ParsedTransaction, *uuid.UUID) {
+		if info.Existing {
+			id := ledger[info.TransactionIndex].ID
+			return &ledgerRows[info.TransactionIndex], &id
+		}
+		return &batchFiles[fileIndex[info.FileID]].ParsedContent[info.TransactionIndex], nil
+	}
+
+	cycles := s.newBillCycles(userID)
+
+	now := time.Now()
+	matches := make([]models.TransferMatch, 0, len(result.Matches))
+	for _, m := range result.Matches {
+		id := m.ID
+		out, outID := row(m.OutTx)
+		in, inID := row(m.InTx)
+		if outID == nil {
+			out.IsTransferOut, out.HasTransferMatch, out.TransferMatchID = true, true, &id
+		}
+		if inID == nil {
+			in.IsTransferIn, in.HasTransferMatch, in.TransferMatchID = true, true, &id
+		}
+
+		match := models.TransferMatch{
+			ID:                id,
+			JobID:             jobID,
+			FromFileID:        m.OutTx.FileID,
+			FromTransaction:   *out,
+			FromTransactionID: outID,
+			ToFileID:          m.InTx.FileID,
+			ToTransaction:     *in,
+			ToTransactionID:   inID,
+			MatchType:         models.MatchTypeTransfer,
+			Confidence:        m.Confidence,
+			MatchFactors:      m.MatchFactors,
+			CreatedAt:         now,
+			Scores:            m.Scores,
+			ScoreBreakdown:    matcher.Weights().Explain(m.Scores),
+		}
+		if fee := roundAmount(out.Amount - in.Amount); fee > feeAmountTolerance && out.Currency == in.Currency {
+			match.Fee = fee
+		}
 
-	for i, tx := range transactions {
-		fileID := uuid.New() // Generate a dummy file ID for matching
-		if tx.BatchFileID != nil {
-			fileID = *tx.BatchFileID
+		var card *models.Account
+		if inID != nil {
+			card = cycles.account(ledger[m.InTx.TransactionIndex].AccountID)
+		} else {
+			card = cycles.accountForRow(in)
 		}
+		if card != nil {
+			if repayment := cycles.match(card.ID, in.TransactionDate, in.Amount); repayment != nil {
+				match.MatchType = models.MatchTypeRepayment
+				match.Repayment = repayment
+			}
+		}
+		matches = append(matches, match)
+	}
 
-		info := TransactionInfo{
-			FileID:             fileID,
-			TransactionIndex:   i,
-			Date:               tx.TransactionDate,
-			Amount:             tx.Amount,
-			Type:               string(tx.Type),
-			AccountName:        tx.AccountName,
-			AccountNumber:      tx.ParsedAccountNumber,
-			Counterparty:       tx.Counterparty,
-			CounterpartyNumber: tx.RelatedAccountNumber,
-			Note:               tx.Note,
-			RawData:            tx.RawData,
+	// A repayment whose card statement is not imported is paired with the credit
+	// account it pays, identified by card number or by the bill amount
+	for _, info := range result.UnmatchedOut {
+		if info.Existing || !info.Repayment {
+			continue
 		}
+		out, _ := row(info)
+		if match := cycles.pairRepayment(out); match != nil {
+			id := uuid.New()
+			out.IsTransferOut, out.HasTransferMatch, out.TransferMatchID = true, true, &id
+			match.ID = id
+			match.JobID = jobID
+			match.FromFileID = info.FileID
+			match.FromTransaction = *out
+			match.CreatedAt = now
+			matches = append(matches, *match)
+		}
+	}
 
-		if isTransferOut(tx) {
-			outTxs = append(outTxs, info)
-		} else if isTransferIn(tx) {
-			inTxs = append(inTxs, info)
+	candidates := make([]models.TransferCandidates, 0, len(result.Candidates))
+	for _, c := range result.Candidates {
+		if c.Tx.Existing {
+			continue // The preview lists candidates for the rows being imported
+		}
+		entry := models.TransferCandidates{
+			FileID:     c.Tx.FileID,
+			LineNumber: c.Tx.LineNumber,
+			Direction:  "out",
 		}
+		if c.Tx.Type == string(models.TransactionTypeIncome) {
+			entry.Direction = "in"
+		}
+		for _, alt := range c.Candidates {
+			_, transactionID := row(alt.Tx)
+			entry.Candidates = append(entry.Candidates, models.TransferCandidate{
+				FileID:          alt.Tx.FileID,
+				LineNumber:      alt.Tx.LineNumber,
+				TransactionID:   transactionID,
+				TransactionDate: alt.Tx.Date,
+				Amount:          alt.Tx.Amount,
+				Note:            alt.Tx.Note,
+				Confidence:      alt.Confidence,
+				MatchFactors:    alt.MatchFactors,
+				ScoreBreakdown:  matcher.Weights().Explain(alt.Scores),
+				Selected:        alt.Selected,
+			})
+		}
+		candidates = append(candidates, entry)
 	}
 
-	// Use the TransferMatcher to find matches
-	matcher := NewTransferMatcher()
-	return matcher.FindMatches(convertToFileTransactions(outTxs, inTxs))
+	groups := make([]models.TransferGroupMatch, 0, len(result.Groups))
+	for _, g := range result.Groups {
+		group := models.TransferGroupMatch{
+			ID:           g.ID,
+			JobID:        jobID,
+			Confidence:   g.Confidence,
+			MatchFactors: g.MatchFactors,
+			CreatedAt:    now,
+		}
+		leg := func(info TransactionInfo) models.TransferGroupLeg {
+			tx, transactionID := row(info)
+			return models.TransferGroupLeg{
+				FileID:        info.FileID,
+				LineNumber:    info.LineNumber,
+				Transaction:   *tx,
+				TransactionID: transactionID,
+			}
+		}
+		for _, info := range g.Outs {
+			group.Outs = append(group.Outs, leg(info))
+		}
+		for _, info := range g.Ins {
+			group.Ins = append(group.Ins, leg(info))
+		}
+		groups = append(groups, group)
+	}
+
+	return matches, candidates, groups
 }
 
-// isTransferOut checks if a transaction is a transfer out
-func isTransferOut(tx models.ParsedTransaction) bool {
-	if tx.Type == models.TransactionTypeExpense {
-		note := strings.ToLower(tx.Note + " " + tx.Counterparty)
-		transferKeywords := []string{"转账", "转出", "汇款", "跨行", "行内"}
-		for _, kw := range transferKeywords {
-			if strings.Contains(note, kw) {
-				return true
+// loadLedgerTransfers returns the stored income and expense rows that could be the
+// other half of a transfer in the batch, as rows for the matcher with the account's
+// name and tail number filled in. Rows already linked as transfers are left out.
+func (s *BatchImportService) loadLedgerTransfers(userID uuid.UUID, matcher *TransferMatcher, batchFiles []models.BatchImportFile) ([]models.Transaction, []models.ParsedTransaction, error) {
+	var start, end time.Time
+	minAmount, maxAmount := math.Inf(1), math.Inf(-1)
+	for _, f := range batchFiles {
+		for _, tx := range f.ParsedContent {
+			if tx.IsDuplicate || !matcher.IsTransfer(tx) {
+				continue
 			}
+			if start.IsZero() || tx.TransactionDate.Before(start) {
+				start = tx.TransactionDate
+			}
+			if tx.TransactionDate.After(end) {
+				end = tx.TransactionDate
+			}
+			minAmount = math.Min(minAmount, tx.Amount)
+			maxAmount = math.Max(maxAmount, tx.Amount)
 		}
 	}
-	return false
+	if start.IsZero() {
+		return nil, nil, nil
+	}
+
+	existing, err := s.transactionRepo.GetByDateRangeAndAmount(
+		userID,
+		start.Add(-s.matchConfig.TimeWindow),
+		end.Add(s.matchConfig.TimeWindow),
+		minAmount-s.matchConfig.MaxAmountDifference,
+		maxAmount+s.matchConfig.MaxAmountDifference,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	accounts, err := s.accountRepo.GetAll(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	accountByID := make(map[uuid.UUID]models.Account, len(accounts))
+	for _, a := range accounts {
+		accountByID[a.ID] = a
+	}
+
+	var ledger []models.Transaction
+	var rows []models.ParsedTransaction
+	for _, t := range existing {
+		if t.Type == models.TransactionTypeTransfer {
+			continue
+		}
+		account := accountByID[t.AccountID]
+		accountID := t.AccountID
+		ledger = append(ledger, t)
+		rows = append(rows, models.ParsedTransaction{
+			TransactionDate:     t.TransactionDate,
+			Type:                t.Type,
+			Amount:              t.Amount,
+			Currency:            t.Currency,
+			Note:                t.Note,
+			AccountName:         account.Name,
+			ParsedAccountNumber: account.TailNumber,
+			SelectedAccountID:   &accountID,
+			ExternalID:          t.ExternalID,
+			Source:              models.ImportSource(t.ImportSource),
+		})
+	}
+	return ledger, rows, nil
+}
+
+// billCycles matches the repayments of a batch to the bill cycles of the user's
+// credit accounts. Repayments matched earlier in the batch count as paid.
+type billCycles struct {
+	service *CreditCardService
+	userID  uuid.UUID
+	credit  []models.Account
+	bills   map[uuid.UUID][]models.CreditCardBill
+}
+
+func (s *BatchImportService) newBillCycles(userID uuid.UUID) *billCycles {
+	cycles := &billCycles{
+		service: s.creditCardService,
+		userID:  userID,
+		bills:   make(map[uuid.UUID][]models.CreditCardBill),
+	}
+	accounts, err := s.accountRepo.GetAll(userID)
+	if err != nil {
+		s.logger.Warn("Failed to load accounts for repayment matching", zap.Error(err))
+		return cycles
+	}
+	for _, a := range accounts {
+		if a.Type == models.AccountTypeCredit {
+			cycles.credit = append(cycles.credit, a)
+		}
+	}
+	return cycles
+}
+
+// account returns the credit account with the given ID, or nil for other accounts
+func (c *billCycles) account(id uuid.UUID) *models.Account {
+	for i := range c.credit {
+		if c.credit[i].ID == id {
+			return &c.credit[i]
+		}
+	}
+	return nil
+}
+
+// accountForRow returns the credit account a card statement row belongs to
+func (c *billCycles) accountForRow(row *models.ParsedTransaction) *models.Account {
+	if row.SelectedAccountID != nil {
+		return c.account(*row.SelectedAccountID)
+	}
+	return c.accountByTail(row.ParsedAccountNumber)
+}
+
+func (c *billCycles) accountByTail(tail string) *models.Account {
+	tail = normalizeTailNumber(tail)
+	if tail == "" {
+		return nil
+	}
+	for i := range c.credit {
+		if c.credit[i].TailNumber != "" && normalizeTailNumber(c.credit[i].TailNumber) == tail {
+			return &c.credit[i]
+		}
+	}
+	return nil
+}
+
+// load returns the account's bills, fetched once per batch
+func (c *billCycles) load(accountID uuid.UUID) []models.CreditCardBill {
+	bills, ok := c.bills[accountID]
+	if !ok && c.service != nil {
+		bills, _ = c.service.GetBills(c.userID, accountID)
+		c.bills[accountID] = bills
+	}
+	return bills
+}
+
+// match finds the bill cycle a repayment pays and counts it as paid
+func (c *billCycles) match(accountID uuid.UUID, date time.Time, amount float64) *models.BillRepayment {
+	bills := c.load(accountID)
+	repayment := MatchBillRepayment(bills, date, amount)
+	if repayment != nil {
+		for i := range bills {
+			if bills[i].ID == repayment.BillID {
+				bills[i].PaidAmount += amount
+			}
+		}
+	}
+	return repayment
+}
+
+// pairRepayment pairs a repayment out of a debit account with the credit account
+// it pays. The card is identified by the counterpart card number, or else by
+// being the only card with a bill due of exactly this amount.
+func (c *billCycles) pairRepayment(out *models.ParsedTransaction) *models.TransferMatch {
+	var factors []string
+	confidence := 0.10 // 还款关键词
+
+	card := c.accountByTail(out.RelatedAccountNumber)
+	if card != nil {
+		confidence += 0.40
+		factors = append(factors, "对方账户尾号匹配信用卡账户")
+	} else {
+		for i := range c.credit {
+			r := MatchBillRepayment(c.load(c.credit[i].ID), out.TransactionDate, out.Amount)
+			if r != nil && repaysExactly(r) {
+				if card != nil {
+					return nil // 多张卡的账单金额相同，无法确定
+				}
+				card = &c.credit[i]
+			}
+		}
+		if card == nil {
+			return nil
+		}
+	}
+
+	repayment := c.match(card.ID, out.TransactionDate, out.Amount)
+	if repayment == nil {
+		return nil
+	}
+	if repaysExactly(repayment) {
+		confidence += 0.30
+		if repayment.Kind == models.RepaymentFull {
+			factors = append(factors, "金额等于本期应还")
+		} else {
+			factors = append(factors, "金额等于最低还款额")
+		}
+	}
+	if !repayment.Overdue {
+		confidence += 0.20
+		factors = append(factors, "在账单还款期内")
+	}
+	factors = append(factors, "信用卡还款")
+
+	accountID := card.ID
+	return &models.TransferMatch{
+		ToFileID: uuid.Nil,
+		ToTransaction: models.ParsedTransaction{
+			TransactionDate:     out.TransactionDate,
+			Type:                models.TransactionTypeIncome,
+			Amount:              out.Amount,
+			Currency:            out.Currency,
+			Note:                out.Note,
+			AccountName:         card.Name,
+			ParsedAccountNumber: card.TailNumber,
+			SelectedAccountID:   &accountID,
+			IsTransferIn:        true,
+		},
+		MatchType:    models.MatchTypeRepayment,
+		Confidence:   math.Min(confidence, 1),
+		MatchFactors: factors,
+		Repayment:    repayment,
+	}
+}
+
+// repaysExactly reports whether a repayment clears the bill or its minimum payment exactly
+func repaysExactly(r *models.BillRepayment) bool {
+	return math.Abs(r.StatementBalance-r.PaidBefore-r.Amount) <= repaymentAmountTolerance ||
+		math.Abs(r.MinimumPayment-r.PaidBefore-r.Amount) <= repaymentAmountTolerance
+}
+
+// ConfirmTransferMatchRequest sets the accounts of the sides still to be imported,
+// overriding the account selected in the preview
+type ConfirmTransferMatchRequest struct {
+	FromAccountID *uuid.UUID `json:"from_account_id"`
+	ToAccountID   *uuid.UUID `json:"to_account_id"`
 }
 
-// isTransferIn checks if a transaction is a transfer in
-func isTransferIn(tx models.ParsedTransaction) bool {
-	if tx.Type == models.TransactionTypeIncome {
-		note := strings.ToLower(tx.Note + " " + tx.Counterparty)
-		inKeywords := []string{"转账", "转入", "汇款", "跨行", "来账"}
-		for _, kw := range inKeywords {
-			if strings.Contains(note, kw) {
-				return true
+// ConfirmTransferMatch imports the batch rows of a transfer match and links them,
+// together with the ledger row the match points to, into one transfer. The rows
+// are then no longer imported on their own.
+func (s *BatchImportService) ConfirmTransferMatch(userID, jobID, matchID uuid.UUID, req *ConfirmTransferMatchRequest) (*models.TransferMatch, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, ok := s.jobs[jobID]
+	if !ok || state.Job.UserID != userID {
+		return nil, ErrBatchJobNotFound
+	}
+	if state.Job.Status != models.BatchImportStatusReadyToImport {
+		return nil, ErrBatchJobNotReady
+	}
+
+	match := findTransferMatch(state, matchID)
+	if match == nil {
+		return nil, ErrTransferMatchNotFound
+	}
+	if match.UserConfirmed {
+		return nil, ErrTransferMatchConfirmed
+	}
+
+	if err := s.confirmTransferMatch(userID, state, match, req); err != nil {
+		// Keep the rows imported before the failure
+		s.persistJob(state)
+		return nil, err
+	}
+	s.recordMatchFeedback(userID, match, true)
+	state.Job.ValidTransactions = countImportable(state.Files)
+	state.Job.UpdatedAt = time.Now()
+	s.persistJob(state)
+
+	confirmed := *match
+	return &confirmed, nil
+}
+
+// RejectTransferMatch drops a proposed transfer match. Its batch rows are
+// imported on their own again, and the rejection is kept to learn from.
+func (s *BatchImportService) RejectTransferMatch(userID, jobID, matchID uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, ok := s.jobs[jobID]
+	if !ok || state.Job.UserID != userID {
+		return ErrBatchJobNotFound
+	}
+	if state.Job.Status != models.BatchImportStatusReadyToImport {
+		return ErrBatchJobNotReady
+	}
+
+	match := findTransferMatch(state, matchID)
+	if match == nil {
+		return ErrTransferMatchNotFound
+	}
+	if match.UserConfirmed {
+		return ErrTransferMatchConfirmed
+	}
+	s.recordMatchFeedback(userID, match, false)
+
+	for i := range state.Files {
+		for j := range state.Files[i].ParsedContent {
+			row := &state.Files[i].ParsedContent[j]
+			if row.TransferMatchID != nil && *row.TransferMatchID == matchID {
+				row.HasTransferMatch, row.TransferMatchID = false, nil
 			}
 		}
 	}
-	return false
+	for i := range state.TransferCandidates {
+		for j := range state.TransferCandidates[i].Candidates {
+			if pairedBy(&state.TransferCandidates[i], &state.TransferCandidates[i].Candidates[j], match) {
+				state.TransferCandidates[i].Candidates[j].Selected = false
+			}
+		}
+	}
+
+	matches := state.TransferMatches[:0]
+	for _, m := range state.TransferMatches {
+		if m.ID != matchID {
+			matches = append(matches, m)
+		}
+	}
+	state.TransferMatches = matches
+	state.Job.MatchPairs = len(matches)
+	state.Job.UpdatedAt = time.Now()
+	s.persistJob(state)
+	return nil
 }
 
-// convertToFileTransactions converts transaction info to FileTransactions
-func convertToFileTransactions(outTxs, inTxs []TransactionInfo) []FileTransactions {
-	fileMap := make(map[uuid.UUID]*FileTransactions)
+func findTransferMatch(state *BatchJobDetail, matchID uuid.UUID) *models.TransferMatch {
+	for i := range state.TransferMatches {
+		if state.TransferMatches[i].ID == matchID {
+			return &state.TransferMatches[i]
+		}
+	}
+	return nil
+}
 
-	for _, tx := range outTxs {
-		if _, ok := fileMap[tx.FileID]; !ok {
-			fileMap[tx.FileID] = &FileTransactions{FileID: tx.FileID}
+// pairedBy reports whether a candidate of a transfer row is the pairing made by the match
+func pairedBy(entry *models.TransferCandidates, candidate *models.TransferCandidate, match *models.TransferMatch) bool {
+	isSide := func(fileID uuid.UUID, line int, transactionID *uuid.UUID, sideFile uuid.UUID, side *models.ParsedTransaction, sideID *uuid.UUID) bool {
+		if sideFile == uuid.Nil {
+			return transactionID != nil && sideID != nil && *transactionID == *sideID
 		}
+		return fileID == sideFile && line == side.LineNumber
+	}
+	from := func(fileID uuid.UUID, line int, id *uuid.UUID) bool {
+		return isSide(fileID, line, id, match.FromFileID, &match.FromTransaction, match.FromTransactionID)
 	}
+	to := func(fileID uuid.UUID, line int, id *uuid.UUID) bool {
+		return isSide(fileID, line, id, match.ToFileID, &match.ToTransaction, match.ToTransactionID)
+	}
+	if entry.Direction == "out" {
+		return from(entry.FileID, entry.LineNumber, nil) && to(candidate.FileID, candidate.LineNumber, candidate.TransactionID)
+	}
+	return to(entry.FileID, entry.LineNumber, nil) && from(candidate.FileID, candidate.LineNumber, candidate.TransactionID)
+}
 
-	for _, tx := range inTxs {
-		if _, ok := fileMap[tx.FileID]; !ok {
-			fileMap[tx.FileID] = &FileTransactions{FileID: tx.FileID}
+// autoConfirmTransfers flags the matches whose confidence reached the user's
+// learned threshold, when the accounts of the rows to import are known. It
+// returns how many were flagged. A threshold of 0 flags nothing. Nothing is
+// written to the ledger: analysis runs before the user has seen the batch,
+// flagged matches are imported and linked when the batch is executed.
+func (s *BatchImportService) autoConfirmTransfers(state *BatchJobDetail, threshold float64) int {
+	if threshold <= 0 {
+		return 0
+	}
+
+	confirmed := 0
+	for i := range state.TransferMatches {
+		match := &state.TransferMatches[i]
+		if match.UserConfirmed || match.ScoreBreakdown == nil || match.Confidence < threshold {
+			continue
+		}
+		_, err := transferSides(state, match, &ConfirmTransferMatchRequest{})
+		if errors.Is(err, ErrTransferAccountRequired) {
+			continue // Left for the user to choose the account
+		}
+		if err != nil {
+			s.logger.Warn("Failed to auto-confirm transfer match",
+				zap.String("match_id", match.ID.String()), zap.Error(err))
+			continue
 		}
+		match.AutoConfirmed = true
+		confirmed++
 	}
+	return confirmed
+}
 
-	result := make([]FileTransactions, 0, len(fileMap))
-	for _, ft := range fileMap {
-		result = append(result, *ft)
+// matchModel returns the match weights and thresholds learned for the user,
+// or the defaults when they cannot be loaded
+func (s *BatchImportService) matchModel(userID uuid.UUID) MatchModel {
+	model, err := s.transferService.MatchModel(userID, s.matchConfig.MinConfidence)
+	if err != nil {
+		s.logger.Warn("Failed to load transfer match feedback", zap.Error(err))
+		return MatchModel{Weights: DefaultMatchWeights()}
 	}
-	return result
+	return model
+}
+
+// recordMatchFeedback keeps the user's verdict on a scored match. Pairings
+// not scored by the matcher, such as repayments to a card without its
+// statement, are not learned from.
+func (s *BatchImportService) recordMatchFeedback(userID uuid.UUID, match *models.TransferMatch, accepted bool) {
+	if match.ScoreBreakdown == nil {
+		return
+	}
+	if err := s.transferService.RecordMatchFeedback(userID, match.Scores, match.Confidence, accepted); err != nil {
+		s.logger.Warn("Failed to record transfer match feedback", zap.Error(err))
+	}
+}
+
+// transferSide is one side of a transfer match: a ledger transaction, or a
+// batch row and the account it is to be imported into
+type transferSide struct {
+	id        *uuid.UUID
+	row       *models.ParsedTransaction
+	accountID *uuid.UUID
+}
+
+// transferSides finds the sides of a match, outgoing first, and the accounts
+// its batch rows are to be imported into. Nothing is written.
+func transferSides(state *BatchJobDetail, match *models.TransferMatch, req *ConfirmTransferMatchRequest) ([]transferSide, error) {
+	findRow := func(out bool) *models.ParsedTransaction {
+		for i := range state.Files {
+			for j := range state.Files[i].ParsedContent {
+				row := &state.Files[i].ParsedContent[j]
+				if row.TransferMatchID != nil && *row.TransferMatchID == match.ID && row.IsTransferOut == out {
+					return row
+				}
+			}
+		}
+		return nil
+	}
+
+	sides := []transferSide{
+		{id: match.FromTransactionID, accountID: req.FromAccountID},
+		{id: match.ToTransactionID, accountID: req.ToAccountID},
+	}
+	for i := range sides {
+		if sides[i].id != nil {
+			continue
+		}
+		sides[i].row = findRow(i == 0)
+		if i == 1 && match.ToFileID == uuid.Nil {
+			// The card side of a repayment whose card statement is not imported
+			sides[i].row = &match.ToTransaction
+		}
+		if sides[i].row == nil {
+			return nil, ErrTransferMatchNotFound
+		}
+		if sides[i].accountID == nil {
+			sides[i].accountID = sides[i].row.SelectedAccountID
+		}
+		if sides[i].accountID == nil {
+			return nil, ErrTransferAccountRequired
+		}
+	}
+	return sides, nil
+}
+
+// confirmTransferMatch imports the batch rows of the match and links them into
+// one transfer. Each imported row is put on the match at once, so that if a
+// later step fails, a retry links the row instead of importing it again.
+func (s *BatchImportService) confirmTransferMatch(userID uuid.UUID, state *BatchJobDetail, match *models.TransferMatch, req *ConfirmTransferMatchRequest) error {
+	sides, err := transferSides(state, match, req)
+	if err != nil {
+		return err
+	}
+
+	for i := range sides {
+		row := sides[i].row
+		if row == nil {
+			continue
+		}
+		id, err := s.importTransferRow(userID, state, row, *sides[i].accountID)
+		if err != nil {
+			return err
+		}
+		sides[i].id = &id
+		if i == 0 {
+			match.FromTransactionID = &id
+		} else {
+			match.ToTransactionID = &id
+		}
+	}
+
+	link, err := s.transferService.LinkTransfer(userID, *sides[0].id, *sides[1].id)
+	if err != nil {
+		return err
+	}
+	match.FeeTransactionID = link.FeeTransactionID
+
+	// A transfer into a credit account pays the bill of its cycle
+	if incoming, err := s.transactionRepo.GetByID(*sides[1].id, userID); err != nil {
+		s.logger.Warn("Failed to load transfer for repayment", zap.Error(err))
+	} else if repayment, err := s.creditCardService.ApplyRepayment(userID, incoming); err != nil {
+		s.logger.Warn("Failed to apply credit card repayment", zap.Error(err))
+	} else if repayment != nil {
+		match.MatchType = models.MatchTypeRepayment
+		match.Repayment = repayment
+	}
+
+	match.UserConfirmed = true
+	return nil
+}
+
+// importTransferRow imports one batch row of a confirmed transfer into the given
+// account. The row is then no longer imported on its own. The batch job is the
+// import job of the row, so that rolling it back undoes the transfer too.
+func (s *BatchImportService) importTransferRow(userID uuid.UUID, state *BatchJobDetail, row *models.ParsedTransaction, accountID uuid.UUID) (uuid.UUID, error) {
+	job := batchImportJob(state)
+	if err := s.importService.importJobRepo.Start(job); err != nil {
+		return uuid.Nil, err
+	}
+
+	created, err := s.importService.createTransactionInternal(userID, &CreateTransactionRequest{
+		AccountID:       accountID,
+		CategoryID:      row.SelectedCategoryID,
+		Type:            row.Type,
+		Amount:          row.Amount,
+		Currency:        row.Currency,
+		Note:            row.Note,
+		TransactionDate: row.TransactionDate,
+	}, row.Source, row.ExternalID, row.OrderID, &job.ID)
+	if err != nil {
+		_ = s.importService.importJobRepo.Finish(job.ID, userID, 1, 0, err.Error())
+		return uuid.Nil, err
+	}
+	if err := s.importService.importJobRepo.Finish(job.ID, userID, 1, 1, ""); err != nil {
+		s.logger.Warn("Failed to update import job", zap.Error(err))
+	}
+	s.importService.reconciler.RecheckAssertions(userID, created.AccountID, created.TransactionDate)
+
+	s.importService.learnAccountAlias(userID, row, created.AccountID, nil)
+
+	row.SelectedAccountID = &created.AccountID
+	row.CanBeImported = false
+	row.ImportWarning = "imported as part of a confirmed transfer"
+	return created.ID, nil
+}
+
+// batchImportJob describes a batch job in the import history
+func batchImportJob(state *BatchJobDetail) *models.ImportJob {
+	job := &models.ImportJob{
+		ID:     state.Job.ID,
+		UserID: state.Job.UserID,
+		Kind:   models.ImportJobKindBatch,
+	}
+	var names []string
+	for i, file := range state.Files {
+		if i == 0 || file.Source == job.Source {
+			job.Source = file.Source
+		} else {
+			job.Source = "" // 多种来源
+		}
+		names = append(names, file.FileName)
+	}
+	job.FileName = strings.Join(names, ", ")
+	if runes := []rune(job.FileName); len(runes) > 1000 {
+		job.FileName = string(runes[:1000])
+	}
+	return job
+}
+
+// ConfirmTransferGroup imports the batch legs of a one-to-many or many-to-one
+// transfer and links them, together with the ledger legs, into one transfer.
+// The request's accounts apply to the imported legs of each side.
+func (s *BatchImportService) ConfirmTransferGroup(userID, jobID, groupID uuid.UUID, req *ConfirmTransferMatchRequest) (*models.TransferGroupMatch, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, ok := s.jobs[jobID]
+	if !ok || state.Job.UserID != userID {
+		return nil, ErrBatchJobNotFound
+	}
+	if state.Job.Status != models.BatchImportStatusReadyToImport {
+		return nil, ErrBatchJobNotReady
+	}
+
+	var group *models.TransferGroupMatch
+	for i := range state.TransferGroups {
+		if state.TransferGroups[i].ID == groupID {
+			group = &state.TransferGroups[i]
+			break
+		}
+	}
+	if group == nil {
+		return nil, ErrTransferGroupNotFound
+	}
+	if group.UserConfirmed {
+		return nil, ErrTransferMatchConfirmed
+	}
+
+	findRow := func(leg *models.TransferGroupLeg) *models.ParsedTransaction {
+		for i := range state.Files {
+			if state.Files[i].ID != leg.FileID {
+				continue
+			}
+			for j := range state.Files[i].ParsedContent {
+				if state.Files[i].ParsedContent[j].LineNumber == leg.LineNumber {
+					return &state.Files[i].ParsedContent[j]
+				}
+			}
+		}
+		return nil
+	}
+
+	type pending struct {
+		leg       *models.TransferGroupLeg
+		row       *models.ParsedTransaction
+		accountID *uuid.UUID
+	}
+	var rows []pending
+	sides := []struct {
+		legs      []models.TransferGroupLeg
+		accountID *uuid.UUID
+	}{
+		{group.Outs, req.FromAccountID},
+		{group.Ins, req.ToAccountID},
+	}
+	for _, side := range sides {
+		for i := range side.legs {
+			leg := &side.legs[i]
+			if leg.TransactionID != nil {
+				continue
+			}
+			row := findRow(leg)
+			if row == nil {
+				return nil, ErrTransferGroupNotFound
+			}
+			// A leg already imported with another transfer cannot join this one
+			if !row.CanBeImported {
+				return nil, ErrTransferGroupUnavailable
+			}
+			accountID := side.accountID
+			if accountID == nil {
+				accountID = row.SelectedAccountID
+			}
+			if accountID == nil {
+				return nil, ErrTransferAccountRequired
+			}
+			rows = append(rows, pending{leg: leg, row: row, accountID: accountID})
+		}
+	}
+
+	for _, p := range rows {
+		id, err := s.importTransferRow(userID, state, p.row, *p.accountID)
+		if err != nil {
+			return nil, err
+		}
+		p.leg.TransactionID = &id
+	}
+
+	legIDs := func(legs []models.TransferGroupLeg) []uuid.UUID {
+		ids := make([]uuid.UUID, len(legs))
+		for i, leg := range legs {
+			ids[i] = *leg.TransactionID
+		}
+		return ids
+	}
+	links, err := s.transferService.LinkTransferGroup(userID, legIDs(group.Outs), legIDs(group.Ins))
+	if err != nil {
+		return nil, err
+	}
+
+	group.LinkGroupID = links[0].GroupID
+	group.UserConfirmed = true
+	state.Job.ValidTransactions = countImportable(state.Files)
+	state.Job.UpdatedAt = time.Now()
+	s.persistJob(state)
+
+	confirmed := group.Clone()
+	return &confirmed, nil
 }
 
 // autoCreateAccounts automatically creates accounts from hints
@@ -269,31 +1629,78 @@ func (s *BatchImportService) autoCreateAccounts(userID uuid.UUID, hints []models
 	return creator.CreateAccountsFromHints(userID, hints, existingAccounts)
 }
 
-// ExecuteBatchImport executes the batch import with user selections
+// ExecuteBatchImport executes the batch import with user selections. Matches
+// flagged as auto-confirmed during analysis and the ones listed in
+// confirmedMatches are imported and linked as transfers; a match the user
+// rejected before is no longer part of the job.
 func (s *BatchImportService) ExecuteBatchImport(
 	jobID uuid.UUID,
 	userID uuid.UUID,
 	selectedAccountIDs map[string]string,
 	confirmedMatches []string,
 ) (*models.ImportResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, ok := s.jobs[jobID]
+	if !ok || state.Job.UserID != userID {
+		return nil, ErrBatchJobNotFound
+	}
+	if state.Job.Status != models.BatchImportStatusReadyToImport {
+		return nil, ErrBatchJobNotReady
+	}
+
 	result := &models.ImportResult{
 		JobID:        jobID,
 		ImportedIDs:  make([]uuid.UUID, 0),
 		Errors:       make([]models.ImportError, 0),
 	}
 
-	// TODO: In a real implementation, we would:
-	// 1. Retrieve the parsed transactions from storage
-	// 2. Apply user account selections
-	// 3. Process confirmed transfer matches
-	// 4. Import all transactions with proper account mappings
-	// 5. Create transfer transactions for matched pairs
+	listed := make(map[string]bool, len(confirmedMatches))
+	for _, id := range confirmedMatches {
+		listed[id] = true
+	}
+	for i := range state.TransferMatches {
+		match := &state.TransferMatches[i]
+		if match.UserConfirmed || !(match.AutoConfirmed || listed[match.ID.String()]) {
+			continue
+		}
+		result.TotalRows++
+		from, to := match.FromTransactionID, match.ToTransactionID
+		err := s.confirmTransferMatch(userID, state, match, &ConfirmTransferMatchRequest{})
+		// Ledger sides were linked, not imported. A side imported before a
+		// failure stays on the match and is linked on the next attempt.
+		if from == nil && match.FromTransactionID != nil {
+			result.ImportedIDs = append(result.ImportedIDs, *match.FromTransactionID)
+		}
+		if to == nil && match.ToTransactionID != nil {
+			result.ImportedIDs = append(result.ImportedIDs, *match.ToTransactionID)
+		}
+		if err != nil {
+			result.FailedRows++
+			result.Errors = append(result.Errors, models.ImportError{
+				LineNumber: match.FromTransaction.LineNumber,
+				Error:      fmt.Sprintf("transfer match %s: %v", match.ID, err),
+			})
+			continue
+		}
+		if !match.AutoConfirmed {
+			s.recordMatchFeedback(userID, match, true)
+		}
+	}
+	result.ImportedRows = len(result.ImportedIDs)
+	state.Job.ValidTransactions = countImportable(state.Files)
+	state.Job.UpdatedAt = time.Now()
+	s.persistJob(state)
 
-	// For now, return a dummy result
+	// TODO: In a real implementation, we would also:
+	// 1. Apply user account selections
+	// 2. Import the remaining transactions with proper account mappings
 	s.logger.Info("Executing batch import",
 		zap.String("job_id", jobID.String()),
 		zap.Int("selected_accounts", len(selectedAccountIDs)),
 		zap.Int("confirmed_matches", len(confirmedMatches)),
+		zap.Int("imported_transfer_rows", result.ImportedRows),
 	)
 
 	return result, nil
@@ -0,0 +1,230 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"account/internal/business/models"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// BatchImportTaskKind is the task queue kind of processing a batch import job.
+// The task has the ID of the job.
+const BatchImportTaskKind = "batch_import"
+
+// batchImportPayload is what a batch import task needs to process its job
+// again after a restart: the spooled files, under the IDs the job gave them
+type batchImportPayload struct {
+	CreatedAt time.Time                `json:"created_at"`
+	Files     []batchImportPayloadFile `json:"files"`
+}
+
+type batchImportPayloadFile struct {
+	ID       uuid.UUID `json:"id"`
+	Source   string    `json:"source"` // 空表示无法识别来源
+	FileName string    `json:"file_name"`
+	Path     string    `json:"path"`
+}
+
+func newBatchImportPayload(files []FileUpload) batchImportPayload {
+	payload := batchImportPayload{
+		CreatedAt: time.Now(),
+		Files:     make([]batchImportPayloadFile, len(files)),
+	}
+	for i, f := range files {
+		payload.Files[i] = batchImportPayloadFile{
+			ID:       uuid.New(),
+			Source:   f.Source,
+			FileName: f.FileName,
+			Path:     f.Path,
+		}
+	}
+	return payload
+}
+
+// newBatchJobDetail returns the state of a batch job that has not been processed yet
+func newBatchJobDetail(jobID, userID uuid.UUID, payload batchImportPayload) *BatchJobDetail {
+	state := &BatchJobDetail{
+		Job: models.BatchImportJob{
+			ID:         jobID,
+			UserID:     userID,
+			Status:     models.BatchImportStatusPending,
+			TotalFiles: len(payload.Files),
+			CreatedAt:  payload.CreatedAt,
+			UpdatedAt:  payload.CreatedAt,
+		},
+		Files:   make([]models.BatchImportFile, len(payload.Files)),
+		uploads: make([]FileUpload, len(payload.Files)),
+	}
+	for i, f := range payload.Files {
+		state.Files[i] = models.BatchImportFile{
+			ID:        f.ID,
+			JobID:     jobID,
+			Source:    models.ImportSource(f.Source),
+			FileName:  f.FileName,
+			Status:    models.FileImportStatusPending,
+			CreatedAt: payload.CreatedAt,
+			UpdatedAt: payload.CreatedAt,
+		}
+		state.uploads[i] = FileUpload{Source: f.Source, FileName: f.FileName, Path: f.Path}
+	}
+	return state
+}
+
+// spoolContents moves the content of uploads that carry it, base64 encoded, to
+// files in dir
+func spoolContents(dir string, files []FileUpload) error {
+	for i := range files {
+		f := &files[i]
+		if f.Path != "" {
+			continue
+		}
+		content, err := f.open()
+		if err != nil {
+			return err
+		}
+		path, _, err := SpoolUpload(dir, content, f.size())
+		content.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", f.FileName, err)
+		}
+		f.Path, f.Content = path, ""
+	}
+	return nil
+}
+
+// runBatchImportTask processes a batch job for the task queue. Every attempt
+// starts over from the spooled files, so a job whose server stopped is
+// rebuilt from its task; what an earlier attempt wrote, the accounts it
+// created, is taken from the task's result rather than written again. The
+// final state of the job is stored as the result too. The uploads are
+// removed once the job is processed or has failed for good; a cancelled
+// job's are removed with the job.
+func (s *BatchImportService) runBatchImportTask(ctx context.Context, task *models.BackgroundTask) error {
+	var payload batchImportPayload
+	if err := json.Unmarshal(task.Payload, &payload); err != nil {
+		return Permanent(fmt.Errorf("invalid batch import task: %w", err))
+	}
+
+	state := newBatchJobDetail(task.ID, task.UserID, payload)
+	state.Job.ErrorMsg = task.ErrorMsg
+	if earlier, err := storedBatchJob(task); err != nil {
+		s.logger.Warn("Invalid batch import result", zap.String("task_id", task.ID.String()), zap.Error(err))
+	} else if earlier != nil {
+		state.Job.AutoCreatedAccountIDs = earlier.Job.AutoCreatedAccountIDs
+	}
+	// A job deleted while its task waited is not brought back
+	s.mu.Lock()
+	if _, exists := s.jobs[state.Job.ID]; exists {
+		s.jobs[state.Job.ID] = state.clone()
+	}
+	s.mu.Unlock()
+
+	err := s.processBatchJob(ctx, state, state.uploads)
+	switch {
+	case err == nil:
+		s.persistJob(state)
+		RemoveUploads(state.uploads)
+	case ctx.Err() != nil:
+		// Cancelled, or interrupted by a shutdown and resumed on the next start
+	case IsPermanent(err) || task.LastAttempt():
+		state.Job.Status = models.BatchImportStatusFailed
+		state.Job.ErrorMsg = err.Error()
+		s.publishProgress(state, models.BatchImportEventFailed, err.Error())
+		s.persistJob(state)
+		RemoveUploads(state.uploads)
+	default:
+		state.Job.Status = models.BatchImportStatusPending
+		state.Job.ErrorMsg = fmt.Sprintf("attempt %d of %d failed, retrying: %v", task.Attempts, task.MaxAttempts, err)
+		s.publishProgress(state, models.BatchImportEventError, state.Job.ErrorMsg)
+	}
+	return err
+}
+
+// RestoreBatchJobs brings back the batch jobs that were queued or being
+// processed when the server stopped, so that they are listed before a worker
+// picks them up again, and the jobs that finished or failed within the
+// preview TTL, in the state they were last stored in
+func (s *BatchImportService) RestoreBatchJobs() error {
+	tasks, err := s.queue.Unfinished(BatchImportTaskKind)
+	if err != nil {
+		return err
+	}
+	finished, err := s.queue.FinishedSince(BatchImportTaskKind, time.Now().Add(-s.importService.previewTTL))
+	if err != nil {
+		return err
+	}
+
+	for _, task := range append(tasks, finished...) {
+		state, err := restoredBatchJob(&task)
+		if err != nil {
+			s.logger.Warn("Invalid batch import task", zap.String("task_id", task.ID.String()), zap.Error(err))
+			continue
+		}
+		s.saveJob(state)
+	}
+	return nil
+}
+
+// restoredBatchJob returns the state of a batch job as its task left it. A
+// job still to be processed starts over from its payload; a finished one is
+// its stored result, or failed with the task's error when none was stored.
+func restoredBatchJob(task *models.BackgroundTask) (*BatchJobDetail, error) {
+	if task.Status != models.TaskStatusQueued && task.Status != models.TaskStatusRunning {
+		state, err := storedBatchJob(task)
+		if err != nil {
+			return nil, err
+		}
+		// The last attempt may have stopped after storing no more than the accounts it created
+		if state != nil && task.Status == models.TaskStatusFailed && state.Job.Status != models.BatchImportStatusFailed {
+			state.Job.Status = models.BatchImportStatusFailed
+			state.Job.ErrorMsg = task.ErrorMsg
+		}
+		if state != nil {
+			return state, nil
+		}
+	}
+
+	var payload batchImportPayload
+	if err := json.Unmarshal(task.Payload, &payload); err != nil {
+		return nil, err
+	}
+	state := newBatchJobDetail(task.ID, task.UserID, payload)
+	state.Job.ErrorMsg = task.ErrorMsg
+	if task.Status == models.TaskStatusFailed {
+		state.Job.Status = models.BatchImportStatusFailed
+		state.Job.UpdatedAt = task.UpdatedAt
+	}
+	return state, nil
+}
+
+// storedBatchJob decodes the job state stored as the task's result, nil when
+// none was stored
+func storedBatchJob(task *models.BackgroundTask) (*BatchJobDetail, error) {
+	if len(task.Result) == 0 {
+		return nil, nil
+	}
+	var state BatchJobDetail
+	if err := json.Unmarshal(task.Result, &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// contextReader stops reading once ctx is cancelled, so that a cancelled job
+// does not parse the rest of a large file
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c *contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
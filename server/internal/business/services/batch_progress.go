@@ -0,0 +1,128 @@
+package services
+
+import (
+	"account/internal/business/models"
+	"account/internal/sync"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// EventTypeImportProgress is the WebSocket message type of batch import progress reports
+const EventTypeImportProgress = "import_progress"
+
+// BuildBatchImportProgress summarises the state of a batch job for a progress
+// report. While files are parsed, the percentage advances with the bytes read.
+func BuildBatchImportProgress(state *BatchJobDetail, event models.BatchImportEvent, message string) models.BatchImportProgress {
+	job := &state.Job
+	progress := models.BatchImportProgress{
+		JobID:               job.ID,
+		Event:               event,
+		Message:             message,
+		Status:              job.Status,
+		TotalFiles:          job.TotalFiles,
+		ParsedFiles:         job.ParsedFiles,
+		DuplicatePairs:      job.DuplicatePairs,
+		MatchPairs:          job.MatchPairs,
+		AutoConfirmedPairs:  job.AutoConfirmedPairs,
+		AutoCreatedAccounts: job.AutoCreatedAccounts,
+		Errors:              []models.BatchImportError{},
+		UpdatedAt:           job.UpdatedAt,
+	}
+
+	// 已读完的文件记 1，正在解析的文件按已读字节计
+	var filesDone float64
+	for i, f := range state.Files {
+		progress.RowsProcessed += f.ParsedRows
+		switch f.Status {
+		case models.FileImportStatusPending:
+		case models.FileImportStatusParsing:
+			progress.CurrentFile = f.FileName
+			progress.CurrentFileIndex = i + 1
+			progress.BytesRead, progress.TotalBytes = f.BytesRead, f.TotalBytes
+			if f.TotalBytes > 0 {
+				filesDone += math.Min(1, float64(f.BytesRead)/float64(f.TotalBytes))
+			}
+		case models.FileImportStatusFailed:
+			progress.FailedFiles++
+			filesDone++
+		default:
+			filesDone++
+		}
+		for _, msg := range f.ParseErrors {
+			progress.Errors = append(progress.Errors, models.BatchImportError{FileName: f.FileName, Message: msg})
+		}
+	}
+	if job.ErrorMsg != "" {
+		progress.Errors = append(progress.Errors, models.BatchImportError{Message: job.ErrorMsg})
+	}
+
+	progress.PercentComplete = CalculateProgress(job).PercentComplete
+	if job.Status == models.BatchImportStatusParsing && job.TotalFiles > 0 {
+		progress.PercentComplete *= filesDone / float64(job.TotalFiles)
+	}
+	progress.PercentComplete = math.Round(progress.PercentComplete*10) / 10
+
+	return progress
+}
+
+// publishProgress saves the job with a progress report and pushes the report
+// to the user's connected devices. Row progress, reported many times per file,
+// only updates the status of the saved job; every other step saves all of it.
+// Nothing is saved for a job that was deleted while it was processed.
+func (s *BatchImportService) publishProgress(state *BatchJobDetail, event models.BatchImportEvent, message string) {
+	state.Job.UpdatedAt = time.Now()
+	progress := BuildBatchImportProgress(state, event, message)
+	state.Progress = &progress
+
+	s.mu.Lock()
+	saved, exists := s.jobs[state.Job.ID]
+	switch {
+	case !exists:
+	case event == models.BatchImportEventRowsProcessed && len(saved.Files) == len(state.Files):
+		saved.copyStatus(state)
+	default:
+		s.jobs[state.Job.ID] = state.clone()
+	}
+	s.mu.Unlock()
+	if !exists {
+		return
+	}
+
+	if s.events != nil {
+		s.events.Publish(state.Job.UserID, sync.Event{Type: EventTypeImportProgress, Data: progress})
+	}
+}
+
+// copyStatus copies the progress of state into a saved job without copying
+// the rows parsed so far
+func (d *BatchJobDetail) copyStatus(state *BatchJobDetail) {
+	d.Job.Status = state.Job.Status
+	d.Job.ParsedFiles = state.Job.ParsedFiles
+	d.Job.UpdatedAt = state.Job.UpdatedAt
+	d.Progress = state.Progress
+	for i := range d.Files {
+		f := &state.Files[i]
+		d.Files[i].Status = f.Status
+		d.Files[i].BytesRead, d.Files[i].TotalBytes, d.Files[i].ParsedRows = f.BytesRead, f.TotalBytes, f.ParsedRows
+	}
+}
+
+// GetBatchProgress returns the latest progress report of a batch job, the one
+// its last WebSocket event carried, for clients that poll instead
+func (s *BatchImportService) GetBatchProgress(userID, jobID uuid.UUID) (*models.BatchImportProgress, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	state, ok := s.jobs[jobID]
+	if !ok || state.Job.UserID != userID {
+		return nil, ErrBatchJobNotFound
+	}
+	if state.Progress == nil {
+		progress := BuildBatchImportProgress(state, "", "")
+		return &progress, nil
+	}
+	progress := *state.Progress
+	return &progress, nil
+}
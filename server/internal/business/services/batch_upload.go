@@ -0,0 +1,107 @@
+package services
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+var (
+	// ErrUploadTooLarge is returned when a file or the whole upload exceeds its size limit
+	ErrUploadTooLarge = errors.New("upload is too large")
+	// ErrUnsupportedFileType is returned for files that are neither text bills nor ZIP archives
+	ErrUnsupportedFileType = errors.New("unsupported file type")
+)
+
+// UploadLimits bounds the files of one batch import upload
+type UploadLimits struct {
+	MaxFileSize  int64 // 单个文件的字节数上限
+	MaxTotalSize int64 // 一次上传全部文件的字节数上限
+	MaxFiles     int
+	// Dir is where uploads wait until their batch job has been processed; the
+	// system temp directory when empty
+	Dir string
+}
+
+// DefaultUploadLimits returns the limits used when none are configured
+func DefaultUploadLimits() UploadLimits {
+	return UploadLimits{
+		MaxFileSize:  50 << 20,
+		MaxTotalSize: 200 << 20,
+		MaxFiles:     20,
+	}
+}
+
+// SpoolUpload copies an uploaded file to a new file in dir, so that a batch job
+// can parse it after the request has ended. At most maxSize bytes are copied;
+// a larger file is removed again and ErrUploadTooLarge returned.
+func SpoolUpload(dir string, r io.Reader, maxSize int64) (string, int64, error) {
+	f, err := os.CreateTemp(dir, "batch-import-*")
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to create upload file: %w", err)
+	}
+
+	n, err := io.Copy(f, io.LimitReader(r, maxSize+1))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && n > maxSize {
+		err = ErrUploadTooLarge
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+		if errors.Is(err, ErrUploadTooLarge) {
+			return "", 0, err
+		}
+		return "", 0, fmt.Errorf("failed to store upload: %w", err)
+	}
+
+	return f.Name(), n, nil
+}
+
+// RemoveUploads deletes the spooled files of uploads that will not be parsed
+func RemoveUploads(files []FileUpload) {
+	for _, f := range files {
+		f.remove()
+	}
+}
+
+// open returns the content of an upload, decoding base64 as it is read
+func (f *FileUpload) open() (io.ReadCloser, error) {
+	if f.Path != "" {
+		return os.Open(f.Path)
+	}
+	return io.NopCloser(base64.NewDecoder(base64.StdEncoding, strings.NewReader(f.Content))), nil
+}
+
+// size returns the size of the upload's content in bytes
+func (f *FileUpload) size() int64 {
+	if f.Path != "" {
+		if info, err := os.Stat(f.Path); err == nil {
+			return info.Size()
+		}
+		return 0
+	}
+	return int64(base64.StdEncoding.DecodedLen(len(f.Content)))
+}
+
+// remove deletes the spooled file of an upload, if any
+func (f *FileUpload) remove() {
+	if f.Path != "" {
+		_ = os.Remove(f.Path)
+	}
+}
+
+// SniffUpload checks from its first bytes that a file is a text bill or a ZIP
+// archive; images, PDFs and other binary files are rejected up front
+func SniffUpload(fileName string, head []byte) error {
+	contentType := http.DetectContentType(head)
+	if strings.HasPrefix(contentType, "text/") || contentType == "application/zip" {
+		return nil
+	}
+	return fmt.Errorf("%w: %s is %s", ErrUnsupportedFileType, fileName, contentType)
+}
@@ -0,0 +1,323 @@
+package services
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"account/internal/business/models"
+)
+
+// camt.053 (BankToCustomerStatement) document. Only the elements needed for
+// import are mapped; encoding/xml matches on local names, so every message
+// version (camt.053.001.02 ... .08) decodes into the same structs.
+type camtDocument struct {
+	Statements []camtStatement `xml:"BkToCstmrStmt>Stmt"`
+}
+
+type camtStatement struct {
+	ID       string        `xml:"Id"`
+	Account  camtAccount   `xml:"Acct"`
+	Balances []camtBalance `xml:"Bal"`
+	Entries  []camtEntry   `xml:"Ntry"`
+}
+
+type camtAccount struct {
+	IBAN     string `xml:"Id>IBAN"`
+	Other    string `xml:"Id>Othr>Id"`
+	Currency string `xml:"Ccy"`
+	Name     string `xml:"Nm"`
+	BankName string `xml:"Svcr>FinInstnId>Nm"`
+	BIC      string `xml:"Svcr>FinInstnId>BIC"`
+	BICFI    string `xml:"Svcr>FinInstnId>BICFI"`
+}
+
+type camtAmount struct {
+	Value    string `xml:",chardata"`
+	Currency string `xml:"Ccy,attr"`
+}
+
+type camtDate struct {
+	Date     string `xml:"Dt"`
+	DateTime string `xml:"DtTm"`
+}
+
+type camtBalance struct {
+	Code   string     `xml:"Tp>CdOrPrtry>Cd"`
+	Amount camtAmount `xml:"Amt"`
+	Sign   string     `xml:"CdtDbtInd"`
+	Date   camtDate   `xml:"Dt"`
+}
+
+type camtEntry struct {
+	Reference     string          `xml:"NtryRef"`
+	Amount        camtAmount      `xml:"Amt"`
+	Sign          string          `xml:"CdtDbtInd"`
+	Reversal      bool            `xml:"RvslInd"`
+	Status        camtStatus      `xml:"Sts"`
+	BookingDate   camtDate        `xml:"BookgDt"`
+	ValueDate     camtDate        `xml:"ValDt"`
+	ServicerRef   string          `xml:"AcctSvcrRef"`
+	Details       []camtTxDetails `xml:"NtryDtls>TxDtls"`
+	AdditionalInf string          `xml:"AddtlNtryInf"`
+}
+
+// camtStatus is a plain code before camt.053.001.08 and a <Cd> choice after
+type camtStatus struct {
+	Value string `xml:",chardata"`
+	Code  string `xml:"Cd"`
+}
+
+// camtParty holds a party name; camt.053.001.02 nests it directly, later
+// versions wrap the party in <Pty>
+type camtParty struct {
+	Name    string `xml:"Nm"`
+	PtyName string `xml:"Pty>Nm"`
+}
+
+func (p camtParty) name() string {
+	return firstNonEmpty(p.Name, p.PtyName)
+}
+
+type camtPartyAccount struct {
+	IBAN  string `xml:"Id>IBAN"`
+	Other string `xml:"Id>Othr>Id"`
+}
+
+type camtTxDetails struct {
+	ServicerRef string     `xml:"Refs>AcctSvcrRef"`
+	EndToEndID  string     `xml:"Refs>EndToEndId"`
+	TxID        string     `xml:"Refs>TxId"`
+	Amount      camtAmount `xml:"Amt"`
+	Sign        string     `xml:"CdtDbtInd"`
+
+	Debtor           camtParty        `xml:"RltdPties>Dbtr"`
+	DebtorAccount    camtPartyAccount `xml:"RltdPties>DbtrAcct"`
+	Creditor         camtParty        `xml:"RltdPties>Cdtr"`
+	CreditorAccount  camtPartyAccount `xml:"RltdPties>CdtrAcct"`
+	Unstructured     []string         `xml:"RmtInf>Ustrd"`
+	StructuredRef    []string         `xml:"RmtInf>Strd>CdtrRefInf>Ref"`
+	AdditionalTxInfo string           `xml:"AddtlTxInf"`
+}
+
+// ParseCamt053 parses an ISO 20022 camt.053 end-of-day statement. Each entry
+// becomes a transaction (batched entries with per-transaction amounts are
+// split), and the opening/closing balances become account hints.
+func ParseCamt053(r io.Reader) (*ParsedStatement, error) {
+	var doc camtDocument
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid camt.053 XML: %w", err)
+	}
+	if len(doc.Statements) == 0 {
+		return nil, fmt.Errorf("no statement found in camt.053 file")
+	}
+
+	result := &ParsedStatement{}
+	lineNumber := 0
+	for _, stmt := range doc.Statements {
+		accountID := stmt.Account.IBAN
+		if accountID == "" {
+			accountID = stmt.Account.Other
+		}
+		bankName := stmt.Account.BankName
+		if bankName == "" {
+			bankName = firstNonEmpty(stmt.Account.BIC, stmt.Account.BICFI)
+		}
+		tailNumber := statementTailNumber(accountID)
+		accountName := statementAccountName(bankName, tailNumber)
+		if accountName == "" {
+			accountName = stmt.Account.Name
+		}
+		currency := strings.ToUpper(stmt.Account.Currency)
+
+		for _, bal := range stmt.Balances {
+			balanceType, ok := camtBalanceType(bal.Code)
+			if !ok {
+				continue
+			}
+			amount, err := strconv.ParseFloat(strings.TrimSpace(bal.Amount.Value), 64)
+			if err != nil {
+				continue
+			}
+			if bal.Sign == "DBIT" {
+				amount = -amount
+			}
+			hint := models.AccountHint{
+				Source:        models.ImportSourceCamt053,
+				AccountName:   accountName,
+				AccountNumber: tailNumber,
+				BankName:      bankName,
+				CardType:      "借记卡",
+				AccountType:   models.AccountTypeBank,
+				Balance:       amount,
+				BalanceType:   balanceType,
+				Currency:      firstNonEmpty(strings.ToUpper(bal.Amount.Currency), currency),
+			}
+			if t, err := parseCamtDate(bal.Date); err == nil {
+				hint.BalanceDate = &t
+			}
+			result.AccountHints = append(result.AccountHints, hint)
+		}
+
+		for _, entry := range stmt.Entries {
+			// Pending and informational entries have not been booked yet
+			status := firstNonEmpty(entry.Status.Code, strings.TrimSpace(entry.Status.Value))
+			if status != "" && status != "BOOK" {
+				continue
+			}
+
+			details := entry.Details
+			if len(details) <= 1 || !camtDetailsHaveAmounts(details) {
+				// Single transaction (or a batch without split amounts): the entry is authoritative
+				var d camtTxDetails
+				if len(details) > 0 {
+					d = details[0]
+				}
+				d.Amount, d.Sign = entry.Amount, entry.Sign
+				details = []camtTxDetails{d}
+			}
+
+			for _, d := range details {
+				lineNumber++
+				tx, ok := buildCamtTransaction(entry, d, accountID, currency)
+				if !ok {
+					continue
+				}
+				tx.LineNumber = lineNumber
+				tx.AccountName = accountName
+				tx.ParsedAccountType = "debit_card"
+				tx.ParsedAccountNumber = tailNumber
+				tx.ParsedBankName = bankName
+				tx.ParsedCardType = "借记卡"
+				tx.HasAccountHint = tailNumber != ""
+				result.Transactions = append(result.Transactions, tx)
+			}
+		}
+	}
+
+	if len(result.Transactions) == 0 && len(result.AccountHints) == 0 {
+		return nil, fmt.Errorf("no entries found in camt.053 file")
+	}
+
+	return result, nil
+}
+
+// buildCamtTransaction maps one entry (or one TxDtls of a batched entry)
+func buildCamtTransaction(entry camtEntry, d camtTxDetails, accountID, currency string) (models.ParsedTransaction, bool) {
+	tx := models.ParsedTransaction{
+		RawData:  make(map[string]string),
+		Source:   models.ImportSourceCamt053,
+		Currency: firstNonEmpty(strings.ToUpper(d.Amount.Currency), currency),
+	}
+
+	amount, err := strconv.ParseFloat(strings.TrimSpace(d.Amount.Value), 64)
+	if err != nil || amount == 0 {
+		return tx, false
+	}
+	tx.Amount = amount
+
+	// CdtDbtInd is the booked direction, also for reversals
+	credit := d.Sign == "CRDT"
+	if credit {
+		tx.Type = models.TransactionTypeIncome
+	} else {
+		tx.Type = models.TransactionTypeExpense
+	}
+
+	booking, err := parseCamtDate(entry.BookingDate)
+	if err != nil {
+		return tx, false
+	}
+	tx.TransactionDate = booking
+	if value, err := parseCamtDate(entry.ValueDate); err == nil && !value.Equal(booking) {
+		tx.ValueDate = &value
+	}
+
+	// The counterparty is the other side of the original payment: the debtor of
+	// a credit and the creditor of a debit. A reversal keeps the original parties.
+	if credit != entry.Reversal {
+		tx.Counterparty = d.Debtor.name()
+		tx.RelatedAccountNumber = firstNonEmpty(d.DebtorAccount.IBAN, d.DebtorAccount.Other)
+	} else {
+		tx.Counterparty = d.Creditor.name()
+		tx.RelatedAccountNumber = firstNonEmpty(d.CreditorAccount.IBAN, d.CreditorAccount.Other)
+	}
+
+	tx.Note = strings.TrimSpace(strings.Join(d.Unstructured, " "))
+	if tx.Note == "" {
+		tx.Note = strings.TrimSpace(strings.Join(d.StructuredRef, " "))
+	}
+	if tx.Note == "" {
+		tx.Note = firstNonEmpty(strings.TrimSpace(d.AdditionalTxInfo), strings.TrimSpace(entry.AdditionalInf), tx.Counterparty)
+	}
+
+	// Bank reference: transaction level first, since batched entries share the entry reference
+	reference := d.ServicerRef
+	if reference == "" && len(entry.Details) <= 1 {
+		reference = firstNonEmpty(entry.ServicerRef, entry.Reference)
+	}
+	if reference == "" && entry.ServicerRef != "" {
+		reference = entry.ServicerRef + "/" + firstNonEmpty(d.TxID, d.EndToEndID)
+	}
+	if reference != "" {
+		tx.ExternalID = statementExternalID(accountID, reference)
+	}
+
+	tx.RawData["AcctSvcrRef"] = firstNonEmpty(d.ServicerRef, entry.ServicerRef)
+	tx.RawData["NtryRef"] = entry.Reference
+	tx.RawData["EndToEndId"] = d.EndToEndID
+	tx.RawData["CdtDbtInd"] = d.Sign
+
+	tx.CategoryHint = strings.Join([]string{tx.Counterparty, tx.Note}, " ")
+	return tx, true
+}
+
+func camtDetailsHaveAmounts(details []camtTxDetails) bool {
+	for _, d := range details {
+		if strings.TrimSpace(d.Amount.Value) == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// camtBalanceType maps ISO 20022 balance codes to opening/closing balances.
+// Only booked balances are used; available balances are ignored.
+func camtBalanceType(code string) (models.BalanceType, bool) {
+	switch code {
+	case "OPBD", "PRCD":
+		return models.BalanceTypeOpening, true
+	case "CLBD":
+		return models.BalanceTypeClosing, true
+	}
+	return "", false
+}
+
+// parseCamtDate parses an ISODate or ISODateTime choice element
+func parseCamtDate(d camtDate) (time.Time, error) {
+	if s := strings.TrimSpace(d.Date); s != "" {
+		return time.Parse("2006-01-02", s)
+	}
+	s := strings.TrimSpace(d.DateTime)
+	if s == "" {
+		return time.Time{}, fmt.Errorf("empty camt date")
+	}
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unable to parse camt date: %s", s)
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,220 @@
+package services
+
+import (
+	"account/internal/business/models"
+	"account/internal/data/repository"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// ErrInvalidCategoryRule is returned for rules that cannot be saved or tested
+var ErrInvalidCategoryRule = errors.New("invalid category rule")
+
+// ruleTestHistoryLimit bounds the number of past transactions a dry run scans
+const ruleTestHistoryLimit = 5000
+
+type CategoryRuleService struct {
+	ruleRepo        *repository.CategoryRuleRepository
+	categoryRepo    *repository.CategoryRepository
+	accountRepo     *repository.AccountRepository
+	transactionRepo *repository.TransactionRepository
+}
+
+func NewCategoryRuleService(
+	ruleRepo *repository.CategoryRuleRepository,
+	categoryRepo *repository.CategoryRepository,
+	accountRepo *repository.AccountRepository,
+	transactionRepo *repository.TransactionRepository,
+) *CategoryRuleService {
+	return &CategoryRuleService{
+		ruleRepo:        ruleRepo,
+		categoryRepo:    categoryRepo,
+		accountRepo:     accountRepo,
+		transactionRepo: transactionRepo,
+	}
+}
+
+type CategoryRuleRequest struct {
+	Name       string                `json:"name" binding:"required"`
+	Priority   int                   `json:"priority"`
+	Enabled    *bool                 `json:"enabled"`
+	MatchMode  models.RuleMatchMode  `json:"match_mode"`
+	Conditions models.RuleConditions `json:"conditions"`
+	Actions    models.RuleActions    `json:"actions"`
+}
+
+type ReorderCategoryRulesRequest struct {
+	RuleIDs []uuid.UUID `json:"rule_ids" binding:"required,min=1"`
+}
+
+// RuleTestMatch is one past transaction matched by a rule in a dry run
+type RuleTestMatch struct {
+	Transaction   models.Transaction `json:"transaction"`
+	NewCategoryID *uuid.UUID         `json:"new_category_id,omitempty"`
+	NewNote       string             `json:"new_note,omitempty"`
+	NewAccountID  *uuid.UUID         `json:"new_account_id,omitempty"`
+	WouldChange   bool               `json:"would_change"`
+}
+
+// RuleTestResult summarises a dry run of a rule against the user's history
+type RuleTestResult struct {
+	Checked     int             `json:"checked"`
+	Matched     int             `json:"matched"`
+	WouldChange int             `json:"would_change"`
+	Matches     []RuleTestMatch `json:"matches"`
+}
+
+func (s *CategoryRuleService) CreateRule(userID uuid.UUID, req *CategoryRuleRequest) (*models.CategoryRule, error) {
+	rule, err := s.buildRule(userID, req)
+	if err != nil {
+		return nil, err
+	}
+
+	created, err := s.ruleRepo.Create(rule)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create category rule: %w", err)
+	}
+
+	return created, nil
+}
+
+func (s *CategoryRuleService) GetRule(id uuid.UUID, userID uuid.UUID) (*models.CategoryRule, error) {
+	return s.ruleRepo.GetByID(id, userID)
+}
+
+func (s *CategoryRuleService) GetAllRules(userID uuid.UUID) ([]models.CategoryRule, error) {
+	return s.ruleRepo.GetAll(userID)
+}
+
+func (s *CategoryRuleService) UpdateRule(id uuid.UUID, userID uuid.UUID, req *CategoryRuleRequest) (*models.CategoryRule, error) {
+	existing, err := s.ruleRepo.GetByID(id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	rule, err := s.buildRule(userID, req)
+	if err != nil {
+		return nil, err
+	}
+	rule.ID = existing.ID
+	rule.CreatedAt = existing.CreatedAt
+	if req.Enabled == nil {
+		rule.Enabled = existing.Enabled
+	}
+
+	return s.ruleRepo.Update(rule, userID)
+}
+
+func (s *CategoryRuleService) DeleteRule(id uuid.UUID, userID uuid.UUID) error {
+	return s.ruleRepo.Delete(id, userID)
+}
+
+// ReorderRules sets the evaluation order; the first rule gets the highest priority
+func (s *CategoryRuleService) ReorderRules(userID uuid.UUID, req *ReorderCategoryRulesRequest) ([]models.CategoryRule, error) {
+	if err := s.ruleRepo.Reorder(userID, req.RuleIDs); err != nil {
+		return nil, err
+	}
+	return s.ruleRepo.GetAll(userID)
+}
+
+// TestRule runs a rule against the user's past transactions without changing them.
+// Stored transactions keep no counterparty, so counterparty conditions are
+// matched against the note, where imports put the merchant name.
+func (s *CategoryRuleService) TestRule(userID uuid.UUID, req *CategoryRuleRequest) (*RuleTestResult, error) {
+	rule, err := s.buildRule(userID, req)
+	if err != nil {
+		return nil, err
+	}
+	rule.ID = uuid.New()
+	rule.Enabled = true
+	engine := NewRuleEngine([]models.CategoryRule{*rule})
+
+	history, err := s.transactionRepo.GetAll(userID, ruleTestHistoryLimit, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	accountNames := make(map[uuid.UUID]string)
+	if accounts, err := s.accountRepo.GetAll(userID); err == nil {
+		for _, a := range accounts {
+			accountNames[a.ID] = a.Name
+		}
+	}
+
+	result := &RuleTestResult{Checked: len(history), Matches: []RuleTestMatch{}}
+	for _, t := range history {
+		outcome := engine.Evaluate(RuleInputFromTransaction(t, accountNames[t.AccountID]))
+		if len(outcome.RuleIDs) == 0 {
+			continue
+		}
+
+		match := RuleTestMatch{
+			Transaction:   t,
+			NewCategoryID: outcome.CategoryID,
+			NewAccountID:  outcome.AccountID,
+		}
+		if outcome.NoteChanged {
+			match.NewNote = outcome.Note
+		}
+		match.WouldChange = (outcome.CategoryID != nil && (t.CategoryID == nil || *t.CategoryID != *outcome.CategoryID)) ||
+			(outcome.NoteChanged && outcome.Note != t.Note) ||
+			(outcome.AccountID != nil && *outcome.AccountID != t.AccountID)
+
+		result.Matched++
+		if match.WouldChange {
+			result.WouldChange++
+		}
+		result.Matches = append(result.Matches, match)
+	}
+
+	return result, nil
+}
+
+// loadRuleEngine returns an engine with the user's enabled rules
+func loadRuleEngine(ruleRepo *repository.CategoryRuleRepository, userID uuid.UUID) (*RuleEngine, error) {
+	rules, err := ruleRepo.GetAll(userID)
+	if err != nil {
+		return nil, err
+	}
+	return NewRuleEngine(rules), nil
+}
+
+// buildRule validates a request and checks that the referenced category and accounts belong to the user
+func (s *CategoryRuleService) buildRule(userID uuid.UUID, req *CategoryRuleRequest) (*models.CategoryRule, error) {
+	rule := &models.CategoryRule{
+		UserID:     userID,
+		Name:       req.Name,
+		Priority:   req.Priority,
+		Enabled:    req.Enabled == nil || *req.Enabled,
+		MatchMode:  req.MatchMode,
+		Conditions: req.Conditions,
+		Actions:    req.Actions,
+	}
+	if rule.MatchMode == "" {
+		rule.MatchMode = models.RuleMatchContains
+	}
+	if rule.Priority == 0 {
+		rule.Priority = 100
+	}
+
+	if err := ValidateRule(*rule); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCategoryRule, err)
+	}
+	if id := rule.Actions.CategoryID; id != nil {
+		if _, err := s.categoryRepo.GetByID(*id, userID); err != nil {
+			return nil, fmt.Errorf("%w: category: %v", ErrInvalidCategoryRule, err)
+		}
+	}
+	for _, id := range []*uuid.UUID{rule.Actions.AccountID, rule.Conditions.AccountID} {
+		if id == nil {
+			continue
+		}
+		if _, err := s.accountRepo.GetByID(*id, userID); err != nil {
+			return nil, fmt.Errorf("%w: account: %v", ErrInvalidCategoryRule, err)
+		}
+	}
+
+	return rule, nil
+}
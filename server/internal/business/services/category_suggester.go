@@ -0,0 +1,362 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"account/internal/business/models"
+	"account/internal/data/repository"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+const (
+	suggesterTrainingLimit = 20000         // 训练使用的最近交易数
+	suggesterModelTTL      = 6 * time.Hour // 超时后重新训练，以包含同步写入的交易
+	suggesterMinExamples   = 5             // 样本太少时不给建议
+	suggestionTopK         = 3
+	suggestionPrefillScore = 0.6 // 置信度达到该值时预填分类
+)
+
+// CategorySuggester 基于用户自己已分类交易的分类建议（多项式朴素贝叶斯）
+// 模型按用户和收支类型分别训练，首次使用时从历史交易训练，之后随用户修改增量更新
+type CategorySuggester struct {
+	transactionRepo *repository.TransactionRepository
+	logger          *zap.Logger
+
+	mu     sync.Mutex // 只保护 models；训练和预测持有各用户自己的锁
+	models map[uuid.UUID]*userCategoryModel
+}
+
+// NewCategorySuggester 创建分类建议器
+func NewCategorySuggester(transactionRepo *repository.TransactionRepository, logger *zap.Logger) *CategorySuggester {
+	return &CategorySuggester{
+		transactionRepo: transactionRepo,
+		logger:          logger,
+		models:          make(map[uuid.UUID]*userCategoryModel),
+	}
+}
+
+// CategoryExample 一条训练样本或待预测交易
+// 交易对方以规整后的商户表示：已保存的交易只留下了商户，训练和预测须使用相同的特征
+type CategoryExample struct {
+	Type    models.TransactionType
+	PayeeID *uuid.UUID
+	Note    string
+	Amount  float64
+	Date    time.Time
+}
+
+// userCategoryModel 一个用户的模型，收入和支出各一个分类器
+type userCategoryModel struct {
+	mu          sync.Mutex
+	trainedAt   time.Time // 零值表示尚未训练
+	classifiers map[models.TransactionType]*NaiveBayesClassifier
+}
+
+// SuggestForImport 为导入预览中的交易填充分类建议
+// 规则或用户已选定分类的交易只给出建议，不覆盖选择
+func (s *CategorySuggester) SuggestForImport(userID uuid.UUID, transactions []models.ParsedTransaction) {
+	model := s.userModel(userID)
+	model.mu.Lock()
+	defer model.mu.Unlock()
+
+	if !s.loadModel(userID, model) {
+		return
+	}
+	for i := range transactions {
+		tx := &transactions[i]
+		classifier := model.classifiers[tx.Type]
+		if classifier == nil {
+			continue
+		}
+		tx.CategorySuggestions = classifier.Predict(CategoryFeatures(CategoryExample{
+			Type:    tx.Type,
+			PayeeID: tx.PayeeID,
+			Note:    tx.Note,
+			Amount:  tx.Amount,
+			Date:    tx.TransactionDate,
+		}), suggestionTopK)
+		if len(tx.CategorySuggestions) > 0 && tx.SelectedCategoryID == nil &&
+			tx.CategorySuggestions[0].Confidence >= suggestionPrefillScore {
+			id := tx.CategorySuggestions[0].CategoryID
+			tx.SelectedCategoryID = &id
+		}
+	}
+}
+
+// Learn 增量学习一条已分类交易
+func (s *CategorySuggester) Learn(userID uuid.UUID, example CategoryExample, categoryID uuid.UUID) {
+	s.update(userID, example, categoryID, 1)
+}
+
+// Forget 撤销一条交易的学习（交易被删除或改了分类）
+func (s *CategorySuggester) Forget(userID uuid.UUID, example CategoryExample, categoryID uuid.UUID) {
+	s.update(userID, example, categoryID, -1)
+}
+
+// update 只更新已加载的模型；未加载的模型下次使用时会从数据库完整训练
+func (s *CategorySuggester) update(userID uuid.UUID, example CategoryExample, categoryID uuid.UUID, delta int) {
+	s.mu.Lock()
+	model, ok := s.models[userID]
+	s.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	model.mu.Lock()
+	defer model.mu.Unlock()
+	if model.trainedAt.IsZero() {
+		return
+	}
+	classifier := model.classifiers[example.Type]
+	if classifier == nil {
+		classifier = NewNaiveBayesClassifier()
+		model.classifiers[example.Type] = classifier
+	}
+	classifier.Update(CategoryFeatures(example), categoryID, delta)
+}
+
+// userModel returns the user's model entry, creating an untrained one on first use
+func (s *CategorySuggester) userModel(userID uuid.UUID) *userCategoryModel {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	model, ok := s.models[userID]
+	if !ok {
+		model = &userCategoryModel{}
+		s.models[userID] = model
+	}
+	return model
+}
+
+// loadModel trains the model from history when untrained or stale, reporting
+// whether it can be used. Callers hold model.mu, so training one user's model
+// does not hold up the others.
+func (s *CategorySuggester) loadModel(userID uuid.UUID, model *userCategoryModel) bool {
+	if !model.trainedAt.IsZero() && time.Since(model.trainedAt) < suggesterModelTTL {
+		return true
+	}
+
+	history, err := s.transactionRepo.GetAll(userID, suggesterTrainingLimit, 0)
+	if err != nil {
+		s.logger.Warn("Failed to load training transactions", zap.Error(err))
+		// A stale model is still better than none
+		return !model.trainedAt.IsZero()
+	}
+
+	classifiers := make(map[models.TransactionType]*NaiveBayesClassifier)
+	for _, t := range history {
+		if t.CategoryID == nil {
+			continue
+		}
+		classifier := classifiers[t.Type]
+		if classifier == nil {
+			classifier = NewNaiveBayesClassifier()
+			classifiers[t.Type] = classifier
+		}
+		classifier.Update(CategoryFeatures(ExampleFromTransaction(t)), *t.CategoryID, 1)
+	}
+	model.classifiers = classifiers
+	model.trainedAt = time.Now()
+	return true
+}
+
+// ExampleFromTransaction 从已保存的交易构建样本
+func ExampleFromTransaction(t models.Transaction) CategoryExample {
+	return CategoryExample{
+		Type:    t.Type,
+		PayeeID: t.PayeeID,
+		Note:    t.Note,
+		Amount:  t.Amount,
+		Date:    t.TransactionDate,
+	}
+}
+
+// CategoryFeatures 提取特征：商户、备注的分词、金额区间、时段
+func CategoryFeatures(example CategoryExample) []string {
+	var features []string
+	if example.PayeeID != nil {
+		features = append(features, "payee:"+example.PayeeID.String())
+	}
+	for _, token := range TokenizeText(example.Note) {
+		features = append(features, "w:"+token)
+	}
+	if example.Amount > 0 {
+		// 对数区间：<10, 10-31, 31-100, 100-316 ...
+		bucket := int(math.Floor(math.Log10(example.Amount) * 2))
+		features = append(features, fmt.Sprintf("amt:%d", bucket))
+	}
+	if !example.Date.IsZero() {
+		if hour := example.Date.Hour(); hour != 0 || example.Date.Minute() != 0 {
+			// 手工录入的交易通常没有时间，只对带时间的交易使用时段特征
+			features = append(features, "tod:"+timeOfDay(hour))
+		}
+	}
+	return features
+}
+
+func timeOfDay(hour int) string {
+	switch {
+	case hour < 6:
+		return "night"
+	case hour < 10:
+		return "morning"
+	case hour < 14:
+		return "noon"
+	case hour < 18:
+		return "afternoon"
+	default:
+		return "evening"
+	}
+}
+
+// TokenizeText 中英文混合分词：英文按单词，中文按相邻二字切分（单字词保留）
+// 数字（订单号、日期）不作为特征
+func TokenizeText(text string) []string {
+	var tokens []string
+	var han []rune
+	var word []rune
+
+	flushHan := func() {
+		switch len(han) {
+		case 0:
+		case 1:
+			tokens = append(tokens, string(han))
+		default:
+			for i := 0; i+1 < len(han); i++ {
+				tokens = append(tokens, string(han[i:i+2]))
+			}
+		}
+		han = han[:0]
+	}
+	flushWord := func() {
+		if len(word) > 1 {
+			tokens = append(tokens, string(word))
+		}
+		word = word[:0]
+	}
+
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			flushWord()
+			han = append(han, r)
+		case unicode.IsLetter(r):
+			flushHan()
+			word = append(word, r)
+		default:
+			flushHan()
+			flushWord()
+		}
+	}
+	flushHan()
+	flushWord()
+	return tokens
+}
+
+// NaiveBayesClassifier 支持增量更新的多项式朴素贝叶斯分类器
+type NaiveBayesClassifier struct {
+	docCounts     map[uuid.UUID]int
+	featureCounts map[uuid.UUID]map[string]int
+	totalFeatures map[uuid.UUID]int
+	vocabulary    map[string]int
+	totalDocs     int
+}
+
+// NewNaiveBayesClassifier 创建分类器
+func NewNaiveBayesClassifier() *NaiveBayesClassifier {
+	return &NaiveBayesClassifier{
+		docCounts:     make(map[uuid.UUID]int),
+		featureCounts: make(map[uuid.UUID]map[string]int),
+		totalFeatures: make(map[uuid.UUID]int),
+		vocabulary:    make(map[string]int),
+	}
+}
+
+// Update 增加（delta=1）或撤销（delta=-1）一条样本
+func (c *NaiveBayesClassifier) Update(features []string, categoryID uuid.UUID, delta int) {
+	if delta < 0 && c.docCounts[categoryID] == 0 {
+		return
+	}
+	c.docCounts[categoryID] += delta
+	c.totalDocs += delta
+	if c.featureCounts[categoryID] == nil {
+		c.featureCounts[categoryID] = make(map[string]int)
+	}
+	for _, f := range features {
+		c.featureCounts[categoryID][f] += delta
+		c.totalFeatures[categoryID] += delta
+		c.vocabulary[f] += delta
+		if c.vocabulary[f] <= 0 {
+			delete(c.vocabulary, f)
+		}
+		if c.featureCounts[categoryID][f] <= 0 {
+			delete(c.featureCounts[categoryID], f)
+		}
+	}
+	if c.docCounts[categoryID] <= 0 {
+		delete(c.docCounts, categoryID)
+		delete(c.featureCounts, categoryID)
+		delete(c.totalFeatures, categoryID)
+	}
+}
+
+// Predict 返回后验概率最高的 k 个分类
+func (c *NaiveBayesClassifier) Predict(features []string, k int) []models.CategorySuggestion {
+	if c.totalDocs < suggesterMinExamples || len(c.docCounts) == 0 {
+		return nil
+	}
+
+	// Features never seen in training carry no information
+	known := features[:0:0]
+	for _, f := range features {
+		if c.vocabulary[f] > 0 {
+			known = append(known, f)
+		}
+	}
+	if len(known) == 0 {
+		return nil
+	}
+
+	vocab := float64(len(c.vocabulary))
+	scores := make([]models.CategorySuggestion, 0, len(c.docCounts))
+	maxLog := math.Inf(-1)
+	for categoryID, docs := range c.docCounts {
+		logProb := math.Log(float64(docs) / float64(c.totalDocs))
+		denominator := float64(c.totalFeatures[categoryID]) + vocab
+		for _, f := range known {
+			logProb += math.Log((float64(c.featureCounts[categoryID][f]) + 1) / denominator)
+		}
+		scores = append(scores, models.CategorySuggestion{CategoryID: categoryID, Confidence: logProb})
+		if logProb > maxLog {
+			maxLog = logProb
+		}
+	}
+
+	// Normalise log probabilities into posteriors
+	var sum float64
+	for i := range scores {
+		scores[i].Confidence = math.Exp(scores[i].Confidence - maxLog)
+		sum += scores[i].Confidence
+	}
+	for i := range scores {
+		scores[i].Confidence = math.Round(scores[i].Confidence/sum*1000) / 1000
+	}
+
+	sort.Slice(scores, func(i, j int) bool {
+		if scores[i].Confidence != scores[j].Confidence {
+			return scores[i].Confidence > scores[j].Confidence
+		}
+		return scores[i].CategoryID.String() < scores[j].CategoryID.String()
+	})
+	if k > 0 && len(scores) > k {
+		scores = scores[:k]
+	}
+	return scores
+}
@@ -0,0 +1,113 @@
+package services
+
+import (
+	"account/internal/business/models"
+	"account/internal/data/repository"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrNotCreditAccount is returned when bills are recorded on a non-credit account
+	ErrNotCreditAccount = errors.New("account is not a credit card account")
+	// ErrInvalidCreditCardBill is returned for a bill with inconsistent dates or amounts
+	ErrInvalidCreditCardBill = errors.New("invalid credit card bill")
+)
+
+type CreditCardService struct {
+	accountRepo *repository.AccountRepository
+	billRepo    *repository.CreditCardBillRepository
+}
+
+func NewCreditCardService(accountRepo *repository.AccountRepository, billRepo *repository.CreditCardBillRepository) *CreditCardService {
+	return &CreditCardService{
+		accountRepo: accountRepo,
+		billRepo:    billRepo,
+	}
+}
+
+// SaveBillRequest is a credit card statement's bill for one cycle
+type SaveBillRequest struct {
+	StatementDate    time.Time `json:"statement_date" binding:"required"`
+	DueDate          time.Time `json:"due_date" binding:"required"`
+	StatementBalance float64   `json:"statement_balance"`
+	MinimumPayment   float64   `json:"minimum_payment"`
+}
+
+// SaveBill records a bill for a credit account, replacing the bill of the same statement date
+func (s *CreditCardService) SaveBill(userID uuid.UUID, accountID uuid.UUID, req *SaveBillRequest) (*models.CreditCardBill, error) {
+	if err := s.checkCreditAccount(userID, accountID); err != nil {
+		return nil, err
+	}
+	if startOfDay(req.DueDate).Before(startOfDay(req.StatementDate)) {
+		return nil, fmt.Errorf("%w: due date is before the statement date", ErrInvalidCreditCardBill)
+	}
+	if req.StatementBalance < 0 || req.MinimumPayment < 0 || req.MinimumPayment > req.StatementBalance {
+		return nil, fmt.Errorf("%w: minimum payment must be between zero and the statement balance", ErrInvalidCreditCardBill)
+	}
+
+	return s.billRepo.Save(&models.CreditCardBill{
+		UserID:           userID,
+		AccountID:        accountID,
+		StatementDate:    startOfDay(req.StatementDate),
+		DueDate:          startOfDay(req.DueDate),
+		StatementBalance: req.StatementBalance,
+		MinimumPayment:   req.MinimumPayment,
+		Status:           models.CreditCardBillUnpaid,
+	})
+}
+
+// GetBills returns a credit account's bills, newest first, with how much has been repaid
+func (s *CreditCardService) GetBills(userID uuid.UUID, accountID uuid.UUID) ([]models.CreditCardBill, error) {
+	if err := s.checkCreditAccount(userID, accountID); err != nil {
+		return nil, err
+	}
+	bills, err := s.billRepo.GetByAccount(userID, accountID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range bills {
+		bills[i].Status = creditCardBillStatus(&bills[i])
+	}
+	return bills, nil
+}
+
+func (s *CreditCardService) DeleteBill(userID uuid.UUID, accountID uuid.UUID, billID uuid.UUID) error {
+	return s.billRepo.Delete(billID, accountID, userID)
+}
+
+// MatchRepayment finds the bill a repayment into the credit account pays, without
+// recording it. It returns nil when no open bill covers the repayment date.
+func (s *CreditCardService) MatchRepayment(userID uuid.UUID, accountID uuid.UUID, date time.Time, amount float64) (*models.BillRepayment, error) {
+	bills, err := s.billRepo.GetByAccount(userID, accountID)
+	if err != nil {
+		return nil, err
+	}
+	return MatchBillRepayment(bills, date, amount), nil
+}
+
+// ApplyRepayment records the incoming half of a repayment against the bill it pays
+func (s *CreditCardService) ApplyRepayment(userID uuid.UUID, repayment *models.Transaction) (*models.BillRepayment, error) {
+	matched, err := s.MatchRepayment(userID, repayment.AccountID, repayment.TransactionDate, repayment.Amount)
+	if err != nil || matched == nil {
+		return nil, err
+	}
+	if err := s.billRepo.AddRepayment(userID, matched.BillID, repayment.ID, repayment.Amount); err != nil {
+		return nil, err
+	}
+	return matched, nil
+}
+
+func (s *CreditCardService) checkCreditAccount(userID uuid.UUID, accountID uuid.UUID) error {
+	account, err := s.accountRepo.GetByID(accountID, userID)
+	if err != nil {
+		return err
+	}
+	if account.Type != models.AccountTypeCredit {
+		return ErrNotCreditAccount
+	}
+	return nil
+}
@@ -0,0 +1,273 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"account/internal/business/models"
+	"github.com/google/uuid"
+)
+
+// DuplicateDetector 跨来源重复检测器
+// 同一笔消费用支付宝/微信/京东绑定的银行卡支付时，会同时出现在平台账单和银行流水中
+type DuplicateDetector struct {
+	amountTolerance float64       // 金额容差（默认0.01）
+	before          time.Duration // 银行记账可早于平台时间（时区、日切差异）
+	after           time.Duration // 银行记账可晚于平台时间（T+1、周末入账）
+	threshold       float64       // 判定为重复的最低置信度
+	noteMerger      *NoteMergeService
+}
+
+// NewDuplicateDetector 创建重复检测器
+func NewDuplicateDetector() *DuplicateDetector {
+	return &DuplicateDetector{
+		amountTolerance: 0.01,
+		before:          24 * time.Hour,
+		after:           72 * time.Hour,
+		threshold:       0.6,
+		noteMerger:      NewNoteMergeService(),
+	}
+}
+
+// 支付方式中的卡尾号，如 "招商银行储蓄卡(1234)"、"工商银行信用卡（5678）"
+var paymentMethodTailPattern = regexp.MustCompile(`[(（]\s*(\d{4})\s*[)）]|(\d{4})\s*$`)
+
+// 平台余额类支付方式，不经过银行卡，不会出现在银行流水中
+var appBalanceKeywords = []string{"余额", "零钱", "花呗", "白条", "小金库", "理财通", "亲属卡", "红包"}
+
+// 银行流水中代表各支付平台的摘要关键词
+var appChannelKeywords = map[models.ImportSource][]string{
+	models.ImportSourceAlipay: {"支付宝", "alipay", "网联", "蚂蚁"},
+	models.ImportSourceWeChat: {"财付通", "微信", "tenpay", "wechat", "网联"},
+	models.ImportSourceJD:     {"京东", "网银在线", "jd.com", "jdpay", "网联"},
+}
+
+// isAppSource 支付平台账单来源
+func isAppSource(source models.ImportSource) bool {
+	switch source {
+	case models.ImportSourceAlipay, models.ImportSourceWeChat, models.ImportSourceJD:
+		return true
+	}
+	return false
+}
+
+// isBankSource 银行流水来源
+func isBankSource(source models.ImportSource) bool {
+	switch source {
+	case models.ImportSourceBank, models.ImportSourceOFX, models.ImportSourceCamt053, models.ImportSourceMT940:
+		return true
+	}
+	return false
+}
+
+// paymentCardTail 从平台账单的支付方式中提取银行卡尾号
+// 余额类支付返回 ok=false；未写明尾号时返回空尾号
+func paymentCardTail(paymentMethod string) (tail string, ok bool) {
+	for _, kw := range appBalanceKeywords {
+		if strings.Contains(paymentMethod, kw) {
+			return "", false
+		}
+	}
+	if m := paymentMethodTailPattern.FindStringSubmatch(paymentMethod); m != nil {
+		return firstNonEmpty(m[1], m[2]), true
+	}
+	return "", true
+}
+
+// bankAccountTail 银行流水所属账户尾号
+func bankAccountTail(tx models.ParsedTransaction) string {
+	number := normalizeTailNumber(firstNonEmpty(tx.ParsedAccountNumber, tx.AccountNumber, tx.AccountName))
+	if len(number) > 4 {
+		number = number[len(number)-4:]
+	}
+	return number
+}
+
+// mergedDuplicateWarning 银行记录已合并到平台记录时的提示
+func mergedDuplicateWarning(appSource models.ImportSource) string {
+	return fmt.Sprintf("已与%s账单中的同一笔交易合并", appSource)
+}
+
+// FindDuplicates 在平台账单与银行流水之间查找同一笔交易
+// 依据：金额一致、时间窗口、支付方式尾号与银行账户尾号、商户名称相似度
+func (d *DuplicateDetector) FindDuplicates(transactions []models.ParsedTransaction) []models.CrossSourceDuplicate {
+	var appIdx, bankIdx []int
+	for i, tx := range transactions {
+		if isAppSource(tx.Source) {
+			appIdx = append(appIdx, i)
+		} else if isBankSource(tx.Source) {
+			bankIdx = append(bankIdx, i)
+		}
+	}
+
+	type candidate struct {
+		app, bank  int
+		confidence float64
+		factors    []string
+	}
+	var candidates []candidate
+	for _, i := range appIdx {
+		for _, j := range bankIdx {
+			confidence, factors, ok := d.calculateConfidence(transactions[i], transactions[j])
+			if ok && confidence >= d.threshold {
+				candidates = append(candidates, candidate{app: i, bank: j, confidence: confidence, factors: factors})
+			}
+		}
+	}
+
+	// 置信度优先，一条记录只参与一个重复对
+	sort.SliceStable(candidates, func(a, b int) bool {
+		return candidates[a].confidence > candidates[b].confidence
+	})
+	usedApp := make(map[int]bool)
+	usedBank := make(map[int]bool)
+	var duplicates []models.CrossSourceDuplicate
+	for _, c := range candidates {
+		if usedApp[c.app] || usedBank[c.bank] {
+			continue
+		}
+		usedApp[c.app] = true
+		usedBank[c.bank] = true
+		duplicates = append(duplicates, d.buildDuplicate(transactions[c.app], transactions[c.bank], c.confidence, c.factors))
+	}
+
+	sort.SliceStable(duplicates, func(a, b int) bool {
+		return duplicates[a].AppTransaction.TransactionDate.Before(duplicates[b].AppTransaction.TransactionDate)
+	})
+	return duplicates
+}
+
+// calculateConfidence 计算平台记录与银行记录为同一笔交易的置信度
+// 金额、方向和时间窗口是必要条件；尾号不一致直接排除
+func (d *DuplicateDetector) calculateConfidence(app, bank models.ParsedTransaction) (float64, []string, bool) {
+	if app.Type != bank.Type || math.Abs(app.Amount-bank.Amount) > d.amountTolerance {
+		return 0, nil, false
+	}
+	diff := bank.TransactionDate.Sub(app.TransactionDate)
+	if diff < -d.before || diff > d.after {
+		return 0, nil, false
+	}
+
+	appTail, viaCard := paymentCardTail(app.AccountName)
+	if !viaCard {
+		return 0, nil, false
+	}
+
+	var confidence float64
+	var factors []string
+
+	// ===== 金额（权重：30%）=====
+	confidence += 0.30
+	factors = append(factors, "金额完全匹配")
+
+	// ===== 卡尾号（权重：40%）=====
+	if tail := bankAccountTail(bank); appTail != "" && tail != "" {
+		if appTail != tail {
+			return 0, nil, false
+		}
+		confidence += 0.40
+		factors = append(factors, "支付方式尾号与银行账户尾号一致")
+	}
+
+	// ===== 时间（权重：15%）=====
+	sameDay := app.TransactionDate.Format("2006-01-02") == bank.TransactionDate.Format("2006-01-02")
+	switch {
+	case sameDay:
+		confidence += 0.15
+		factors = append(factors, "同日入账")
+	case diff > 0:
+		confidence += 0.08
+		factors = append(factors, "银行延后入账")
+	}
+
+	// ===== 银行摘要中的支付渠道（权重：15%）=====
+	bankText := strings.ToLower(bank.Counterparty + " " + bank.Note)
+	for _, kw := range appChannelKeywords[app.Source] {
+		if strings.Contains(bankText, kw) {
+			confidence += 0.15
+			factors = append(factors, "银行摘要包含支付渠道("+kw+")")
+			break
+		}
+	}
+
+	// ===== 商户名称相似度（权重：最高15%）=====
+	if similarity := merchantSimilarity(app.Counterparty, bank.Counterparty+" "+bank.Note); similarity >= 0.3 {
+		confidence += 0.15 * similarity
+		factors = append(factors, "商户名称相似")
+	}
+
+	if confidence > 1 {
+		confidence = 1
+	}
+	return math.Round(confidence*100) / 100, factors, true
+}
+
+// buildDuplicate 生成合并建议：保留平台的商户与商品详情，使用银行的账户信息
+func (d *DuplicateDetector) buildDuplicate(app, bank models.ParsedTransaction, confidence float64, factors []string) models.CrossSourceDuplicate {
+	suggestion := d.noteMerger.MergeNotes(bank.Note, app.Note)
+
+	merged := app
+	merged.Note = suggestion.MergedNote
+	merged.AccountName = bank.AccountName
+	merged.AccountNumber = bank.AccountNumber
+	merged.ParsedAccountType = bank.ParsedAccountType
+	merged.ParsedAccountNumber = bank.ParsedAccountNumber
+	merged.ParsedBankName = bank.ParsedBankName
+	merged.ParsedCardType = bank.ParsedCardType
+	merged.HasAccountHint = bank.HasAccountHint
+	if bank.SelectedAccountID != nil {
+		merged.SelectedAccountID = bank.SelectedAccountID
+	}
+
+	return models.CrossSourceDuplicate{
+		ID:              uuid.New(),
+		AppTransaction:  app,
+		BankTransaction: bank,
+		Confidence:      confidence,
+		MatchFactors:    factors,
+		Merged:          merged,
+		NoteMerge: models.NoteMergeSuggestion{
+			PrimaryNote:   suggestion.PrimaryNote,
+			SecondaryNote: suggestion.SecondaryNote,
+			MergedNote:    suggestion.MergedNote,
+			MergeStrategy: suggestion.MergeStrategy,
+			Reason:        suggestion.Reason,
+		},
+	}
+}
+
+// merchantSimilarity 商户名称相似度：平台商户名的字符二元组在银行摘要中的覆盖率
+// 银行摘要常附加前缀或截断，如 "支付宝-瑞幸咖啡(北京)"，因此不要求两边长度相近
+func merchantSimilarity(appName, bankText string) float64 {
+	appGrams := bigrams(appName)
+	if len(appGrams) == 0 {
+		return 0
+	}
+	bankGrams := bigrams(bankText)
+	hits := 0
+	for g := range appGrams {
+		if bankGrams[g] {
+			hits++
+		}
+	}
+	return float64(hits) / float64(len(appGrams))
+}
+
+func bigrams(text string) map[string]bool {
+	var runes []rune
+	for _, r := range strings.ToLower(text) {
+		if r == ' ' || r == '-' || r == '_' || r == '(' || r == ')' || r == '（' || r == '）' {
+			continue
+		}
+		runes = append(runes, r)
+	}
+	grams := make(map[string]bool)
+	for i := 0; i+1 < len(runes); i++ {
+		grams[string(runes[i:i+2])] = true
+	}
+	return grams
+}
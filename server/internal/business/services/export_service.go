@@ -0,0 +1,276 @@
+package services
+
+import (
+	"account/internal/business/models"
+	"account/internal/data/repository"
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ExportFormat represents a supported export file format
+type ExportFormat string
+
+const (
+	ExportFormatOFX ExportFormat = "ofx"
+	ExportFormatQIF ExportFormat = "qif"
+)
+
+// ExportService exports account transactions to formats other tools can read
+type ExportService struct {
+	transactionRepo *repository.TransactionRepository
+	accountRepo     *repository.AccountRepository
+	categoryRepo    *repository.CategoryRepository
+	transferRepo    *repository.TransferLinkRepository
+}
+
+// NewExportService creates a new ExportService
+func NewExportService(
+	transactionRepo *repository.TransactionRepository,
+	accountRepo *repository.AccountRepository,
+	categoryRepo *repository.CategoryRepository,
+	transferRepo *repository.TransferLinkRepository,
+) *ExportService {
+	return &ExportService{
+		transactionRepo: transactionRepo,
+		accountRepo:     accountRepo,
+		categoryRepo:    categoryRepo,
+		transferRepo:    transferRepo,
+	}
+}
+
+// ExportRequest contains the parameters for an account export
+type ExportRequest struct {
+	AccountID uuid.UUID
+	Format    ExportFormat
+	StartDate time.Time
+	EndDate   time.Time
+}
+
+// ExportFile is a rendered export ready to be downloaded
+type ExportFile struct {
+	FileName    string
+	ContentType string
+	Content     []byte
+}
+
+// ExportAccount renders all transactions of an account in the given date range
+func (s *ExportService) ExportAccount(userID uuid.UUID, req *ExportRequest) (*ExportFile, error) {
+	account, err := s.accountRepo.GetByID(req.AccountID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	transactions, err := s.transactionRepo.GetByAccountAndDateRange(userID, req.AccountID, req.StartDate, req.EndDate)
+	if err != nil {
+		return nil, err
+	}
+	incoming, err := s.incomingTransfers(userID, transactions)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	file := &ExportFile{}
+	baseName := fmt.Sprintf("%s_%s_%s",
+		strings.NewReplacer("/", "_", "\\", "_", " ", "_", "\"", "").Replace(account.Name),
+		req.StartDate.Format("20060102"),
+		req.EndDate.Format("20060102"),
+	)
+
+	switch req.Format {
+	case ExportFormatOFX:
+		if err := WriteOFX(&buf, account, transactions, incoming, time.Now().UTC()); err != nil {
+			return nil, fmt.Errorf("failed to write OFX: %w", err)
+		}
+		file.FileName = baseName + ".ofx"
+		file.ContentType = "application/x-ofx"
+	case ExportFormatQIF:
+		categoryNames := make(map[uuid.UUID]string)
+		if categories, err := s.categoryRepo.GetAll(userID); err == nil {
+			for _, c := range categories {
+				categoryNames[c.ID] = c.Name
+			}
+		}
+		if err := WriteQIF(&buf, account, transactions, incoming, categoryNames); err != nil {
+			return nil, fmt.Errorf("failed to write QIF: %w", err)
+		}
+		file.FileName = baseName + ".qif"
+		file.ContentType = "application/qif"
+	default:
+		return nil, fmt.Errorf("unsupported export format: %s", req.Format)
+	}
+
+	file.Content = buf.Bytes()
+	return file, nil
+}
+
+// incomingTransfers returns the transfer halves that received money, i.e. the
+// to side of their transfer link
+func (s *ExportService) incomingTransfers(userID uuid.UUID, transactions []models.Transaction) (map[uuid.UUID]bool, error) {
+	var ids []uuid.UUID
+	for _, tx := range transactions {
+		if tx.Type == models.TransactionTypeTransfer {
+			ids = append(ids, tx.ID)
+		}
+	}
+	incoming := make(map[uuid.UUID]bool)
+	if len(ids) == 0 {
+		return incoming, nil
+	}
+
+	links, err := s.transferRepo.GetByTransactionIDs(userID, ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, link := range links {
+		incoming[link.ToTransactionID] = true
+	}
+	return incoming, nil
+}
+
+// signedAmount returns the amount as seen from the account: income and
+// incoming transfers positive, everything else negative
+func signedAmount(tx models.Transaction, incoming map[uuid.UUID]bool) float64 {
+	if tx.Type == models.TransactionTypeIncome || (tx.Type == models.TransactionTypeTransfer && incoming[tx.ID]) {
+		return tx.Amount
+	}
+	return -tx.Amount
+}
+
+// WriteOFX writes transactions as an OFX 2.x (XML) statement. Credit accounts
+// are written as credit card statements, all other accounts as checking accounts.
+// incoming holds the transfers received by the account (see signedAmount).
+func WriteOFX(w io.Writer, account *models.Account, transactions []models.Transaction, incoming map[uuid.UUID]bool, asOf time.Time) error {
+	const dateLayout = "20060102150405"
+
+	start, end := asOf, asOf
+	for _, tx := range transactions {
+		if tx.TransactionDate.Before(start) {
+			start = tx.TransactionDate
+		}
+	}
+	if len(transactions) > 0 {
+		end = transactions[0].TransactionDate
+		for _, tx := range transactions {
+			if tx.TransactionDate.After(end) {
+				end = tx.TransactionDate
+			}
+		}
+	}
+
+	currency := account.Currency
+	if currency == "" {
+		currency = "CNY"
+	}
+
+	isCredit := account.Type == models.AccountTypeCredit
+	msgSet, stmtRs, acctFrom := "BANKMSGSRSV1", "STMTRS", "BANKACCTFROM"
+	if isCredit {
+		msgSet, stmtRs, acctFrom = "CREDITCARDMSGSRSV1", "CCSTMTRS", "CCACCTFROM"
+	}
+	trnRs := strings.Replace(stmtRs, "RS", "TRNRS", 1)
+
+	e := html.EscapeString
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="no"?>` + "\n")
+	b.WriteString(`<?OFX OFXHEADER="200" VERSION="220" SECURITY="NONE" OLDFILEUID="NONE" NEWFILEUID="NONE"?>` + "\n")
+	b.WriteString("<OFX>\n")
+	b.WriteString("<SIGNONMSGSRSV1><SONRS>\n")
+	b.WriteString("<STATUS><CODE>0</CODE><SEVERITY>INFO</SEVERITY></STATUS>\n")
+	fmt.Fprintf(&b, "<DTSERVER>%s</DTSERVER>\n<LANGUAGE>ENG</LANGUAGE>\n", asOf.UTC().Format(dateLayout))
+	b.WriteString("</SONRS></SIGNONMSGSRSV1>\n")
+	fmt.Fprintf(&b, "<%s><%s>\n", msgSet, trnRs)
+	b.WriteString("<TRNUID>0</TRNUID>\n<STATUS><CODE>0</CODE><SEVERITY>INFO</SEVERITY></STATUS>\n")
+	fmt.Fprintf(&b, "<%s>\n<CURDEF>%s</CURDEF>\n", stmtRs, e(currency))
+	fmt.Fprintf(&b, "<%s>\n", acctFrom)
+	if !isCredit {
+		b.WriteString("<BANKID>000000000</BANKID>\n")
+	}
+	acctID := account.TailNumber
+	if acctID == "" {
+		acctID = account.ID.String()
+	}
+	fmt.Fprintf(&b, "<ACCTID>%s</ACCTID>\n", e(acctID))
+	if !isCredit {
+		b.WriteString("<ACCTTYPE>CHECKING</ACCTTYPE>\n")
+	}
+	fmt.Fprintf(&b, "</%s>\n", acctFrom)
+
+	fmt.Fprintf(&b, "<BANKTRANLIST>\n<DTSTART>%s</DTSTART>\n<DTEND>%s</DTEND>\n",
+		start.UTC().Format(dateLayout), end.UTC().Format(dateLayout))
+	for _, tx := range transactions {
+		trnType := "DEBIT"
+		switch tx.Type {
+		case models.TransactionTypeIncome:
+			trnType = "CREDIT"
+		case models.TransactionTypeTransfer:
+			trnType = "XFER"
+		}
+		b.WriteString("<STMTTRN>\n")
+		fmt.Fprintf(&b, "<TRNTYPE>%s</TRNTYPE>\n", trnType)
+		fmt.Fprintf(&b, "<DTPOSTED>%s</DTPOSTED>\n", tx.TransactionDate.UTC().Format(dateLayout))
+		fmt.Fprintf(&b, "<TRNAMT>%.2f</TRNAMT>\n", signedAmount(tx, incoming))
+		fmt.Fprintf(&b, "<FITID>%s</FITID>\n", tx.ID.String())
+		if note := strings.TrimSpace(tx.Note); note != "" {
+			name := note
+			if len([]rune(name)) > 32 { // NAME is limited to 32 characters
+				name = string([]rune(name)[:32])
+			}
+			fmt.Fprintf(&b, "<NAME>%s</NAME>\n<MEMO>%s</MEMO>\n", e(name), e(note))
+		}
+		if tx.Currency != "" && tx.Currency != currency {
+			fmt.Fprintf(&b, "<CURRENCY><CURRATE>1</CURRATE><CURSYM>%s</CURSYM></CURRENCY>\n", e(tx.Currency))
+		}
+		b.WriteString("</STMTTRN>\n")
+	}
+	b.WriteString("</BANKTRANLIST>\n")
+
+	fmt.Fprintf(&b, "<LEDGERBAL><BALAMT>%.2f</BALAMT><DTASOF>%s</DTASOF></LEDGERBAL>\n",
+		account.Balance, asOf.UTC().Format(dateLayout))
+	fmt.Fprintf(&b, "</%s>\n</%s></%s>\n</OFX>\n", stmtRs, trnRs, msgSet)
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteQIF writes transactions as a QIF file with a leading !Account block
+func WriteQIF(w io.Writer, account *models.Account, transactions []models.Transaction, incoming map[uuid.UUID]bool, categoryNames map[uuid.UUID]string) error {
+	qifType := "Bank"
+	switch account.Type {
+	case models.AccountTypeCredit:
+		qifType = "CCard"
+	case models.AccountTypeCash, models.AccountTypeAlipay, models.AccountTypeWeChat:
+		qifType = "Cash"
+	case models.AccountTypeOther:
+		qifType = "Oth A"
+	}
+
+	var b strings.Builder
+	b.WriteString("!Option:AutoSwitch\n!Account\n")
+	fmt.Fprintf(&b, "N%s\nT%s\n^\n!Clear:AutoSwitch\n", account.Name, qifType)
+	fmt.Fprintf(&b, "!Type:%s\n", qifType)
+
+	for _, tx := range transactions {
+		fmt.Fprintf(&b, "D%s\n", tx.TransactionDate.UTC().Format("01/02/2006"))
+		fmt.Fprintf(&b, "T%.2f\n", signedAmount(tx, incoming))
+		if note := strings.TrimSpace(tx.Note); note != "" {
+			// QIF is line based, so notes must stay on one line
+			fmt.Fprintf(&b, "M%s\n", strings.Join(strings.Fields(note), " "))
+		}
+		if tx.CategoryID != nil {
+			if name, ok := categoryNames[*tx.CategoryID]; ok {
+				fmt.Fprintf(&b, "L%s\n", name)
+			}
+		}
+		b.WriteString("^\n")
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
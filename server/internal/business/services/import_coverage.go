@@ -0,0 +1,334 @@
+package services
+
+import (
+	"sort"
+	"time"
+
+	"account/internal/business/models"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// defaultCoverageMinGapDays is the shortest run of uncovered days reported as
+// missing. Statements rarely have rows on every day, so the days between two
+// consecutive imports are not a gap unless there are a few of them.
+const defaultCoverageMinGapDays = 7
+
+const oneDay = 24 * time.Hour
+
+// ImportCoverageRequest selects the coverage to report. Empty fields select
+// every account and source; the window defaults to the last twelve months.
+type ImportCoverageRequest struct {
+	AccountID  *uuid.UUID
+	Source     models.ImportSource
+	From       time.Time
+	To         time.Time
+	MinGapDays int
+}
+
+// GetImportCoverage reports, for each account and source, which periods the
+// user's imports covered and which runs of days were never imported
+func (s *ImportService) GetImportCoverage(userID uuid.UUID, req *ImportCoverageRequest) (*models.ImportCoverageReport, error) {
+	to := req.To
+	if to.IsZero() {
+		to = time.Now()
+	}
+	from := req.From
+	if from.IsZero() {
+		from = to.AddDate(-1, 0, 0)
+	}
+	minGap := req.MinGapDays
+	if minGap <= 0 {
+		minGap = defaultCoverageMinGapDays
+	}
+
+	var sources []string
+	if req.Source != "" {
+		sources = []string{string(req.Source)}
+	}
+	// Earlier periods are needed too: they tell whether the window starts with a gap
+	periods, err := s.coverageRepo.GetOverlapping(userID, req.AccountID, sources, time.Time{}, to)
+	if err != nil {
+		return nil, err
+	}
+
+	return BuildCoverageReport(periods, from, to, minGap), nil
+}
+
+// BuildCoverageReport merges the coverage periods of each account and source
+// and finds the runs of at least minGapDays days between from and to that no
+// period covers. Nothing before an account's first import counts as missing.
+func BuildCoverageReport(periods []models.ImportCoveragePeriod, from, to time.Time, minGapDays int) *models.ImportCoverageReport {
+	from, to = startOfDay(from), startOfDay(to)
+	report := &models.ImportCoverageReport{From: from, To: to, Accounts: []models.AccountCoverage{}}
+
+	type coverageKey struct {
+		accountID uuid.UUID
+		source    models.ImportSource
+	}
+	groups := make(map[coverageKey][]models.ImportCoveragePeriod)
+	var keys []coverageKey
+	for _, p := range periods {
+		key := coverageKey{p.AccountID, p.Source}
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], p)
+	}
+
+	for _, key := range keys {
+		group := groups[key]
+		coverage := models.AccountCoverage{
+			AccountID:   key.accountID,
+			AccountName: group[0].AccountName,
+			Source:      key.source,
+			Covered:     []models.CoveredRange{},
+			Missing:     []models.MissingRange{},
+		}
+
+		merged := mergeCoverage(group)
+		windowStart := from
+		if merged[0].Start.After(windowStart) {
+			windowStart = merged[0].Start
+		}
+		coverage.LastCovered = merged[len(merged)-1].End
+
+		cursor := windowStart
+		for _, r := range merged {
+			if r.End.Before(windowStart) || r.Start.After(to) {
+				continue
+			}
+			if r.Start.Before(windowStart) {
+				r.Start = windowStart
+			}
+			if r.End.After(to) {
+				r.End = to
+			}
+			coverage.Covered = append(coverage.Covered, r)
+
+			if r.Start.After(cursor) {
+				coverage.Missing = appendGap(coverage.Missing, cursor, r.Start.Add(-oneDay), minGapDays)
+			}
+			if next := r.End.Add(oneDay); next.After(cursor) {
+				cursor = next
+			}
+		}
+		if !cursor.After(to) {
+			coverage.Missing = appendGap(coverage.Missing, cursor, to, minGapDays)
+		}
+
+		report.Accounts = append(report.Accounts, coverage)
+	}
+
+	sort.SliceStable(report.Accounts, func(i, j int) bool {
+		a, b := report.Accounts[i], report.Accounts[j]
+		if a.AccountName != b.AccountName {
+			return a.AccountName < b.AccountName
+		}
+		return a.Source < b.Source
+	})
+	return report
+}
+
+// mergeCoverage merges overlapping and adjacent periods into runs of covered days
+func mergeCoverage(periods []models.ImportCoveragePeriod) []models.CoveredRange {
+	sorted := append([]models.ImportCoveragePeriod(nil), periods...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].StartDate.Before(sorted[j].StartDate) })
+
+	var merged []models.CoveredRange
+	for _, p := range sorted {
+		start, end := startOfDay(p.StartDate), startOfDay(p.EndDate)
+		if n := len(merged); n > 0 && !start.After(merged[n-1].End.Add(oneDay)) {
+			last := &merged[n-1]
+			if end.After(last.End) {
+				last.End = end
+			}
+			if !containsUUID(last.ImportJobIDs, p.ImportJobID) {
+				last.ImportJobIDs = append(last.ImportJobIDs, p.ImportJobID)
+			}
+			continue
+		}
+		merged = append(merged, models.CoveredRange{Start: start, End: end, ImportJobIDs: []uuid.UUID{p.ImportJobID}})
+	}
+	return merged
+}
+
+// appendGap adds the days from start to end as missing when there are at least minDays of them
+func appendGap(missing []models.MissingRange, start, end time.Time, minDays int) []models.MissingRange {
+	days := int(end.Sub(start)/oneDay) + 1
+	if days < minDays {
+		return missing
+	}
+	return append(missing, models.MissingRange{Start: start, End: end, Days: days})
+}
+
+// CoveragePeriods works out the periods an import covers: for each account and
+// source, the days from its first to its last row. A statement's opening or
+// closing balance date widens the period to the statement's own dates, since
+// a statement also covers its days without entries.
+func CoveragePeriods(userID, jobID uuid.UUID, rows []models.ParsedTransaction, hints []models.AccountHint) []models.ImportCoveragePeriod {
+	type coverageKey struct {
+		accountID uuid.UUID
+		source    models.ImportSource
+	}
+	byKey := make(map[coverageKey]*models.ImportCoveragePeriod)
+	numbers := make(map[coverageKey]map[string]bool)
+	var keys []coverageKey
+
+	now := time.Now()
+	for _, tx := range rows {
+		if tx.SelectedAccountID == nil || tx.TransactionDate.IsZero() {
+			continue
+		}
+		key := coverageKey{*tx.SelectedAccountID, tx.Source}
+		date := startOfDay(tx.TransactionDate)
+		p, ok := byKey[key]
+		if !ok {
+			p = &models.ImportCoveragePeriod{
+				ID:          uuid.New(),
+				UserID:      userID,
+				AccountID:   key.accountID,
+				Source:      key.source,
+				ImportJobID: jobID,
+				StartDate:   date,
+				EndDate:     date,
+				CreatedAt:   now,
+			}
+			byKey[key] = p
+			numbers[key] = make(map[string]bool)
+			keys = append(keys, key)
+		}
+		if date.Before(p.StartDate) {
+			p.StartDate = date
+		}
+		if date.After(p.EndDate) {
+			p.EndDate = date
+		}
+		p.RowCount++
+		for _, number := range []string{tx.AccountNumber, tx.ParsedAccountNumber} {
+			if number != "" {
+				numbers[key][number] = true
+			}
+		}
+	}
+
+	for _, hint := range hints {
+		if hint.BalanceDate == nil || hint.AccountNumber == "" {
+			continue
+		}
+		date := startOfDay(*hint.BalanceDate)
+		for _, key := range keys {
+			if !numbers[key][hint.AccountNumber] {
+				continue
+			}
+			p := byKey[key]
+			switch hint.BalanceType {
+			case models.BalanceTypeOpening:
+				if date.Before(p.StartDate) {
+					p.StartDate = date
+				}
+			default:
+				if date.After(p.EndDate) {
+					p.EndDate = date
+				}
+			}
+		}
+	}
+
+	periods := make([]models.ImportCoveragePeriod, 0, len(keys))
+	for _, key := range keys {
+		periods = append(periods, *byKey[key])
+	}
+	return periods
+}
+
+// MarkCoveredRows flags the rows dated inside a period already imported for
+// their account, or for any account of their source while none is selected,
+// and sums up how the rows overlap earlier imports
+func MarkCoveredRows(rows []models.ParsedTransaction, periods []models.ImportCoveragePeriod) *models.PreviewCoverage {
+	coverage := &models.PreviewCoverage{Overlaps: []models.CoveredRange{}}
+	var hit []models.ImportCoveragePeriod
+	hitIDs := make(map[uuid.UUID]bool)
+
+	for i := range rows {
+		tx := &rows[i]
+		tx.InCoveredPeriod = false
+		if tx.TransactionDate.IsZero() {
+			continue
+		}
+		date := startOfDay(tx.TransactionDate)
+		for _, p := range periods {
+			if p.Source != tx.Source || (tx.SelectedAccountID != nil && *tx.SelectedAccountID != p.AccountID) {
+				continue
+			}
+			if date.Before(startOfDay(p.StartDate)) || date.After(startOfDay(p.EndDate)) {
+				continue
+			}
+			tx.InCoveredPeriod = true
+			if !hitIDs[p.ID] {
+				hitIDs[p.ID] = true
+				hit = append(hit, p)
+			}
+		}
+		if tx.InCoveredPeriod {
+			coverage.CoveredRows++
+			if !tx.IsDuplicate {
+				coverage.NewRows++
+			}
+		}
+	}
+
+	if len(hit) > 0 {
+		coverage.Overlaps = mergeCoverage(hit)
+	}
+	return coverage
+}
+
+// markCoveredRows loads the coverage of the rows' sources and dates and marks
+// the rows inside it. Nil is returned when the coverage cannot be loaded.
+func (s *ImportService) markCoveredRows(userID uuid.UUID, rows []models.ParsedTransaction) *models.PreviewCoverage {
+	var start, end time.Time
+	var sources []string
+	seen := make(map[models.ImportSource]bool)
+	for _, tx := range rows {
+		if tx.TransactionDate.IsZero() {
+			continue
+		}
+		if start.IsZero() || tx.TransactionDate.Before(start) {
+			start = tx.TransactionDate
+		}
+		if tx.TransactionDate.After(end) {
+			end = tx.TransactionDate
+		}
+		if !seen[tx.Source] {
+			seen[tx.Source] = true
+			sources = append(sources, string(tx.Source))
+		}
+	}
+	if len(sources) == 0 {
+		return MarkCoveredRows(rows, nil)
+	}
+
+	periods, err := s.coverageRepo.GetOverlapping(userID, nil, sources, startOfDay(start), startOfDay(end))
+	if err != nil {
+		s.logger.Warn("Failed to load import coverage", zap.Error(err))
+		return nil
+	}
+	return MarkCoveredRows(rows, periods)
+}
+
+// recordCoverage stores the periods an executed import covered
+func (s *ImportService) recordCoverage(userID, jobID uuid.UUID, rows []models.ParsedTransaction, hints []models.AccountHint) {
+	if err := s.coverageRepo.Create(CoveragePeriods(userID, jobID, rows, hints)); err != nil {
+		s.logger.Warn("Failed to record import coverage", zap.String("job_id", jobID.String()), zap.Error(err))
+	}
+}
+
+func containsUUID(values []uuid.UUID, v uuid.UUID) bool {
+	for _, x := range values {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,324 @@
+package services
+
+import (
+	"account/internal/business/models"
+	"account/internal/data/repository"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrPreviewRowNotFound = errors.New("preview row not found")
+	// ErrPreviewRowNotImportable is returned when a row without a valid amount or date is included
+	ErrPreviewRowNotImportable = errors.New("preview row cannot be imported")
+	ErrPreviewNoteTooLong      = errors.New("note is too long")
+)
+
+const maxPreviewNoteLength = 500
+
+// ImportPreviewFilter selects preview rows for a group edit. Empty fields match
+// every row; an empty filter matches the whole preview.
+type ImportPreviewFilter struct {
+	LineNumbers   []int                  `json:"line_numbers"`
+	AccountName   string                 `json:"account_name"` // 文件中的账户名
+	Counterparty  string                 `json:"counterparty"` // 对方或商户名包含
+	Keyword       string                 `json:"keyword"`      // 备注包含
+	Type          models.TransactionType `json:"type"`
+	MinAmount     *float64               `json:"min_amount"`
+	MaxAmount     *float64               `json:"max_amount"`
+	From          *time.Time             `json:"from"`
+	To            *time.Time             `json:"to"`
+	Duplicate     *bool                  `json:"duplicate"`
+	Included      *bool                  `json:"included"`
+	Covered       *bool                  `json:"covered"`       // 日期落在已导入过的期间内
+	Uncategorized bool                   `json:"uncategorized"` // 只选尚未选分类的行
+}
+
+// Matches reports whether a row is selected by the filter
+func (f *ImportPreviewFilter) Matches(tx *models.ParsedTransaction) bool {
+	if len(f.LineNumbers) > 0 && !containsInt(f.LineNumbers, tx.LineNumber) {
+		return false
+	}
+	if f.AccountName != "" && !strings.EqualFold(strings.TrimSpace(tx.AccountName), strings.TrimSpace(f.AccountName)) {
+		return false
+	}
+	if f.Counterparty != "" {
+		needle := strings.ToLower(strings.TrimSpace(f.Counterparty))
+		if !strings.Contains(strings.ToLower(tx.Counterparty), needle) && !strings.Contains(strings.ToLower(tx.PayeeName), needle) {
+			return false
+		}
+	}
+	if f.Keyword != "" && !strings.Contains(strings.ToLower(tx.Note), strings.ToLower(strings.TrimSpace(f.Keyword))) {
+		return false
+	}
+	if f.Type != "" && tx.Type != f.Type {
+		return false
+	}
+	if f.MinAmount != nil && tx.Amount < *f.MinAmount {
+		return false
+	}
+	if f.MaxAmount != nil && tx.Amount > *f.MaxAmount {
+		return false
+	}
+	if f.From != nil && tx.TransactionDate.Before(*f.From) {
+		return false
+	}
+	if f.To != nil && tx.TransactionDate.After(*f.To) {
+		return false
+	}
+	if f.Duplicate != nil && tx.IsDuplicate != *f.Duplicate {
+		return false
+	}
+	if f.Included != nil && tx.CanBeImported != *f.Included {
+		return false
+	}
+	if f.Covered != nil && tx.InCoveredPeriod != *f.Covered {
+		return false
+	}
+	if f.Uncategorized && tx.SelectedCategoryID != nil {
+		return false
+	}
+	return true
+}
+
+// ImportPreviewEdit changes the account, category, note or include flag of
+// preview rows. Nil fields are left as they are.
+type ImportPreviewEdit struct {
+	AccountID     *uuid.UUID `json:"account_id"`
+	CategoryID    *uuid.UUID `json:"category_id"`
+	ClearCategory bool       `json:"clear_category"` // 清除已选分类
+	Note          *string    `json:"note"`
+	Include       *bool      `json:"include"` // 是否导入该行
+}
+
+// IsEmpty reports whether the edit changes nothing
+func (e *ImportPreviewEdit) IsEmpty() bool {
+	return e.AccountID == nil && e.CategoryID == nil && !e.ClearCategory && e.Note == nil && e.Include == nil
+}
+
+// Apply changes one row and reports whether it changed. A row without a valid
+// amount or date cannot be included, whatever the edit says.
+func (e *ImportPreviewEdit) Apply(tx *models.ParsedTransaction) (bool, error) {
+	if e.Include != nil && *e.Include && (tx.Amount <= 0 || tx.TransactionDate.IsZero()) {
+		return false, ErrPreviewRowNotImportable
+	}
+
+	changed := false
+	if e.AccountID != nil && (tx.SelectedAccountID == nil || *tx.SelectedAccountID != *e.AccountID) {
+		id := *e.AccountID
+		tx.SelectedAccountID = &id
+		changed = true
+	}
+	if e.ClearCategory && tx.SelectedCategoryID != nil {
+		tx.SelectedCategoryID = nil
+		changed = true
+	}
+	if e.CategoryID != nil && (tx.SelectedCategoryID == nil || *tx.SelectedCategoryID != *e.CategoryID) {
+		id := *e.CategoryID
+		tx.SelectedCategoryID = &id
+		changed = true
+	}
+	if e.Note != nil && tx.Note != *e.Note {
+		tx.Note = *e.Note
+		changed = true
+	}
+	if e.Include != nil && tx.CanBeImported != *e.Include {
+		tx.CanBeImported = *e.Include
+		changed = true
+	}
+	return changed, nil
+}
+
+// EditPreviewRows applies an edit to every row the filter selects and returns
+// the rows that changed. Rows that cannot be included are left out of a group
+// edit that includes rows.
+func EditPreviewRows(rows []models.ParsedTransaction, filter *ImportPreviewFilter, edit *ImportPreviewEdit) []models.ParsedTransaction {
+	var changed []models.ParsedTransaction
+	for i := range rows {
+		if !filter.Matches(&rows[i]) {
+			continue
+		}
+		if ok, err := edit.Apply(&rows[i]); err == nil && ok {
+			changed = append(changed, rows[i])
+		}
+	}
+	return changed
+}
+
+// PreviewEditResult is the outcome of editing a stored preview
+type PreviewEditResult struct {
+	JobID   uuid.UUID                  `json:"job_id"`
+	Version int                        `json:"version"` // 修改后的版本
+	Updated int                        `json:"updated"`
+	Rows    []models.ParsedTransaction `json:"rows"` // 被修改的行
+}
+
+// CreatePreview parses a file and stores the preview, so that its rows can be
+// edited and imported by job ID
+func (s *ImportService) CreatePreview(userID uuid.UUID, req *ParseRequest) (*models.ImportPreview, error) {
+	preview, err := s.ParseFile(userID, req)
+	if err != nil {
+		return nil, err
+	}
+	preview.UserID = userID
+	preview.FileName = req.FileName
+	preview.Coverage = s.markCoveredRows(userID, preview.Transactions)
+
+	if err := s.previewRepo.Create(preview, s.previewTTL); err != nil {
+		return nil, err
+	}
+	if removed, err := s.previewRepo.DeleteExpired(); err != nil {
+		s.logger.Warn("Failed to delete expired import previews", zap.Error(err))
+	} else if removed > 0 {
+		s.logger.Info("Deleted expired import previews", zap.Int64("count", removed))
+	}
+
+	return preview, nil
+}
+
+// GetPreview returns a stored preview with current account and category choices
+func (s *ImportService) GetPreview(userID, jobID uuid.UUID) (*models.ImportPreview, error) {
+	preview, err := s.previewRepo.GetByID(jobID, userID)
+	if err != nil {
+		return nil, err
+	}
+	preview.Recount()
+
+	accounts, _ := s.accountRepo.GetAll(userID)
+	preview.Categories, _ = s.categoryRepo.GetAll(userID)
+	aliases, err := s.accountRepo.GetAliases(userID)
+	if err != nil {
+		s.logger.Warn("Failed to load account aliases", zap.Error(err))
+	}
+	preview.AccountSuggestions = s.accountSuggestions(preview.Transactions, accounts, NewAccountAliasResolver(accounts, aliases))
+	// Other imports may have run since the preview was parsed
+	preview.Coverage = s.markCoveredRows(userID, preview.Transactions)
+
+	return preview, nil
+}
+
+// UpdatePreviewRow edits one row of a stored preview. version, when not zero,
+// must be the version the client last read.
+func (s *ImportService) UpdatePreviewRow(userID, jobID uuid.UUID, lineNumber int, edit *ImportPreviewEdit, version int) (*PreviewEditResult, error) {
+	return s.updatePreview(userID, jobID, version, edit, func(rows []models.ParsedTransaction) ([]models.ParsedTransaction, error) {
+		for i := range rows {
+			if rows[i].LineNumber != lineNumber {
+				continue
+			}
+			ok, err := edit.Apply(&rows[i])
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				return nil, nil
+			}
+			return []models.ParsedTransaction{rows[i]}, nil
+		}
+		return nil, ErrPreviewRowNotFound
+	})
+}
+
+// UpdatePreviewRows edits every row of a stored preview that the filter selects
+func (s *ImportService) UpdatePreviewRows(userID, jobID uuid.UUID, filter *ImportPreviewFilter, edit *ImportPreviewEdit, version int) (*PreviewEditResult, error) {
+	return s.updatePreview(userID, jobID, version, edit, func(rows []models.ParsedTransaction) ([]models.ParsedTransaction, error) {
+		return EditPreviewRows(rows, filter, edit), nil
+	})
+}
+
+func (s *ImportService) updatePreview(
+	userID, jobID uuid.UUID,
+	version int,
+	edit *ImportPreviewEdit,
+	apply func([]models.ParsedTransaction) ([]models.ParsedTransaction, error),
+) (*PreviewEditResult, error) {
+	if err := s.validatePreviewEdit(userID, edit); err != nil {
+		return nil, err
+	}
+
+	preview, err := s.previewRepo.GetByID(jobID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if preview.Status == models.ImportPreviewExecuted {
+		return nil, repository.ErrImportPreviewExecuted
+	}
+	if version != 0 && version != preview.Version {
+		return nil, repository.ErrImportPreviewConflict
+	}
+
+	changed, err := apply(preview.Transactions)
+	if err != nil {
+		return nil, err
+	}
+	result := &PreviewEditResult{JobID: jobID, Version: preview.Version, Updated: len(changed), Rows: changed}
+	if len(changed) == 0 {
+		result.Rows = []models.ParsedTransaction{}
+		return result, nil
+	}
+
+	if err := s.previewRepo.UpdateRows(preview); err != nil {
+		return nil, err
+	}
+	result.Version = preview.Version
+	return result, nil
+}
+
+// validatePreviewEdit checks that the account and category of an edit belong to the user
+func (s *ImportService) validatePreviewEdit(userID uuid.UUID, edit *ImportPreviewEdit) error {
+	if edit.AccountID != nil {
+		if _, err := s.accountRepo.GetByID(*edit.AccountID, userID); err != nil {
+			return err
+		}
+	}
+	if edit.CategoryID != nil {
+		if _, err := s.categoryRepo.GetByID(*edit.CategoryID, userID); err != nil {
+			return err
+		}
+	}
+	if edit.Note != nil {
+		note := strings.TrimSpace(*edit.Note)
+		if len([]rune(note)) > maxPreviewNoteLength {
+			return ErrPreviewNoteTooLong
+		}
+		edit.Note = &note
+	}
+	return nil
+}
+
+// DiscardPreview deletes a stored preview that will not be imported
+func (s *ImportService) DiscardPreview(userID, jobID uuid.UUID) error {
+	return s.previewRepo.Delete(jobID, userID)
+}
+
+// accountSuggestions lists, per account name found in the rows, the accounts it
+// may refer to; the account an alias resolves to comes first
+func (s *ImportService) accountSuggestions(transactions []models.ParsedTransaction, accounts []models.Account, resolver *AccountAliasResolver) map[string][]models.Account {
+	suggestions := make(map[string][]models.Account)
+	for i := range transactions {
+		tx := &transactions[i]
+		if tx.AccountName == "" {
+			continue
+		}
+		matches := s.findMatchingAccounts(tx.AccountName, accounts)
+		if account := resolver.Resolve(tx.AccountName, tx.ParsedAccountNumber); account != nil {
+			matches = append([]models.Account{*account}, withoutAccount(matches, account.ID)...)
+		}
+		if len(matches) > 0 {
+			suggestions[tx.AccountName] = matches
+		}
+	}
+	return suggestions
+}
+
+func containsInt(values []int, v int) bool {
+	for _, x := range values {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
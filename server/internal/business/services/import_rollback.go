@@ -0,0 +1,195 @@
+package services
+
+import (
+	"account/internal/business/models"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// GetImportHistory returns the user's executed imports, newest first
+func (s *ImportService) GetImportHistory(userID uuid.UUID, limit, offset int) ([]models.ImportJob, error) {
+	return s.importJobRepo.GetAll(userID, limit, offset)
+}
+
+// GetImportJob returns one import of the user's import history
+func (s *ImportService) GetImportJob(userID, jobID uuid.UUID) (*models.ImportJob, error) {
+	return s.importJobRepo.GetByID(jobID, userID)
+}
+
+// RollbackImport undoes an import: it deletes the imported transactions, unlinks
+// the transfers they are part of and restores the account balances. Every change
+// bumps a version so that sync sends it to the user's devices.
+func (s *ImportService) RollbackImport(userID, jobID uuid.UUID) (*models.ImportRollback, error) {
+	if _, err := s.importJobRepo.GetByID(jobID, userID); err != nil {
+		return nil, err
+	}
+
+	transactions, err := s.transactionRepo.GetByImportJob(userID, jobID)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]uuid.UUID, len(transactions))
+	for i, tx := range transactions {
+		ids[i] = tx.ID
+	}
+
+	links, err := s.transferRepo.GetByTransactionIDs(userID, ids)
+	if err != nil {
+		return nil, err
+	}
+	// 多笔转账的任何一条腿被撤销，整组转账都要解除
+	var groupIDs []uuid.UUID
+	seenGroup := make(map[uuid.UUID]bool)
+	for _, link := range links {
+		if link.GroupID != nil && !seenGroup[*link.GroupID] {
+			seenGroup[*link.GroupID] = true
+			groupIDs = append(groupIDs, *link.GroupID)
+		}
+	}
+	groupLinks, err := s.transferRepo.GetByGroupIDs(userID, groupIDs)
+	if err != nil {
+		return nil, err
+	}
+	links = append(links, groupLinks...)
+
+	var relatedIDs []uuid.UUID
+	for _, link := range links {
+		relatedIDs = append(relatedIDs, link.FromTransactionID, link.ToTransactionID)
+		if link.FeeTransactionID != nil {
+			relatedIDs = append(relatedIDs, *link.FeeTransactionID)
+		}
+	}
+	related, err := s.transactionRepo.GetByIDs(userID, relatedIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	rollback := PlanImportRollback(jobID, transactions, links, related)
+	if err := s.importJobRepo.Rollback(userID, rollback); err != nil {
+		return nil, err
+	}
+
+	// 撤销的交易可能落在已对账的区间内；撤销的分类样本不再参与分类建议
+	earliestByAccount := make(map[uuid.UUID]time.Time)
+	for _, tx := range append(transactions, related...) {
+		if earliest, ok := earliestByAccount[tx.AccountID]; !ok || tx.TransactionDate.Before(earliest) {
+			earliestByAccount[tx.AccountID] = tx.TransactionDate
+		}
+	}
+	for accountID, earliest := range earliestByAccount {
+		s.reconciler.RecheckAssertions(userID, accountID, earliest)
+	}
+	for _, tx := range transactions {
+		if tx.CategoryID != nil {
+			s.suggester.Forget(userID, ExampleFromTransaction(tx), *tx.CategoryID)
+		}
+	}
+
+	s.logger.Info("Rolled back import",
+		zap.String("job_id", jobID.String()),
+		zap.Int("deleted", len(rollback.DeletedTransactionIDs)),
+		zap.Int("restored", len(rollback.RestoredTransactions)))
+	return rollback, nil
+}
+
+// PlanImportRollback works out the changes that undo an import. transactions are
+// the imported rows, links every transfer they are part of and related the other
+// legs and split-off fees of those transfers. Deleted rows are reversed out of the
+// balances; a ledger row left on the other side of a transfer becomes income or
+// expense again, with its fee merged back.
+func PlanImportRollback(jobID uuid.UUID, transactions []models.Transaction, links []models.TransferLink, related []models.Transaction) *models.ImportRollback {
+	rollback := &models.ImportRollback{
+		JobID:                  jobID,
+		DeletedTransactionIDs:  []uuid.UUID{},
+		RemovedTransferLinkIDs: []uuid.UUID{},
+		RestoredTransactions:   []models.RestoredTransaction{},
+		BalanceChanges:         []models.AccountBalanceChange{},
+	}
+
+	inJob := make(map[uuid.UUID]bool, len(transactions))
+	for _, tx := range transactions {
+		inJob[tx.ID] = true
+	}
+	byID := make(map[uuid.UUID]models.Transaction, len(related))
+	for _, tx := range related {
+		byID[tx.ID] = tx
+	}
+
+	outgoing := make(map[uuid.UUID]bool)
+	incoming := make(map[uuid.UUID]bool)
+	fees := make(map[uuid.UUID]float64) // 转出交易 -> 拆出的手续费
+	var feeIDs []uuid.UUID
+	seenLink := make(map[uuid.UUID]bool)
+	for _, link := range links {
+		if seenLink[link.ID] {
+			continue
+		}
+		seenLink[link.ID] = true
+		rollback.RemovedTransferLinkIDs = append(rollback.RemovedTransferLinkIDs, link.ID)
+		outgoing[link.FromTransactionID] = true
+		incoming[link.ToTransactionID] = true
+		if link.FeeTransactionID == nil {
+			continue
+		}
+		if fee, ok := byID[*link.FeeTransactionID]; ok {
+			fees[link.FromTransactionID] += fee.Amount
+			feeIDs = append(feeIDs, fee.ID)
+		}
+	}
+
+	changes := make(map[uuid.UUID]float64)
+	var accountOrder []uuid.UUID
+	change := func(accountID uuid.UUID, amount float64) {
+		if _, ok := changes[accountID]; !ok {
+			accountOrder = append(accountOrder, accountID)
+		}
+		changes[accountID] += amount
+	}
+
+	for _, tx := range transactions {
+		rollback.DeletedTransactionIDs = append(rollback.DeletedTransactionIDs, tx.ID)
+		switch {
+		case tx.Type != models.TransactionTypeTransfer:
+			change(tx.AccountID, -balanceEffect(tx.Type, tx.Amount))
+		case outgoing[tx.ID]:
+			change(tx.AccountID, tx.Amount+fees[tx.ID])
+		case incoming[tx.ID]:
+			change(tx.AccountID, -tx.Amount)
+		}
+	}
+	// 手续费随转账一起删除；转出方不在本次导入中时手续费并回原交易
+	for _, id := range feeIDs {
+		if !inJob[id] {
+			rollback.DeletedTransactionIDs = append(rollback.DeletedTransactionIDs, id)
+		}
+	}
+
+	for _, tx := range related {
+		if inJob[tx.ID] || tx.Type != models.TransactionTypeTransfer {
+			continue
+		}
+		switch {
+		case outgoing[tx.ID]:
+			rollback.RestoredTransactions = append(rollback.RestoredTransactions, models.RestoredTransaction{
+				TransactionID: tx.ID,
+				Type:          models.TransactionTypeExpense,
+				Amount:        roundAmount(tx.Amount + fees[tx.ID]),
+			})
+		case incoming[tx.ID]:
+			rollback.RestoredTransactions = append(rollback.RestoredTransactions, models.RestoredTransaction{
+				TransactionID: tx.ID,
+				Type:          models.TransactionTypeIncome,
+				Amount:        tx.Amount,
+			})
+		}
+	}
+
+	for _, accountID := range accountOrder {
+		if amount := roundAmount(changes[accountID]); amount != 0 {
+			rollback.BalanceChanges = append(rollback.BalanceChanges, models.AccountBalanceChange{AccountID: accountID, Change: amount})
+		}
+	}
+	return rollback
+}
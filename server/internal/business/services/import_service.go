@@ -3,10 +3,10 @@ package services
 import (
 	"account/internal/business/models"
 	"account/internal/data/repository"
-	"encoding/csv"
 	"fmt"
 	"io"
 	"math"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -20,6 +20,17 @@ type ImportService struct {
 	transactionRepo *repository.TransactionRepository
 	accountRepo     *repository.AccountRepository
 	categoryRepo    *repository.CategoryRepository
+	payeeRepo       *repository.PayeeRepository
+	decisionRepo    *repository.DuplicateDecisionRepository
+	ruleRepo        *repository.CategoryRuleRepository
+	importJobRepo   *repository.ImportJobRepository
+	previewRepo     *repository.ImportPreviewRepository
+	transferRepo    *repository.TransferLinkRepository
+	coverageRepo    *repository.ImportCoverageRepository
+	refundService   *RefundService
+	reconciler      *ReconciliationService
+	suggester       *CategorySuggester
+	previewTTL      time.Duration
 	logger          *zap.Logger
 }
 
@@ -28,12 +39,34 @@ func NewImportService(
 	transactionRepo *repository.TransactionRepository,
 	accountRepo *repository.AccountRepository,
 	categoryRepo *repository.CategoryRepository,
+	payeeRepo *repository.PayeeRepository,
+	decisionRepo *repository.DuplicateDecisionRepository,
+	ruleRepo *repository.CategoryRuleRepository,
+	importJobRepo *repository.ImportJobRepository,
+	previewRepo *repository.ImportPreviewRepository,
+	transferRepo *repository.TransferLinkRepository,
+	coverageRepo *repository.ImportCoverageRepository,
+	refundService *RefundService,
+	reconciler *ReconciliationService,
+	suggester *CategorySuggester,
+	previewTTL time.Duration,
 	logger *zap.Logger,
 ) *ImportService {
 	return &ImportService{
 		transactionRepo: transactionRepo,
 		accountRepo:     accountRepo,
 		categoryRepo:    categoryRepo,
+		payeeRepo:       payeeRepo,
+		decisionRepo:    decisionRepo,
+		ruleRepo:        ruleRepo,
+		importJobRepo:   importJobRepo,
+		previewRepo:     previewRepo,
+		transferRepo:    transferRepo,
+		coverageRepo:    coverageRepo,
+		refundService:   refundService,
+		reconciler:      reconciler,
+		suggester:       suggester,
+		previewTTL:      previewTTL,
 		logger:          logger,
 	}
 }
@@ -43,24 +76,44 @@ type ParseRequest struct {
 	Source   models.ImportSource `json:"source" binding:"required"`
 	FileName string               `json:"file_name"`
 	File     io.Reader            `json:"-"`
+	// Size of File in bytes, when known; only used to report progress
+	Size int64 `json:"-"`
+	// Progress, when set, is called as the file is read
+	Progress func(ParseProgress) `json:"-"`
 }
 
-// ParseFile parses an uploaded file and returns preview data
+// ParseFile parses an uploaded file and returns preview data. CSV bills are
+// read one record at a time and enriched in chunks, so the raw file is never
+// held in memory as a whole. Statement formats are not streamed: OFX is read
+// into memory and camt.053 decoded into a document before their rows reach
+// the pipeline, and QIF and MT940 rows are collected into a statement first.
+// They cover one account for a statement period, far smaller than the
+// multi-year bill exports streaming is for. The parsed rows of every format
+// are kept for the preview.
 func (s *ImportService) ParseFile(userID uuid.UUID, req *ParseRequest) (*models.ImportPreview, error) {
-	var transactions []models.ParsedTransaction
+	counter := &countingReader{r: req.File}
+	pipeline := s.newParsePipeline(userID, req.Source, func(rows int) {
+		if req.Progress != nil {
+			req.Progress(ParseProgress{BytesRead: counter.n, TotalBytes: req.Size, Rows: rows})
+		}
+	})
+
+	var accountHints []models.AccountHint
 	var err error
 
 	switch req.Source {
-	case models.ImportSourceAlipay:
-		transactions, err = s.parseAlipayCSV(req.File)
-	case models.ImportSourceWeChat:
-		transactions, err = s.parseWeChatCSV(req.File)
-	case models.ImportSourceJD:
-		transactions, err = s.parseJDCSV(req.File)
-	case models.ImportSourceBank:
-		transactions, err = s.parseBankCSV(req.File)
-	case models.ImportSourceGeneric:
-		transactions, err = s.parseGenericCSV(req.File)
+	case models.ImportSourceAlipay, models.ImportSourceWeChat, models.ImportSourceJD,
+		models.ImportSourceBank, models.ImportSourceGeneric:
+		err = s.ParseCSVRecords(req.Source, openBillText(counter), pipeline.add, pipeline.diagnose)
+	// Statement formats are documents rather than row lists and are parsed whole
+	case models.ImportSourceOFX:
+		accountHints, err = pipeline.addStatement(ParseOFX(counter))
+	case models.ImportSourceQIF:
+		accountHints, err = pipeline.addStatement(ParseQIF(counter))
+	case models.ImportSourceCamt053:
+		accountHints, err = pipeline.addStatement(ParseCamt053(counter))
+	case models.ImportSourceMT940:
+		accountHints, err = pipeline.addStatement(ParseMT940(counter))
 	default:
 		return nil, fmt.Errorf("unsupported import source: %s", req.Source)
 	}
@@ -68,25 +121,12 @@ func (s *ImportService) ParseFile(userID uuid.UUID, req *ParseRequest) (*models.
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse file: %w", err)
 	}
+	pipeline.flush()
+	transactions := pipeline.rows
 
-	// Enhance transactions with account/category suggestions
 	validCount := 0
 	duplicateCount := 0
-	for i := range transactions {
-		tx := &transactions[i]
-		tx.Source = req.Source
-		tx.LineNumber = i + 1
-
-		// Check for duplicates
-		isDup, err := s.checkForDuplicate(userID, tx)
-		if err != nil {
-			s.logger.Warn("Failed to check duplicate", zap.Error(err))
-		}
-		tx.IsDuplicate = isDup
-
-		// Determine if can be imported
-		tx.CanBeImported = !tx.IsDuplicate && tx.Amount > 0 && !tx.TransactionDate.IsZero()
-
+	for _, tx := range transactions {
 		if tx.IsDuplicate {
 			duplicateCount++
 		}
@@ -95,21 +135,41 @@ func (s *ImportService) ParseFile(userID uuid.UUID, req *ParseRequest) (*models.
 		}
 	}
 
+	// Link refunds to their purchases so stats can net them out
+	if err := s.refundService.MatchRefunds(userID, transactions); err != nil {
+		s.logger.Warn("Failed to match refunds", zap.Error(err))
+	}
+
 	// Get user's accounts and categories for suggestions
 	accounts, _ := s.accountRepo.GetAll(userID)
 	categories, _ := s.categoryRepo.GetAll(userID)
 
-	accountSuggestions := make(map[string][]models.Account)
-	for _, tx := range transactions {
-		if tx.AccountName != "" {
-			// Find matching accounts
-			matches := s.findMatchingAccounts(tx.AccountName, accounts)
-			if len(matches) > 0 {
-				accountSuggestions[tx.AccountName] = matches
-			}
+	// Names the user picked an account for before resolve to that account first
+	aliases, err := s.accountRepo.GetAliases(userID)
+	if err != nil {
+		s.logger.Warn("Failed to load account aliases", zap.Error(err))
+	}
+	resolver := NewAccountAliasResolver(accounts, aliases)
+
+	for i := range transactions {
+		resolver.ApplyToParsed(&transactions[i])
+	}
+
+	// Apply the user's categorisation rules once accounts are known, so that
+	// rules conditioned on an account can match
+	if engine, err := loadRuleEngine(s.ruleRepo, userID); err != nil {
+		s.logger.Warn("Failed to load category rules", zap.Error(err))
+	} else {
+		for i := range transactions {
+			engine.ApplyToParsed(&transactions[i])
 		}
 	}
 
+	// Learned suggestions fill in what the rules left open
+	s.suggester.SuggestForImport(userID, transactions)
+
+	accountSuggestions := s.accountSuggestions(transactions, accounts, resolver)
+
 	preview := &models.ImportPreview{
 		JobID:             uuid.New(),
 		Source:            req.Source,
@@ -118,28 +178,112 @@ func (s *ImportService) ParseFile(userID uuid.UUID, req *ParseRequest) (*models.
 		DuplicateRows:     duplicateCount,
 		Transactions:      transactions,
 		AccountSuggestions: accountSuggestions,
+		AccountHints:      accountHints,
+		Diagnostics:       pipeline.diagnostics,
 		Categories:        categories,
 	}
+	preview.RejectedRows = preview.Diagnostics.Rejected()
 
 	return preview, nil
 }
 
-// ExecuteImportRequest contains the data needed to execute an import
+// ParseCSVRecords reads the rows of a CSV bill as they are in the file, before
+// IDs, duplicates and categories are worked out. Every record that is skipped
+// or only partly read goes to report.
+func (s *ImportService) ParseCSVRecords(source models.ImportSource, r io.Reader, emit func(models.ParsedTransaction), report func(models.ParseDiagnostic)) error {
+	switch source {
+	case models.ImportSourceAlipay:
+		return s.parseAlipayCSV(r, emit, report)
+	case models.ImportSourceWeChat:
+		return s.parseWeChatCSV(r, emit, report)
+	case models.ImportSourceJD:
+		return s.parseJDCSV(r, emit, report)
+	case models.ImportSourceBank:
+		return s.parseBankCSV(r, emit, report)
+	case models.ImportSourceGeneric:
+		return s.parseGenericCSV(r, emit, report)
+	default:
+		return fmt.Errorf("unsupported import source: %s", source)
+	}
+}
+
+// mergedBankRows returns the bank statement rows the user merged into a payment
+// app record, keyed by external ID, with the source of that app record
+func (s *ImportService) mergedBankRows(userID uuid.UUID, source models.ImportSource, externalIDs []string) map[string]models.ImportSource {
+	merged := make(map[string]models.ImportSource)
+	if !isBankSource(source) || len(externalIDs) == 0 {
+		return merged
+	}
+
+	decisions, err := s.decisionRepo.GetByBankExternalIDs(userID, string(source), externalIDs)
+	if err != nil {
+		s.logger.Warn("Failed to load duplicate decisions", zap.Error(err))
+		return merged
+	}
+	for _, d := range decisions {
+		if d.Decision == models.DuplicateDecisionMerge {
+			merged[d.BankExternalID] = d.AppSource
+		}
+	}
+	return merged
+}
+
+// ExecuteImportRequest contains the data needed to execute an import. The rows
+// come from the stored preview of the job, as edited by the user.
 type ExecuteImportRequest struct {
-	JobID        uuid.UUID               `json:"job_id" binding:"required"`
-	Transactions []models.ParsedTransaction `json:"transactions" binding:"required"`
+	JobID uuid.UUID `json:"job_id" binding:"required"`
+	// Preview version the client last read; 0 executes the stored version
+	Version int `json:"version"`
+	// Closing balances of the imported statements, reconciled once all rows are in
+	StatementBalances []StatementBalance `json:"statement_balances"`
 }
 
-// ExecuteImport executes the actual import of transactions
+// ExecuteImport executes the actual import of transactions. The preview's job ID
+// becomes the import job in the import history, and every created transaction
+// carries it so that the whole import can be rolled back.
 func (s *ImportService) ExecuteImport(userID uuid.UUID, req *ExecuteImportRequest) (*models.ImportResult, error) {
+	preview, err := s.previewRepo.GetByID(req.JobID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if preview.Status == models.ImportPreviewExecuted {
+		return nil, repository.ErrImportPreviewExecuted
+	}
+	version := preview.Version
+	if req.Version != 0 {
+		version = req.Version
+	}
+
+	// Claim the preview before anything is written, so that executing it twice
+	// cannot import its rows twice and an edit made meanwhile is not lost
+	if err := s.previewRepo.MarkExecuted(preview.JobID, userID, version); err != nil {
+		return nil, err
+	}
+	job := &models.ImportJob{
+		ID:       preview.JobID,
+		UserID:   userID,
+		Kind:     models.ImportJobKindFile,
+		Source:   preview.Source,
+		FileName: preview.FileName,
+	}
+	if err := s.importJobRepo.Start(job); err != nil {
+		return nil, err
+	}
+	rows := preview.Transactions
+
 	result := &models.ImportResult{
 		JobID:        req.JobID,
-		TotalRows:    len(req.Transactions),
+		TotalRows:    len(rows),
 		ImportedIDs:  make([]uuid.UUID, 0),
 		Errors:       make([]models.ImportError, 0),
 	}
+	payees := make(map[string]*uuid.UUID)
+	learnedAliases := make(map[string]bool)
+	createdByLine := make(map[int]uuid.UUID)
+	earliestByAccount := make(map[uuid.UUID]time.Time)
+	var refunds []models.ParsedTransaction
 
-	for _, parsedTx := range req.Transactions {
+	for _, parsedTx := range rows {
 		if !parsedTx.CanBeImported || parsedTx.SelectedAccountID == nil {
 			result.SkippedRows++
 			continue
@@ -149,6 +293,7 @@ func (s *ImportService) ExecuteImport(userID uuid.UUID, req *ExecuteImportReques
 		createReq := &CreateTransactionRequest{
 			AccountID:       *parsedTx.SelectedAccountID,
 			CategoryID:      parsedTx.SelectedCategoryID,
+			PayeeID:         s.resolveImportPayee(userID, &parsedTx, payees),
 			Type:            parsedTx.Type,
 			Amount:          parsedTx.Amount,
 			Currency:        parsedTx.Currency,
@@ -156,11 +301,12 @@ func (s *ImportService) ExecuteImport(userID uuid.UUID, req *ExecuteImportReques
 			TransactionDate: parsedTx.TransactionDate,
 		}
 
-		if createReq.Currency == "" {
-			createReq.Currency = "CNY"
+		tx, err := s.createTransactionInternal(userID, createReq, parsedTx.Source, parsedTx.ExternalID, parsedTx.OrderID, &job.ID)
+		if err == repository.ErrDuplicateExternalID {
+			// Already imported earlier (or twice in this request)
+			result.SkippedRows++
+			continue
 		}
-
-		tx, err := s.createTransactionInternal(userID, createReq)
 		if err != nil {
 			result.FailedRows++
 			result.Errors = append(result.Errors, models.ImportError{
@@ -170,15 +316,116 @@ func (s *ImportService) ExecuteImport(userID uuid.UUID, req *ExecuteImportReques
 			continue
 		}
 
+		if tx.CategoryID != nil {
+			s.suggester.Learn(userID, ExampleFromTransaction(*tx), *tx.CategoryID)
+		}
+		s.learnAccountAlias(userID, &parsedTx, tx.AccountID, learnedAliases)
+
 		result.ImportedRows++
 		result.ImportedIDs = append(result.ImportedIDs, tx.ID)
+		createdByLine[parsedTx.LineNumber] = tx.ID
+		if earliest, ok := earliestByAccount[tx.AccountID]; !ok || tx.TransactionDate.Before(earliest) {
+			earliestByAccount[tx.AccountID] = tx.TransactionDate
+		}
+		if parsedTx.IsRefund {
+			refunds = append(refunds, parsedTx)
+		}
+	}
+
+	// Refunds may precede their purchase in the file, so link after all rows exist
+	for _, refund := range refunds {
+		var purchaseID uuid.UUID
+		switch {
+		case refund.RefundOfTransactionID != nil:
+			purchaseID = *refund.RefundOfTransactionID
+		case refund.RefundOfLineNumber != 0:
+			id, ok := createdByLine[refund.RefundOfLineNumber]
+			if !ok {
+				continue // Purchase row was not imported
+			}
+			purchaseID = id
+		default:
+			continue
+		}
+		if _, err := s.refundService.LinkRefund(userID, createdByLine[refund.LineNumber], purchaseID); err != nil {
+			s.logger.Warn("Failed to link refund", zap.Int("line", refund.LineNumber), zap.Error(err))
+		}
+	}
+
+	// Imported rows may land inside an already reconciled period
+	for accountID, earliest := range earliestByAccount {
+		s.reconciler.RecheckAssertions(userID, accountID, earliest)
+	}
+
+	for _, balance := range req.StatementBalances {
+		// Rows skipped as duplicates are still part of the statement
+		var statementRows []models.ParsedTransaction
+		for _, parsedTx := range rows {
+			if parsedTx.SelectedAccountID != nil && *parsedTx.SelectedAccountID == balance.AccountID {
+				statementRows = append(statementRows, parsedTx)
+			}
+		}
+		reconciliation, err := s.reconciler.Reconcile(userID, balance.AccountID, &ReconcileRequest{
+			ClosingBalance: balance.ClosingBalance,
+			BalanceDate:    balance.BalanceDate,
+			Transactions:   statementRows,
+		})
+		if err != nil {
+			s.logger.Warn("Failed to reconcile statement balance",
+				zap.String("account_id", balance.AccountID.String()), zap.Error(err))
+			continue
+		}
+		result.Reconciliations = append(result.Reconciliations, *reconciliation)
+	}
+
+	var errorMsg string
+	if result.FailedRows > 0 {
+		errorMsg = fmt.Sprintf("%d rows failed to import", result.FailedRows)
+	}
+	if err := s.importJobRepo.Finish(job.ID, userID, result.TotalRows, result.ImportedRows, errorMsg); err != nil {
+		s.logger.Warn("Failed to update import job", zap.String("job_id", job.ID.String()), zap.Error(err))
+	}
+	// Rows skipped as duplicates were covered all the same
+	if result.ImportedRows > 0 || result.SkippedRows > 0 {
+		s.recordCoverage(userID, job.ID, rows, preview.AccountHints)
 	}
 
 	return result, nil
 }
 
+// resolveImportPayee returns the payee of an imported row, creating payees that
+// the preview only named. Results are cached per request, keyed by ID or name.
+func (s *ImportService) resolveImportPayee(userID uuid.UUID, tx *models.ParsedTransaction, cache map[string]*uuid.UUID) *uuid.UUID {
+	key := strings.ToLower(strings.TrimSpace(tx.PayeeName))
+	if tx.PayeeID != nil {
+		key = tx.PayeeID.String()
+	}
+	if key == "" {
+		return nil
+	}
+	if id, ok := cache[key]; ok {
+		return id
+	}
+
+	var id *uuid.UUID
+	if tx.PayeeID != nil {
+		if _, err := s.payeeRepo.GetByID(*tx.PayeeID, userID); err == nil {
+			id = tx.PayeeID
+		}
+	} else if name, err := validatePayeeName(tx.PayeeName); err == nil {
+		payee, err := s.payeeRepo.GetOrCreate(userID, name)
+		if err != nil {
+			s.logger.Warn("Failed to create payee", zap.String("payee", name), zap.Error(err))
+		} else {
+			id = &payee.ID
+		}
+	}
+	cache[key] = id
+	return id
+}
+
 // Internal method to create transaction (simplified version without full service dependencies)
-func (s *ImportService) createTransactionInternal(userID uuid.UUID, req *CreateTransactionRequest) (*models.Transaction, error) {
+func (s *ImportService) createTransactionInternal(userID uuid.UUID, req *CreateTransactionRequest, source models.ImportSource, externalID string, orderID string, importJobID *uuid.UUID) (*models.Transaction, error) {
 	if req.Amount <= 0 {
 		return nil, fmt.Errorf("amount must be greater than 0")
 	}
@@ -188,6 +435,14 @@ func (s *ImportService) createTransactionInternal(userID uuid.UUID, req *CreateT
 		return nil, fmt.Errorf("invalid account: %w", err)
 	}
 
+	// Files without currency information (e.g. QIF) use the account's currency
+	if req.Currency == "" {
+		req.Currency = account.Currency
+	}
+	if req.Currency == "" {
+		req.Currency = "CNY"
+	}
+
 	if req.CategoryID != nil {
 		_, err := s.categoryRepo.GetByID(*req.CategoryID, userID)
 		if err != nil {
@@ -195,16 +450,24 @@ func (s *ImportService) createTransactionInternal(userID uuid.UUID, req *CreateT
 		}
 	}
 
-	transaction, err := s.transactionRepo.Create(
+	transaction, err := s.transactionRepo.CreateImported(
 		userID,
 		req.AccountID,
 		req.CategoryID,
+		req.PayeeID,
 		req.Type,
 		req.Amount,
 		req.Currency,
 		req.Note,
 		req.TransactionDate,
+		string(source),
+		externalID,
+		orderID,
+		importJobID,
 	)
+	if err == repository.ErrDuplicateExternalID {
+		return nil, err
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to create transaction: %w", err)
 	}
@@ -222,514 +485,442 @@ func (s *ImportService) createTransactionInternal(userID uuid.UUID, req *CreateT
 }
 
 // parseAlipayCSV parses Alipay CSV format
-func (s *ImportService) parseAlipayCSV(r io.Reader) ([]models.ParsedTransaction, error) {
-	reader := csv.NewReader(r)
-	reader.LazyQuotes = true
-	reader.FieldsPerRecord = -1
+func (s *ImportService) parseAlipayCSV(r io.Reader, emit func(models.ParsedTransaction), report func(models.ParseDiagnostic)) error {
+	return streamCSV(r, alipayHeaderRows, isTimeHeader, parseRecords(s.parseAlipayRecord, emit, report))
+}
 
-	records, err := reader.ReadAll()
-	if err != nil {
-		return nil, err
+// parseAlipayRecord maps one Alipay record to a transaction; nil skips the record
+func (s *ImportService) parseAlipayRecord(header, record []string, diag *recordDiagnostics) *models.ParsedTransaction {
+	if !checkColumns(record, 3, diag) {
+		return nil
 	}
 
-	if len(records) < 2 {
-		return nil, fmt.Errorf("no data in file")
+	tx := models.ParsedTransaction{
+		RawData: make(map[string]string),
+		Currency: "CNY",
 	}
 
-	// Alipay CSV usually has a header row at index 0 or 1
-	// Find the actual header by looking for known column names
-	headerIndex := 0
-	for i, record := range records {
-		if len(record) > 0 && (strings.Contains(record[0], "交易时间") || strings.Contains(record[0], "时间")) {
-			headerIndex = i
-			break
-		}
-		if i > 5 { // Don't search too far
-			break
+	// Map fields by index (Alipay format is somewhat consistent)
+	// Common Alipay columns: 交易时间, 交易分类, 交易对方, 金额, 收/支, 支付方式
+	for j, field := range record {
+		if j < len(header) {
+			tx.RawData[header[j]] = field
 		}
 	}
 
-	transactions := make([]models.ParsedTransaction, 0)
-
-	for i := headerIndex + 1; i < len(records); i++ {
-		record := records[i]
-		if len(record) < 3 {
-			continue
-		}
+	// Parse transaction date
+	tx.TransactionDate = s.parseDateField(tx.RawData, []string{"交易时间", "时间", "日期"}, diag)
 
-		tx := models.ParsedTransaction{
-			RawData: make(map[string]string),
-			Currency: "CNY",
-		}
-
-		// Map fields by index (Alipay format is somewhat consistent)
-		// Common Alipay columns: 交易时间, 交易分类, 交易对方, 金额, 收/支, 支付方式
-		for j, field := range record {
-			if headerIndex < len(records) && j < len(records[headerIndex]) {
-				tx.RawData[records[headerIndex][j]] = field
-			}
-		}
-
-		// Parse transaction date
-		for _, key := range []string{"交易时间", "时间", "日期"} {
-			if val, ok := tx.RawData[key]; ok && val != "" {
-				tx.TransactionDate, _ = s.parseChineseDate(val)
-				break
-			}
-		}
-
-		// Parse amount
-		for _, key := range []string{"金额", "资金变动", "消费金额"} {
-			if val, ok := tx.RawData[key]; ok && val != "" {
-				tx.Amount, _ = s.parseAmount(val)
-				break
-			}
-		}
-
-		// Determine type (income/expense)
-		for _, key := range []string{"收/支", "类型", "收支类型"} {
-			if val, ok := tx.RawData[key]; ok {
-				if strings.Contains(val, "收") || strings.Contains(val, "收入") || strings.Contains(val, "+") {
-					tx.Type = models.TransactionTypeIncome
-				} else if strings.Contains(val, "支") || strings.Contains(val, "支出") || strings.Contains(val, "-") {
-					tx.Type = models.TransactionTypeExpense
-				}
-			}
-		}
+	// Parse amount
+	tx.Amount = s.parseAmountField(tx.RawData, []string{"金额", "资金变动", "消费金额"}, diag)
 
-		// Fallback: infer type from amount sign
-		if tx.Type == "" {
-			if tx.Amount < 0 {
+	// Determine type (income/expense)
+	for _, key := range []string{"收/支", "类型", "收支类型"} {
+		if val, ok := tx.RawData[key]; ok {
+			if strings.Contains(val, "收") || strings.Contains(val, "收入") || strings.Contains(val, "+") {
+				tx.Type = models.TransactionTypeIncome
+			} else if strings.Contains(val, "支") || strings.Contains(val, "支出") || strings.Contains(val, "-") {
 				tx.Type = models.TransactionTypeExpense
-				tx.Amount = math.Abs(tx.Amount)
-			} else if tx.Amount > 0 {
-				tx.Type = models.TransactionTypeExpense // Default to expense for Alipay
 			}
 		}
+	}
 
-		// Get counterparty/merchant
-		for _, key := range []string{"交易对方", "对方", "商户名称", "名称"} {
-			if val, ok := tx.RawData[key]; ok && val != "" {
-				tx.Counterparty = val
-				break
-			}
+	// Fallback: infer type from amount sign
+	if tx.Type == "" {
+		if tx.Amount < 0 {
+			tx.Type = models.TransactionTypeExpense
+			tx.Amount = math.Abs(tx.Amount)
+		} else if tx.Amount > 0 {
+			tx.Type = models.TransactionTypeExpense // Default to expense for Alipay
 		}
+	}
 
-		// Get account name
-		for _, key := range []string{"支付方式", "账户", "付款方式"} {
-			if val, ok := tx.RawData[key]; ok && val != "" {
-				tx.AccountName = val
-				break
-			}
+	// Get counterparty/merchant
+	for _, key := range []string{"交易对方", "对方", "商户名称", "名称"} {
+		if val, ok := tx.RawData[key]; ok && val != "" {
+			tx.Counterparty = val
+			break
 		}
+	}
 
-		// Get note
-		for _, key := range []string{"备注", "说明", "商品说明"} {
-			if val, ok := tx.RawData[key]; ok && val != "" {
-				tx.Note = val
-				break
-			}
+	// Get account name
+	for _, key := range []string{"支付方式", "账户", "付款方式"} {
+		if val, ok := tx.RawData[key]; ok && val != "" {
+			tx.AccountName = val
+			break
 		}
+	}
 
-		// Combine for category hint
-		tx.CategoryHint = strings.Join([]string{tx.Counterparty, tx.Note}, " ")
-
-		if tx.Amount > 0 && !tx.TransactionDate.IsZero() {
-			transactions = append(transactions, tx)
+	// Get note
+	for _, key := range []string{"备注", "说明", "商品说明"} {
+		if val, ok := tx.RawData[key]; ok && val != "" {
+			tx.Note = val
+			break
 		}
 	}
 
-	return transactions, nil
+	// Combine for category hint
+	tx.CategoryHint = strings.Join([]string{tx.Counterparty, tx.Note}, " ")
+
+	if tx.Amount > 0 && !tx.TransactionDate.IsZero() {
+		return &tx
+	}
+	if tx.Amount < 0 {
+		diag.fail("", "", "negative amount")
+	}
+	return nil
 }
 
 // parseWeChatCSV parses WeChat CSV format
-func (s *ImportService) parseWeChatCSV(r io.Reader) ([]models.ParsedTransaction, error) {
-	reader := csv.NewReader(r)
-	reader.LazyQuotes = true
-	reader.FieldsPerRecord = -1
-	reader.Comma = ','
+func (s *ImportService) parseWeChatCSV(r io.Reader, emit func(models.ParsedTransaction), report func(models.ParseDiagnostic)) error {
+	return streamCSV(r, alipayHeaderRows, isTimeHeader, parseRecords(s.parseWeChatRecord, emit, report))
+}
 
-	records, err := reader.ReadAll()
-	if err != nil {
-		return nil, err
+// parseWeChatRecord maps one WeChat record to a transaction; nil skips the record
+func (s *ImportService) parseWeChatRecord(header, record []string, diag *recordDiagnostics) *models.ParsedTransaction {
+	if !checkColumns(record, 3, diag) {
+		return nil
 	}
 
-	if len(records) < 2 {
-		return nil, fmt.Errorf("no data in file")
+	tx := models.ParsedTransaction{
+		RawData: make(map[string]string),
+		Currency: "CNY",
 	}
 
-	// Find header row
-	headerIndex := 0
-	for i, record := range records {
-		if len(record) > 0 && (strings.Contains(record[0], "交易时间") || strings.Contains(record[0], "时间")) {
-			headerIndex = i
-			break
-		}
-		if i > 5 {
-			break
+	// Store raw data
+	for j, field := range record {
+		if j < len(header) {
+			tx.RawData[header[j]] = field
 		}
 	}
 
-	transactions := make([]models.ParsedTransaction, 0)
+	// Parse transaction date
+	tx.TransactionDate = s.parseDateField(tx.RawData, []string{"交易时间", "时间", "日期"}, diag)
 
-	for i := headerIndex + 1; i < len(records); i++ {
-		record := records[i]
-		if len(record) < 3 {
-			continue
-		}
+	// Parse amount
+	tx.Amount = s.parseAmountField(tx.RawData, []string{"金额", "资金变动", "消费金额", "收/支金额"}, diag)
 
-		tx := models.ParsedTransaction{
-			RawData: make(map[string]string),
-			Currency: "CNY",
-		}
-
-		// Store raw data
-		for j, field := range record {
-			if headerIndex < len(records) && j < len(records[headerIndex]) {
-				tx.RawData[records[headerIndex][j]] = field
-			}
-		}
-
-		// Parse transaction date
-		for _, key := range []string{"交易时间", "时间", "日期"} {
-			if val, ok := tx.RawData[key]; ok && val != "" {
-				tx.TransactionDate, _ = s.parseChineseDate(val)
-				break
-			}
-		}
-
-		// Parse amount
-		for _, key := range []string{"金额", "资金变动", "消费金额", "收/支金额"} {
-			if val, ok := tx.RawData[key]; ok && val != "" {
-				tx.Amount, _ = s.parseAmount(val)
-				break
+	// Determine type
+	for _, key := range []string{"收/支", "类型", "收支类型", "交易类型"} {
+		if val, ok := tx.RawData[key]; ok {
+			if strings.Contains(val, "收") || strings.Contains(val, "收入") || strings.Contains(val, "转账") && strings.Contains(val, "存入") {
+				tx.Type = models.TransactionTypeIncome
+			} else if strings.Contains(val, "支") || strings.Contains(val, "支出") || strings.Contains(val, "消费") || strings.Contains(val, "转账") && strings.Contains(val, "转出") {
+				tx.Type = models.TransactionTypeExpense
 			}
 		}
+	}
 
-		// Determine type
-		for _, key := range []string{"收/支", "类型", "收支类型", "交易类型"} {
+	// Fallback type detection
+	if tx.Type == "" {
+		for _, key := range []string{"交易类型", "类型"} {
 			if val, ok := tx.RawData[key]; ok {
-				if strings.Contains(val, "收") || strings.Contains(val, "收入") || strings.Contains(val, "转账") && strings.Contains(val, "存入") {
-					tx.Type = models.TransactionTypeIncome
-				} else if strings.Contains(val, "支") || strings.Contains(val, "支出") || strings.Contains(val, "消费") || strings.Contains(val, "转账") && strings.Contains(val, "转出") {
+				if strings.Contains(val, "转账") {
+					// Need more info for transfers
 					tx.Type = models.TransactionTypeExpense
+				} else if strings.Contains(val, "红包") || strings.Contains(val, "退款") {
+					tx.Type = models.TransactionTypeIncome
 				}
 			}
 		}
+	}
 
-		// Fallback type detection
-		if tx.Type == "" {
-			for _, key := range []string{"交易类型", "类型"} {
-				if val, ok := tx.RawData[key]; ok {
-					if strings.Contains(val, "转账") {
-						// Need more info for transfers
-						tx.Type = models.TransactionTypeExpense
-					} else if strings.Contains(val, "红包") || strings.Contains(val, "退款") {
-						tx.Type = models.TransactionTypeIncome
-					}
-				}
-			}
-		}
-
-		if tx.Type == "" && tx.Amount > 0 {
-			tx.Type = models.TransactionTypeExpense // Default to expense
-		}
+	if tx.Type == "" && tx.Amount > 0 {
+		tx.Type = models.TransactionTypeExpense // Default to expense
+	}
 
-		// Get counterparty
-		for _, key := range []string{"交易对方", "对方", "商户名称", "名称", "联系人"} {
-			if val, ok := tx.RawData[key]; ok && val != "" {
-				tx.Counterparty = val
-				break
-			}
+	// Get counterparty
+	for _, key := range []string{"交易对方", "对方", "商户名称", "名称", "联系人"} {
+		if val, ok := tx.RawData[key]; ok && val != "" {
+			tx.Counterparty = val
+			break
 		}
+	}
 
-		// Get account
-		for _, key := range []string{"支付方式", "账户", "付款方式", "收/付款方式"} {
-			if val, ok := tx.RawData[key]; ok && val != "" {
-				tx.AccountName = val
-				break
-			}
+	// Get account
+	for _, key := range []string{"支付方式", "账户", "付款方式", "收/付款方式"} {
+		if val, ok := tx.RawData[key]; ok && val != "" {
+			tx.AccountName = val
+			break
 		}
+	}
 
-		// Get note
-		for _, key := range []string{"备注", "说明", "商品", "商品说明"} {
-			if val, ok := tx.RawData[key]; ok && val != "" {
-				tx.Note = val
-				break
-			}
+	// Get note
+	for _, key := range []string{"备注", "说明", "商品", "商品说明"} {
+		if val, ok := tx.RawData[key]; ok && val != "" {
+			tx.Note = val
+			break
 		}
+	}
 
-		tx.CategoryHint = strings.Join([]string{tx.Counterparty, tx.Note}, " ")
+	tx.CategoryHint = strings.Join([]string{tx.Counterparty, tx.Note}, " ")
 
-		if tx.Amount > 0 && !tx.TransactionDate.IsZero() {
-			transactions = append(transactions, tx)
-		}
+	if tx.Amount > 0 && !tx.TransactionDate.IsZero() {
+		return &tx
 	}
-
-	return transactions, nil
+	if tx.Amount < 0 {
+		diag.fail("", "", "negative amount")
+	}
+	return nil
 }
 
 // parseBankCSV parses generic bank CSV format
-func (s *ImportService) parseBankCSV(r io.Reader) ([]models.ParsedTransaction, error) {
-	reader := csv.NewReader(r)
-	reader.LazyQuotes = true
-	reader.FieldsPerRecord = -1
+func (s *ImportService) parseBankCSV(r io.Reader, emit func(models.ParsedTransaction), report func(models.ParseDiagnostic)) error {
+	return streamCSV(r, bankHeaderRows, isBankHeader, parseRecords(s.parseBankRecord, emit, report))
+}
 
-	records, err := reader.ReadAll()
-	if err != nil {
-		return nil, err
+// parseBankRecord maps one bank record to a transaction; nil skips the record
+func (s *ImportService) parseBankRecord(header, record []string, diag *recordDiagnostics) *models.ParsedTransaction {
+	if !checkColumns(record, 3, diag) {
+		return nil
 	}
 
-	if len(records) < 2 {
-		return nil, fmt.Errorf("no data in file")
+	tx := models.ParsedTransaction{
+		RawData: make(map[string]string),
+		Currency: "CNY",
 	}
 
-	// Find header row
-	headerIndex := 0
-	for i, record := range records {
-		recordStr := strings.Join(record, " ")
-		if strings.Contains(recordStr, "日期") || strings.Contains(recordStr, "date") ||
-		   strings.Contains(recordStr, "摘要") || strings.Contains(recordStr, "description") {
-			headerIndex = i
-			break
-		}
-		if i > 5 {
-			break
+	// Store raw data with header keys
+	for j, field := range record {
+		key := fmt.Sprintf("col%d", j)
+		if j < len(header) {
+			key = header[j]
 		}
+		tx.RawData[key] = field
 	}
 
-	transactions := make([]models.ParsedTransaction, 0)
-
-	for i := headerIndex + 1; i < len(records); i++ {
-		record := records[i]
-		if len(record) < 3 {
-			continue
-		}
-
-		tx := models.ParsedTransaction{
-			RawData: make(map[string]string),
-			Currency: "CNY",
-		}
-
-		// Store raw data with header keys
-		for j, field := range record {
-			key := fmt.Sprintf("col%d", j)
-			if headerIndex < len(records) && j < len(records[headerIndex]) {
-				key = records[headerIndex][j]
+	// Try to parse date from common column names
+	dateFound := false
+	var dateKeys []string // 有值但无法解析的日期列
+	for key, val := range tx.RawData {
+		if (strings.Contains(key, "日期") || strings.Contains(key, "date") ||
+		    strings.Contains(key, "time") || strings.Contains(key, "时间")) && val != "" {
+			if t, err := s.parseChineseDate(val); err == nil {
+				tx.TransactionDate = t
+				dateFound = true
+				break
+			} else if t, err := time.Parse("2006-01-02", val); err == nil {
+				tx.TransactionDate = t
+				dateFound = true
+				break
+			} else if t, err := time.Parse("2006/01/02", val); err == nil {
+				tx.TransactionDate = t
+				dateFound = true
+				break
 			}
-			tx.RawData[key] = field
+			dateKeys = append(dateKeys, key)
 		}
+	}
 
-		// Try to parse date from common column names
-		dateFound := false
-		for key, val := range tx.RawData {
-			if (strings.Contains(key, "日期") || strings.Contains(key, "date") ||
-			    strings.Contains(key, "time") || strings.Contains(key, "时间")) && val != "" {
-				if t, err := s.parseChineseDate(val); err == nil {
-					tx.TransactionDate = t
-					dateFound = true
-					break
-				} else if t, err := time.Parse("2006-01-02", val); err == nil {
-					tx.TransactionDate = t
-					dateFound = true
-					break
-				} else if t, err := time.Parse("2006/01/02", val); err == nil {
-					tx.TransactionDate = t
-					dateFound = true
-					break
-				}
-			}
+	if !dateFound {
+		sort.Strings(dateKeys)
+		for _, key := range dateKeys {
+			diag.fail(key, tx.RawData[key], "unable to parse date")
 		}
-
-		if !dateFound {
-			continue
+		if len(dateKeys) == 0 {
+			diag.fail("", "", "no transaction date")
 		}
+		return nil
+	}
 
-		// Try to parse amount
-		amountFound := false
-		for key, val := range tx.RawData {
-			if (strings.Contains(key, "金额") || strings.Contains(key, "amount") ||
-			    strings.Contains(key, "支出") || strings.Contains(key, "收入") ||
-			    strings.Contains(key, "debit") || strings.Contains(key, "credit")) && val != "" {
-				if amt, err := s.parseAmount(val); err == nil && amt > 0 {
-					tx.Amount = amt
-					// Determine type from column name
-					if strings.Contains(key, "支出") || strings.Contains(key, "debit") || strings.Contains(key, "out") {
-						tx.Type = models.TransactionTypeExpense
-					} else if strings.Contains(key, "收入") || strings.Contains(key, "credit") || strings.Contains(key, "in") {
-						tx.Type = models.TransactionTypeIncome
-					}
-					amountFound = true
-					break
+	// Try to parse amount
+	amountFound := false
+	var amountKey string
+	var badAmountKeys, negativeKeys []string
+	for key, val := range tx.RawData {
+		if (strings.Contains(key, "金额") || strings.Contains(key, "amount") ||
+		    strings.Contains(key, "支出") || strings.Contains(key, "收入") ||
+		    strings.Contains(key, "debit") || strings.Contains(key, "credit")) && val != "" {
+			amt, err := s.parseAmount(val)
+			if err == nil && amt > 0 {
+				tx.Amount = amt
+				amountKey = key
+				// Determine type from column name
+				if strings.Contains(key, "支出") || strings.Contains(key, "debit") || strings.Contains(key, "out") {
+					tx.Type = models.TransactionTypeExpense
+				} else if strings.Contains(key, "收入") || strings.Contains(key, "credit") || strings.Contains(key, "in") {
+					tx.Type = models.TransactionTypeIncome
 				}
+				amountFound = true
+				break
+			}
+			// Placeholders and zeros fill the column a row does not use
+			if err != nil && !isBlankAmount(val) {
+				badAmountKeys = append(badAmountKeys, key)
+			} else if err == nil && amt < 0 {
+				negativeKeys = append(negativeKeys, key)
 			}
 		}
+	}
 
-		// Try separate income/expense columns
-		if !amountFound {
-			var incomeAmt, expenseAmt float64
-			for key, val := range tx.RawData {
-				if (strings.Contains(key, "收入") || strings.Contains(key, "credit")) && val != "" {
-					incomeAmt, _ = s.parseAmount(val)
-				}
-				if (strings.Contains(key, "支出") || strings.Contains(key, "debit")) && val != "" {
-					expenseAmt, _ = s.parseAmount(val)
-				}
+	// Try separate income/expense columns
+	if !amountFound {
+		var incomeAmt, expenseAmt float64
+		for key, val := range tx.RawData {
+			if (strings.Contains(key, "收入") || strings.Contains(key, "credit")) && val != "" {
+				incomeAmt, _ = s.parseAmount(val)
 			}
-			if incomeAmt > 0 {
-				tx.Amount = incomeAmt
-				tx.Type = models.TransactionTypeIncome
-				amountFound = true
-			} else if expenseAmt > 0 {
-				tx.Amount = expenseAmt
-				tx.Type = models.TransactionTypeExpense
-				amountFound = true
+			if (strings.Contains(key, "支出") || strings.Contains(key, "debit")) && val != "" {
+				expenseAmt, _ = s.parseAmount(val)
 			}
 		}
-
-		if !amountFound || tx.Type == "" {
-			continue
+		if incomeAmt > 0 {
+			tx.Amount = incomeAmt
+			tx.Type = models.TransactionTypeIncome
+			amountFound = true
+		} else if expenseAmt > 0 {
+			tx.Amount = expenseAmt
+			tx.Type = models.TransactionTypeExpense
+			amountFound = true
 		}
+	}
 
-		// Get description/note
-		for key, val := range tx.RawData {
-			if (strings.Contains(key, "摘要") || strings.Contains(key, "description") ||
-			    strings.Contains(key, "备注") || strings.Contains(key, "note")) && val != "" {
-				tx.Note = val
-				break
-			}
+	if !amountFound {
+		sort.Strings(badAmountKeys)
+		for _, key := range badAmountKeys {
+			diag.fail(key, tx.RawData[key], "unable to parse amount")
 		}
-
-		// Get counterparty
-		for key, val := range tx.RawData {
-			if (strings.Contains(key, "对方") || strings.Contains(key, "merchant") ||
-			    strings.Contains(key, "payee") || strings.Contains(key, "收款人")) && val != "" {
-				tx.Counterparty = val
-				break
-			}
+		sort.Strings(negativeKeys)
+		for _, key := range negativeKeys {
+			diag.fail(key, tx.RawData[key], "negative amount")
+		}
+		if len(badAmountKeys) == 0 && len(negativeKeys) == 0 {
+			diag.fail("", "", "no amount")
 		}
+		return nil
+	}
+	if tx.Type == "" {
+		diag.fail(amountKey, tx.RawData[amountKey], "unable to tell income from expense")
+		return nil
+	}
 
-		// Get account info
-		for key, val := range tx.RawData {
-			if (strings.Contains(key, "账户") || strings.Contains(key, "account") ||
-			    strings.Contains(key, "卡号")) && val != "" {
-				tx.AccountName = val
-				break
-			}
+	// Get description/note
+	for key, val := range tx.RawData {
+		if (strings.Contains(key, "摘要") || strings.Contains(key, "description") ||
+		    strings.Contains(key, "备注") || strings.Contains(key, "note")) && val != "" {
+			tx.Note = val
+			break
 		}
+	}
 
-		tx.CategoryHint = strings.Join([]string{tx.Counterparty, tx.Note}, " ")
+	// Get counterparty
+	for key, val := range tx.RawData {
+		if (strings.Contains(key, "对方") || strings.Contains(key, "merchant") ||
+		    strings.Contains(key, "payee") || strings.Contains(key, "收款人")) && val != "" {
+			tx.Counterparty = val
+			break
+		}
+	}
 
-		transactions = append(transactions, tx)
+	// Get account info
+	for key, val := range tx.RawData {
+		if (strings.Contains(key, "账户") || strings.Contains(key, "account") ||
+		    strings.Contains(key, "卡号")) && val != "" {
+			tx.AccountName = val
+			break
+		}
 	}
 
-	return transactions, nil
+	tx.CategoryHint = strings.Join([]string{tx.Counterparty, tx.Note}, " ")
+
+	return &tx
 }
 
 // parseGenericCSV parses generic CSV with flexible column mapping
-func (s *ImportService) parseGenericCSV(r io.Reader) ([]models.ParsedTransaction, error) {
-	reader := csv.NewReader(r)
-	reader.LazyQuotes = true
-	reader.FieldsPerRecord = -1
+func (s *ImportService) parseGenericCSV(r io.Reader, emit func(models.ParsedTransaction), report func(models.ParseDiagnostic)) error {
+	return streamCSV(r, 1, nil, parseRecords(s.parseGenericRecord, emit, report))
+}
 
-	records, err := reader.ReadAll()
-	if err != nil {
-		return nil, err
+// parseGenericRecord maps one generic record to a transaction; nil skips the record
+func (s *ImportService) parseGenericRecord(header, record []string, diag *recordDiagnostics) *models.ParsedTransaction {
+	if !checkColumns(record, 2, diag) {
+		return nil
 	}
 
-	if len(records) < 2 {
-		return nil, fmt.Errorf("no data in file")
+	tx := models.ParsedTransaction{
+		RawData: make(map[string]string),
+		Currency: "CNY",
 	}
 
-	// Use first row as header
-	headerIndex := 0
-	transactions := make([]models.ParsedTransaction, 0)
+	// Store raw data
+	for j, field := range record {
+		key := fmt.Sprintf("col%d", j)
+		if j < len(header) {
+			key = header[j]
+		}
+		tx.RawData[key] = field
+	}
 
-	for i := headerIndex + 1; i < len(records); i++ {
-		record := records[i]
-		if len(record) < 2 {
+	// Try to find date in any column
+	for _, val := range tx.RawData {
+		if val == "" {
 			continue
 		}
-
-		tx := models.ParsedTransaction{
-			RawData: make(map[string]string),
-			Currency: "CNY",
+		if t, err := s.parseChineseDate(val); err == nil {
+			tx.TransactionDate = t
+			break
+		} else if t, err := time.Parse("2006-01-02", val); err == nil {
+			tx.TransactionDate = t
+			break
+		} else if t, err := time.Parse("2006/01/02", val); err == nil {
+			tx.TransactionDate = t
+			break
 		}
+	}
 
-		// Store raw data
-		for j, field := range record {
-			key := fmt.Sprintf("col%d", j)
-			if headerIndex < len(records) && j < len(records[headerIndex]) {
-				key = records[headerIndex][j]
-			}
-			tx.RawData[key] = field
+	// Try to find amount in any column
+	for key, val := range tx.RawData {
+		if val == "" {
+			continue
 		}
-
-		// Try to find date in any column
-		for _, val := range tx.RawData {
-			if val == "" {
-				continue
-			}
-			if t, err := s.parseChineseDate(val); err == nil {
-				tx.TransactionDate = t
-				break
-			} else if t, err := time.Parse("2006-01-02", val); err == nil {
-				tx.TransactionDate = t
-				break
-			} else if t, err := time.Parse("2006/01/02", val); err == nil {
-				tx.TransactionDate = t
-				break
+		if amt, err := s.parseAmount(val); err == nil && amt > 0 {
+			tx.Amount = amt
+			// Guess type from sign or column name
+			if strings.HasPrefix(strings.TrimSpace(val), "-") {
+				tx.Type = models.TransactionTypeExpense
+				tx.Amount = math.Abs(tx.Amount)
+			} else if strings.Contains(key, "支出") || strings.Contains(key, "expense") {
+				tx.Type = models.TransactionTypeExpense
+			} else if strings.Contains(key, "收入") || strings.Contains(key, "income") {
+				tx.Type = models.TransactionTypeIncome
+			} else {
+				tx.Type = models.TransactionTypeExpense // Default to expense
 			}
+			break
 		}
+	}
 
-		// Try to find amount in any column
-		for key, val := range tx.RawData {
-			if val == "" {
-				continue
-			}
-			if amt, err := s.parseAmount(val); err == nil && amt > 0 {
-				tx.Amount = amt
-				// Guess type from sign or column name
-				if strings.HasPrefix(strings.TrimSpace(val), "-") {
-					tx.Type = models.TransactionTypeExpense
-					tx.Amount = math.Abs(tx.Amount)
-				} else if strings.Contains(key, "支出") || strings.Contains(key, "expense") {
-					tx.Type = models.TransactionTypeExpense
-				} else if strings.Contains(key, "收入") || strings.Contains(key, "income") {
-					tx.Type = models.TransactionTypeIncome
-				} else {
-					tx.Type = models.TransactionTypeExpense // Default to expense
-				}
-				break
-			}
+	// Use other columns as note
+	var notes []string
+	for _, val := range tx.RawData {
+		if val == "" {
+			continue
 		}
-
-		// Use other columns as note
-		var notes []string
-		for _, val := range tx.RawData {
-			if val == "" {
-				continue
-			}
-			// Skip columns that are already used for date/amount
-			if _, err := s.parseAmount(val); err == nil {
-				continue
-			}
-			if _, err := time.Parse("2006-01-02", val); err == nil {
-				continue
-			}
-			if _, err := s.parseChineseDate(val); err == nil {
-				continue
-			}
-			notes = append(notes, val)
+		// Skip columns that are already used for date/amount
+		if _, err := s.parseAmount(val); err == nil {
+			continue
 		}
-		tx.Note = strings.Join(notes, " | ")
-
-		if tx.Amount > 0 && !tx.TransactionDate.IsZero() && tx.Type != "" {
-			transactions = append(transactions, tx)
+		if _, err := time.Parse("2006-01-02", val); err == nil {
+			continue
+		}
+		if _, err := s.parseChineseDate(val); err == nil {
+			continue
 		}
+		notes = append(notes, val)
 	}
+	tx.Note = strings.Join(notes, " | ")
 
-	return transactions, nil
+	if tx.Amount > 0 && !tx.TransactionDate.IsZero() && tx.Type != "" {
+		return &tx
+	}
+	// Any column may hold the date or amount, so no single field is to blame
+	if tx.TransactionDate.IsZero() {
+		diag.fail("", "", "no column holds a date")
+	}
+	if tx.Amount <= 0 {
+		diag.fail("", "", "no column holds an amount")
+	}
+	return nil
 }
 
 // parseChineseDate parses Chinese date formats like "2024-01-15 14:30:00" or "2024/01/15"
@@ -777,21 +968,125 @@ func (s *ImportService) parseAmount(str string) (float64, error) {
 	return strconv.ParseFloat(str, 64)
 }
 
-// checkForDuplicate checks if a transaction already exists
-func (s *ImportService) checkForDuplicate(userID uuid.UUID, tx *models.ParsedTransaction) (bool, error) {
-	// Simple duplicate check: same date, amount, and similar note
-	existing, err := s.transactionRepo.GetByDateRangeAndAmount(
-		userID,
-		tx.TransactionDate.Add(-24*time.Hour),
-		tx.TransactionDate.Add(24*time.Hour),
-		tx.Amount-0.01,
-		tx.Amount+0.01,
-	)
-	if err != nil {
-		return false, err
+// externalIDColumns are the columns holding each source's unique row ID
+var externalIDColumns = map[models.ImportSource][]string{
+	models.ImportSourceAlipay:  {"交易号", "交易订单号"},
+	models.ImportSourceWeChat:  {"交易单号"},
+	models.ImportSourceJD:      {"订单号", "交易号"},
+	models.ImportSourceBank:    {"流水号", "交易流水号", "交易流水", "流水号码", "交易参考号"},
+	models.ImportSourceGeneric: {"流水号", "交易流水号", "交易号", "交易单号", "订单号"},
+}
+
+// AssignExternalIDs fills ExternalID from the source's ID column for parsers
+// that don't set it themselves, for the rows of one whole file
+func AssignExternalIDs(source models.ImportSource, transactions []models.ParsedTransaction) {
+	newExternalIDAssigner(source).assign(transactions)
+}
+
+// externalIDAssigner assigns external IDs to the rows of one file as they are
+// parsed. An ID read from a bill's ID column is combined with the row's type,
+// signed amount and time: payment apps reuse one order number for a purchase
+// and its refunds, and a row's key must not depend on which of them the file
+// happens to contain. Statement formats set unique IDs themselves; one that is
+// repeated anyway gets the row's fingerprint too. Rows alike in every part of
+// the key are numbered, which is stable since they are interchangeable.
+type externalIDAssigner struct {
+	columns []string
+	raw     map[string]bool // IDs as the parser or the file gave them
+	seen    map[string]int  // assigned keys
+}
+
+func newExternalIDAssigner(source models.ImportSource) *externalIDAssigner {
+	return &externalIDAssigner{
+		columns: externalIDColumns[source],
+		raw:     make(map[string]bool),
+		seen:    make(map[string]int),
+	}
+}
+
+func (a *externalIDAssigner) assign(transactions []models.ParsedTransaction) {
+	for i := range transactions {
+		tx := &transactions[i]
+		fromColumn := false
+		if tx.ExternalID == "" && len(a.columns) > 0 {
+			// Header cells often carry padding ("交易号  "), so match on trimmed keys
+			raw := make(map[string]string, len(tx.RawData))
+			for key, val := range tx.RawData {
+				raw[strings.TrimSpace(key)] = val
+			}
+			for _, column := range a.columns {
+				// Values are padded with tabs or written as ="..." to keep Excel from mangling them
+				if id := strings.Trim(raw[column], " \t\"="); id != "" {
+					tx.ExternalID = id
+					fromColumn = true
+					break
+				}
+			}
+		}
+		if tx.ExternalID == "" {
+			continue
+		}
+
+		key := tx.ExternalID
+		if fromColumn || a.raw[key] {
+			key = externalIDFingerprint(key, tx)
+		}
+		a.raw[tx.ExternalID] = true
+
+		a.seen[key]++
+		if n := a.seen[key]; n > 1 {
+			key = fmt.Sprintf("%s#%d", key, n)
+		}
+		tx.ExternalID = key
+	}
+}
+
+// externalIDFingerprint combines a source ID with what does not change about
+// the row between exports: its type, signed amount and time
+func externalIDFingerprint(id string, tx *models.ParsedTransaction) string {
+	amount := tx.Amount
+	if tx.Type == models.TransactionTypeExpense {
+		amount = -amount
+	}
+	return fmt.Sprintf("%s#%s:%+.2f@%s", id, tx.Type, amount, tx.TransactionDate.UTC().Format("20060102T150405"))
+}
+
+// learnAccountAlias remembers the account the user picked for the row's account
+// name, so that later imports resolve the name to it. Accounts an alias or rule
+// already suggested are not learned again, nor are names already learned in
+// this request; seen may be nil.
+func (s *ImportService) learnAccountAlias(userID uuid.UUID, tx *models.ParsedTransaction, accountID uuid.UUID, seen map[string]bool) {
+	if tx.SuggestedAccountID != nil && *tx.SuggestedAccountID == accountID {
+		return
+	}
+	label := AccountAliasLabel(tx.AccountName, tx.ParsedAccountNumber)
+	key := NormalizeAccountAliasKey(label)
+	if key == "" || seen[key] {
+		return
 	}
+	if seen != nil {
+		seen[key] = true
+	}
+
+	if err := s.accountRepo.LearnAlias(&models.AccountAlias{
+		UserID:    userID,
+		AccountID: accountID,
+		Alias:     label,
+		AliasKey:  key,
+	}); err != nil {
+		s.logger.Warn("Failed to learn account alias", zap.Error(err))
+	}
+}
 
-	return len(existing) > 0, nil
+// withoutAccount drops the account with the given ID from the list
+func withoutAccount(accounts []models.Account, id uuid.UUID) []models.Account {
+	result := make([]models.Account, 0, len(accounts))
+	for _, account := range accounts {
+		if account.ID != id {
+			result = append(result, account)
+		}
+	}
+	return result
 }
 
 // findMatchingAccounts finds accounts that match the given name hint
@@ -810,122 +1105,83 @@ func (s *ImportService) findMatchingAccounts(nameHint string, accounts []models.
 }
 
 // parseJDCSV parses JD (京东) bill CSV format
-func (s *ImportService) parseJDCSV(r io.Reader) ([]models.ParsedTransaction, error) {
-	reader := csv.NewReader(r)
-	reader.LazyQuotes = true
-	reader.FieldsPerRecord = -1
-	reader.Comma = ','
+func (s *ImportService) parseJDCSV(r io.Reader, emit func(models.ParsedTransaction), report func(models.ParseDiagnostic)) error {
+	return streamCSV(r, jdHeaderRows, isJDHeader, parseRecords(s.parseJDRecord, emit, report))
+}
 
-	records, err := reader.ReadAll()
-	if err != nil {
-		return nil, err
+// parseJDRecord maps one JD record to a transaction; nil skips the record
+func (s *ImportService) parseJDRecord(header, record []string, diag *recordDiagnostics) *models.ParsedTransaction {
+	if !checkColumns(record, 3, diag) {
+		return nil
 	}
 
-	if len(records) < 2 {
-		return nil, fmt.Errorf("no data in file")
+	tx := models.ParsedTransaction{
+		RawData:  make(map[string]string),
+		Currency: "CNY",
+		Source:   models.ImportSourceJD,
 	}
 
-	// Find header row - JD format varies
-	headerIndex := 0
-	for i, record := range records {
-		if len(record) > 0 {
-			headerStr := strings.Join(record, "")
-			if strings.Contains(headerStr, "订单号") ||
-				strings.Contains(headerStr, "消费时间") ||
-				strings.Contains(headerStr, "金额") {
-				headerIndex = i
-				break
-			}
-		}
-		if i > 10 {
-			break
+	// Store raw data
+	for j, field := range record {
+		if j < len(header) {
+			tx.RawData[header[j]] = field
 		}
 	}
 
-	transactions := make([]models.ParsedTransaction, 0)
-
-	for i := headerIndex + 1; i < len(records); i++ {
-		record := records[i]
-		if len(record) < 3 {
-			continue
-		}
-
-		tx := models.ParsedTransaction{
-			RawData:  make(map[string]string),
-			Currency: "CNY",
-			Source:   models.ImportSourceJD,
-		}
+	// Parse fields for JD format
+	// 订单号、消费时间、金额、商品名称、订单状态、支付方式等
 
-		// Store raw data
-		for j, field := range record {
-			if headerIndex < len(records) && j < len(records[headerIndex]) {
-				tx.RawData[records[headerIndex][j]] = field
-			}
-		}
+	// Parse date
+	tx.TransactionDate = s.parseDateField(tx.RawData, []string{"消费时间", "下单时间", "时间", "日期"}, diag)
 
-		// Parse fields for JD format
-		// 订单号、消费时间、金额、商品名称、订单状态、支付方式等
+	// Parse amount
+	tx.Amount = s.parseAmountField(tx.RawData, []string{"金额", "实付金额", "支付金额", "总价", "订单金额"}, diag)
 
-		// Parse date
-		for _, key := range []string{"消费时间", "下单时间", "时间", "日期"} {
-			if val, ok := tx.RawData[key]; ok && val != "" {
-				tx.TransactionDate, _ = s.parseChineseDate(val)
-				break
-			}
-		}
-
-		// Parse amount
-		for _, key := range []string{"金额", "实付金额", "支付金额", "总价", "订单金额"} {
-			if val, ok := tx.RawData[key]; ok && val != "" {
-				tx.Amount, _ = s.parseAmount(val)
-				break
-			}
-		}
-
-		// Determine type (JD is mostly expense)
-		tx.Type = models.TransactionTypeExpense
-		for _, key := range []string{"订单状态", "类型", "收支类型"} {
-			if val, ok := tx.RawData[key]; ok {
-				if strings.Contains(val, "退款") || strings.Contains(val, "退") || strings.Contains(val, "取消") {
-					tx.Type = models.TransactionTypeIncome // 退款视为收入
-				}
+	// Determine type (JD is mostly expense)
+	tx.Type = models.TransactionTypeExpense
+	for _, key := range []string{"订单状态", "类型", "收支类型"} {
+		if val, ok := tx.RawData[key]; ok {
+			if strings.Contains(val, "退款") || strings.Contains(val, "退") || strings.Contains(val, "取消") {
+				tx.Type = models.TransactionTypeIncome // 退款视为收入
 			}
 		}
+	}
 
-		// Get product info
-		for _, key := range []string{"商品名称", "商品", "名称", "商品标题", "订单描述"} {
-			if val, ok := tx.RawData[key]; ok && val != "" {
-				tx.Note = val
-				break
-			}
+	// Get product info
+	for _, key := range []string{"商品名称", "商品", "名称", "商品标题", "订单描述"} {
+		if val, ok := tx.RawData[key]; ok && val != "" {
+			tx.Note = val
+			break
 		}
+	}
 
-		// Get payment method (as account name hint)
-		for _, key := range []string{"支付方式", "支付渠道", "付款方式", "支付工具"} {
-			if val, ok := tx.RawData[key]; ok && val != "" {
-				tx.AccountName = "京东" + val
-				break
-			}
+	// Get payment method (as account name hint)
+	for _, key := range []string{"支付方式", "支付渠道", "付款方式", "支付工具"} {
+		if val, ok := tx.RawData[key]; ok && val != "" {
+			tx.AccountName = "京东" + val
+			break
 		}
+	}
 
-		// Get merchant/counterparty
-		for _, key := range []string{"商户", "商家", "店铺名称", "卖家"} {
-			if val, ok := tx.RawData[key]; ok && val != "" {
-				tx.Counterparty = val
-				break
-			}
+	// Get merchant/counterparty
+	for _, key := range []string{"商户", "商家", "店铺名称", "卖家"} {
+		if val, ok := tx.RawData[key]; ok && val != "" {
+			tx.Counterparty = val
+			break
 		}
+	}
 
-		if tx.Counterparty == "" && tx.Note != "" {
-			tx.Counterparty = "京东购物"
-		}
+	if tx.Counterparty == "" && tx.Note != "" {
+		tx.Counterparty = "京东购物"
+	}
 
-		tx.CategoryHint = strings.Join([]string{tx.Counterparty, tx.Note}, " ")
+	tx.CategoryHint = strings.Join([]string{tx.Counterparty, tx.Note}, " ")
 
-		if tx.Amount > 0 && !tx.TransactionDate.IsZero() {
-			transactions = append(transactions, tx)
-		}
+	if tx.Amount > 0 && !tx.TransactionDate.IsZero() {
+		return &tx
 	}
-
-	return transactions, nil
+	if tx.Amount < 0 {
+		diag.fail("", "", "negative amount")
+	}
+	return nil
 }
@@ -0,0 +1,346 @@
+package services
+
+import (
+	"account/internal/business/models"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// importChunkSize is how many parsed rows are enriched and checked for
+// duplicates at a time, so that a large file costs one query per chunk
+const importChunkSize = 500
+
+// Rows searched for the header, counted from the top of the file
+const (
+	alipayHeaderRows = 7
+	bankHeaderRows   = 7
+	jdHeaderRows     = 12
+)
+
+// duplicateWindow is how far apart in time a stored transaction may be from an
+// imported row and still count as its duplicate
+const duplicateWindow = 24 * time.Hour
+
+// ParseProgress reports how far the parsing of a file has come
+type ParseProgress struct {
+	BytesRead  int64 `json:"bytes_read"`
+	TotalBytes int64 `json:"total_bytes"` // 0 when the size is unknown
+	Rows       int   `json:"rows"`        // 已解析的行数
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// streamCSV reads a CSV bill one record at a time. The header is the first of
+// the first scan records that isHeader accepts, or the first record when none
+// does (or isHeader is nil); every record after the header is passed to emit,
+// with the line of the file it starts on. Only the records up to the header
+// are held in memory.
+func streamCSV(r io.Reader, scan int, isHeader func([]string) bool, emit func(line int, header, record []string)) error {
+	reader := csv.NewReader(r)
+	reader.LazyQuotes = true
+	reader.FieldsPerRecord = -1
+
+	var head [][]string
+	var lines []int
+	headerIndex := -1
+	for len(head) < scan {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		line, _ := reader.FieldPos(0)
+		head = append(head, record)
+		lines = append(lines, line)
+		if isHeader != nil && isHeader(record) {
+			headerIndex = len(head) - 1
+			break
+		}
+	}
+	count := len(head)
+
+	var header []string
+	switch {
+	case headerIndex >= 0:
+		header = head[headerIndex]
+	case len(head) > 0:
+		header = head[0]
+		for i, record := range head[1:] {
+			emit(lines[i+1], header, record)
+		}
+	}
+	head, lines = nil, nil
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		count++
+		line, _ := reader.FieldPos(0)
+		emit(line, header, record)
+	}
+
+	if count < 2 {
+		return fmt.Errorf("no data in file")
+	}
+	return nil
+}
+
+// isTimeHeader finds the header of Alipay and WeChat bills, which start with a
+// few lines about the account; the header row begins with the time column
+func isTimeHeader(record []string) bool {
+	return len(record) > 0 && (strings.Contains(record[0], "交易时间") || strings.Contains(record[0], "时间"))
+}
+
+// isBankHeader finds the header of a bank statement by its date or description column
+func isBankHeader(record []string) bool {
+	recordStr := strings.Join(record, " ")
+	return strings.Contains(recordStr, "日期") || strings.Contains(recordStr, "date") ||
+		strings.Contains(recordStr, "摘要") || strings.Contains(recordStr, "description")
+}
+
+// isJDHeader finds the header of a JD bill; the JD format varies
+func isJDHeader(record []string) bool {
+	if len(record) == 0 {
+		return false
+	}
+	headerStr := strings.Join(record, "")
+	return strings.Contains(headerStr, "订单号") ||
+		strings.Contains(headerStr, "消费时间") ||
+		strings.Contains(headerStr, "金额")
+}
+
+// parsePipeline takes parsed rows as the file is read and enriches them a chunk
+// at a time: external and order IDs, refund detection, payee normalisation and
+// duplicate checks. Duplicate checks cost one or two queries per chunk rather
+// than one per row. The enriched rows are all kept in rows: the preview
+// returned and stored for the file holds every one of them, so only the raw
+// file and the records being parsed are bounded by the chunk size.
+type parsePipeline struct {
+	s          *ImportService
+	userID     uuid.UUID
+	source     models.ImportSource
+	ids        *externalIDAssigner
+	normalizer *PayeeNormalizer
+	progress   func(rows int)
+
+	chunk       []models.ParsedTransaction
+	rows        []models.ParsedTransaction
+	diagnostics []models.ParseDiagnostic
+}
+
+func (s *ImportService) newParsePipeline(userID uuid.UUID, source models.ImportSource, progress func(rows int)) *parsePipeline {
+	normalizer, err := loadPayeeNormalizer(s.payeeRepo, userID)
+	if err != nil {
+		s.logger.Warn("Failed to load payees", zap.Error(err))
+		normalizer = NewPayeeNormalizer(nil)
+	}
+	return &parsePipeline{
+		s:          s,
+		userID:     userID,
+		source:     source,
+		ids:        newExternalIDAssigner(source),
+		normalizer: normalizer,
+		progress:   progress,
+		chunk:      make([]models.ParsedTransaction, 0, importChunkSize),
+		rows:       make([]models.ParsedTransaction, 0),
+	}
+}
+
+// add takes one parsed row
+func (p *parsePipeline) add(tx models.ParsedTransaction) {
+	p.chunk = append(p.chunk, tx)
+	if len(p.chunk) >= importChunkSize {
+		p.flush()
+	}
+}
+
+// diagnose takes the diagnostic of a record that was skipped or only partly read
+func (p *parsePipeline) diagnose(d models.ParseDiagnostic) {
+	p.diagnostics = append(p.diagnostics, d)
+}
+
+// addStatement takes the rows of a statement (OFX, QIF, camt.053, MT940) and
+// returns its account hints
+func (p *parsePipeline) addStatement(stmt *ParsedStatement, err error) ([]models.AccountHint, error) {
+	if err != nil {
+		return nil, err
+	}
+	for _, tx := range stmt.Transactions {
+		p.add(tx)
+	}
+	return stmt.AccountHints, nil
+}
+
+// flush enriches the rows taken since the last flush
+func (p *parsePipeline) flush() {
+	if len(p.chunk) == 0 {
+		return
+	}
+	chunk := p.chunk
+	offset := len(p.rows)
+
+	// Stable IDs from the source make re-imports idempotent
+	p.ids.assign(chunk)
+	assignOrderIDs(p.source, chunk)
+	for i := range chunk {
+		tx := &chunk[i]
+		tx.Source = p.source
+		if tx.LineNumber == 0 {
+			tx.LineNumber = offset + i + 1
+		}
+		DetectRefund(tx)
+		// Resolve noisy counterparties into payees, which duplicate checks compare
+		p.normalizer.ApplyToParsed(tx)
+	}
+
+	p.s.markDuplicates(p.userID, p.source, chunk)
+
+	for i := range chunk {
+		tx := &chunk[i]
+		// Determine if can be imported
+		tx.CanBeImported = !tx.IsDuplicate && tx.Amount > 0 && !tx.TransactionDate.IsZero()
+	}
+
+	p.rows = append(p.rows, chunk...)
+	p.chunk = p.chunk[:0]
+	if p.progress != nil {
+		p.progress(len(p.rows))
+	}
+}
+
+// markDuplicates flags the rows of a chunk that are already stored: by external
+// ID when the source provides one, otherwise by date, amount, type and description
+func (s *ImportService) markDuplicates(userID uuid.UUID, source models.ImportSource, chunk []models.ParsedTransaction) {
+	externalIDs := make([]string, 0, len(chunk))
+	for _, tx := range chunk {
+		if tx.ExternalID != "" {
+			externalIDs = append(externalIDs, tx.ExternalID)
+		}
+	}
+	existingIDs, err := s.transactionRepo.GetExistingExternalIDs(userID, string(source), externalIDs)
+	if err != nil {
+		s.logger.Warn("Failed to check external ids", zap.Error(err))
+	}
+	mergedInto := s.mergedBankRows(userID, source, externalIDs)
+
+	var fuzzy []int
+	for i := range chunk {
+		tx := &chunk[i]
+		if tx.ExternalID != "" && existingIDs != nil {
+			tx.IsDuplicate = existingIDs[tx.ExternalID]
+		} else if tx.Amount > 0 && !tx.TransactionDate.IsZero() {
+			fuzzy = append(fuzzy, i)
+		}
+	}
+
+	if len(fuzzy) > 0 {
+		start, end := chunk[fuzzy[0]].TransactionDate, chunk[fuzzy[0]].TransactionDate
+		amounts := make([]float64, 0, len(fuzzy)*3)
+		for _, i := range fuzzy {
+			tx := &chunk[i]
+			if tx.TransactionDate.Before(start) {
+				start = tx.TransactionDate
+			}
+			if tx.TransactionDate.After(end) {
+				end = tx.TransactionDate
+			}
+			// Amounts within a cent of the row count as the same amount
+			cents := math.Round(tx.Amount * 100)
+			amounts = append(amounts, (cents-1)/100, cents/100, (cents+1)/100)
+		}
+
+		existing, err := s.transactionRepo.GetByDateRangeAndAmounts(userID, start.Add(-duplicateWindow), end.Add(duplicateWindow), amounts)
+		if err != nil {
+			s.logger.Warn("Failed to check duplicates", zap.Error(err))
+		} else {
+			rows := make([]models.ParsedTransaction, len(fuzzy))
+			for j, i := range fuzzy {
+				rows[j] = chunk[i]
+			}
+			MarkStoredDuplicates(rows, existing)
+			for j, i := range fuzzy {
+				chunk[i].IsDuplicate = rows[j].IsDuplicate
+			}
+		}
+	}
+
+	// Bank rows merged into a payment app record earlier are already covered by that record
+	for i := range chunk {
+		tx := &chunk[i]
+		if appSource, ok := mergedInto[tx.ExternalID]; ok && tx.ExternalID != "" && !tx.IsDuplicate {
+			tx.IsDuplicate = true
+			tx.ImportWarning = mergedDuplicateWarning(appSource)
+		}
+	}
+}
+
+// MarkStoredDuplicates flags each row that has a stored transaction of the same
+// type within a day and a cent of it that describes the same payment. A
+// same-day full refund has the purchase's amount but is not a copy of it,
+// hence the type check; two coffees of the same price on one day at different
+// shops are not copies either, hence the description.
+func MarkStoredDuplicates(rows []models.ParsedTransaction, existing []models.Transaction) {
+	byCents := make(map[int64][]*models.Transaction, len(existing))
+	for i := range existing {
+		t := &existing[i]
+		cents := int64(math.Round(t.Amount * 100))
+		byCents[cents] = append(byCents[cents], t)
+	}
+
+	for i := range rows {
+		tx := &rows[i]
+		cents := int64(math.Round(tx.Amount * 100))
+		for c := cents - 1; c <= cents+1 && !tx.IsDuplicate; c++ {
+			for _, t := range byCents[c] {
+				if t.Type == tx.Type && absDuration(t.TransactionDate.Sub(tx.TransactionDate)) <= duplicateWindow &&
+					similarDescription(tx, t) {
+					tx.IsDuplicate = true
+					break
+				}
+			}
+		}
+	}
+}
+
+// duplicateNoteSimilarity is how much of one description must be found in the
+// other for a stored transaction to describe the same payment as a row
+const duplicateNoteSimilarity = 0.5
+
+// similarDescription tells whether a stored transaction may describe the same
+// payment as an imported row: the same payee when both have one, otherwise a
+// note that shares most of its words with the row's counterparty and note. A
+// side without any description gives no evidence and does not rule a match out.
+func similarDescription(tx *models.ParsedTransaction, t *models.Transaction) bool {
+	if tx.PayeeID != nil && t.PayeeID != nil {
+		return *tx.PayeeID == *t.PayeeID
+	}
+	text := tx.Counterparty + " " + tx.Note
+	if len(bigrams(t.Note)) == 0 || len(bigrams(text)) == 0 {
+		return true
+	}
+	return math.Max(merchantSimilarity(t.Note, text), merchantSimilarity(text, t.Note)) >= duplicateNoteSimilarity
+}
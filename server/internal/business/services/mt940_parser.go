@@ -0,0 +1,360 @@
+package services
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"account/internal/business/models"
+)
+
+var (
+	mt940TagPattern = regexp.MustCompile(`^:(\d{2}[A-Z]?):`)
+
+	// :61: statement line: value date, optional entry date (MMDD), debit/credit
+	// mark (RC/RD = reversal), optional funds code, amount, transaction type,
+	// customer reference, optional //bank reference, optional supplementary details
+	mt940StatementLinePattern = regexp.MustCompile(`^(\d{6})(\d{4})?(RC|RD|C|D)([A-Z]?)(\d+,\d*)([NSF][A-Z0-9]{3})([^\n]*?)(?://([^\n]*))?(?:\n([\s\S]*))?$`)
+
+	// :60F:/:62F: balance: mark, date, currency, amount
+	mt940BalancePattern = regexp.MustCompile(`^([CD])(\d{6})([A-Z]{3})(\d+,\d*)`)
+
+	// German structured :86: subfields (?20 ... ?63)
+	mt940SubfieldPattern = regexp.MustCompile(`\?(\d{2})`)
+)
+
+// mt940Field is one ":tag:value" field; continuation lines are kept with "\n"
+type mt940Field struct {
+	Tag   string
+	Value string
+	Line  int
+}
+
+// ParseMT940 parses a SWIFT MT940 customer statement. Files may contain
+// several statements and SWIFT block envelopes ({1:...}{4: ... -}).
+// Opening (:60F:/:60M:) and closing (:62F:/:62M:) balances become account hints.
+func ParseMT940(r io.Reader) (*ParsedStatement, error) {
+	fields, err := readMT940Fields(r)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ParsedStatement{}
+	accountID := ""
+	bankName := ""
+	currency := ""
+	seenRefs := make(map[string]int)
+	var current *models.ParsedTransaction
+
+	flush := func() {
+		if current != nil {
+			result.Transactions = append(result.Transactions, *current)
+			current = nil
+		}
+	}
+
+	accountName := func() string {
+		return statementAccountName(bankName, statementTailNumber(accountID))
+	}
+
+	for _, f := range fields {
+		switch f.Tag {
+		case "25":
+			flush()
+			accountID = strings.TrimSpace(f.Value)
+			bankName = ""
+			// "BLZ/Account" or "BIC/Account" style identifications
+			if i := strings.LastIndex(accountID, "/"); i >= 0 {
+				bankName = accountID[:i]
+				accountID = accountID[i+1:]
+			}
+		case "60F", "60M", "62F", "62M":
+			flush()
+			hint, ok := parseMT940Balance(f.Value)
+			if !ok {
+				continue
+			}
+			if f.Tag[:2] == "60" {
+				hint.BalanceType = models.BalanceTypeOpening
+				currency = hint.Currency
+			} else {
+				hint.BalanceType = models.BalanceTypeClosing
+			}
+			tailNumber := statementTailNumber(accountID)
+			hint.AccountName = accountName()
+			hint.AccountNumber = tailNumber
+			hint.BankName = bankName
+			hint.CardType = "借记卡"
+			result.AccountHints = append(result.AccountHints, hint)
+		case "61":
+			flush()
+			tx, ok := parseMT940StatementLine(f.Value, currency)
+			if !ok {
+				continue
+			}
+			tx.LineNumber = f.Line
+			tx.AccountName = accountName()
+			tx.ParsedAccountType = "debit_card"
+			tx.ParsedAccountNumber = statementTailNumber(accountID)
+			tx.ParsedBankName = bankName
+			tx.ParsedCardType = "借记卡"
+			tx.HasAccountHint = tx.ParsedAccountNumber != ""
+
+			// The bank reference is the dedup key; fall back to the customer reference.
+			// Either may repeat within one statement (NONREF), so repeats are counted.
+			ref := tx.RawData["bank_reference"]
+			if ref == "" && tx.RawData["customer_reference"] != "NONREF" {
+				ref = tx.RawData["customer_reference"]
+			}
+			if ref != "" {
+				key := statementExternalID(accountID, ref)
+				seenRefs[key]++
+				if seenRefs[key] > 1 {
+					key = fmt.Sprintf("%s#%d", key, seenRefs[key])
+				}
+				tx.ExternalID = key
+			}
+			current = &tx
+		case "86":
+			if current == nil {
+				continue
+			}
+			applyMT940Information(current, f.Value)
+			flush()
+		}
+	}
+	flush()
+
+	if len(result.Transactions) == 0 && len(result.AccountHints) == 0 {
+		return nil, fmt.Errorf("no statement found in MT940 file")
+	}
+
+	return result, nil
+}
+
+// readMT940Fields splits the file into tagged fields, dropping SWIFT envelopes
+func readMT940Fields(r io.Reader) ([]mt940Field, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var fields []mt940Field
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimRight(scanner.Text(), "\r ")
+		// Block headers "{1:...}{2:...}{4:" and the "-}" trailer
+		if i := strings.Index(line, "{4:"); i >= 0 {
+			line = line[i+3:]
+		}
+		if strings.HasPrefix(line, "{") || line == "-" || strings.HasPrefix(line, "-}") {
+			continue
+		}
+		if line == "" {
+			continue
+		}
+
+		if m := mt940TagPattern.FindStringSubmatch(line); m != nil {
+			fields = append(fields, mt940Field{Tag: m[1], Value: line[len(m[0]):], Line: lineNumber})
+			continue
+		}
+		if len(fields) > 0 {
+			fields[len(fields)-1].Value += "\n" + line
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("not an MT940 file: no tagged fields found")
+	}
+	return fields, nil
+}
+
+// parseMT940Balance parses "C240131EUR1234,56"
+func parseMT940Balance(value string) (models.AccountHint, bool) {
+	m := mt940BalancePattern.FindStringSubmatch(strings.TrimSpace(value))
+	if m == nil {
+		return models.AccountHint{}, false
+	}
+	amount, err := parseMT940Amount(m[4])
+	if err != nil {
+		return models.AccountHint{}, false
+	}
+	if m[1] == "D" {
+		amount = -amount
+	}
+	hint := models.AccountHint{
+		Source:      models.ImportSourceMT940,
+		AccountType: models.AccountTypeBank,
+		Balance:     amount,
+		Currency:    m[3],
+	}
+	if t, err := time.Parse("060102", m[2]); err == nil {
+		hint.BalanceDate = &t
+	}
+	return hint, true
+}
+
+// parseMT940StatementLine parses the :61: field. The first date is the value
+// date; the optional MMDD entry date is the booking date.
+func parseMT940StatementLine(value, currency string) (models.ParsedTransaction, bool) {
+	tx := models.ParsedTransaction{
+		RawData:  make(map[string]string),
+		Source:   models.ImportSourceMT940,
+		Currency: currency,
+	}
+
+	m := mt940StatementLinePattern.FindStringSubmatch(value)
+	if m == nil {
+		return tx, false
+	}
+
+	valueDate, err := time.Parse("060102", m[1])
+	if err != nil {
+		return tx, false
+	}
+	booking := valueDate
+	if m[2] != "" {
+		if t, err := time.Parse("0102", m[2]); err == nil {
+			booking = time.Date(valueDate.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+			// Entry date and value date may straddle the turn of the year
+			if booking.Sub(valueDate) > 180*24*time.Hour {
+				booking = booking.AddDate(-1, 0, 0)
+			} else if valueDate.Sub(booking) > 180*24*time.Hour {
+				booking = booking.AddDate(1, 0, 0)
+			}
+		}
+	}
+	tx.TransactionDate = booking
+	if !valueDate.Equal(booking) {
+		tx.ValueDate = &valueDate
+	}
+
+	amount, err := parseMT940Amount(m[5])
+	if err != nil || amount == 0 {
+		return tx, false
+	}
+	tx.Amount = amount
+
+	// RC (reversal of credit) debits the account, RD credits it
+	switch m[3] {
+	case "C", "RD":
+		tx.Type = models.TransactionTypeIncome
+	default:
+		tx.Type = models.TransactionTypeExpense
+	}
+
+	tx.RawData["mark"] = m[3]
+	tx.RawData["type_code"] = m[6]
+	tx.RawData["customer_reference"] = strings.TrimSpace(m[7])
+	tx.RawData["bank_reference"] = strings.TrimSpace(m[8])
+	tx.RawData["supplementary"] = strings.TrimSpace(m[9])
+
+	tx.Note = tx.RawData["supplementary"]
+	tx.CategoryHint = tx.Note
+	return tx, true
+}
+
+// applyMT940Information reads counterparty and remittance info from :86:.
+// Both the German structured format (?20 remittance, ?32 name, ?31 IBAN) and
+// the "/NAME/.../REMI/..." keyword format are understood; anything else is
+// kept as a free-text note.
+func applyMT940Information(tx *models.ParsedTransaction, value string) {
+	info := strings.ReplaceAll(value, "\n", "")
+	tx.RawData["information"] = info
+
+	switch {
+	case mt940SubfieldPattern.MatchString(info) && strings.Contains(info, "?20"):
+		subfields := make(map[int]string)
+		locs := mt940SubfieldPattern.FindAllStringSubmatchIndex(info, -1)
+		for i, loc := range locs {
+			code, _ := strconv.Atoi(info[loc[2]:loc[3]])
+			end := len(info)
+			if i+1 < len(locs) {
+				end = locs[i+1][0]
+			}
+			subfields[code] += info[loc[1]:end]
+		}
+
+		var remittance []string
+		for code := 20; code <= 29; code++ {
+			remittance = append(remittance, subfields[code])
+		}
+		for code := 60; code <= 63; code++ {
+			remittance = append(remittance, subfields[code])
+		}
+		tx.Note = strings.TrimSpace(strings.Join(remittance, ""))
+		tx.Counterparty = strings.TrimSpace(subfields[32] + subfields[33])
+		tx.RelatedAccountNumber = strings.TrimSpace(subfields[31])
+		if tx.Note == "" {
+			tx.Note = strings.TrimSpace(subfields[0])
+		}
+	case strings.HasPrefix(info, "/"):
+		values := parseMT940Keywords(info)
+		tx.Note = firstNonEmpty(values["REMI"], values["EREF"])
+		tx.Counterparty = values["NAME"]
+		tx.RelatedAccountNumber = values["IBAN"]
+		if cntp := values["CNTP"]; cntp != "" {
+			// CNTP/IBAN/BIC/NAME/CITY
+			parts := strings.Split(cntp, "/")
+			if tx.RelatedAccountNumber == "" && len(parts) > 0 {
+				tx.RelatedAccountNumber = parts[0]
+			}
+			if tx.Counterparty == "" && len(parts) > 2 {
+				tx.Counterparty = parts[2]
+			}
+		}
+		// Some banks put the remittance info under /REMI/USTD//
+		tx.Note = strings.TrimSpace(strings.TrimPrefix(tx.Note, "USTD//"))
+	default:
+		tx.Note = strings.TrimSpace(info)
+	}
+
+	if tx.Note == "" {
+		tx.Note = firstNonEmpty(tx.RawData["supplementary"], tx.Counterparty)
+	}
+	tx.CategoryHint = strings.Join([]string{tx.Counterparty, tx.Note}, " ")
+}
+
+// mt940Keywords are the codes of the "/CODE/value" :86: format (SEPA usage rules)
+var mt940Keywords = map[string]bool{
+	"NAME": true, "IBAN": true, "BIC": true, "REMI": true, "EREF": true,
+	"CNTP": true, "TRCD": true, "ORDP": true, "BENM": true, "MARF": true,
+	"CSID": true, "PREF": true, "ADDR": true, "ISDT": true, "RTRN": true,
+}
+
+func parseMT940Keywords(info string) map[string]string {
+	values := make(map[string]string)
+	parts := strings.Split(info, "/")
+	key := ""
+	var buf []string
+	save := func() {
+		if key != "" {
+			values[key] = strings.TrimSpace(strings.Join(buf, "/"))
+		}
+	}
+	for _, p := range parts[1:] {
+		if mt940Keywords[p] {
+			save()
+			key, buf = p, nil
+			continue
+		}
+		buf = append(buf, p)
+	}
+	save()
+
+	// Drop the empty segment left by the trailing separator of each value
+	for k, v := range values {
+		values[k] = strings.Trim(v, "/ ")
+	}
+	return values
+}
+
+// parseMT940Amount parses SWIFT amounts, which always use a decimal comma
+func parseMT940Amount(str string) (float64, error) {
+	return strconv.ParseFloat(strings.Replace(strings.TrimSpace(str), ",", ".", 1), 64)
+}
@@ -59,6 +59,11 @@ func (s *NoteMergeService) SuggestMerge(match TransferMatch) MergeSuggestion {
 	return s.createMergeSuggestion(bankNote, appNote)
 }
 
+// MergeNotes 合并同一笔交易的银行摘要与平台详情（已知哪边是平台时使用）
+func (s *NoteMergeService) MergeNotes(bankNote, appNote string) MergeSuggestion {
+	return s.createMergeSuggestion(bankNote, appNote)
+}
+
 // createMergeSuggestion 创建合并建议
 func (s *NoteMergeService) createMergeSuggestion(bankNote, appNote string) MergeSuggestion {
 	// 清理备注
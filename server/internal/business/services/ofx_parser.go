@@ -0,0 +1,379 @@
+package services
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"account/internal/business/models"
+)
+
+// ParsedStatement is the result of parsing a statement-style file (OFX, QIF, ...):
+// the transactions plus account hints that belong to the statement as a whole.
+type ParsedStatement struct {
+	Transactions []models.ParsedTransaction
+	AccountHints []models.AccountHint
+}
+
+// ofxNode is one aggregate element of an OFX document, e.g. <STMTTRN>.
+// Leaf elements (<TRNAMT>-12.50) are stored in Fields.
+type ofxNode struct {
+	Name     string
+	Fields   map[string]string
+	Children []*ofxNode
+	parent   *ofxNode
+}
+
+func (n *ofxNode) field(name string) string {
+	return n.Fields[name]
+}
+
+// child returns the first direct child aggregate with the given name
+func (n *ofxNode) child(name string) *ofxNode {
+	for _, c := range n.Children {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// findAll returns all aggregates with the given name below n (depth-first)
+func (n *ofxNode) findAll(name string) []*ofxNode {
+	var result []*ofxNode
+	for _, c := range n.Children {
+		if c.Name == name {
+			result = append(result, c)
+		}
+		result = append(result, c.findAll(name)...)
+	}
+	return result
+}
+
+// ParseOFX parses an OFX/QFX file. Both OFX 1.x (SGML, unclosed leaf tags)
+// and OFX 2.x (XML) are supported, as are bank, credit card and brokerage
+// (cash movements only) statements.
+func ParseOFX(r io.Reader) (*ParsedStatement, error) {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	root, err := parseOFXTree(string(content))
+	if err != nil {
+		return nil, err
+	}
+
+	org := ""
+	if fi := root.findAll("FI"); len(fi) > 0 {
+		org = fi[0].field("ORG")
+	}
+
+	result := &ParsedStatement{}
+
+	statements := make([]*ofxNode, 0)
+	for _, name := range []string{"STMTRS", "CCSTMTRS", "INVSTMTRS"} {
+		statements = append(statements, root.findAll(name)...)
+	}
+	if len(statements) == 0 {
+		return nil, fmt.Errorf("no statement found in OFX file")
+	}
+
+	for _, stmt := range statements {
+		txs, hint := parseOFXStatement(stmt, org)
+		// Rows are numbered across the document: the line number keys a preview row
+		for i := range txs {
+			txs[i].LineNumber += len(result.Transactions)
+		}
+		result.Transactions = append(result.Transactions, txs...)
+		result.AccountHints = append(result.AccountHints, hint)
+	}
+
+	return result, nil
+}
+
+// parseOFXTree builds the aggregate tree. It works on the tag stream only, so
+// the SGML header of OFX 1.x and the XML prolog of OFX 2.x are both skipped.
+func parseOFXTree(content string) (*ofxNode, error) {
+	start := strings.Index(strings.ToUpper(content), "<OFX>")
+	if start < 0 {
+		return nil, fmt.Errorf("not an OFX file: missing <OFX> element")
+	}
+	content = content[start:]
+
+	root := &ofxNode{Name: "", Fields: make(map[string]string)}
+	current := root
+
+	for len(content) > 0 {
+		open := strings.IndexByte(content, '<')
+		if open < 0 {
+			break
+		}
+		closeIdx := strings.IndexByte(content[open:], '>')
+		if closeIdx < 0 {
+			return nil, fmt.Errorf("malformed OFX: unterminated tag")
+		}
+		tag := strings.TrimSpace(content[open+1 : open+closeIdx])
+		content = content[open+closeIdx+1:]
+
+		// Skip processing instructions and comments
+		if strings.HasPrefix(tag, "?") || strings.HasPrefix(tag, "!") {
+			continue
+		}
+
+		if strings.HasPrefix(tag, "/") {
+			name := strings.ToUpper(strings.TrimSpace(tag[1:]))
+			// Closing tag of an aggregate: pop up to the matching element.
+			// Closing tags of leaf elements (OFX 2.x) have no matching aggregate and are ignored.
+			for n := current; n != nil && n != root; n = n.parent {
+				if n.Name == name {
+					current = n.parent
+					break
+				}
+			}
+			continue
+		}
+
+		name := strings.ToUpper(tag)
+		if i := strings.IndexAny(name, " \t\r\n"); i >= 0 {
+			name = name[:i] // drop attributes
+		}
+		if strings.HasSuffix(name, "/") {
+			continue // empty element
+		}
+
+		next := strings.IndexByte(content, '<')
+		value := content
+		if next >= 0 {
+			value = content[:next]
+		}
+		value = strings.TrimSpace(value)
+
+		if value != "" {
+			current.Fields[name] = html.UnescapeString(value)
+			continue
+		}
+
+		node := &ofxNode{Name: name, Fields: make(map[string]string), parent: current}
+		current.Children = append(current.Children, node)
+		current = node
+	}
+
+	return root, nil
+}
+
+// parseOFXStatement converts one STMTRS/CCSTMTRS/INVSTMTRS aggregate
+func parseOFXStatement(stmt *ofxNode, org string) ([]models.ParsedTransaction, models.AccountHint) {
+	currency := strings.ToUpper(stmt.field("CURDEF"))
+
+	var acctFrom *ofxNode
+	accountType := models.AccountTypeBank
+	parsedAccountType := "debit_card"
+	cardType := "借记卡"
+	bankName := org
+
+	switch stmt.Name {
+	case "CCSTMTRS":
+		acctFrom = stmt.child("CCACCTFROM")
+		accountType = models.AccountTypeCredit
+		parsedAccountType = "credit_card"
+		cardType = "信用卡"
+	case "INVSTMTRS":
+		acctFrom = stmt.child("INVACCTFROM")
+		accountType = models.AccountTypeInvestment
+		parsedAccountType = "investment"
+		cardType = ""
+	default:
+		acctFrom = stmt.child("BANKACCTFROM")
+	}
+
+	accountID := ""
+	if acctFrom != nil {
+		accountID = acctFrom.field("ACCTID")
+		if bankName == "" {
+			bankName = acctFrom.field("BANKID")
+		}
+		if bankName == "" {
+			bankName = acctFrom.field("BROKERID")
+		}
+		if acctFrom.field("ACCTTYPE") == "CREDITLINE" {
+			accountType = models.AccountTypeCredit
+			parsedAccountType = "credit_card"
+			cardType = "信用卡"
+		}
+	}
+	tailNumber := statementTailNumber(accountID)
+
+	hint := models.AccountHint{
+		Source:        models.ImportSourceOFX,
+		AccountName:   statementAccountName(bankName, tailNumber),
+		AccountNumber: tailNumber,
+		BankName:      bankName,
+		CardType:      cardType,
+		AccountType:   accountType,
+		Currency:      currency,
+		BalanceType:   models.BalanceTypeClosing,
+	}
+
+	// Ledger balance (bank/credit card) or available cash (brokerage)
+	if bal := stmt.child("LEDGERBAL"); bal != nil {
+		hint.Balance, _ = parseOFXAmount(bal.field("BALAMT"))
+		if t, err := parseOFXDate(bal.field("DTASOF")); err == nil {
+			hint.BalanceDate = &t
+		}
+	} else if bal := stmt.child("INVBAL"); bal != nil {
+		hint.Balance, _ = parseOFXAmount(bal.field("AVAILCASH"))
+		if t, err := parseOFXDate(stmt.field("DTASOF")); err == nil {
+			hint.BalanceDate = &t
+		}
+	}
+
+	transactions := make([]models.ParsedTransaction, 0)
+	for i, trn := range stmt.findAll("STMTTRN") {
+		tx := models.ParsedTransaction{
+			RawData:             make(map[string]string),
+			Currency:            currency,
+			Source:              models.ImportSourceOFX,
+			LineNumber:          i + 1,
+			AccountName:         hint.AccountName,
+			ParsedAccountType:   parsedAccountType,
+			ParsedAccountNumber: tailNumber,
+			ParsedBankName:      bankName,
+			ParsedCardType:      cardType,
+			ParsedBalance:       hint.Balance,
+			HasAccountHint:      tailNumber != "",
+		}
+		for k, v := range trn.Fields {
+			tx.RawData[k] = v
+		}
+
+		date := trn.field("DTPOSTED")
+		if date == "" {
+			date = trn.field("DTUSER")
+		}
+		tx.TransactionDate, _ = parseOFXDate(date)
+
+		amount, err := parseOFXAmount(trn.field("TRNAMT"))
+		if err != nil {
+			continue
+		}
+		if amount < 0 {
+			tx.Type = models.TransactionTypeExpense
+		} else {
+			tx.Type = models.TransactionTypeIncome
+		}
+		tx.Amount = math.Abs(amount)
+
+		// Per-transaction currency overrides CURDEF
+		for _, name := range []string{"CURRENCY", "ORIGCURRENCY"} {
+			if cur := trn.child(name); cur != nil && cur.field("CURSYM") != "" {
+				tx.Currency = strings.ToUpper(cur.field("CURSYM"))
+			}
+		}
+
+		tx.Counterparty = trn.field("NAME")
+		if payee := trn.child("PAYEE"); payee != nil && tx.Counterparty == "" {
+			tx.Counterparty = payee.field("NAME")
+		}
+		tx.Note = trn.field("MEMO")
+		if tx.Note == "" {
+			tx.Note = tx.Counterparty
+		}
+
+		if trn.field("TRNTYPE") == "XFER" {
+			tx.IsTransferOut = tx.Type == models.TransactionTypeExpense
+			tx.IsTransferIn = tx.Type == models.TransactionTypeIncome
+		}
+
+		if fitID := trn.field("FITID"); fitID != "" {
+			tx.ExternalID = statementExternalID(accountID, fitID)
+		}
+
+		tx.CategoryHint = strings.Join([]string{tx.Counterparty, tx.Note}, " ")
+
+		if tx.Amount > 0 && !tx.TransactionDate.IsZero() {
+			transactions = append(transactions, tx)
+		}
+	}
+
+	return transactions, hint
+}
+
+// statementExternalID builds the duplicate key for a statement transaction.
+// Bank references (OFX FITID, camt.053 AcctSvcrRef, ...) are only unique within
+// one account, so the account ID is part of the key.
+func statementExternalID(accountID, fitID string) string {
+	if accountID == "" {
+		return fitID
+	}
+	return accountID + ":" + fitID
+}
+
+// statementTailNumber returns the last four digits of an account ID or IBAN
+func statementTailNumber(accountID string) string {
+	digits := normalizeTailNumber(accountID)
+	if len(digits) > 4 {
+		return digits[len(digits)-4:]
+	}
+	return digits
+}
+
+func statementAccountName(bankName, tailNumber string) string {
+	if tailNumber == "" {
+		return bankName
+	}
+	return strings.TrimSpace(bankName + "(" + tailNumber + ")")
+}
+
+// parseOFXAmount parses OFX amounts; some banks use a decimal comma ("-12,50")
+func parseOFXAmount(str string) (float64, error) {
+	str = strings.TrimSpace(str)
+	if !strings.Contains(str, ".") {
+		str = strings.Replace(str, ",", ".", 1)
+	}
+	return strconv.ParseFloat(str, 64)
+}
+
+// parseOFXDate parses OFX datetime values such as "20240115", "20240115120000"
+// or "20240115120000.000[-5:EST]"
+func parseOFXDate(str string) (time.Time, error) {
+	str = strings.TrimSpace(str)
+	if str == "" {
+		return time.Time{}, fmt.Errorf("empty OFX date")
+	}
+
+	loc := time.UTC
+	if i := strings.IndexByte(str, '['); i >= 0 {
+		tz := strings.TrimSuffix(str[i+1:], "]")
+		str = str[:i]
+		if j := strings.IndexByte(tz, ':'); j >= 0 {
+			tz = tz[:j]
+		}
+		if offset, err := strconv.ParseFloat(tz, 64); err == nil {
+			loc = time.FixedZone("", int(offset*3600))
+		}
+	}
+	if i := strings.IndexByte(str, '.'); i >= 0 {
+		str = str[:i]
+	}
+
+	layouts := map[int]string{
+		8:  "20060102",
+		12: "200601021504",
+		14: "20060102150405",
+	}
+	layout, ok := layouts[len(str)]
+	if !ok {
+		return time.Time{}, fmt.Errorf("unable to parse OFX date: %s", str)
+	}
+
+	t, err := time.ParseInLocation(layout, str, loc)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return t.UTC(), nil
+}
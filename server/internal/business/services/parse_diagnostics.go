@@ -0,0 +1,161 @@
+package services
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"account/internal/business/models"
+	"github.com/google/uuid"
+)
+
+// recordDiagnostics collects what could not be read from one record of a bill
+type recordDiagnostics struct {
+	line   int
+	record []string
+	found  []models.ParseDiagnostic
+}
+
+// fail notes a field of the record that could not be read; an empty field
+// stands for the record as a whole
+func (d *recordDiagnostics) fail(field, value, reason string) {
+	d.found = append(d.found, models.ParseDiagnostic{
+		LineNumber: d.line,
+		Record:     d.record,
+		Field:      field,
+		Value:      value,
+		Reason:     reason,
+	})
+}
+
+// recordParser maps one record of a bill to a transaction; nil skips the record
+type recordParser func(header, record []string, diag *recordDiagnostics) *models.ParsedTransaction
+
+// parseRecords adapts a record parser to streamCSV. Kept records go to emit;
+// every record that was skipped or only partly read is reported, with a
+// generic reason when the parser gave none. Blank records are left out
+// silently, there is nothing in them to fix.
+func parseRecords(parse recordParser, emit func(models.ParsedTransaction), report func(models.ParseDiagnostic)) func(line int, header, record []string) {
+	return func(line int, header, record []string) {
+		if isBlankRecord(record) {
+			return
+		}
+		diag := &recordDiagnostics{line: line, record: record}
+		tx := parse(header, record, diag)
+		if tx != nil {
+			emit(*tx)
+		} else if len(diag.found) == 0 {
+			diag.fail("", "", "no transaction found in record")
+		}
+		for _, d := range diag.found {
+			d.Skipped = tx == nil
+			report(d)
+		}
+	}
+}
+
+func isBlankRecord(record []string) bool {
+	for _, field := range record {
+		if strings.TrimSpace(field) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// isBlankAmount tells whether an amount column holds a placeholder such as
+// "--" that banks put in the column a row does not use
+func isBlankAmount(val string) bool {
+	return strings.Trim(val, "-/ ") == ""
+}
+
+// checkColumns reports a record with fewer than min columns
+func checkColumns(record []string, min int, diag *recordDiagnostics) bool {
+	if len(record) < min {
+		diag.fail("", "", fmt.Sprintf("expected at least %d columns, found %d", min, len(record)))
+		return false
+	}
+	return true
+}
+
+// parseDateField parses the first of the columns that has a value as the
+// transaction date, reporting why none could be used
+func (s *ImportService) parseDateField(raw map[string]string, keys []string, diag *recordDiagnostics) time.Time {
+	for _, key := range keys {
+		if val, ok := raw[key]; ok && val != "" {
+			t, err := s.parseChineseDate(val)
+			if err != nil {
+				diag.fail(key, val, err.Error())
+			}
+			return t
+		}
+	}
+	diag.fail("", "", "no transaction date")
+	return time.Time{}
+}
+
+// parseAmountField parses the first of the columns that has a value as the
+// amount, reporting why none could be used. The sign is kept.
+func (s *ImportService) parseAmountField(raw map[string]string, keys []string, diag *recordDiagnostics) float64 {
+	for _, key := range keys {
+		if val, ok := raw[key]; ok && val != "" {
+			amt, err := s.parseAmount(val)
+			switch {
+			case err != nil:
+				diag.fail(key, val, "unable to parse amount")
+			case amt == 0:
+				diag.fail(key, val, "amount is zero")
+			}
+			return amt
+		}
+	}
+	diag.fail("", "", "no amount")
+	return 0
+}
+
+// ParseDiagnosticsFile writes diagnostics as a CSV file: the line, field and
+// reason of each, followed by the columns of the original record so that the
+// record can be fixed and uploaded again
+func ParseDiagnosticsFile(fileName string, diagnostics []models.ParseDiagnostic) (*ExportFile, error) {
+	var buf bytes.Buffer
+	// Spreadsheet apps need the BOM to read UTF-8
+	buf.Write(utf8BOM)
+	w := csv.NewWriter(&buf)
+
+	if err := w.Write([]string{"line_number", "skipped", "field", "value", "reason", "record"}); err != nil {
+		return nil, err
+	}
+	for _, d := range diagnostics {
+		row := []string{strconv.Itoa(d.LineNumber), strconv.FormatBool(d.Skipped), d.Field, d.Value, d.Reason}
+		if err := w.Write(append(row, d.Record...)); err != nil {
+			return nil, err
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, fmt.Errorf("failed to write diagnostics: %w", err)
+	}
+
+	base := strings.TrimSuffix(filepath.Base(fileName), filepath.Ext(fileName))
+	if base == "" || base == "." {
+		base = "import"
+	}
+	return &ExportFile{
+		FileName:    base + "_rejected.csv",
+		ContentType: "text/csv; charset=utf-8",
+		Content:     buf.Bytes(),
+	}, nil
+}
+
+// GetPreviewDiagnosticsFile returns the diagnostics of a stored preview as a CSV file
+func (s *ImportService) GetPreviewDiagnosticsFile(userID, jobID uuid.UUID) (*ExportFile, error) {
+	preview, err := s.previewRepo.GetByID(jobID, userID)
+	if err != nil {
+		return nil, err
+	}
+	return ParseDiagnosticsFile(preview.FileName, preview.Diagnostics)
+}
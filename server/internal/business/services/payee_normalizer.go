@@ -0,0 +1,202 @@
+package services
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"account/internal/business/models"
+)
+
+// payeeBrand 内置商户词典的一项：任一关键词命中即归到 Name
+// 两个字及以下的关键词只匹配开头，避免“北京东路店”被当成“京东”
+type payeeBrand struct {
+	Name     string
+	Keywords []string
+}
+
+// builtinPayeeBrands 常见商户及其工商名称，更具体的条目在前（美团外卖先于美团）
+var builtinPayeeBrands = []payeeBrand{
+	{Name: "美团外卖", Keywords: []string{"美团外卖"}},
+	{Name: "美团", Keywords: []string{"美团", "三快在线", "三快科技"}},
+	{Name: "饿了么", Keywords: []string{"饿了么", "拉扎斯"}},
+	{Name: "滴滴出行", Keywords: []string{"滴滴", "小桔科技"}},
+	{Name: "哈啰出行", Keywords: []string{"哈啰", "哈罗单车"}},
+	{Name: "星巴克", Keywords: []string{"星巴克", "starbucks"}},
+	{Name: "瑞幸咖啡", Keywords: []string{"瑞幸", "luckin"}},
+	{Name: "肯德基", Keywords: []string{"肯德基", "kfc"}},
+	{Name: "麦当劳", Keywords: []string{"麦当劳", "mcdonald"}},
+	{Name: "盒马鲜生", Keywords: []string{"盒马"}},
+	{Name: "京东", Keywords: []string{"京东", "京东商城", "京东到家", "jd.com"}},
+	{Name: "天猫", Keywords: []string{"天猫"}},
+	{Name: "淘宝", Keywords: []string{"淘宝"}},
+	{Name: "拼多多", Keywords: []string{"拼多多"}},
+	{Name: "12306", Keywords: []string{"12306", "中国铁路"}},
+	{Name: "中国移动", Keywords: []string{"中国移动"}},
+	{Name: "中国联通", Keywords: []string{"中国联通"}},
+	{Name: "中国电信", Keywords: []string{"中国电信"}},
+}
+
+// payeeChannelPrefixes 支付通道前缀，“财付通-美团”中真正的商户是“美团”
+var payeeChannelPrefixes = []string{"财付通", "支付宝", "微信支付", "京东支付", "网联", "银联", "云闪付"}
+
+// genericCounterparties 不代表具体商户的交易对方（按 NormalizePayeeKey 规整后比较）
+var genericCounterparties = map[string]bool{
+	"扫二维码付款":      true,
+	"二维码付款":       true,
+	"二维码收款":       true,
+	"转账":          true,
+	"微信转账":        true,
+	"微信红包":        true,
+	"收款方":         true,
+	"付款方":         true,
+	"商户消费":        true,
+	"消费":          true,
+	"财付通":         true,
+	"支付宝":         true,
+	"财付通支付科技有限公司": true,
+	"支付宝中国网络技术有限公司": true,
+	"网银在线北京科技有限公司":  true,
+}
+
+// payeeCompanySuffixes 工商名称后缀，去掉后得到商户简称
+var payeeCompanySuffixes = []string{"股份有限公司", "有限责任公司", "有限公司"}
+
+// PayeeNormalizer 把账单中杂乱的交易对方解析为商户
+// 顺序：用户别名 → 内置规整（通道前缀、通用对方、商户词典、分店和公司后缀）→ 已有商户名
+type PayeeNormalizer struct {
+	byKey map[string]*models.Payee
+}
+
+// NewPayeeNormalizer 用用户的商户及别名创建解析器
+func NewPayeeNormalizer(payees []models.Payee) *PayeeNormalizer {
+	n := &PayeeNormalizer{byKey: make(map[string]*models.Payee)}
+	for i := range payees {
+		payee := &payees[i]
+		if key := NormalizePayeeKey(payee.Name); key != "" {
+			n.byKey[key] = payee
+		}
+	}
+	// Aliases win over payee names when both normalise to the same key
+	for i := range payees {
+		payee := &payees[i]
+		for _, alias := range payee.Aliases {
+			if alias.AliasKey != "" {
+				n.byKey[alias.AliasKey] = payee
+			}
+		}
+	}
+	return n
+}
+
+// Resolve 返回交易对方对应的已有商户；没有已有商户时返回规整后的名称（导入时创建）
+func (n *PayeeNormalizer) Resolve(counterparty string) (*models.Payee, string) {
+	if payee, ok := n.byKey[NormalizePayeeKey(counterparty)]; ok {
+		return payee, payee.Name
+	}
+
+	name := CanonicalPayeeName(counterparty)
+	if name == "" {
+		return nil, ""
+	}
+	if payee, ok := n.byKey[NormalizePayeeKey(name)]; ok {
+		return payee, payee.Name
+	}
+	return nil, name
+}
+
+// ApplyToParsed 为解析出的交易填充商户
+func (n *PayeeNormalizer) ApplyToParsed(tx *models.ParsedTransaction) {
+	if tx.Counterparty == "" {
+		return
+	}
+	payee, name := n.Resolve(tx.Counterparty)
+	tx.PayeeName = name
+	if payee != nil {
+		id := payee.ID
+		tx.PayeeID = &id
+	}
+}
+
+// CanonicalPayeeName 内置规整：返回商户名，交易对方不代表具体商户时返回空串
+func CanonicalPayeeName(counterparty string) string {
+	name := strings.Join(strings.Fields(counterparty), " ")
+	name = strings.NewReplacer("（", "(", "）", ")", "－", "-", "—", "-", "–", "-").Replace(name)
+	if isGenericCounterparty(name) {
+		return ""
+	}
+
+	// 财付通-美团、支付宝-饿了么
+	for stripped := true; stripped; {
+		stripped = false
+		for _, prefix := range payeeChannelPrefixes {
+			if rest, ok := strings.CutPrefix(name, prefix+"-"); ok && strings.TrimSpace(rest) != "" {
+				name = strings.TrimSpace(rest)
+				stripped = true
+			}
+		}
+	}
+	if isGenericCounterparty(name) {
+		return ""
+	}
+
+	if brand := matchPayeeBrand(name); brand != "" {
+		return brand
+	}
+
+	// 星巴克(国贸店)、老王烧烤-万达店
+	for strings.HasSuffix(name, ")") {
+		open := strings.LastIndex(name, "(")
+		if open <= 0 {
+			break
+		}
+		name = strings.TrimSpace(name[:open])
+	}
+	if head, tail, ok := strings.Cut(name, "-"); ok && strings.HasSuffix(tail, "店") && strings.TrimSpace(head) != "" {
+		name = strings.TrimSpace(head)
+	}
+
+	for _, suffix := range payeeCompanySuffixes {
+		if trimmed := strings.TrimSuffix(name, suffix); trimmed != name && trimmed != "" {
+			name = trimmed
+			break
+		}
+	}
+
+	return strings.TrimSpace(name)
+}
+
+func isGenericCounterparty(name string) bool {
+	key := NormalizePayeeKey(name)
+	return key == "" || genericCounterparties[key]
+}
+
+func matchPayeeBrand(name string) string {
+	lower := strings.ToLower(name)
+	for _, brand := range builtinPayeeBrands {
+		for _, keyword := range brand.Keywords {
+			if utf8.RuneCountInString(keyword) <= 2 {
+				if strings.HasPrefix(lower, keyword) {
+					return brand.Name
+				}
+			} else if strings.Contains(lower, keyword) {
+				return brand.Name
+			}
+		}
+	}
+	return ""
+}
+
+// NormalizePayeeKey 商户和别名的匹配键：小写、全角转半角、去掉空白和标点
+func NormalizePayeeKey(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r >= 0xFF01 && r <= 0xFF5E {
+			r -= 0xFEE0
+		}
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(unicode.ToLower(r))
+		}
+	}
+	return b.String()
+}
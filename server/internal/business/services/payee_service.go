@@ -0,0 +1,130 @@
+package services
+
+import (
+	"account/internal/business/models"
+	"account/internal/data/repository"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// ErrInvalidPayee is returned for payee names and aliases that cannot be saved
+var ErrInvalidPayee = errors.New("invalid payee")
+
+const payeeNameMaxLength = 100
+
+type PayeeService struct {
+	payeeRepo *repository.PayeeRepository
+}
+
+func NewPayeeService(payeeRepo *repository.PayeeRepository) *PayeeService {
+	return &PayeeService{payeeRepo: payeeRepo}
+}
+
+type CreatePayeeRequest struct {
+	Name    string   `json:"name" binding:"required"`
+	Aliases []string `json:"aliases"`
+}
+
+type UpdatePayeeRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+type PayeeAliasRequest struct {
+	Alias string `json:"alias" binding:"required"`
+}
+
+func (s *PayeeService) CreatePayee(userID uuid.UUID, req *CreatePayeeRequest) (*models.Payee, error) {
+	name, err := validatePayeeName(req.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	payee, err := s.payeeRepo.Create(&models.Payee{UserID: userID, Name: name})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, alias := range req.Aliases {
+		if _, err := s.AddAlias(payee.ID, userID, &PayeeAliasRequest{Alias: alias}); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.payeeRepo.GetByID(payee.ID, userID)
+}
+
+func (s *PayeeService) GetPayee(id uuid.UUID, userID uuid.UUID) (*models.Payee, error) {
+	return s.payeeRepo.GetByID(id, userID)
+}
+
+func (s *PayeeService) GetAllPayees(userID uuid.UUID) ([]models.Payee, error) {
+	return s.payeeRepo.GetAll(userID)
+}
+
+func (s *PayeeService) UpdatePayee(id uuid.UUID, userID uuid.UUID, req *UpdatePayeeRequest) (*models.Payee, error) {
+	name, err := validatePayeeName(req.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	payee, err := s.payeeRepo.GetByID(id, userID)
+	if err != nil {
+		return nil, err
+	}
+	payee.Name = name
+
+	return s.payeeRepo.Update(payee, userID)
+}
+
+// DeletePayee removes a payee; its transactions keep their data but lose the payee link
+func (s *PayeeService) DeletePayee(id uuid.UUID, userID uuid.UUID) error {
+	return s.payeeRepo.Delete(id, userID)
+}
+
+// AddAlias maps another counterparty spelling to the payee for future imports
+func (s *PayeeService) AddAlias(payeeID uuid.UUID, userID uuid.UUID, req *PayeeAliasRequest) (*models.PayeeAlias, error) {
+	alias := strings.TrimSpace(req.Alias)
+	key := NormalizePayeeKey(alias)
+	if key == "" {
+		return nil, fmt.Errorf("%w: alias must contain letters or digits", ErrInvalidPayee)
+	}
+
+	if _, err := s.payeeRepo.GetByID(payeeID, userID); err != nil {
+		return nil, err
+	}
+
+	return s.payeeRepo.AddAlias(&models.PayeeAlias{
+		UserID:   userID,
+		PayeeID:  payeeID,
+		Alias:    alias,
+		AliasKey: key,
+	})
+}
+
+func (s *PayeeService) DeleteAlias(payeeID uuid.UUID, aliasID uuid.UUID, userID uuid.UUID) error {
+	return s.payeeRepo.DeleteAlias(aliasID, payeeID, userID)
+}
+
+// loadPayeeNormalizer returns a normalizer with the user's payees and aliases
+func loadPayeeNormalizer(payeeRepo *repository.PayeeRepository, userID uuid.UUID) (*PayeeNormalizer, error) {
+	payees, err := payeeRepo.GetAll(userID)
+	if err != nil {
+		return nil, err
+	}
+	return NewPayeeNormalizer(payees), nil
+}
+
+func validatePayeeName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if NormalizePayeeKey(name) == "" {
+		return "", fmt.Errorf("%w: name must contain letters or digits", ErrInvalidPayee)
+	}
+	if utf8.RuneCountInString(name) > payeeNameMaxLength {
+		return "", fmt.Errorf("%w: name is longer than %d characters", ErrInvalidPayee, payeeNameMaxLength)
+	}
+	return name, nil
+}
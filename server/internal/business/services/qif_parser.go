@@ -0,0 +1,309 @@
+package services
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"account/internal/business/models"
+)
+
+// qifIncomeActions are investment actions that bring cash into the account
+var qifIncomeActions = map[string]bool{
+	"sell": true, "sellx": true, "div": true, "divx": true, "intinc": true, "intincx": true,
+	"xin": true, "cglong": true, "cgshort": true, "cgmid": true, "miscinc": true, "rtrncap": true,
+}
+
+var qifDatePattern = regexp.MustCompile(`^\s*(\d{1,4})\s*([/.\-'])\s*(\d{1,2})\s*([/.\-'])\s*(\d{1,4})\s*$`)
+
+// qifRecord is one "^"-terminated record of a QIF file
+type qifRecord map[byte]string
+
+// ParseQIF parses a Quicken Interchange Format file. QIF has no transaction
+// IDs, so a content hash plus an occurrence counter is used as the duplicate key;
+// it keeps two identical purchases on the same day apart while staying stable
+// across re-exports of the same period.
+func ParseQIF(r io.Reader) (*ParsedStatement, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	result := &ParsedStatement{}
+	sectionType := "bank"
+	inAccountBlock := false
+	accountName := ""
+	accountType := models.AccountTypeBank
+	seenKeys := make(map[string]int)
+	record := make(qifRecord)
+	lineNumber := 0
+	recordLine := 0
+
+	flush := func() {
+		defer func() { record = make(qifRecord) }()
+		if len(record) == 0 {
+			return
+		}
+
+		if inAccountBlock {
+			accountName = record['N']
+			accountType = qifAccountType(record['T'])
+			hint := models.AccountHint{
+				Source:      models.ImportSourceQIF,
+				AccountName: accountName,
+				AccountType: accountType,
+				CardType:    qifCardType(accountType),
+			}
+			if bal, ok := record['$']; ok {
+				hint.Balance, _ = parseQIFAmount(bal)
+			}
+			result.AccountHints = append(result.AccountHints, hint)
+			return
+		}
+
+		tx, ok := buildQIFTransaction(record, sectionType, accountName, accountType)
+		if !ok {
+			return
+		}
+		tx.LineNumber = recordLine
+
+		key := qifDedupKey(accountName, tx)
+		seenKeys[key]++
+		tx.ExternalID = fmt.Sprintf("qif:%s#%d", key, seenKeys[key])
+
+		result.Transactions = append(result.Transactions, tx)
+	}
+
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "!") {
+			flush()
+			header := strings.ToLower(strings.TrimSpace(line[1:]))
+			switch {
+			case header == "account":
+				inAccountBlock = true
+			case strings.HasPrefix(header, "type:"):
+				inAccountBlock = false
+				sectionType = strings.TrimSpace(strings.TrimPrefix(header, "type:"))
+				if accountName == "" {
+					accountType = qifAccountType(sectionType)
+				}
+			}
+			continue
+		}
+
+		if line[0] == '^' {
+			flush()
+			continue
+		}
+
+		if len(record) == 0 {
+			recordLine = lineNumber
+		}
+		code := line[0]
+		value := strings.TrimSpace(line[1:])
+		switch code {
+		case 'S', 'E', '$':
+			// Split lines: keep only the first occurrence, the total (T) is authoritative
+			if _, exists := record[code]; exists {
+				continue
+			}
+		}
+		record[code] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	flush()
+
+	if len(result.Transactions) == 0 {
+		return nil, fmt.Errorf("no transactions found in QIF file")
+	}
+
+	return result, nil
+}
+
+// buildQIFTransaction converts a QIF record into a ParsedTransaction
+func buildQIFTransaction(record qifRecord, sectionType, accountName string, accountType models.AccountType) (models.ParsedTransaction, bool) {
+	tx := models.ParsedTransaction{
+		RawData:           make(map[string]string),
+		Source:            models.ImportSourceQIF,
+		AccountName:       accountName,
+		ParsedAccountType: qifParsedAccountType(accountType),
+		ParsedCardType:    qifCardType(accountType),
+	}
+	for code, value := range record {
+		tx.RawData[string(code)] = value
+	}
+
+	date, err := parseQIFDate(record['D'])
+	if err != nil {
+		return tx, false
+	}
+	tx.TransactionDate = date
+
+	amountStr := record['T']
+	if amountStr == "" {
+		amountStr = record['U']
+	}
+	amount, err := parseQIFAmount(amountStr)
+	if err != nil || amount == 0 {
+		return tx, false
+	}
+
+	if strings.HasPrefix(sectionType, "invst") {
+		action := strings.ToLower(record['N'])
+		if qifIncomeActions[action] {
+			tx.Type = models.TransactionTypeIncome
+		} else {
+			tx.Type = models.TransactionTypeExpense
+		}
+		tx.Note = strings.TrimSpace(strings.Join([]string{record['N'], record['Y'], record['M']}, " "))
+	} else {
+		if amount < 0 {
+			tx.Type = models.TransactionTypeExpense
+		} else {
+			tx.Type = models.TransactionTypeIncome
+		}
+		tx.Note = record['M']
+	}
+	tx.Amount = math.Abs(amount)
+
+	tx.Counterparty = record['P']
+	if tx.Note == "" {
+		tx.Note = tx.Counterparty
+	}
+
+	// L holds the category, or [Account] for transfers
+	if category := record['L']; category != "" {
+		if strings.HasPrefix(category, "[") && strings.HasSuffix(category, "]") {
+			tx.RelatedAccountName = strings.Trim(category, "[]")
+			tx.IsTransferOut = tx.Type == models.TransactionTypeExpense
+			tx.IsTransferIn = tx.Type == models.TransactionTypeIncome
+		} else {
+			tx.CategoryHint = category
+		}
+	}
+	if tx.CategoryHint == "" {
+		tx.CategoryHint = strings.Join([]string{tx.Counterparty, tx.Note}, " ")
+	}
+
+	return tx, true
+}
+
+// qifDedupKey hashes the identifying fields of a transaction
+func qifDedupKey(accountName string, tx models.ParsedTransaction) string {
+	h := sha1.New()
+	fmt.Fprintf(h, "%s|%s|%s|%.2f|%s|%s|%s",
+		accountName,
+		tx.TransactionDate.Format("2006-01-02"),
+		tx.Type,
+		tx.Amount,
+		tx.Counterparty,
+		tx.RawData["M"],
+		tx.RawData["N"],
+	)
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// qifAccountType maps a QIF account type (Bank, CCard, Cash, Invst, Oth A, Oth L)
+func qifAccountType(t string) models.AccountType {
+	switch strings.ToLower(strings.TrimSpace(t)) {
+	case "ccard":
+		return models.AccountTypeCredit
+	case "cash":
+		return models.AccountTypeCash
+	case "invst", "port", "401(k)/403(b)":
+		return models.AccountTypeInvestment
+	case "oth a", "oth l":
+		return models.AccountTypeOther
+	default:
+		return models.AccountTypeBank
+	}
+}
+
+func qifParsedAccountType(accountType models.AccountType) string {
+	switch accountType {
+	case models.AccountTypeCredit:
+		return "credit_card"
+	case models.AccountTypeBank:
+		return "debit_card"
+	}
+	return string(accountType)
+}
+
+func qifCardType(accountType models.AccountType) string {
+	switch accountType {
+	case models.AccountTypeCredit:
+		return "信用卡"
+	case models.AccountTypeBank:
+		return "借记卡"
+	}
+	return ""
+}
+
+// parseQIFDate parses the many date styles found in QIF files:
+// "1/15/2024", "01/15/24", "1/15'24", "15.01.2024", "2024-01-15".
+// Slash dates are read as month/day unless the first part cannot be a month.
+func parseQIFDate(str string) (time.Time, error) {
+	m := qifDatePattern.FindStringSubmatch(str)
+	if m == nil {
+		return time.Time{}, fmt.Errorf("unable to parse QIF date: %s", str)
+	}
+
+	p1, _ := strconv.Atoi(m[1])
+	p2, _ := strconv.Atoi(m[3])
+	p3, _ := strconv.Atoi(m[5])
+
+	var year, month, day int
+	switch {
+	case len(m[1]) == 4:
+		year, month, day = p1, p2, p3
+	case m[2] == "." || p1 > 12:
+		day, month, year = p1, p2, p3
+	default:
+		month, day, year = p1, p2, p3
+	}
+
+	if len(m[5]) <= 2 && len(m[1]) != 4 {
+		if m[4] == "'" || year < 70 {
+			year += 2000
+		} else {
+			year += 1900
+		}
+	}
+
+	if month < 1 || month > 12 || day < 1 || day > 31 {
+		return time.Time{}, fmt.Errorf("unable to parse QIF date: %s", str)
+	}
+
+	date := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+	if date.Day() != day || int(date.Month()) != month { // e.g. 02/31
+		return time.Time{}, fmt.Errorf("unable to parse QIF date: %s", str)
+	}
+	return date, nil
+}
+
+// parseQIFAmount parses "-1,234.56" as well as European "-1.234,56". A comma is
+// only a decimal point when one or two digits follow it at the end, so "1,234"
+// and "1,234,567" are read as thousands.
+func parseQIFAmount(str string) (float64, error) {
+	str = strings.ReplaceAll(strings.TrimSpace(str), " ", "")
+	lastComma := strings.LastIndex(str, ",")
+	if lastComma > strings.LastIndex(str, ".") {
+		if decimals := len(str) - lastComma - 1; decimals >= 1 && decimals <= 2 {
+			str = strings.ReplaceAll(str[:lastComma], ".", "") + "." + str[lastComma+1:]
+		}
+	}
+	return strconv.ParseFloat(strings.ReplaceAll(str, ",", ""), 64)
+}
@@ -0,0 +1,207 @@
+package services
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"account/internal/business/models"
+)
+
+const (
+	reconcileAmountTolerance = 0.01
+	reconcileDateWindow      = 3 * 24 * time.Hour // 银行记账日与账本日期允许相差的天数
+)
+
+// FindReconciliationCulprits 找出可能造成余额差异（对账单余额 - 账面余额）的交易。
+// 有对账单明细时逐行比对账本：对账单有账本没有的是遗漏，账本有对账单没有的是多记或重复，
+// 金额相同方向相反的是收支记反。没有明细时只能在账本中找重复记录，以及反转后恰好抵消差额的交易。
+// 能单独解释差额的排在前面。
+func FindReconciliationCulprits(difference float64, ledger []models.Transaction, statement []models.ParsedTransaction) []models.ReconciliationCulprit {
+	var culprits []models.ReconciliationCulprit
+	if len(statement) > 0 {
+		culprits = compareWithStatement(ledger, statement)
+	} else {
+		culprits = scanLedger(difference, ledger)
+	}
+
+	for i := range culprits {
+		culprits[i].ExplainsDifference = math.Abs(culprits[i].Impact-difference) <= reconcileAmountTolerance
+	}
+	sort.SliceStable(culprits, func(i, j int) bool {
+		return culprits[i].ExplainsDifference && !culprits[j].ExplainsDifference
+	})
+	return culprits
+}
+
+func compareWithStatement(ledger []models.Transaction, statement []models.ParsedTransaction) []models.ReconciliationCulprit {
+	ledgerUsed := make([]bool, len(ledger))
+	statementUsed := make([]bool, len(statement))
+
+	// 流水号相同的一定是同一笔
+	for i := range statement {
+		if statement[i].ExternalID == "" {
+			continue
+		}
+		for j := range ledger {
+			if !ledgerUsed[j] && ledger[j].ExternalID == statement[i].ExternalID {
+				ledgerUsed[j], statementUsed[i] = true, true
+				break
+			}
+		}
+	}
+
+	matchPass := func(sameType bool) []models.ReconciliationCulprit {
+		var culprits []models.ReconciliationCulprit
+		for i := range statement {
+			row := &statement[i]
+			if statementUsed[i] || signedParsedAmount(row) == 0 {
+				continue
+			}
+			best := -1
+			var bestGap time.Duration
+			for j := range ledger {
+				tx := &ledger[j]
+				if ledgerUsed[j] || (tx.Type == row.Type) != sameType || signedLedgerAmount(tx) == 0 {
+					continue
+				}
+				if math.Abs(tx.Amount-row.Amount) > reconcileAmountTolerance {
+					continue
+				}
+				gap := absDuration(tx.TransactionDate.Sub(row.TransactionDate))
+				if gap > reconcileDateWindow {
+					continue
+				}
+				if best < 0 || gap < bestGap {
+					best, bestGap = j, gap
+				}
+			}
+			if best < 0 {
+				continue
+			}
+			ledgerUsed[best], statementUsed[i] = true, true
+			if !sameType {
+				culprits = append(culprits, models.ReconciliationCulprit{
+					Kind:         models.CulpritMisSigned,
+					Transaction:  &ledger[best],
+					StatementRow: row,
+					Impact:       roundAmount(signedParsedAmount(row) - signedLedgerAmount(&ledger[best])),
+				})
+			}
+		}
+		return culprits
+	}
+
+	matchPass(true)
+	culprits := matchPass(false)
+
+	for i := range statement {
+		row := &statement[i]
+		if statementUsed[i] || signedParsedAmount(row) == 0 {
+			continue
+		}
+		culprits = append(culprits, models.ReconciliationCulprit{
+			Kind:         models.CulpritMissing,
+			StatementRow: row,
+			Impact:       roundAmount(signedParsedAmount(row)),
+		})
+	}
+
+	for j := range ledger {
+		tx := &ledger[j]
+		if ledgerUsed[j] || signedLedgerAmount(tx) == 0 {
+			continue
+		}
+		kind := models.CulpritExtra
+		for k := range ledger {
+			if k != j && sameLedgerEntry(tx, &ledger[k]) {
+				kind = models.CulpritDuplicate
+				break
+			}
+		}
+		culprits = append(culprits, models.ReconciliationCulprit{
+			Kind:        kind,
+			Transaction: tx,
+			Impact:      roundAmount(-signedLedgerAmount(tx)),
+		})
+	}
+
+	return culprits
+}
+
+func scanLedger(difference float64, ledger []models.Transaction) []models.ReconciliationCulprit {
+	var culprits []models.ReconciliationCulprit
+
+	// 同一天、同方向、同金额、同备注的后几笔视为重复
+	for j := range ledger {
+		tx := &ledger[j]
+		if signedLedgerAmount(tx) == 0 {
+			continue
+		}
+		for k := 0; k < j; k++ {
+			if sameLedgerEntry(tx, &ledger[k]) && tx.Note == ledger[k].Note {
+				culprits = append(culprits, models.ReconciliationCulprit{
+					Kind:        models.CulpritDuplicate,
+					Transaction: tx,
+					Impact:      roundAmount(-signedLedgerAmount(tx)),
+				})
+				break
+			}
+		}
+	}
+
+	// 没有对账单可比，只有反转后正好抵消差额的交易才值得怀疑
+	for j := range ledger {
+		tx := &ledger[j]
+		signed := signedLedgerAmount(tx)
+		if signed == 0 {
+			continue
+		}
+		if impact := roundAmount(-2 * signed); math.Abs(impact-difference) <= reconcileAmountTolerance {
+			culprits = append(culprits, models.ReconciliationCulprit{
+				Kind:        models.CulpritMisSigned,
+				Transaction: tx,
+				Impact:      impact,
+			})
+		}
+	}
+
+	return culprits
+}
+
+// sameLedgerEntry reports whether two ledger rows look like the same entry recorded twice
+func sameLedgerEntry(a, b *models.Transaction) bool {
+	return a.Type == b.Type &&
+		math.Abs(a.Amount-b.Amount) <= reconcileAmountTolerance &&
+		a.TransactionDate.Format("2006-01-02") == b.TransactionDate.Format("2006-01-02")
+}
+
+// signedLedgerAmount is the row's effect on the account balance; transfers do not move it
+func signedLedgerAmount(tx *models.Transaction) float64 {
+	return balanceEffect(tx.Type, tx.Amount)
+}
+
+func signedParsedAmount(tx *models.ParsedTransaction) float64 {
+	return balanceEffect(tx.Type, tx.Amount)
+}
+
+func balanceEffect(tType models.TransactionType, amount float64) float64 {
+	switch tType {
+	case models.TransactionTypeIncome:
+		return amount
+	case models.TransactionTypeExpense:
+		return -amount
+	}
+	return 0
+}
+
+func roundAmount(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
@@ -0,0 +1,248 @@
+package services
+
+import (
+	"account/internal/business/models"
+	"account/internal/data/repository"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// reconcileLookback bounds the ledger search for culprits when neither the
+// statement rows nor an earlier reconciled balance say where to start
+const reconcileLookback = 90
+
+type ReconciliationService struct {
+	transactionRepo *repository.TransactionRepository
+	accountRepo     *repository.AccountRepository
+	assertionRepo   *repository.BalanceAssertionRepository
+	transferRepo    *repository.TransferLinkRepository
+	logger          *zap.Logger
+}
+
+func NewReconciliationService(
+	transactionRepo *repository.TransactionRepository,
+	accountRepo *repository.AccountRepository,
+	assertionRepo *repository.BalanceAssertionRepository,
+	transferRepo *repository.TransferLinkRepository,
+	logger *zap.Logger,
+) *ReconciliationService {
+	return &ReconciliationService{
+		transactionRepo: transactionRepo,
+		accountRepo:     accountRepo,
+		assertionRepo:   assertionRepo,
+		transferRepo:    transferRepo,
+		logger:          logger,
+	}
+}
+
+// ReconcileRequest is a statement's closing balance at the end of BalanceDate.
+// Transactions are the statement's rows, if available, to pinpoint differences.
+type ReconcileRequest struct {
+	ClosingBalance float64                    `json:"closing_balance"`
+	BalanceDate    time.Time                  `json:"balance_date" binding:"required"`
+	Transactions   []models.ParsedTransaction `json:"transactions"`
+}
+
+// StatementBalance is a closing balance to reconcile after an import
+type StatementBalance struct {
+	AccountID      uuid.UUID `json:"account_id" binding:"required"`
+	BalanceDate    time.Time `json:"balance_date" binding:"required"`
+	ClosingBalance float64   `json:"closing_balance"`
+}
+
+// Reconcile compares a statement's closing balance with the account's ledger
+// balance at the end of that day. A matching balance is recorded as an assertion,
+// otherwise the rows that may explain the difference are returned.
+func (s *ReconciliationService) Reconcile(userID uuid.UUID, accountID uuid.UUID, req *ReconcileRequest) (*models.ReconciliationResult, error) {
+	account, err := s.accountRepo.GetByID(accountID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	day := startOfDay(req.BalanceDate)
+	ledgerBalance, err := s.ledgerBalanceAt(account, day)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.ReconciliationResult{
+		AccountID:        accountID,
+		BalanceDate:      day,
+		StatementBalance: req.ClosingBalance,
+		LedgerBalance:    ledgerBalance,
+		Difference:       roundAmount(req.ClosingBalance - ledgerBalance),
+		Culprits:         []models.ReconciliationCulprit{},
+	}
+	result.Reconciled = math.Abs(result.Difference) <= reconcileAmountTolerance
+
+	if result.Reconciled {
+		assertion, err := s.assertionRepo.Save(&models.BalanceAssertion{
+			UserID:        userID,
+			AccountID:     accountID,
+			BalanceDate:   day,
+			Balance:       req.ClosingBalance,
+			LedgerBalance: ledgerBalance,
+			Status:        models.BalanceAssertionReconciled,
+		})
+		if err != nil {
+			return nil, err
+		}
+		result.Assertion = assertion
+		return result, nil
+	}
+
+	start, err := s.culpritWindowStart(userID, accountID, day, req.Transactions)
+	if err != nil {
+		return nil, err
+	}
+	dayEnd := day.AddDate(0, 0, 1)
+
+	ledger, err := s.transactionRepo.GetByAccountAndDateRange(userID, accountID, start, dayEnd.Add(-time.Nanosecond))
+	if err != nil {
+		return nil, err
+	}
+	if err := s.resolveTransferDirections(userID, ledger); err != nil {
+		return nil, err
+	}
+
+	var statement []models.ParsedTransaction
+	for _, row := range req.Transactions {
+		if !row.TransactionDate.Before(start) && row.TransactionDate.Before(dayEnd) {
+			statement = append(statement, row)
+		}
+	}
+
+	if culprits := FindReconciliationCulprits(result.Difference, ledger, statement); culprits != nil {
+		result.Culprits = culprits
+	}
+
+	return result, nil
+}
+
+// RecheckAssertions re-computes the ledger balance of every assertion dated on or
+// after from, marking the ones an edit has broken (or repaired). Failures are
+// only logged so that they never fail the edit itself.
+func (s *ReconciliationService) RecheckAssertions(userID uuid.UUID, accountID uuid.UUID, from time.Time) {
+	assertions, err := s.assertionRepo.GetFrom(userID, accountID, startOfDay(from))
+	if err != nil {
+		s.logger.Error("Failed to get balance assertions", zap.Error(err))
+		return
+	}
+	if len(assertions) == 0 {
+		return
+	}
+
+	account, err := s.accountRepo.GetByID(accountID, userID)
+	if err != nil {
+		s.logger.Error("Failed to get account for balance assertions", zap.Error(err))
+		return
+	}
+
+	for _, assertion := range assertions {
+		ledgerBalance, err := s.ledgerBalanceAt(account, startOfDay(assertion.BalanceDate))
+		if err != nil {
+			s.logger.Error("Failed to compute ledger balance", zap.Error(err))
+			return
+		}
+
+		status := models.BalanceAssertionReconciled
+		if math.Abs(assertion.Balance-ledgerBalance) > reconcileAmountTolerance {
+			status = models.BalanceAssertionBroken
+			if assertion.Status != models.BalanceAssertionBroken {
+				s.logger.Warn("Edit broke a reconciled balance",
+					zap.String("account_id", accountID.String()),
+					zap.String("balance_date", assertion.BalanceDate.Format("2006-01-02")),
+					zap.Float64("difference", roundAmount(assertion.Balance-ledgerBalance)),
+				)
+			}
+		}
+
+		if err := s.assertionRepo.UpdateCheck(assertion.ID, ledgerBalance, status); err != nil {
+			s.logger.Error("Failed to update balance assertion", zap.Error(err))
+		}
+	}
+}
+
+// GetAssertions returns an account's recorded balances, newest first
+func (s *ReconciliationService) GetAssertions(userID uuid.UUID, accountID uuid.UUID) ([]models.BalanceAssertion, error) {
+	if _, err := s.accountRepo.GetByID(accountID, userID); err != nil {
+		return nil, err
+	}
+	return s.assertionRepo.GetByAccount(userID, accountID)
+}
+
+func (s *ReconciliationService) DeleteAssertion(userID uuid.UUID, accountID uuid.UUID, assertionID uuid.UUID) error {
+	return s.assertionRepo.Delete(assertionID, accountID, userID)
+}
+
+// ledgerBalanceAt returns the account balance at the end of the given day:
+// the current balance minus everything recorded after that day
+func (s *ReconciliationService) ledgerBalanceAt(account *models.Account, day time.Time) (float64, error) {
+	later, err := s.transactionRepo.GetBalanceChangeSince(account.UserID, account.ID, day.AddDate(0, 0, 1))
+	if err != nil {
+		return 0, err
+	}
+	return roundAmount(account.Balance - later), nil
+}
+
+// resolveTransferDirections rewrites linked transfer halves as income or expense
+// so that they are compared with the statement like any other row
+func (s *ReconciliationService) resolveTransferDirections(userID uuid.UUID, ledger []models.Transaction) error {
+	var ids []uuid.UUID
+	for _, tx := range ledger {
+		if tx.Type == models.TransactionTypeTransfer {
+			ids = append(ids, tx.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	links, err := s.transferRepo.GetByTransactionIDs(userID, ids)
+	if err != nil {
+		return err
+	}
+	direction := make(map[uuid.UUID]models.TransactionType)
+	for _, link := range links {
+		direction[link.FromTransactionID] = models.TransactionTypeExpense
+		direction[link.ToTransactionID] = models.TransactionTypeIncome
+	}
+	for i := range ledger {
+		if t, ok := direction[ledger[i].ID]; ok && ledger[i].Type == models.TransactionTypeTransfer {
+			ledger[i].Type = t
+		}
+	}
+	return nil
+}
+
+// culpritWindowStart picks the first day to search for culprits: the statement's
+// first row, else the day after the last reconciled balance, else a fixed lookback
+func (s *ReconciliationService) culpritWindowStart(userID uuid.UUID, accountID uuid.UUID, day time.Time, statement []models.ParsedTransaction) (time.Time, error) {
+	var start time.Time
+	for _, row := range statement {
+		if start.IsZero() || row.TransactionDate.Before(start) {
+			start = row.TransactionDate
+		}
+	}
+	if !start.IsZero() && !startOfDay(start).After(day) {
+		return startOfDay(start), nil
+	}
+
+	previous, err := s.assertionRepo.GetLatestReconciledBefore(userID, accountID, day)
+	if err == nil {
+		return startOfDay(previous.BalanceDate).AddDate(0, 0, 1), nil
+	}
+	if !errors.Is(err, repository.ErrBalanceAssertionNotFound) {
+		return time.Time{}, err
+	}
+	return day.AddDate(0, 0, -reconcileLookback), nil
+}
+
+// startOfDay truncates to the calendar day, keeping the date as written
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
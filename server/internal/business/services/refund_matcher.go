@@ -0,0 +1,193 @@
+package services
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"account/internal/business/models"
+	"github.com/google/uuid"
+)
+
+const (
+	refundLookback        = 180 * 24 * time.Hour // 退款只关联半年内的购买
+	refundAmountTolerance = 0.01
+)
+
+// refundStatusColumns 账单中描述交易类型或状态的列
+var refundStatusColumns = []string{"交易状态", "订单状态", "交易类型", "类型", "收支类型", "收/支", "交易分类", "当前状态"}
+
+// refundStatusKeywords 状态列中表示退款的词；京东的“取消”订单同样退回货款
+var refundStatusKeywords = []string{"退款", "退货", "退回", "取消", "refund"}
+
+// refundNoteKeywords 银行流水和对账单没有状态列，只看摘要
+var refundNoteKeywords = []string{"退款", "退货", "refund"}
+
+// orderIDColumns 购买和退款共用的商户订单号所在的列
+var orderIDColumns = map[models.ImportSource][]string{
+	models.ImportSourceAlipay:  {"商家订单号", "商户订单号"},
+	models.ImportSourceWeChat:  {"商户单号"},
+	models.ImportSourceJD:      {"订单号"},
+	models.ImportSourceBank:    {"订单号", "商户订单号"},
+	models.ImportSourceGeneric: {"订单号", "商户订单号", "商家订单号", "商户单号"},
+}
+
+// DetectRefund 标记退款行：收入且状态列或摘要中出现退款字样
+func DetectRefund(tx *models.ParsedTransaction) {
+	if tx.Type != models.TransactionTypeIncome {
+		return
+	}
+
+	raw := trimmedRawData(tx.RawData)
+	for _, column := range refundStatusColumns {
+		if containsAnyFold(raw[column], refundStatusKeywords) {
+			tx.IsRefund = true
+			return
+		}
+	}
+	tx.IsRefund = containsAnyFold(tx.Note, refundNoteKeywords) || containsAnyFold(tx.Counterparty, refundNoteKeywords)
+}
+
+// assignOrderIDs fills OrderID from the source's merchant order column
+func assignOrderIDs(source models.ImportSource, transactions []models.ParsedTransaction) {
+	columns := orderIDColumns[source]
+	if len(columns) == 0 {
+		return
+	}
+
+	for i := range transactions {
+		tx := &transactions[i]
+		if tx.OrderID != "" {
+			continue
+		}
+		raw := trimmedRawData(tx.RawData)
+		for _, column := range columns {
+			// WeChat writes "/" for rows without a merchant order
+			if id := strings.Trim(raw[column], " \t\"="); id != "" && id != "/" {
+				tx.OrderID = id
+				break
+			}
+		}
+	}
+}
+
+// RefundCandidate 可能被退款的购买：同一文件中的行（LineNumber）或已入账的交易（TransactionID）
+type RefundCandidate struct {
+	LineNumber    int
+	TransactionID *uuid.UUID
+	OrderID       string
+	PayeeKey      string
+	Remaining     float64 // 尚未退款的金额
+	Date          time.Time
+}
+
+// MatchRefundsInFile 把退款行关联到同一文件中的购买行，按退款时间先后依次扣减购买的可退金额
+func MatchRefundsInFile(transactions []models.ParsedTransaction) {
+	var candidates []*RefundCandidate
+	for _, tx := range transactions {
+		if tx.Type == models.TransactionTypeExpense && tx.CanBeImported {
+			candidates = append(candidates, &RefundCandidate{
+				LineNumber: tx.LineNumber,
+				OrderID:    tx.OrderID,
+				PayeeKey:   refundPayeeKey(&tx),
+				Remaining:  tx.Amount,
+				Date:       tx.TransactionDate,
+			})
+		}
+	}
+	if len(candidates) == 0 {
+		return
+	}
+
+	for _, refund := range pendingRefunds(transactions) {
+		if match := PickRefundPurchase(refund, candidates); match != nil {
+			refund.RefundOfLineNumber = match.LineNumber
+			match.Remaining -= refund.Amount
+		}
+	}
+}
+
+// pendingRefunds returns the importable refunds without a purchase yet, oldest first
+func pendingRefunds(transactions []models.ParsedTransaction) []*models.ParsedTransaction {
+	var refunds []*models.ParsedTransaction
+	for i := range transactions {
+		tx := &transactions[i]
+		if tx.IsRefund && tx.CanBeImported && tx.RefundOfLineNumber == 0 && tx.RefundOfTransactionID == nil {
+			refunds = append(refunds, tx)
+		}
+	}
+	sort.SliceStable(refunds, func(i, j int) bool {
+		return refunds[i].TransactionDate.Before(refunds[j].TransactionDate)
+	})
+	return refunds
+}
+
+// PickRefundPurchase 选出退款对应的购买：订单号相同优先，其次同一商户；
+// 购买须在退款之前、半年以内，且剩余可退金额不少于退款金额（支持部分退款）。
+// 同等条件下优先金额正好相等的，再优先时间最近的。
+func PickRefundPurchase(refund *models.ParsedTransaction, candidates []*RefundCandidate) *RefundCandidate {
+	payeeKey := refundPayeeKey(refund)
+
+	var best *RefundCandidate
+	var bestScore int
+	for _, c := range candidates {
+		if c.Date.After(refund.TransactionDate) || refund.TransactionDate.Sub(c.Date) > refundLookback {
+			continue
+		}
+		if c.Remaining+refundAmountTolerance < refund.Amount {
+			continue
+		}
+
+		score := 0
+		switch {
+		case refund.OrderID != "" && c.OrderID != "":
+			if refund.OrderID != c.OrderID {
+				continue // Both sides name an order and they differ
+			}
+			score = 4
+		case payeeKey != "" && payeeKey == c.PayeeKey:
+			score = 2
+		default:
+			continue
+		}
+		if math.Abs(c.Remaining-refund.Amount) <= refundAmountTolerance {
+			score++
+		}
+
+		if best == nil || score > bestScore || (score == bestScore && c.Date.After(best.Date)) {
+			best = c
+			bestScore = score
+		}
+	}
+	return best
+}
+
+func refundPayeeKey(tx *models.ParsedTransaction) string {
+	if tx.PayeeName != "" {
+		return NormalizePayeeKey(tx.PayeeName)
+	}
+	return NormalizePayeeKey(tx.Counterparty)
+}
+
+// trimmedRawData re-keys raw columns without header padding ("交易号  ")
+func trimmedRawData(rawData map[string]string) map[string]string {
+	raw := make(map[string]string, len(rawData))
+	for key, val := range rawData {
+		raw[strings.TrimSpace(key)] = val
+	}
+	return raw
+}
+
+func containsAnyFold(s string, keywords []string) bool {
+	if s == "" {
+		return false
+	}
+	lower := strings.ToLower(s)
+	for _, keyword := range keywords {
+		if strings.Contains(lower, keyword) {
+			return true
+		}
+	}
+	return false
+}
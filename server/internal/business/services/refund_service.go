@@ -0,0 +1,200 @@
+package services
+
+import (
+	"account/internal/business/models"
+	"account/internal/data/repository"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// ErrInvalidRefundLink is returned when a refund cannot be linked to the given purchase
+var ErrInvalidRefundLink = errors.New("invalid refund link")
+
+type RefundService struct {
+	transactionRepo *repository.TransactionRepository
+	refundRepo      *repository.RefundLinkRepository
+}
+
+func NewRefundService(transactionRepo *repository.TransactionRepository, refundRepo *repository.RefundLinkRepository) *RefundService {
+	return &RefundService{
+		transactionRepo: transactionRepo,
+		refundRepo:      refundRepo,
+	}
+}
+
+type LinkRefundRequest struct {
+	PurchaseID uuid.UUID `json:"purchase_id" binding:"required"`
+}
+
+// RefundSummary shows how much of a purchase has been refunded
+type RefundSummary struct {
+	Purchase        models.Transaction   `json:"purchase"`
+	RefundedAmount  float64              `json:"refunded_amount"`
+	RemainingAmount float64              `json:"remaining_amount"`
+	Refunds         []models.Transaction `json:"refunds"`
+}
+
+// MatchRefunds links the refunds of an import preview to their purchases:
+// first to purchase rows of the same file, then to stored purchases
+func (s *RefundService) MatchRefunds(userID uuid.UUID, transactions []models.ParsedTransaction) error {
+	MatchRefundsInFile(transactions)
+
+	refunds := pendingRefunds(transactions)
+	if len(refunds) == 0 {
+		return nil
+	}
+
+	candidates := make(map[uuid.UUID]*RefundCandidate)
+	addCandidate := func(t models.Transaction, payeeKey string) {
+		if c, ok := candidates[t.ID]; ok {
+			if c.PayeeKey == "" {
+				c.PayeeKey = payeeKey
+			}
+			return
+		}
+		id := t.ID
+		candidates[t.ID] = &RefundCandidate{
+			TransactionID: &id,
+			OrderID:       t.OrderID,
+			PayeeKey:      payeeKey,
+			Remaining:     t.Amount,
+			Date:          t.TransactionDate,
+		}
+	}
+
+	var orderIDs []string
+	for _, refund := range refunds {
+		if refund.OrderID != "" {
+			orderIDs = append(orderIDs, refund.OrderID)
+		}
+	}
+	byOrder, err := s.transactionRepo.GetExpensesByOrderIDs(userID, orderIDs)
+	if err != nil {
+		return err
+	}
+	for _, t := range byOrder {
+		addCandidate(t, "")
+	}
+
+	// Stored purchases carry no counterparty, so merchant matches go through the payee
+	for _, refund := range refunds {
+		if refund.PayeeID == nil {
+			continue
+		}
+		byPayee, err := s.transactionRepo.GetExpensesByPayee(
+			userID, *refund.PayeeID,
+			refund.TransactionDate.Add(-refundLookback), refund.TransactionDate,
+			refund.Amount-refundAmountTolerance,
+		)
+		if err != nil {
+			return err
+		}
+		for _, t := range byPayee {
+			addCandidate(t, refundPayeeKey(refund))
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	ids := make([]uuid.UUID, 0, len(candidates))
+	list := make([]*RefundCandidate, 0, len(candidates))
+	for id, c := range candidates {
+		ids = append(ids, id)
+		list = append(list, c)
+	}
+	refunded, err := s.refundRepo.GetRefundedAmounts(userID, ids, nil)
+	if err != nil {
+		return err
+	}
+	for id, amount := range refunded {
+		candidates[id].Remaining -= amount
+	}
+
+	for _, refund := range refunds {
+		if match := PickRefundPurchase(refund, list); match != nil {
+			refund.RefundOfTransactionID = match.TransactionID
+			match.Remaining -= refund.Amount
+		}
+	}
+	return nil
+}
+
+// LinkRefund links a refund to a purchase, replacing an earlier link of the refund.
+// All refunds of a purchase together may not exceed the purchase amount.
+func (s *RefundService) LinkRefund(userID uuid.UUID, refundID uuid.UUID, purchaseID uuid.UUID) (*RefundSummary, error) {
+	if refundID == purchaseID {
+		return nil, fmt.Errorf("%w: a transaction cannot refund itself", ErrInvalidRefundLink)
+	}
+
+	refund, err := s.transactionRepo.GetByID(refundID, userID)
+	if err != nil {
+		return nil, err
+	}
+	purchase, err := s.transactionRepo.GetByID(purchaseID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if refund.Type != models.TransactionTypeIncome {
+		return nil, fmt.Errorf("%w: refund must be an income transaction", ErrInvalidRefundLink)
+	}
+	if purchase.Type != models.TransactionTypeExpense {
+		return nil, fmt.Errorf("%w: purchase must be an expense transaction", ErrInvalidRefundLink)
+	}
+
+	refunded, err := s.refundRepo.GetRefundedAmounts(userID, []uuid.UUID{purchaseID}, &refundID)
+	if err != nil {
+		return nil, err
+	}
+	if refunded[purchaseID]+refund.Amount > purchase.Amount+refundAmountTolerance {
+		return nil, fmt.Errorf("%w: refunds of %.2f would exceed the purchase amount %.2f",
+			ErrInvalidRefundLink, refunded[purchaseID]+refund.Amount, purchase.Amount)
+	}
+
+	_, err = s.refundRepo.Save(&models.RefundLink{
+		UserID:                userID,
+		PurchaseTransactionID: purchaseID,
+		RefundTransactionID:   refundID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetRefundSummary(userID, purchaseID)
+}
+
+// UnlinkRefund turns a linked refund back into plain income
+func (s *RefundService) UnlinkRefund(userID uuid.UUID, refundID uuid.UUID) error {
+	return s.refundRepo.DeleteByRefund(userID, refundID)
+}
+
+// GetRefundSummary returns a purchase with its refunds
+func (s *RefundService) GetRefundSummary(userID uuid.UUID, purchaseID uuid.UUID) (*RefundSummary, error) {
+	purchase, err := s.transactionRepo.GetByID(purchaseID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if purchase.Type != models.TransactionTypeExpense {
+		return nil, fmt.Errorf("%w: only expense transactions have refunds", ErrInvalidRefundLink)
+	}
+
+	links, err := s.refundRepo.GetByPurchase(userID, purchaseID)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &RefundSummary{Purchase: *purchase, Refunds: []models.Transaction{}}
+	for _, link := range links {
+		refund, err := s.transactionRepo.GetByID(link.RefundTransactionID, userID)
+		if err != nil {
+			return nil, err
+		}
+		summary.Refunds = append(summary.Refunds, *refund)
+		summary.RefundedAmount += refund.Amount
+	}
+	summary.RemainingAmount = purchase.Amount - summary.RefundedAmount
+
+	return summary, nil
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"account/internal/business/models"
+)
+
+const repaymentAmountTolerance = 0.01
+
+// MatchBillRepayment 把一笔信用卡还款匹配到它所还的账单周期。
+// 账单日当天及之后入账的还款才属于该期账单，账单日之前的还款还的是尚未出账的消费，不匹配任何账单。
+// 金额恰好等于某期剩余应还或剩余最低还款额的优先匹配该期，否则按发卡行的冲抵顺序先还最早未还清的一期。
+func MatchBillRepayment(bills []models.CreditCardBill, date time.Time, amount float64) *models.BillRepayment {
+	day := startOfDay(date)
+
+	var open []models.CreditCardBill
+	for _, bill := range bills {
+		if startOfDay(bill.StatementDate).After(day) {
+			continue
+		}
+		if bill.StatementBalance-bill.PaidAmount > repaymentAmountTolerance {
+			open = append(open, bill)
+		}
+	}
+	if len(open) == 0 {
+		return nil
+	}
+	sort.SliceStable(open, func(i, j int) bool {
+		return open[i].StatementDate.Before(open[j].StatementDate)
+	})
+
+	chosen := &open[0]
+	for i := range open {
+		bill := &open[i]
+		outstanding := bill.StatementBalance - bill.PaidAmount
+		minimumDue := bill.MinimumPayment - bill.PaidAmount
+		if math.Abs(outstanding-amount) <= repaymentAmountTolerance ||
+			(minimumDue > repaymentAmountTolerance && math.Abs(minimumDue-amount) <= repaymentAmountTolerance) {
+			chosen = bill
+			break
+		}
+	}
+
+	return &models.BillRepayment{
+		BillID:           chosen.ID,
+		AccountID:        chosen.AccountID,
+		StatementDate:    chosen.StatementDate,
+		DueDate:          chosen.DueDate,
+		StatementBalance: chosen.StatementBalance,
+		MinimumPayment:   chosen.MinimumPayment,
+		PaidBefore:       chosen.PaidAmount,
+		Amount:           amount,
+		Kind:             repaymentKind(chosen, chosen.PaidAmount+amount),
+		Overdue:          day.After(startOfDay(chosen.DueDate)),
+	}
+}
+
+// repaymentKind 按累计已还金额判断还款类型
+func repaymentKind(bill *models.CreditCardBill, paid float64) models.RepaymentKind {
+	switch {
+	case paid >= bill.StatementBalance-repaymentAmountTolerance:
+		return models.RepaymentFull
+	case bill.MinimumPayment > 0 && paid >= bill.MinimumPayment-repaymentAmountTolerance:
+		return models.RepaymentMinimum
+	}
+	return models.RepaymentPartial
+}
+
+// creditCardBillStatus 账单的还款状态
+func creditCardBillStatus(bill *models.CreditCardBill) models.CreditCardBillStatus {
+	if bill.PaidAmount <= repaymentAmountTolerance {
+		return models.CreditCardBillUnpaid
+	}
+	switch repaymentKind(bill, bill.PaidAmount) {
+	case models.RepaymentFull:
+		return models.CreditCardBillPaid
+	case models.RepaymentMinimum:
+		return models.CreditCardBillMinimum
+	}
+	return models.CreditCardBillPartial
+}
@@ -0,0 +1,245 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"account/internal/business/models"
+	"github.com/google/uuid"
+)
+
+// RuleInput 规则匹配所需的交易信息
+type RuleInput struct {
+	Counterparty string
+	Note         string
+	Amount       float64
+	Type         models.TransactionType
+	Source       models.ImportSource
+	AccountID    *uuid.UUID
+	AccountName  string
+	RawCategory  string
+}
+
+// RuleOutcome 所有命中规则的合并结果
+// 每种动作只取优先级最高的命中规则，后续规则只能补充尚未设置的动作
+type RuleOutcome struct {
+	CategoryID  *uuid.UUID
+	Note        string
+	NoteChanged bool
+	AccountID   *uuid.UUID
+	RuleIDs     []uuid.UUID // 实际生效的规则
+}
+
+// RuleEngine 分类规则引擎
+type RuleEngine struct {
+	rules []compiledRule
+}
+
+type compiledRule struct {
+	rule     models.CategoryRule
+	patterns map[string]*regexp.Regexp // 正则模式下各文本条件
+}
+
+// NewRuleEngine 创建规则引擎，停用的规则和无法编译的正则规则会被忽略
+func NewRuleEngine(rules []models.CategoryRule) *RuleEngine {
+	sorted := append([]models.CategoryRule(nil), rules...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Priority < sorted[j].Priority })
+
+	engine := &RuleEngine{}
+	for _, rule := range sorted {
+		if !rule.Enabled {
+			continue
+		}
+		compiled, err := compileRule(rule)
+		if err != nil {
+			continue
+		}
+		engine.rules = append(engine.rules, compiled)
+	}
+	return engine
+}
+
+// ValidateRule 检查规则是否可用
+func ValidateRule(rule models.CategoryRule) error {
+	switch rule.MatchMode {
+	case models.RuleMatchContains, models.RuleMatchExact, models.RuleMatchRegex:
+	default:
+		return fmt.Errorf("invalid match mode: %s", rule.MatchMode)
+	}
+	if rule.Conditions.IsEmpty() {
+		return fmt.Errorf("rule needs at least one condition")
+	}
+	if rule.Actions.IsEmpty() {
+		return fmt.Errorf("rule needs at least one action")
+	}
+	c := rule.Conditions
+	if c.MinAmount != nil && c.MaxAmount != nil && *c.MinAmount > *c.MaxAmount {
+		return fmt.Errorf("min_amount is greater than max_amount")
+	}
+	_, err := compileRule(rule)
+	return err
+}
+
+func compileRule(rule models.CategoryRule) (compiledRule, error) {
+	compiled := compiledRule{rule: rule}
+	if rule.MatchMode != models.RuleMatchRegex {
+		return compiled, nil
+	}
+
+	compiled.patterns = make(map[string]*regexp.Regexp)
+	c := rule.Conditions
+	for field, pattern := range map[string]string{
+		"counterparty": c.Counterparty,
+		"note":         c.Note,
+		"account_name": c.AccountName,
+		"raw_category": c.RawCategory,
+	} {
+		if pattern == "" {
+			continue
+		}
+		re, err := regexp.Compile("(?i)" + pattern)
+		if err != nil {
+			return compiled, fmt.Errorf("invalid %s pattern: %w", field, err)
+		}
+		compiled.patterns[field] = re
+	}
+	return compiled, nil
+}
+
+// Evaluate 依优先级对交易执行所有规则
+func (e *RuleEngine) Evaluate(in RuleInput) RuleOutcome {
+	outcome := RuleOutcome{Note: in.Note}
+	for _, r := range e.rules {
+		if !r.matches(in) {
+			continue
+		}
+
+		applied := false
+		actions := r.rule.Actions
+		if actions.CategoryID != nil && outcome.CategoryID == nil {
+			outcome.CategoryID = actions.CategoryID
+			applied = true
+		}
+		if actions.SetNote != "" && !outcome.NoteChanged {
+			outcome.Note = strings.NewReplacer("{note}", in.Note, "{counterparty}", in.Counterparty).Replace(actions.SetNote)
+			outcome.NoteChanged = true
+			applied = true
+		}
+		if actions.AccountID != nil && outcome.AccountID == nil {
+			outcome.AccountID = actions.AccountID
+			applied = true
+		}
+		if applied {
+			outcome.RuleIDs = append(outcome.RuleIDs, r.rule.ID)
+		}
+	}
+	return outcome
+}
+
+// ApplyToParsed 将规则应用到导入预览中的交易；用户已选择的分类和账户不会被覆盖
+func (e *RuleEngine) ApplyToParsed(tx *models.ParsedTransaction) {
+	outcome := e.Evaluate(RuleInputFromParsed(*tx))
+	if len(outcome.RuleIDs) == 0 {
+		return
+	}
+	if outcome.CategoryID != nil && tx.SelectedCategoryID == nil {
+		tx.SelectedCategoryID = outcome.CategoryID
+	}
+	if outcome.NoteChanged {
+		tx.Note = outcome.Note
+	}
+	if outcome.AccountID != nil && tx.SelectedAccountID == nil {
+		suggested := *outcome.AccountID
+		tx.SelectedAccountID = outcome.AccountID
+		tx.SuggestedAccountID = &suggested
+	}
+	tx.AppliedRuleIDs = outcome.RuleIDs
+}
+
+// RuleInputFromParsed 从导入预览交易构建规则输入
+func RuleInputFromParsed(tx models.ParsedTransaction) RuleInput {
+	return RuleInput{
+		Counterparty: firstNonEmpty(tx.Counterparty, tx.CategoryHint),
+		Note:         tx.Note,
+		Amount:       tx.Amount,
+		Type:         tx.Type,
+		Source:       tx.Source,
+		AccountID:    tx.SelectedAccountID,
+		AccountName:  tx.AccountName,
+		RawCategory:  rawCategory(tx.RawData),
+	}
+}
+
+// RuleInputFromTransaction 从已入账（或即将入账）的交易构建规则输入
+// 入账交易不保存对方名称，对方条件按备注匹配
+func RuleInputFromTransaction(t models.Transaction, accountName string) RuleInput {
+	accountID := t.AccountID
+	return RuleInput{
+		Counterparty: t.Note,
+		Note:         t.Note,
+		Amount:       t.Amount,
+		Type:         t.Type,
+		Source:       models.ImportSource(t.ImportSource),
+		AccountID:    &accountID,
+		AccountName:  accountName,
+	}
+}
+
+// rawCategory 账单自带的分类：支付宝“交易分类”、微信“交易类型”
+func rawCategory(raw map[string]string) string {
+	for _, key := range []string{"交易分类", "交易类型", "分类"} {
+		if val := strings.TrimSpace(raw[key]); val != "" {
+			return val
+		}
+	}
+	return ""
+}
+
+func (r compiledRule) matches(in RuleInput) bool {
+	c := r.rule.Conditions
+	if c.TransactionType != "" && c.TransactionType != in.Type {
+		return false
+	}
+	if c.MinAmount != nil && in.Amount < *c.MinAmount {
+		return false
+	}
+	if c.MaxAmount != nil && in.Amount > *c.MaxAmount {
+		return false
+	}
+	if len(c.Sources) > 0 {
+		found := false
+		for _, s := range c.Sources {
+			if s == in.Source {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if c.AccountID != nil && (in.AccountID == nil || *in.AccountID != *c.AccountID) {
+		return false
+	}
+
+	return r.matchText("counterparty", c.Counterparty, in.Counterparty) &&
+		r.matchText("note", c.Note, in.Note) &&
+		r.matchText("account_name", c.AccountName, in.AccountName) &&
+		r.matchText("raw_category", c.RawCategory, in.RawCategory)
+}
+
+func (r compiledRule) matchText(field, pattern, value string) bool {
+	if pattern == "" {
+		return true
+	}
+	switch r.rule.MatchMode {
+	case models.RuleMatchRegex:
+		return r.patterns[field] != nil && r.patterns[field].MatchString(value)
+	case models.RuleMatchExact:
+		return strings.EqualFold(strings.TrimSpace(value), strings.TrimSpace(pattern))
+	default:
+		return strings.Contains(strings.ToLower(value), strings.ToLower(pattern))
+	}
+}
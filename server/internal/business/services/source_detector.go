@@ -0,0 +1,141 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"account/internal/business/models"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/transform"
+)
+
+var mt940StatementLineTag = regexp.MustCompile(`(?m)^:(60[FM]|61):`)
+
+// DetectImportSource guesses the import source of a bill file from its
+// content, falling back to the file name. Content is expected as UTF-8; see
+// NormalizeBillText. The second result is false when nothing matched.
+func DetectImportSource(fileName string, content []byte) (models.ImportSource, bool) {
+	head := content
+	if len(head) > 8192 {
+		head = head[:8192]
+	}
+	text := string(head)
+	upper := strings.ToUpper(text)
+
+	switch {
+	case strings.Contains(upper, "<OFX>") || strings.Contains(upper, "OFXHEADER"):
+		return models.ImportSourceOFX, true
+	case strings.Contains(text, "camt.053") || strings.Contains(text, "BkToCstmrStmt"):
+		return models.ImportSourceCamt053, true
+	case mt940StatementLineTag.MatchString(text) && strings.Contains(text, ":25:"):
+		return models.ImportSourceMT940, true
+	case strings.HasPrefix(strings.TrimSpace(text), "!Type:") || strings.HasPrefix(strings.TrimSpace(text), "!Account") ||
+		strings.HasPrefix(strings.TrimSpace(text), "!Option"):
+		return models.ImportSourceQIF, true
+	case strings.Contains(text, "微信支付") || strings.Contains(text, "交易单号"):
+		return models.ImportSourceWeChat, true
+	case strings.Contains(text, "支付宝") || strings.Contains(text, "交易号"):
+		return models.ImportSourceAlipay, true
+	case strings.Contains(text, "京东") || strings.Contains(text, "订单号"):
+		return models.ImportSourceJD, true
+	case strings.Contains(text, "流水号") || strings.Contains(text, "余额") ||
+		strings.Contains(text, "收入金额") || strings.Contains(text, "支出金额"):
+		return models.ImportSourceBank, true
+	}
+
+	name := strings.ToLower(filepath.Base(fileName))
+	switch {
+	case strings.HasSuffix(name, ".ofx") || strings.HasSuffix(name, ".qfx"):
+		return models.ImportSourceOFX, true
+	case strings.HasSuffix(name, ".qif"):
+		return models.ImportSourceQIF, true
+	case strings.HasSuffix(name, ".sta") || strings.HasSuffix(name, ".mt940"):
+		return models.ImportSourceMT940, true
+	case strings.Contains(name, "alipay") || strings.Contains(name, "支付宝"):
+		return models.ImportSourceAlipay, true
+	case strings.Contains(name, "wechat") || strings.Contains(name, "微信"):
+		return models.ImportSourceWeChat, true
+	case strings.HasSuffix(name, ".csv"):
+		return models.ImportSourceGeneric, true
+	}
+
+	return "", false
+}
+
+// NormalizeBillText converts GBK/GB18030 encoded exports (Alipay, most
+// Chinese banks) to UTF-8 and strips a UTF-8 byte order mark. Binary content
+// and content that is already UTF-8 is returned unchanged.
+func NormalizeBillText(content []byte) []byte {
+	content = bytes.TrimPrefix(content, utf8BOM)
+	if utf8.Valid(content) || bytes.IndexByte(content, 0) >= 0 {
+		return content
+	}
+	decoded, err := simplifiedchinese.GB18030.NewDecoder().Bytes(content)
+	if err != nil {
+		return content
+	}
+	return decoded
+}
+
+// billHeadSize is how much of a file is looked at to detect its source and encoding
+const billHeadSize = 8192
+
+// NewBillReader buffers a bill file so that PeekBillHead can look at its start
+func NewBillReader(r io.Reader) *bufio.Reader {
+	return bufio.NewReaderSize(r, billHeadSize)
+}
+
+// PeekBillHead returns the start of a bill file converted to UTF-8, for
+// DetectImportSource, without consuming it from r
+func PeekBillHead(r *bufio.Reader) ([]byte, error) {
+	head, err := r.Peek(billHeadSize)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, err
+	}
+	head = bytes.TrimPrefix(head, utf8BOM)
+	if isUTF8Head(head, err == io.EOF) || bytes.IndexByte(head, 0) >= 0 {
+		return append([]byte(nil), head...), nil
+	}
+	decoded, _, _ := transform.Bytes(simplifiedchinese.GB18030.NewDecoder(), head)
+	return decoded, nil
+}
+
+// openBillText is NormalizeBillText for a stream: the encoding is detected
+// from the start of the file and the rest is converted as it is read
+func openBillText(r io.Reader) io.Reader {
+	br := NewBillReader(r)
+	head, err := br.Peek(billHeadSize)
+	if bytes.HasPrefix(head, utf8BOM) {
+		_, _ = br.Discard(len(utf8BOM))
+		head = head[len(utf8BOM):]
+	}
+	if err != nil && err != io.EOF || isUTF8Head(head, err == io.EOF) || bytes.IndexByte(head, 0) >= 0 {
+		// Read errors surface on the first read of the parser
+		return br
+	}
+	return transform.NewReader(br, simplifiedchinese.GB18030.NewDecoder())
+}
+
+var utf8BOM = []byte("\xef\xbb\xbf")
+
+// isUTF8Head reports whether the start of a file is UTF-8. Unless the head is
+// the whole file, a character cut off at its end is ignored.
+func isUTF8Head(head []byte, whole bool) bool {
+	if !whole {
+		for i := len(head) - 1; i >= 0 && i >= len(head)-utf8.UTFMax; i-- {
+			if utf8.RuneStart(head[i]) {
+				if !utf8.FullRune(head[i:]) {
+					head = head[:i]
+				}
+				break
+			}
+		}
+	}
+	return utf8.Valid(head)
+}
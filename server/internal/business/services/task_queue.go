@@ -0,0 +1,307 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	stdsync "sync"
+	"time"
+
+	"account/internal/business/models"
+	"account/internal/data/repository"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// TaskHandler runs one attempt of a background task. It should return when
+// ctx is cancelled, which happens when the task is cancelled or the server
+// stops. An error is retried with backoff unless it is Permanent or the attempt
+// was the task's last.
+type TaskHandler func(ctx context.Context, task *models.BackgroundTask) error
+
+// TaskQueueConfig tunes the workers of the task queue
+type TaskQueueConfig struct {
+	Workers      int           // 同时运行的任务数
+	PerUserLimit int           // 每个用户同时运行的任务数
+	MaxAttempts  int           // 新任务的最多尝试次数
+	Lease        time.Duration // 任务被占用的时长，运行中定期续期
+	PollInterval time.Duration
+	RetryBase    time.Duration // 第一次重试前的等待，之后每次加倍
+	RetryMax     time.Duration
+}
+
+// DefaultTaskQueueConfig returns the settings used when none are configured
+func DefaultTaskQueueConfig() TaskQueueConfig {
+	return TaskQueueConfig{
+		Workers:      4,
+		PerUserLimit: 1,
+		MaxAttempts:  3,
+		Lease:        5 * time.Minute,
+		PollInterval: 5 * time.Second,
+		RetryBase:    30 * time.Second,
+		RetryMax:     30 * time.Minute,
+	}
+}
+
+// RetryBackoff returns how long to wait before retrying a task whose attempt
+// failed: RetryBase after the first attempt, doubling up to RetryMax
+func (c TaskQueueConfig) RetryBackoff(attempt int) time.Duration {
+	backoff := c.RetryBase
+	for i := 1; i < attempt && backoff < c.RetryMax; i++ {
+		backoff *= 2
+	}
+	if backoff > c.RetryMax {
+		backoff = c.RetryMax
+	}
+	return backoff
+}
+
+// permanentError marks a task error that retrying cannot fix
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps an error so that the task fails without further attempts
+func Permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+// IsPermanent tells whether a task error was marked Permanent
+func IsPermanent(err error) bool {
+	var permanent *permanentError
+	return errors.As(err, &permanent)
+}
+
+// TaskQueue runs background tasks stored in Postgres. Tasks survive a restart
+// of the server: a task that was running when the server stopped is picked up
+// again once its lease runs out, or right away after a graceful stop.
+type TaskQueue struct {
+	repo     *repository.TaskRepository
+	config   TaskQueueConfig
+	logger   *zap.Logger
+	handlers map[string]TaskHandler
+
+	mu      stdsync.Mutex
+	running map[uuid.UUID]context.CancelFunc
+	wake    chan struct{}
+	stop    context.CancelFunc
+	wg      stdsync.WaitGroup
+}
+
+// NewTaskQueue creates a new TaskQueue. Handlers are registered before Start.
+func NewTaskQueue(repo *repository.TaskRepository, config TaskQueueConfig, logger *zap.Logger) *TaskQueue {
+	return &TaskQueue{
+		repo:     repo,
+		config:   config,
+		logger:   logger,
+		handlers: make(map[string]TaskHandler),
+		running:  make(map[uuid.UUID]context.CancelFunc),
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// Register sets the handler for tasks of a kind
+func (q *TaskQueue) Register(kind string, handler TaskHandler) {
+	q.handlers[kind] = handler
+}
+
+// Enqueue stores a task for the user. The payload is kept as JSON and handed
+// to the handler of the kind.
+func (q *TaskQueue) Enqueue(id, userID uuid.UUID, kind string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode task payload: %w", err)
+	}
+
+	task := &models.BackgroundTask{
+		ID:          id,
+		UserID:      userID,
+		Kind:        kind,
+		Payload:     data,
+		MaxAttempts: q.config.MaxAttempts,
+	}
+	if err := q.repo.Enqueue(task); err != nil {
+		return err
+	}
+
+	// Wake an idle worker rather than waiting for the next poll
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Cancel cancels a queued or running task of the user. A task running on this
+// server is stopped right away, one running elsewhere on its next heartbeat.
+func (q *TaskQueue) Cancel(id, userID uuid.UUID) error {
+	if err := q.repo.Cancel(id, userID); err != nil {
+		return err
+	}
+
+	q.mu.Lock()
+	cancel, ok := q.running[id]
+	q.mu.Unlock()
+	if ok {
+		cancel()
+	}
+	return nil
+}
+
+// Unfinished returns the queued and running tasks of a kind
+func (q *TaskQueue) Unfinished(kind string) ([]models.BackgroundTask, error) {
+	return q.repo.GetUnfinished(kind)
+}
+
+// FinishedSince returns the succeeded and failed tasks of a kind that finished after since
+func (q *TaskQueue) FinishedSince(kind string, since time.Time) ([]models.BackgroundTask, error) {
+	return q.repo.GetFinishedSince(kind, since)
+}
+
+// SaveResult stores the result of a task as JSON. A handler may save one at
+// any time; it is kept across attempts and after the task has finished.
+func (q *TaskQueue) SaveResult(id, userID uuid.UUID, result interface{}) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("failed to encode task result: %w", err)
+	}
+	return q.repo.SaveResult(id, userID, data)
+}
+
+// Delete removes a task that has finished or was cancelled, with its result
+func (q *TaskQueue) Delete(id, userID uuid.UUID) error {
+	return q.repo.Delete(id, userID)
+}
+
+// Start starts the workers
+func (q *TaskQueue) Start() {
+	ctx, stop := context.WithCancel(context.Background())
+	q.stop = stop
+
+	kinds := make([]string, 0, len(q.handlers))
+	for kind := range q.handlers {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+
+	for i := 0; i < q.config.Workers; i++ {
+		q.wg.Add(1)
+		go q.work(ctx, kinds)
+	}
+}
+
+// Stop stops the workers and waits for them. Tasks that are still running are
+// cancelled and put back in the queue for the next start.
+func (q *TaskQueue) Stop() {
+	if q.stop == nil {
+		return
+	}
+	q.stop()
+	q.wg.Wait()
+}
+
+// work claims and runs due tasks until ctx is cancelled
+func (q *TaskQueue) work(ctx context.Context, kinds []string) {
+	defer q.wg.Done()
+
+	ticker := time.NewTicker(q.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		task, err := q.repo.Claim(kinds, q.config.PerUserLimit, q.config.Lease)
+		if err != nil {
+			q.logger.Error("Failed to claim task", zap.Error(err))
+		}
+		if task != nil {
+			q.run(ctx, task)
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-q.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// run runs one attempt of a task and records its outcome
+func (q *TaskQueue) run(ctx context.Context, task *models.BackgroundTask) {
+	taskCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	q.mu.Lock()
+	q.running[task.ID] = cancel
+	q.mu.Unlock()
+	defer func() {
+		q.mu.Lock()
+		delete(q.running, task.ID)
+		q.mu.Unlock()
+	}()
+
+	// Keep the lease while the task runs; a cancelled task loses it
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(q.config.Lease / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := q.repo.Heartbeat(task.ID, q.config.Lease); errors.Is(err, repository.ErrTaskNotRunning) {
+					cancel()
+					return
+				} else if err != nil {
+					q.logger.Warn("Failed to extend task lease", zap.String("task_id", task.ID.String()), zap.Error(err))
+				}
+			}
+		}
+	}()
+
+	logger := q.logger.With(zap.String("task_id", task.ID.String()), zap.String("kind", task.Kind), zap.Int("attempt", task.Attempts))
+	err := q.call(taskCtx, task)
+
+	var recordErr error
+	switch {
+	case err == nil:
+		recordErr = q.repo.Complete(task.ID)
+	case ctx.Err() != nil:
+		logger.Info("Task interrupted by shutdown")
+		recordErr = q.repo.Release(task.ID)
+	case taskCtx.Err() != nil:
+		logger.Info("Task cancelled")
+	case IsPermanent(err) || task.LastAttempt():
+		logger.Error("Task failed", zap.Error(err))
+		recordErr = q.repo.Fail(task.ID, err.Error())
+	default:
+		retryAt := time.Now().Add(q.config.RetryBackoff(task.Attempts))
+		logger.Warn("Task attempt failed, retrying", zap.Error(err), zap.Time("retry_at", retryAt))
+		recordErr = q.repo.Retry(task.ID, retryAt, err.Error())
+	}
+	if recordErr != nil && !errors.Is(recordErr, repository.ErrTaskNotRunning) {
+		logger.Error("Failed to record task outcome", zap.Error(recordErr))
+	}
+}
+
+// call runs the handler of a task, turning a panic into an error
+func (q *TaskQueue) call(ctx context.Context, task *models.BackgroundTask) (err error) {
+	handler, ok := q.handlers[task.Kind]
+	if !ok {
+		return Permanent(fmt.Errorf("no handler for task kind %s", task.Kind))
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("task panicked: %v", r)
+		}
+	}()
+	return handler(ctx, task)
+}
@@ -13,33 +13,48 @@ type TransactionService struct {
 	transactionRepo *repository.TransactionRepository
 	accountRepo     *repository.AccountRepository
 	categoryRepo    *repository.CategoryRepository
+	payeeRepo       *repository.PayeeRepository
+	ruleRepo        *repository.CategoryRuleRepository
+	suggester       *CategorySuggester
+	reconciler      *ReconciliationService
 }
 
 func NewTransactionService(
 	transactionRepo *repository.TransactionRepository,
 	accountRepo *repository.AccountRepository,
 	categoryRepo *repository.CategoryRepository,
+	payeeRepo *repository.PayeeRepository,
+	ruleRepo *repository.CategoryRuleRepository,
+	suggester *CategorySuggester,
+	reconciler *ReconciliationService,
 ) *TransactionService {
 	return &TransactionService{
 		transactionRepo: transactionRepo,
 		accountRepo:     accountRepo,
 		categoryRepo:    categoryRepo,
+		payeeRepo:       payeeRepo,
+		ruleRepo:        ruleRepo,
+		suggester:       suggester,
+		reconciler:      reconciler,
 	}
 }
 
 type CreateTransactionRequest struct {
 	AccountID       uuid.UUID                `json:"account_id" binding:"required"`
 	CategoryID      *uuid.UUID               `json:"category_id"`
+	PayeeID         *uuid.UUID               `json:"payee_id"`
 	Type            models.TransactionType   `json:"type" binding:"required"`
 	Amount          float64                  `json:"amount" binding:"required,min=0"`
 	Currency        string                   `json:"currency"`
 	Note            string                   `json:"note"`
 	TransactionDate time.Time                `json:"transaction_date" binding:"required"`
+	ApplyRules      bool                     `json:"apply_rules"` // 按分类规则补全分类和备注
 }
 
 type UpdateTransactionRequest struct {
 	AccountID       uuid.UUID                `json:"account_id"`
 	CategoryID      *uuid.UUID               `json:"category_id"`
+	PayeeID         *uuid.UUID               `json:"payee_id"`
 	Type            models.TransactionType   `json:"type"`
 	Amount          float64                  `json:"amount"`
 	Currency        string                   `json:"currency"`
@@ -48,8 +63,9 @@ type UpdateTransactionRequest struct {
 }
 
 type TransactionListRequest struct {
-	Limit  int    `form:"limit,default=50"`
-	Offset int    `form:"offset,default=0"`
+	Limit   int    `form:"limit,default=50"`
+	Offset  int    `form:"offset,default=0"`
+	PayeeID string `form:"payee_id"` // 只返回该商户的交易
 }
 
 type StatsRequest struct {
@@ -74,6 +90,14 @@ type CategoryStatsItem struct {
 	Percentage      float64 `json:"percentage"`
 }
 
+type PayeeStatsItem struct {
+	PayeeID          string  `json:"payee_id"`
+	PayeeName        string  `json:"payee_name"`
+	TotalAmount      float64 `json:"total_amount"`
+	TransactionCount int     `json:"transaction_count"`
+	Percentage       float64 `json:"percentage"`
+}
+
 type MonthlyStatsItem struct {
 	Year         int     `json:"year"`
 	Month        int     `json:"month"`
@@ -85,6 +109,7 @@ type MonthlyStatsItem struct {
 type DetailedStatsResponse struct {
 	Summary       *StatsResponse         `json:"summary"`
 	ByCategory    []CategoryStatsItem    `json:"by_category"`
+	ByPayee       []PayeeStatsItem       `json:"by_payee"`
 	MonthlyTrend  []MonthlyStatsItem     `json:"monthly_trend"`
 }
 
@@ -107,6 +132,26 @@ func (s *TransactionService) CreateTransaction(userID uuid.UUID, req *CreateTran
 		return nil, fmt.Errorf("invalid account: %w", err)
 	}
 
+	// The account is chosen explicitly, so only category and note actions apply
+	if req.ApplyRules {
+		engine, err := loadRuleEngine(s.ruleRepo, userID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load category rules: %w", err)
+		}
+		outcome := engine.Evaluate(RuleInputFromTransaction(models.Transaction{
+			AccountID: req.AccountID,
+			Type:      req.Type,
+			Amount:    req.Amount,
+			Note:      req.Note,
+		}, account.Name))
+		if req.CategoryID == nil {
+			req.CategoryID = outcome.CategoryID
+		}
+		if outcome.NoteChanged {
+			req.Note = outcome.Note
+		}
+	}
+
 	if req.CategoryID != nil {
 		_, err := s.categoryRepo.GetByID(*req.CategoryID, userID)
 		if err != nil {
@@ -114,10 +159,18 @@ func (s *TransactionService) CreateTransaction(userID uuid.UUID, req *CreateTran
 		}
 	}
 
+	if req.PayeeID != nil {
+		_, err := s.payeeRepo.GetByID(*req.PayeeID, userID)
+		if err != nil {
+			return nil, fmt.Errorf("invalid payee: %w", err)
+		}
+	}
+
 	transaction, err := s.transactionRepo.Create(
 		userID,
 		req.AccountID,
 		req.CategoryID,
+		req.PayeeID,
 		req.Type,
 		req.Amount,
 		req.Currency,
@@ -131,6 +184,11 @@ func (s *TransactionService) CreateTransaction(userID uuid.UUID, req *CreateTran
 	if err := s.updateAccountBalance(account, req.Type, req.Amount); err != nil {
 		return nil, err
 	}
+	s.reconciler.RecheckAssertions(userID, transaction.AccountID, transaction.TransactionDate)
+
+	if transaction.CategoryID != nil {
+		s.suggester.Learn(userID, ExampleFromTransaction(*transaction), *transaction.CategoryID)
+	}
 
 	return transaction, nil
 }
@@ -151,6 +209,14 @@ func (s *TransactionService) GetAllTransactions(userID uuid.UUID, req *Transacti
 		req.Limit = 200
 	}
 
+	if req.PayeeID != "" {
+		payeeID, err := uuid.Parse(req.PayeeID)
+		if err != nil {
+			return nil, fmt.Errorf("invalid payee id: %w", err)
+		}
+		return s.transactionRepo.GetByPayee(userID, payeeID, req.Limit, req.Offset)
+	}
+
 	transactions, err := s.transactionRepo.GetAll(userID, req.Limit, req.Offset)
 	if err != nil {
 		return nil, err
@@ -199,6 +265,14 @@ func (s *TransactionService) UpdateTransaction(id uuid.UUID, userID uuid.UUID, r
 		}
 	}
 
+	if req.PayeeID != nil {
+		_, err := s.payeeRepo.GetByID(*req.PayeeID, userID)
+		if err != nil {
+			return nil, fmt.Errorf("invalid payee: %w", err)
+		}
+	}
+
+	before := *transaction
 	oldType := transaction.Type
 	oldAmount := transaction.Amount
 
@@ -214,6 +288,9 @@ func (s *TransactionService) UpdateTransaction(id uuid.UUID, userID uuid.UUID, r
 	if req.CategoryID != nil {
 		transaction.CategoryID = req.CategoryID
 	}
+	if req.PayeeID != nil {
+		transaction.PayeeID = req.PayeeID
+	}
 	if req.Amount > 0 {
 		transaction.Amount = req.Amount
 	}
@@ -245,6 +322,24 @@ func (s *TransactionService) UpdateTransaction(id uuid.UUID, userID uuid.UUID, r
 		return nil, err
 	}
 
+	// Both the old and the new position of the transaction may break a reconciled balance
+	if before.AccountID != updatedTransaction.AccountID {
+		s.reconciler.RecheckAssertions(userID, before.AccountID, before.TransactionDate)
+		s.reconciler.RecheckAssertions(userID, updatedTransaction.AccountID, updatedTransaction.TransactionDate)
+	} else if before.TransactionDate.Before(updatedTransaction.TransactionDate) {
+		s.reconciler.RecheckAssertions(userID, before.AccountID, before.TransactionDate)
+	} else {
+		s.reconciler.RecheckAssertions(userID, before.AccountID, updatedTransaction.TransactionDate)
+	}
+
+	// Category corrections retrain the suggestion model
+	if before.CategoryID != nil {
+		s.suggester.Forget(userID, ExampleFromTransaction(before), *before.CategoryID)
+	}
+	if updatedTransaction.CategoryID != nil {
+		s.suggester.Learn(userID, ExampleFromTransaction(*updatedTransaction), *updatedTransaction.CategoryID)
+	}
+
 	return updatedTransaction, nil
 }
 
@@ -266,6 +361,11 @@ func (s *TransactionService) DeleteTransaction(id uuid.UUID, userID uuid.UUID) e
 	if err := s.reverseAccountBalance(account, transaction.Type, transaction.Amount); err != nil {
 		return err
 	}
+	s.reconciler.RecheckAssertions(userID, transaction.AccountID, transaction.TransactionDate)
+
+	if transaction.CategoryID != nil {
+		s.suggester.Forget(userID, ExampleFromTransaction(*transaction), *transaction.CategoryID)
+	}
 
 	return nil
 }
@@ -331,6 +431,28 @@ func (s *TransactionService) GetDetailedStats(userID uuid.UUID, req *StatsReques
 		})
 	}
 
+	// Get spending by payee
+	payeeStats, err := s.transactionRepo.GetPayeeStats(userID, req.StartDate, req.EndDate)
+	if err != nil {
+		return nil, err
+	}
+
+	var payeeItems []PayeeStatsItem
+	for _, ps := range payeeStats {
+		var percentage float64
+		if expense > 0 {
+			percentage = (ps.TotalAmount / expense) * 100
+		}
+
+		payeeItems = append(payeeItems, PayeeStatsItem{
+			PayeeID:          ps.PayeeID.String(),
+			PayeeName:        ps.PayeeName,
+			TotalAmount:      ps.TotalAmount,
+			TransactionCount: ps.TransactionCount,
+			Percentage:       percentage,
+		})
+	}
+
 	// Get monthly trend
 	monthlyStats, err := s.transactionRepo.GetMonthlyTrend(userID, req.StartDate, req.EndDate)
 	if err != nil {
@@ -351,6 +473,7 @@ func (s *TransactionService) GetDetailedStats(userID uuid.UUID, req *StatsReques
 	return &DetailedStatsResponse{
 		Summary:      summary,
 		ByCategory:   categoryItems,
+		ByPayee:      payeeItems,
 		MonthlyTrend: monthlyItems,
 	}, nil
 }
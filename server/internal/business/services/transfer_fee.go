@@ -0,0 +1,114 @@
+package services
+
+import (
+	"math"
+	"sort"
+
+	"account/internal/business/models"
+	"github.com/google/uuid"
+)
+
+// FeeKind 手续费的收取方式
+type FeeKind string
+
+const (
+	FeeFixed      FeeKind = "fixed"      // 每笔固定金额
+	FeePercentage FeeKind = "percentage" // 按到账金额的比例
+)
+
+const (
+	feePatternMinSamples = 2  // 至少观察到几笔才认为成规律
+	feePatternHistory    = 10 // 每个账户对参与学习的最近手续费笔数
+	feeAmountTolerance   = 0.01
+)
+
+// FeePattern 某两个账户之间转账的手续费规律
+// 按账户而不是账户名称区分：名称可以随意修改，同一家银行的名称也常常写法不一
+type FeePattern struct {
+	FromAccountID uuid.UUID
+	ToAccountID   uuid.UUID
+	Kind          FeeKind
+	Fee           float64 // 固定手续费
+	Rate          float64 // 费率
+	Samples       int
+}
+
+// Expected 按规律估算到账金额为 amount 的转账的手续费
+func (p FeePattern) Expected(amount float64) float64 {
+	if p.Kind == FeeFixed {
+		return p.Fee
+	}
+	return roundAmount(amount * p.Rate)
+}
+
+// LearnFeePatterns 从已确认转账拆出的手续费中学习每个账户对的收费规律：
+// 每笔手续费都相同视为固定收费，按同一费率能算出每笔手续费视为按比例收费，其余不成规律。
+// fees 中同一账户对的记录应按时间从新到旧排列，只取最近的若干笔。
+func LearnFeePatterns(fees []models.TransferFee) []FeePattern {
+	type pair struct{ from, to uuid.UUID }
+	var order []pair
+	byPair := make(map[pair][]models.TransferFee)
+	for _, fee := range fees {
+		key := pair{fee.FromAccountID, fee.ToAccountID}
+		if _, ok := byPair[key]; !ok {
+			order = append(order, key)
+		}
+		if len(byPair[key]) < feePatternHistory {
+			byPair[key] = append(byPair[key], fee)
+		}
+	}
+
+	var patterns []FeePattern
+	for _, key := range order {
+		samples := byPair[key]
+		if len(samples) < feePatternMinSamples {
+			continue
+		}
+		pattern := FeePattern{FromAccountID: key.from, ToAccountID: key.to, Samples: len(samples)}
+
+		amounts := make([]float64, len(samples))
+		for i, s := range samples {
+			amounts[i] = s.Fee
+		}
+		if fixed := median(amounts); fitsAll(samples, func(models.TransferFee) float64 { return fixed }) {
+			pattern.Kind, pattern.Fee = FeeFixed, fixed
+			patterns = append(patterns, pattern)
+			continue
+		}
+
+		var rateSum float64
+		for _, s := range samples {
+			if s.Amount <= 0 {
+				rateSum = math.NaN()
+				break
+			}
+			rateSum += s.Fee / s.Amount
+		}
+		rate := rateSum / float64(len(samples))
+		if !math.IsNaN(rate) && fitsAll(samples, func(s models.TransferFee) float64 { return roundAmount(s.Amount * rate) }) {
+			pattern.Kind, pattern.Rate = FeePercentage, rate
+			patterns = append(patterns, pattern)
+		}
+	}
+	return patterns
+}
+
+// fitsAll 判断估算值是否与每笔实际手续费相差不超过一分钱
+func fitsAll(samples []models.TransferFee, expected func(models.TransferFee) float64) bool {
+	for _, s := range samples {
+		if math.Abs(expected(s)-s.Fee) > feeAmountTolerance {
+			return false
+		}
+	}
+	return true
+}
+
+func median(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
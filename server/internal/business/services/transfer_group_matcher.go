@@ -0,0 +1,235 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	groupCandidateLimit = 16   // 每笔交易只在时间最近的若干笔中找组合
+	groupSolutionLimit  = 64   // 每笔交易最多比较的组合数
+	groupLegPenalty     = 0.05 // 每多一笔扣减的置信度，组合越简单越可信
+)
+
+// groupProposal 一个候选组合及其成员在转出/转入列表中的下标
+type groupProposal struct {
+	outs, ins  []int
+	confidence float64
+	factors    []string
+}
+
+// findGroups 在未能一对一配对的记录中找一对多、多对一的组合：
+// 对每一笔转出（转入），在时间窗口内的未配对转入（转出）中找金额之和等于它的子集，
+// 再按置信度从高到低选出互不重叠的组合。组合只是建议，需要用户确认
+func (m *TransferMatcher) findGroups(outTxs, inTxs []TransactionInfo) []TransferGroup {
+	if m.config.MaxGroupSize < 2 || len(outTxs) == 0 || len(inTxs) == 0 {
+		return nil
+	}
+
+	inByDate, outByDate := indexByDate(inTxs), indexByDate(outTxs)
+	var proposals []groupProposal
+	for i, out := range outTxs {
+		if members, confidence, factors := m.bestSubset(out, inTxs, inByDate, false); members != nil {
+			proposals = append(proposals, groupProposal{outs: []int{i}, ins: members, confidence: confidence, factors: factors})
+		}
+	}
+	for j, in := range inTxs {
+		if members, confidence, factors := m.bestSubset(in, outTxs, outByDate, true); members != nil {
+			proposals = append(proposals, groupProposal{outs: members, ins: []int{j}, confidence: confidence, factors: factors})
+		}
+	}
+
+	sort.SliceStable(proposals, func(a, b int) bool {
+		if proposals[a].confidence != proposals[b].confidence {
+			return proposals[a].confidence > proposals[b].confidence
+		}
+		return len(proposals[a].outs)+len(proposals[a].ins) < len(proposals[b].outs)+len(proposals[b].ins)
+	})
+
+	usedOut := make(map[int]bool)
+	usedIn := make(map[int]bool)
+	var groups []TransferGroup
+	for _, p := range proposals {
+		if anyUsed(usedOut, p.outs) || anyUsed(usedIn, p.ins) {
+			continue
+		}
+		group := TransferGroup{ID: uuid.New(), Confidence: p.confidence, MatchFactors: p.factors}
+		for _, i := range p.outs {
+			usedOut[i] = true
+			group.Outs = append(group.Outs, outTxs[i])
+		}
+		for _, j := range p.ins {
+			usedIn[j] = true
+			group.Ins = append(group.Ins, inTxs[j])
+		}
+		sortByDate(group.Outs)
+		sortByDate(group.Ins)
+		groups = append(groups, group)
+	}
+
+	sort.SliceStable(groups, func(a, b int) bool {
+		return groupDate(groups[a]).Before(groupDate(groups[b]))
+	})
+	return groups
+}
+
+// indexByDate 返回按日期排序的下标，供二分查找时间窗口
+func indexByDate(txs []TransactionInfo) []int {
+	byDate := make([]int, len(txs))
+	for i := range byDate {
+		byDate[i] = i
+	}
+	sort.SliceStable(byDate, func(a, b int) bool {
+		return txs[byDate[a]].Date.Before(txs[byDate[b]].Date)
+	})
+	return byDate
+}
+
+// bestSubset 在 others 中找金额之和等于 single 的最可信组合，返回成员下标、置信度和依据。
+// byDate 是 others 按日期排序的下标；singleIsIn 表示 single 是转入、others 是转出
+func (m *TransferMatcher) bestSubset(single TransactionInfo, others []TransactionInfo, byDate []int, singleIsIn bool) ([]int, float64, []string) {
+	tolerance := toCents(m.config.AmountTolerance)
+	target := toCents(single.Amount)
+
+	var pool []int
+	from := single.Date.Add(-m.config.TimeWindow)
+	k := sort.Search(len(byDate), func(k int) bool {
+		return !others[byDate[k]].Date.Before(from)
+	})
+	for ; k < len(byDate); k++ {
+		other := others[byDate[k]]
+		if other.Date.After(single.Date.Add(m.config.TimeWindow)) {
+			break
+		}
+		// 账本中的交易只与新导入的行组合
+		if single.Existing && other.Existing {
+			continue
+		}
+		if toCents(other.Amount) >= target-tolerance {
+			continue
+		}
+		pool = append(pool, byDate[k])
+	}
+	if len(pool) < 2 {
+		return nil, 0, nil
+	}
+	sort.Ints(pool) // 距离相同时按原顺序取舍
+
+	sort.SliceStable(pool, func(a, b int) bool {
+		return absDuration(others[pool[a]].Date.Sub(single.Date)) < absDuration(others[pool[b]].Date.Sub(single.Date))
+	})
+	if len(pool) > groupCandidateLimit {
+		pool = pool[:groupCandidateLimit]
+	}
+	// 按金额从大到小搜索，便于剪枝
+	sort.SliceStable(pool, func(a, b int) bool {
+		return others[pool[a]].Amount > others[pool[b]].Amount
+	})
+	suffix := make([]int64, len(pool)+1)
+	for k := len(pool) - 1; k >= 0; k-- {
+		suffix[k] = suffix[k+1] + toCents(others[pool[k]].Amount)
+	}
+
+	var best []int
+	var bestFactors []string
+	bestConfidence := -1.0
+	explored := 0
+	var chosen []int
+	var search func(start int, remaining int64)
+	search = func(start int, remaining int64) {
+		if explored >= groupSolutionLimit {
+			return
+		}
+		if remaining >= -tolerance && remaining <= tolerance {
+			if len(chosen) >= 2 {
+				explored++
+				confidence, factors := m.groupConfidence(single, others, chosen, singleIsIn)
+				if confidence > bestConfidence {
+					best = append([]int(nil), chosen...)
+					bestConfidence, bestFactors = confidence, factors
+				}
+			}
+			return
+		}
+		if len(chosen) >= m.config.MaxGroupSize {
+			return
+		}
+		for k := start; k < len(pool); k++ {
+			if suffix[k] < remaining-tolerance {
+				break // 剩下的全部加上也不够
+			}
+			amount := toCents(others[pool[k]].Amount)
+			if amount > remaining+tolerance {
+				continue
+			}
+			chosen = append(chosen, pool[k])
+			search(k+1, remaining-amount)
+			chosen = chosen[:len(chosen)-1]
+		}
+	}
+	search(0, target)
+
+	if best == nil || bestConfidence < m.config.CandidateConfidence {
+		return nil, 0, nil
+	}
+	return best, bestConfidence, bestFactors
+}
+
+// groupConfidence 组合的置信度：合计金额相符记满金额分，其余按每一笔与 single
+// 的尾号、时间、名称证据取平均，每多一笔略扣分
+func (m *TransferMatcher) groupConfidence(single TransactionInfo, others []TransactionInfo, members []int, singleIsIn bool) (float64, []string) {
+	var evidence float64
+	var legFactors []string
+	seen := make(map[string]bool)
+	for _, k := range members {
+		out, in := single, others[k]
+		if singleIsIn {
+			out, in = others[k], single
+		}
+		// 逐笔按金额相同打分，再去掉金额部分
+		out.Amount = in.Amount
+		confidence, _, factors := m.calculateMatchConfidence(out, in)
+		evidence += confidence - m.weights.Amount
+		for _, f := range factors {
+			if f != "金额完全匹配" && !seen[f] {
+				seen[f] = true
+				legFactors = append(legFactors, f)
+			}
+		}
+	}
+
+	confidence := m.weights.Amount + evidence/float64(len(members)) - groupLegPenalty*float64(len(members)-2)
+	factors := append([]string{fmt.Sprintf("%d笔合计金额匹配", len(members))}, legFactors...)
+	return math.Max(confidence, 0), factors
+}
+
+func toCents(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+func anyUsed(used map[int]bool, indexes []int) bool {
+	for _, i := range indexes {
+		if used[i] {
+			return true
+		}
+	}
+	return false
+}
+
+func sortByDate(txs []TransactionInfo) {
+	sort.SliceStable(txs, func(a, b int) bool {
+		return txs[a].Date.Before(txs[b].Date)
+	})
+}
+
+// groupDate 组合中最早一笔的时间
+func groupDate(g TransferGroup) time.Time {
+	if len(g.Outs) > 0 && (len(g.Ins) == 0 || g.Outs[0].Date.Before(g.Ins[0].Date)) {
+		return g.Outs[0].Date
+	}
+	return g.Ins[0].Date
+}
@@ -0,0 +1,203 @@
+package services
+
+import (
+	"math"
+	"sort"
+
+	"account/internal/business/models"
+)
+
+const (
+	matchModelMinEach       = 5    // 确认和拒绝都至少有几笔才学习权重和配对阈值
+	matchModelPriorSamples  = 20   // 默认权重相当于多少笔反馈，样本少时学到的权重向默认值收缩
+	matchModelIterations    = 2000 // 逻辑回归梯度下降的迭代次数
+	matchModelLearningRate  = 1.0
+	matchModelRegularizer   = 0.01
+	matchThresholdFloor     = 0.5  // 学到的配对阈值不低于候选阈值
+	matchThresholdCeiling   = 0.95 // 也不会高到几乎不再配对
+	matchFeedbackHistory    = 500  // 参与学习的最近反馈笔数
+	autoConfirmMinSamples   = 10   // 阈值以上至少有几笔反馈才自动确认
+	autoConfirmMaxRejection = 0.05 // 阈值以上被拒绝的比例上限
+)
+
+// MatchModel 从一个用户的确认/拒绝中学到的转账匹配参数
+type MatchModel struct {
+	Weights       models.MatchWeights
+	MinConfidence float64 // 学到的配对阈值，0 表示沿用配置
+	AutoConfirm   float64 // 达到该置信度的配对在分析时直接确认，0 表示不自动确认
+	Accepted      int
+	Rejected      int
+}
+
+// LearnMatchModel 用户确认和拒绝过的配对记录了当时各类证据的得分。
+// 权重：以得分为特征、是否确认为结果做逻辑回归，正系数归一化后按样本量与默认权重加权平均；
+// 配对阈值：按新权重重新打分，取把确认和拒绝分得最准的分界；
+// 自动确认阈值：不低于配对阈值、其上有足够反馈且几乎没有被拒绝过的最低置信度。
+// minConfidence 是配置的配对阈值，没有学到配对阈值时用它做自动确认的下限。
+func LearnMatchModel(feedback []models.TransferMatchFeedback, minConfidence float64) MatchModel {
+	model := MatchModel{Weights: DefaultMatchWeights()}
+	for _, f := range feedback {
+		if f.Accepted {
+			model.Accepted++
+		} else {
+			model.Rejected++
+		}
+	}
+
+	if model.Accepted >= matchModelMinEach && model.Rejected >= matchModelMinEach {
+		if learned, ok := fitMatchWeights(feedback); ok {
+			n := float64(len(feedback))
+			blend := func(def, fit float64) float64 {
+				return (matchModelPriorSamples*def + n*fit) / (matchModelPriorSamples + n)
+			}
+			def := model.Weights
+			model.Weights = models.MatchWeights{
+				TailNumber: blend(def.TailNumber, learned.TailNumber),
+				Amount:     blend(def.Amount, learned.Amount),
+				Time:       blend(def.Time, learned.Time),
+				Name:       blend(def.Name, learned.Name),
+			}
+		}
+		model.MinConfidence = acceptanceThreshold(feedback, model.Weights)
+	}
+
+	floor := minConfidence
+	if model.MinConfidence > 0 {
+		floor = model.MinConfidence
+	}
+	model.AutoConfirm = autoConfirmThreshold(feedback, model.Weights, floor)
+	return model
+}
+
+// fitMatchWeights 逻辑回归，返回归一化后的非负系数
+func fitMatchWeights(feedback []models.TransferMatchFeedback) (models.MatchWeights, bool) {
+	features := func(s models.MatchScores) [4]float64 {
+		return [4]float64{s.TailNumber, s.Amount, s.Time, s.Name}
+	}
+
+	var coef [4]float64
+	var bias float64
+	n := float64(len(feedback))
+	for iter := 0; iter < matchModelIterations; iter++ {
+		var grad [4]float64
+		var gradBias float64
+		for _, f := range feedback {
+			x := features(f.MatchScores)
+			z := bias
+			for k := range coef {
+				z += coef[k] * x[k]
+			}
+			diff := 1 / (1 + math.Exp(-z))
+			if f.Accepted {
+				diff--
+			}
+			for k := range grad {
+				grad[k] += diff * x[k]
+			}
+			gradBias += diff
+		}
+		for k := range coef {
+			coef[k] -= matchModelLearningRate * (grad[k]/n + matchModelRegularizer*coef[k])
+		}
+		bias -= matchModelLearningRate * gradBias / n
+	}
+
+	var sum float64
+	for k := range coef {
+		coef[k] = math.Max(coef[k], 0)
+		sum += coef[k]
+	}
+	if sum == 0 {
+		return models.MatchWeights{}, false
+	}
+	return models.MatchWeights{
+		TailNumber: coef[0] / sum,
+		Amount:     coef[1] / sum,
+		Time:       coef[2] / sum,
+		Name:       coef[3] / sum,
+	}, true
+}
+
+// scoredFeedback 按给定权重重新计算的置信度，从低到高
+type scoredFeedback struct {
+	confidence float64
+	accepted   bool
+}
+
+func rescore(feedback []models.TransferMatchFeedback, weights models.MatchWeights) []scoredFeedback {
+	scored := make([]scoredFeedback, len(feedback))
+	for i, f := range feedback {
+		scored[i] = scoredFeedback{confidence: weights.Confidence(f.MatchScores), accepted: f.Accepted}
+	}
+	sort.SliceStable(scored, func(a, b int) bool {
+		return scored[a].confidence < scored[b].confidence
+	})
+	return scored
+}
+
+// acceptanceThreshold 找出“置信度不低于阈值即配对”判对最多的阈值，取其与下方最近一笔的中点
+func acceptanceThreshold(feedback []models.TransferMatchFeedback, weights models.MatchWeights) float64 {
+	scored := rescore(feedback, weights)
+
+	// 阈值取第 i 笔的置信度时，前 i 笔判为拒绝、其余判为确认
+	acceptedAbove := 0
+	for _, f := range scored {
+		if f.accepted {
+			acceptedAbove++
+		}
+	}
+	best, bestCorrect := -1, -1
+	rejectedBelow := 0
+	for i := 0; i <= len(scored); i++ {
+		if i > 0 {
+			if scored[i-1].accepted {
+				acceptedAbove--
+			} else {
+				rejectedBelow++
+			}
+		}
+		// 同分的反馈只能一起划到阈值的同一侧
+		if i > 0 && i < len(scored) && scored[i].confidence == scored[i-1].confidence {
+			continue
+		}
+		if correct := acceptedAbove + rejectedBelow; correct > bestCorrect {
+			best, bestCorrect = i, correct
+		}
+	}
+
+	var threshold float64
+	switch {
+	case best == 0:
+		threshold = scored[0].confidence
+	case best == len(scored):
+		threshold = scored[len(scored)-1].confidence + 0.01
+	default:
+		threshold = (scored[best-1].confidence + scored[best].confidence) / 2
+	}
+	return math.Min(math.Max(threshold, matchThresholdFloor), matchThresholdCeiling)
+}
+
+// autoConfirmThreshold 从 floor 往上找第一个其上反馈够多、拒绝够少的置信度
+func autoConfirmThreshold(feedback []models.TransferMatchFeedback, weights models.MatchWeights, floor float64) float64 {
+	scored := rescore(feedback, weights)
+	rejected := 0
+	for _, f := range scored {
+		if !f.accepted {
+			rejected++
+		}
+	}
+	for i, f := range scored {
+		above := len(scored) - i
+		if above < autoConfirmMinSamples {
+			break
+		}
+		if f.confidence >= floor && (i == 0 || f.confidence != scored[i-1].confidence) &&
+			float64(rejected) <= autoConfirmMaxRejection*float64(above) {
+			return f.confidence
+		}
+		if !f.accepted {
+			rejected--
+		}
+	}
+	return 0
+}
@@ -1,7 +1,9 @@
 package services
 
 import (
+	"fmt"
 	"math"
+	"sort"
 	"strings"
 	"time"
 
@@ -10,25 +12,94 @@ import (
 )
 
 // TransferMatcher 跨文件转账匹配器
-// 使用账户尾号匹配作为最高优先级的匹配策略
+// 使用账户尾号匹配作为最高优先级的匹配策略；转出和转入的配对作为全局指派问题求解，
+// 而不是逐笔贪心，避免同一天多笔相近金额的转账互相错配
 type TransferMatcher struct {
-	timeWindow      time.Duration // 时间窗口（默认24小时）
-	amountTolerance float64       // 金额容差（默认0.01）
+	config      TransferMatchConfig
+	weights     models.MatchWeights
+	feePatterns []FeePattern
+}
+
+// TransferMatchConfig 转账匹配的阈值
+type TransferMatchConfig struct {
+	TimeWindow          time.Duration // 转出与转入的最大时间差（默认24小时）
+	AmountTolerance     float64       // 视为金额相同的误差（默认0.01）
+	MaxAmountDifference float64       // 允许的最大金额差，即手续费（默认5）
+	MinConfidence       float64       // 自动配对的最低置信度（默认0.8）
+	CandidateConfidence float64       // 列为候选的最低置信度（默认0.5）
+	MaxCandidates       int           // 每笔交易最多列出的候选数（默认3）
+	MaxGroupSize        int           // 一对多/多对一时多的一侧最多几笔（默认4，小于2为不分组）
+}
+
+// DefaultTransferMatchConfig 默认阈值
+func DefaultTransferMatchConfig() TransferMatchConfig {
+	return TransferMatchConfig{
+		TimeWindow:          24 * time.Hour,
+		AmountTolerance:     0.01,
+		MaxAmountDifference: 5.0,
+		MinConfidence:       0.8,
+		CandidateConfidence: 0.5,
+		MaxCandidates:       3,
+		MaxGroupSize:        4,
+	}
 }
 
 // NewTransferMatcher 创建匹配器
 func NewTransferMatcher() *TransferMatcher {
-	return &TransferMatcher{
-		timeWindow:      24 * time.Hour,
-		amountTolerance: 0.01,
+	return NewTransferMatcherWithConfig(DefaultTransferMatchConfig())
+}
+
+// NewTransferMatcherWithConfig 用指定阈值创建匹配器
+func NewTransferMatcherWithConfig(config TransferMatchConfig) *TransferMatcher {
+	return &TransferMatcher{config: config, weights: DefaultMatchWeights()}
+}
+
+// DefaultMatchWeights 默认权重：尾号40%、金额30%、时间20%、名称10%
+func DefaultMatchWeights() models.MatchWeights {
+	return models.MatchWeights{TailNumber: 0.40, Amount: 0.30, Time: 0.20, Name: 0.10}
+}
+
+// WithModel 使用从用户确认/拒绝中学到的权重和配对阈值
+func (m *TransferMatcher) WithModel(model MatchModel) *TransferMatcher {
+	m.weights = model.Weights
+	if model.MinConfidence > 0 {
+		m.config.MinConfidence = model.MinConfidence
 	}
+	return m
 }
 
+// Weights 当前使用的各类证据权重
+func (m *TransferMatcher) Weights() models.MatchWeights {
+	return m.weights
+}
+
+// WithFeePatterns 使用学到的账户对手续费规律：金额差符合规律的配对视同金额匹配
+func (m *TransferMatcher) WithFeePatterns(patterns []FeePattern) *TransferMatcher {
+	m.feePatterns = patterns
+	return m
+}
+
+// expectedFee 返回转出方到转入方之间转账的预期手续费
+func (m *TransferMatcher) expectedFee(outTx, inTx TransactionInfo) (FeePattern, float64, bool) {
+	for _, p := range m.feePatterns {
+		if outTx.AccountID != nil && inTx.AccountID != nil &&
+			p.FromAccountID == *outTx.AccountID && p.ToAccountID == *inTx.AccountID {
+			return p, p.Expected(inTx.Amount), true
+		}
+	}
+	return FeePattern{}, 0, false
+}
+
+// exactAssignmentLimit 连通分量不超过该规模时用匈牙利算法求最优解，更大的分量按置信度全局贪心
+const exactAssignmentLimit = 300
+
 // MatchResult 匹配结果
 type MatchResult struct {
 	Matches      []TransferMatch
-	UnmatchedOut []TransactionInfo // 未匹配的转出
-	UnmatchedIn  []TransactionInfo // 未匹配的转入
+	UnmatchedOut []TransactionInfo    // 未匹配的转出
+	UnmatchedIn  []TransactionInfo    // 未匹配的转入
+	Candidates   []TransferCandidates // 每笔有候选的转账及其候选对方
+	Groups       []TransferGroup      // 一对多/多对一的分组建议，由未匹配的记录组成
 }
 
 // TransferMatch 转账匹配对
@@ -36,17 +107,46 @@ type TransferMatch struct {
 	ID           uuid.UUID
 	OutTx        TransactionInfo
 	InTx         TransactionInfo
-	Confidence   float64  // 匹配置信度 0-1
-	MatchFactors []string // 匹配依据
+	Confidence   float64            // 匹配置信度 0-1
+	Scores       models.MatchScores // 各类证据的得分
+	MatchFactors []string           // 匹配依据
+}
+
+// TransferGroup 一笔转出对应多笔转入（如工资分到几个账户），或多笔转出对应一笔转入（如几笔微信转账一起存入银行）
+type TransferGroup struct {
+	ID           uuid.UUID
+	Outs         []TransactionInfo
+	Ins          []TransactionInfo
+	Confidence   float64
+	MatchFactors []string
+}
+
+// TransferCandidates 一笔转账的候选对方，按置信度从高到低
+type TransferCandidates struct {
+	Tx         TransactionInfo
+	Candidates []TransferCandidate
+}
+
+// TransferCandidate 候选对方
+type TransferCandidate struct {
+	Tx           TransactionInfo
+	Confidence   float64
+	Scores       models.MatchScores
+	MatchFactors []string
+	Selected     bool // 全局匹配选中的对方
 }
 
 // TransactionInfo 交易信息（用于匹配）
 type TransactionInfo struct {
 	FileID             uuid.UUID
 	TransactionIndex   int
+	Existing           bool // 账本中已有的交易
+	Repayment          bool // 信用卡还款
+	LineNumber         int
 	Date               time.Time
 	Amount             float64
-	Type               string // expense, income
+	Type               string     // expense, income
+	AccountID          *uuid.UUID // 已确定的账户，用于查找该账户间的手续费规律
 	AccountName        string
 	AccountNumber      string // 账户尾号
 	Counterparty       string
@@ -55,22 +155,38 @@ type TransactionInfo struct {
 	RawData            map[string]string
 }
 
+// transferEdge 转出与转入之间的一条候选边
+type transferEdge struct {
+	out, in    int
+	confidence float64
+	weight     float64 // 置信度加上很小的时间接近度，用于打破平局
+	scores     models.MatchScores
+	factors    []string
+}
+
 // FindMatches 在所有文件中查找匹配的转账记录
-// 核心算法：账户尾号匹配作为最高优先级（40%权重）
+// 核心算法：账户尾号匹配作为最高优先级（40%权重）；
+// 只为时间窗口和金额差以内的交易对打分，再在置信度达标的边上求总置信度最大的配对
 func (m *TransferMatcher) FindMatches(files []FileTransactions) *MatchResult {
-	var matches []TransferMatch
-
 	// 收集所有转出和转入记录
 	var outTxs, inTxs []TransactionInfo
 
 	for _, file := range files {
 		for i, tx := range file.Transactions {
+			// 已在账本中的行由账本中的那一条参与匹配
+			if !file.Existing && tx.IsDuplicate {
+				continue
+			}
 			info := TransactionInfo{
 				FileID:             file.FileID,
 				TransactionIndex:   i,
+				Existing:           file.Existing,
+				Repayment:          IsRepayment(tx),
+				LineNumber:         tx.LineNumber,
 				Date:               tx.TransactionDate,
 				Amount:             tx.Amount,
 				Type:               string(tx.Type),
+				AccountID:          tx.SelectedAccountID,
 				AccountName:        tx.AccountName,
 				AccountNumber:      tx.ParsedAccountNumber,
 				Counterparty:       tx.Counterparty,
@@ -90,47 +206,28 @@ func (m *TransferMatcher) FindMatches(files []FileTransactions) *MatchResult {
 		}
 	}
 
-	// 匹配算法
+	edges := m.scoreCandidates(outTxs, inTxs)
+	selected := m.assign(len(outTxs), edges)
+
 	matchedOut := make(map[int]bool)
 	matchedIn := make(map[int]bool)
-
-	for i, outTx := range outTxs {
-		if matchedOut[i] {
-			continue
-		}
-
-		bestMatch := -1
-		bestConfidence := 0.0
-		var bestFactors []string
-
-		for j, inTx := range inTxs {
-			if matchedIn[j] {
-				continue
-			}
-
-			confidence, factors := m.calculateMatchConfidence(outTx, inTx)
-
-			if confidence > bestConfidence {
-				bestConfidence = confidence
-				bestMatch = j
-				bestFactors = factors
-			}
-		}
-
-		// 如果最佳匹配的置信度足够高，则确认为匹配
-		if bestMatch >= 0 && bestConfidence >= 0.8 {
-			match := TransferMatch{
-				ID:           uuid.New(),
-				OutTx:        outTx,
-				InTx:         inTxs[bestMatch],
-				Confidence:   bestConfidence,
-				MatchFactors: bestFactors,
-			}
-			matches = append(matches, match)
-			matchedOut[i] = true
-			matchedIn[bestMatch] = true
-		}
+	var matches []TransferMatch
+	for _, e := range selected {
+		edge := edges[e]
+		matches = append(matches, TransferMatch{
+			ID:           uuid.New(),
+			OutTx:        outTxs[edge.out],
+			InTx:         inTxs[edge.in],
+			Confidence:   edge.confidence,
+			Scores:       edge.scores,
+			MatchFactors: edge.factors,
+		})
+		matchedOut[edge.out] = true
+		matchedIn[edge.in] = true
 	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].OutTx.Date.Before(matches[j].OutTx.Date)
+	})
 
 	// 收集未匹配的记录
 	var unmatchedOut, unmatchedIn []TransactionInfo
@@ -149,7 +246,301 @@ func (m *TransferMatcher) FindMatches(files []FileTransactions) *MatchResult {
 		Matches:      matches,
 		UnmatchedOut: unmatchedOut,
 		UnmatchedIn:  unmatchedIn,
+		Candidates:   m.collectCandidates(outTxs, inTxs, edges, selected),
+		Groups:       m.findGroups(unmatchedOut, unmatchedIn),
+	}
+}
+
+// scoreCandidates 为时间窗口内、金额差不超过手续费的转出/转入对打分。
+// 转入按时间排序后二分查找窗口，数万行的批次也只需比较相近的交易
+func (m *TransferMatcher) scoreCandidates(outTxs, inTxs []TransactionInfo) []transferEdge {
+	byDate := make([]int, len(inTxs))
+	for i := range byDate {
+		byDate[i] = i
 	}
+	sort.Slice(byDate, func(a, b int) bool {
+		return inTxs[byDate[a]].Date.Before(inTxs[byDate[b]].Date)
+	})
+
+	window := m.config.TimeWindow
+	threshold := math.Min(m.config.CandidateConfidence, m.config.MinConfidence)
+	var edges []transferEdge
+	for i, outTx := range outTxs {
+		from := outTx.Date.Add(-window)
+		k := sort.Search(len(byDate), func(k int) bool {
+			return !inTxs[byDate[k]].Date.Before(from)
+		})
+		for ; k < len(byDate); k++ {
+			j := byDate[k]
+			inTx := inTxs[j]
+			if inTx.Date.After(outTx.Date.Add(window)) {
+				break
+			}
+			// 账本中的交易只与新导入的行配对
+			if outTx.Existing && inTx.Existing {
+				continue
+			}
+			if math.Abs(outTx.Amount-inTx.Amount) > m.config.MaxAmountDifference+m.config.AmountTolerance {
+				continue
+			}
+
+			confidence, scores, factors := m.calculateMatchConfidence(outTx, inTx)
+			if confidence < threshold {
+				continue
+			}
+			gap := absDuration(inTx.Date.Sub(outTx.Date))
+			closeness := 1.0
+			if window > 0 {
+				closeness -= float64(gap) / float64(window)
+			}
+			edges = append(edges, transferEdge{
+				out:        i,
+				in:         j,
+				confidence: confidence,
+				weight:     confidence + 1e-6*closeness,
+				scores:     scores,
+				factors:    factors,
+			})
+		}
+	}
+	return edges
+}
+
+// assign 返回选中的边：在置信度达标的边上求总权重最大的一对一配对。
+// 按连通分量分别求解，分量通常只有几笔同日同额的转账
+func (m *TransferMatcher) assign(outCount int, edges []transferEdge) []int {
+	var eligible []int
+	parent := make(map[int]int)
+	var find func(int) int
+	find = func(x int) int {
+		if p, ok := parent[x]; ok && p != x {
+			parent[x] = find(p)
+			return parent[x]
+		}
+		parent[x] = x
+		return x
+	}
+	for e, edge := range edges {
+		if edge.confidence < m.config.MinConfidence {
+			continue
+		}
+		eligible = append(eligible, e)
+		// 转入节点编号在转出之后
+		a, b := find(edge.out), find(outCount+edge.in)
+		if a != b {
+			parent[a] = b
+		}
+	}
+
+	components := make(map[int][]int)
+	var roots []int
+	for _, e := range eligible {
+		root := find(edges[e].out)
+		if _, ok := components[root]; !ok {
+			roots = append(roots, root)
+		}
+		components[root] = append(components[root], e)
+	}
+
+	var selected []int
+	for _, root := range roots {
+		selected = append(selected, assignComponent(edges, components[root])...)
+	}
+	return selected
+}
+
+// assignComponent 求一个连通分量内的最大权配对
+func assignComponent(edges []transferEdge, component []int) []int {
+	if len(component) == 1 {
+		return component
+	}
+
+	outIndex := make(map[int]int)
+	inIndex := make(map[int]int)
+	for _, e := range component {
+		if _, ok := outIndex[edges[e].out]; !ok {
+			outIndex[edges[e].out] = len(outIndex)
+		}
+		if _, ok := inIndex[edges[e].in]; !ok {
+			inIndex[edges[e].in] = len(inIndex)
+		}
+	}
+
+	size := len(outIndex)
+	if len(inIndex) > size {
+		size = len(inIndex)
+	}
+	if size > exactAssignmentLimit {
+		return assignGreedy(edges, component)
+	}
+
+	// 最小化负权重；没有边的格子代价为0，表示不配对
+	cost := make([][]float64, size)
+	edgeAt := make([][]int, size)
+	for r := range cost {
+		cost[r] = make([]float64, size)
+		edgeAt[r] = make([]int, size)
+		for c := range edgeAt[r] {
+			edgeAt[r][c] = -1
+		}
+	}
+	for _, e := range component {
+		r, c := outIndex[edges[e].out], inIndex[edges[e].in]
+		if edgeAt[r][c] < 0 || edges[e].weight > -cost[r][c] {
+			cost[r][c] = -edges[e].weight
+			edgeAt[r][c] = e
+		}
+	}
+
+	var selected []int
+	for r, c := range hungarian(cost) {
+		if e := edgeAt[r][c]; e >= 0 {
+			selected = append(selected, e)
+		}
+	}
+	return selected
+}
+
+// assignGreedy 超大分量的退路：按权重从高到低依次配对
+func assignGreedy(edges []transferEdge, component []int) []int {
+	sorted := append([]int(nil), component...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return edges[sorted[i]].weight > edges[sorted[j]].weight
+	})
+
+	usedOut := make(map[int]bool)
+	usedIn := make(map[int]bool)
+	var selected []int
+	for _, e := range sorted {
+		if usedOut[edges[e].out] || usedIn[edges[e].in] {
+			continue
+		}
+		usedOut[edges[e].out] = true
+		usedIn[edges[e].in] = true
+		selected = append(selected, e)
+	}
+	return selected
+}
+
+// hungarian 求方阵的最小代价完美指派，返回每行分配到的列（O(n³)）
+func hungarian(cost [][]float64) []int {
+	n := len(cost)
+	u := make([]float64, n+1)
+	v := make([]float64, n+1)
+	p := make([]int, n+1) // p[列] = 分配到该列的行，均从1开始编号
+	way := make([]int, n+1)
+
+	for i := 1; i <= n; i++ {
+		p[0] = i
+		j0 := 0
+		minv := make([]float64, n+1)
+		used := make([]bool, n+1)
+		for j := range minv {
+			minv[j] = math.Inf(1)
+		}
+		for {
+			used[j0] = true
+			i0, delta, j1 := p[j0], math.Inf(1), 0
+			for j := 1; j <= n; j++ {
+				if used[j] {
+					continue
+				}
+				cur := cost[i0-1][j-1] - u[i0] - v[j]
+				if cur < minv[j] {
+					minv[j] = cur
+					way[j] = j0
+				}
+				if minv[j] < delta {
+					delta = minv[j]
+					j1 = j
+				}
+			}
+			for j := 0; j <= n; j++ {
+				if used[j] {
+					u[p[j]] += delta
+					v[j] -= delta
+				} else {
+					minv[j] -= delta
+				}
+			}
+			j0 = j1
+			if p[j0] == 0 {
+				break
+			}
+		}
+		for j0 != 0 {
+			j1 := way[j0]
+			p[j0] = p[j1]
+			j0 = j1
+		}
+	}
+
+	assignment := make([]int, n)
+	for j := 1; j <= n; j++ {
+		if p[j] > 0 {
+			assignment[p[j]-1] = j - 1
+		}
+	}
+	return assignment
+}
+
+// collectCandidates 列出每笔转账的候选对方，供预览中手动改配
+func (m *TransferMatcher) collectCandidates(outTxs, inTxs []TransactionInfo, edges []transferEdge, selected []int) []TransferCandidates {
+	isSelected := make(map[int]bool, len(selected))
+	for _, e := range selected {
+		isSelected[e] = true
+	}
+
+	byOut := make(map[int][]int)
+	byIn := make(map[int][]int)
+	for e, edge := range edges {
+		byOut[edge.out] = append(byOut[edge.out], e)
+		byIn[edge.in] = append(byIn[edge.in], e)
+	}
+
+	build := func(tx TransactionInfo, list []int, other func(transferEdge) TransactionInfo) TransferCandidates {
+		sort.SliceStable(list, func(i, j int) bool {
+			return edges[list[i]].weight > edges[list[j]].weight
+		})
+		if m.config.MaxCandidates > 0 && len(list) > m.config.MaxCandidates {
+			list = list[:m.config.MaxCandidates]
+		}
+		result := TransferCandidates{Tx: tx}
+		for _, e := range list {
+			result.Candidates = append(result.Candidates, TransferCandidate{
+				Tx:           other(edges[e]),
+				Confidence:   edges[e].confidence,
+				Scores:       edges[e].scores,
+				MatchFactors: edges[e].factors,
+				Selected:     isSelected[e],
+			})
+		}
+		return result
+	}
+
+	var candidates []TransferCandidates
+	for i, tx := range outTxs {
+		if list := byOut[i]; len(list) > 0 {
+			candidates = append(candidates, build(tx, list, func(e transferEdge) TransactionInfo { return inTxs[e.in] }))
+		}
+	}
+	for j, tx := range inTxs {
+		if list := byIn[j]; len(list) > 0 {
+			candidates = append(candidates, build(tx, list, func(e transferEdge) TransactionInfo { return outTxs[e.out] }))
+		}
+	}
+	return candidates
+}
+
+// IsTransfer 判断交易是否可能是转账的一端
+func (m *TransferMatcher) IsTransfer(tx models.ParsedTransaction) bool {
+	return m.isTransferOut(tx) || m.isTransferIn(tx)
+}
+
+// IsRepayment 判断是否为信用卡还款：借记卡账单中是支出，信用卡账单中是入账
+func IsRepayment(tx models.ParsedTransaction) bool {
+	note := tx.Note + " " + tx.Counterparty
+	return strings.Contains(note, "还款") || strings.Contains(note, "还信用卡")
 }
 
 // isTransferOut 判断是否为转出记录
@@ -157,7 +548,7 @@ func (m *TransferMatcher) isTransferOut(tx models.ParsedTransaction) bool {
 	// 类型为支出且包含转账关键词
 	if tx.Type == models.TransactionTypeExpense {
 		note := strings.ToLower(tx.Note + " " + tx.Counterparty)
-		transferKeywords := []string{"转账", "转出", "汇款", "跨行", "行内"}
+		transferKeywords := []string{"转账", "转出", "汇款", "跨行", "行内", "充值", "提现", "还款", "还信用卡"}
 		for _, kw := range transferKeywords {
 			if strings.Contains(note, kw) {
 				return true
@@ -172,7 +563,7 @@ func (m *TransferMatcher) isTransferIn(tx models.ParsedTransaction) bool {
 	// 类型为收入且包含转入关键词
 	if tx.Type == models.TransactionTypeIncome {
 		note := strings.ToLower(tx.Note + " " + tx.Counterparty)
-		inKeywords := []string{"转账", "转入", "汇款", "跨行", "来账"}
+		inKeywords := []string{"转账", "转入", "汇款", "跨行", "来账", "充值", "提现", "还款"}
 		for _, kw := range inKeywords {
 			if strings.Contains(note, kw) {
 				return true
@@ -183,19 +574,21 @@ func (m *TransferMatcher) isTransferIn(tx models.ParsedTransaction) bool {
 }
 
 // calculateMatchConfidence 计算匹配置信度
-// 优先级排序：账户尾号匹配(40%) > 金额匹配(30%) > 时间匹配(20%) > 账户名称关联(10%)
-func (m *TransferMatcher) calculateMatchConfidence(outTx, inTx TransactionInfo) (float64, []string) {
-	var confidence float64
+// 每类证据先各自打分(0-1)，再按权重加总。默认权重的优先级：
+// 账户尾号匹配(40%) > 金额匹配(30%) > 时间匹配(20%) > 账户名称关联(10%)，
+// 用户确认/拒绝得多了之后改用学到的权重
+func (m *TransferMatcher) calculateMatchConfidence(outTx, inTx TransactionInfo) (float64, models.MatchScores, []string) {
+	var scores models.MatchScores
 	var factors []string
 
-	// ===== 最高优先级：账户尾号匹配（权重：40%）=====
+	// ===== 最高优先级：账户尾号匹配（默认权重：40%）=====
 	// 这是最强的关联证据，因为支付宝/微信/京东账单都会记录消费的账户尾号
 	tailNumberMatched := false
 
 	// 场景1：转出账户尾号 == 转入记录的对方账户尾号
 	if outTx.AccountNumber != "" && inTx.CounterpartyNumber != "" {
 		if normalizeTailNumber(outTx.AccountNumber) == normalizeTailNumber(inTx.CounterpartyNumber) {
-			confidence += 0.40
+			scores.TailNumber = 1
 			factors = append(factors, "账户尾号精确匹配(转出账户=转入对方账户)")
 			tailNumberMatched = true
 		}
@@ -204,7 +597,7 @@ func (m *TransferMatcher) calculateMatchConfidence(outTx, inTx TransactionInfo)
 	// 场景2：转入账户尾号 == 转出记录的对方账户尾号
 	if !tailNumberMatched && inTx.AccountNumber != "" && outTx.CounterpartyNumber != "" {
 		if normalizeTailNumber(inTx.AccountNumber) == normalizeTailNumber(outTx.CounterpartyNumber) {
-			confidence += 0.40
+			scores.TailNumber = 1
 			factors = append(factors, "账户尾号精确匹配(转入账户=转出对方账户)")
 			tailNumberMatched = true
 		}
@@ -215,54 +608,73 @@ func (m *TransferMatcher) calculateMatchConfidence(outTx, inTx TransactionInfo)
 		if normalizeTailNumber(outTx.AccountNumber) == normalizeTailNumber(inTx.AccountNumber) {
 			// 这种情况可能是同一账户的进出，不一定是转账匹配
 			// 给较低的权重，结合其他因素判断
-			confidence += 0.15
+			scores.TailNumber = 0.375
 			factors = append(factors, "同一账户尾号(可能为内部转账)")
 		}
 	}
 
-	// ===== 第二优先级：金额匹配（权重：30%）=====
+	// ===== 第二优先级：金额匹配（默认权重：30%）=====
 	amountDiff := math.Abs(outTx.Amount - inTx.Amount)
-	if amountDiff <= m.amountTolerance {
-		confidence += 0.30
+	pattern, fee, hasPattern := m.expectedFee(outTx, inTx)
+	if amountDiff <= m.config.AmountTolerance {
+		scores.Amount = 1
 		factors = append(factors, "金额完全匹配")
+	} else if hasPattern && outTx.Amount > inTx.Amount && math.Abs(amountDiff-fee) <= m.config.AmountTolerance {
+		// 差额正好是这两个账户之间惯常的手续费
+		scores.Amount = 1
+		if pattern.Kind == FeeFixed {
+			factors = append(factors, fmt.Sprintf("金额差符合这两个账户间的固定手续费(%.2f)", pattern.Fee))
+		} else {
+			factors = append(factors, fmt.Sprintf("金额差符合这两个账户间的手续费率(%.2f%%)", pattern.Rate*100))
+		}
 	} else if amountDiff <= 1.0 {
 		// 小额差异可能是手续费
-		confidence += 0.25
+		scores.Amount = 5.0 / 6
 		factors = append(factors, "金额基本匹配(可能有手续费)")
-	} else if amountDiff <= 5.0 {
+	} else if amountDiff <= m.config.MaxAmountDifference {
 		// 较大差异可能是跨行手续费
-		confidence += 0.15
+		scores.Amount = 0.5
 		factors = append(factors, "金额近似(可能有较高手续费)")
 	}
 
-	// ===== 第三优先级：时间匹配（权重：20%）=====
+	// ===== 第三优先级：时间匹配（默认权重：20%）=====
 	timeDiff := inTx.Date.Sub(outTx.Date)
 	if timeDiff >= 0 && timeDiff <= 30*time.Minute {
-		confidence += 0.20
+		scores.Time = 1
 		factors = append(factors, "时间高度匹配(30分钟内)")
 	} else if timeDiff > 0 && timeDiff <= 2*time.Hour {
-		confidence += 0.18
+		scores.Time = 0.9
 		factors = append(factors, "时间较匹配(2小时内)")
 	} else if timeDiff > 0 && timeDiff <= 24*time.Hour {
-		confidence += 0.12
+		scores.Time = 0.6
 		factors = append(factors, "时间基本匹配(24小时内)")
 	}
 
-	// ===== 第四优先级：账户名称关联（权重：10%）=====
-	if strings.Contains(inTx.Counterparty, outTx.AccountName) ||
-		strings.Contains(outTx.AccountName, inTx.Counterparty) ||
-		strings.Contains(inTx.Counterparty, outTx.AccountName) ||
-		strings.Contains(outTx.Counterparty, inTx.AccountName) {
-		confidence += 0.10
+	// ===== 第四优先级：账户名称关联（默认权重：10%）=====
+	// 空名称会被 strings.Contains 当作命中，必须两边都有值
+	if containsEither(inTx.Counterparty, outTx.AccountName) || containsEither(outTx.Counterparty, inTx.AccountName) {
+		scores.Name = 1
 		factors = append(factors, "账户名称或银行名称关联")
+	} else if outTx.Repayment && inTx.Repayment {
+		scores.Name = 1
+		factors = append(factors, "双方均为信用卡还款")
 	}
 
-	return confidence, factors
+	return m.weights.Confidence(scores), scores, factors
+}
+
+// containsEither reports whether one non-empty name contains the other
+func containsEither(a, b string) bool {
+	if a == "" || b == "" {
+		return false
+	}
+	return strings.Contains(a, b) || strings.Contains(b, a)
 }
 
 // FileTransactions represents transactions from a single file
 type FileTransactions struct {
 	FileID       uuid.UUID
+	Existing     bool // ledger transactions, only paired with imported rows
 	Source       models.ImportSource
 	FileName     string
 	Transactions []models.ParsedTransaction
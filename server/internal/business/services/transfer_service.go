@@ -0,0 +1,218 @@
+package services
+
+import (
+	"account/internal/business/models"
+	"account/internal/data/repository"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// ErrInvalidTransferLink is returned when two transactions cannot form a transfer
+var ErrInvalidTransferLink = errors.New("invalid transfer link")
+
+type TransferService struct {
+	transactionRepo *repository.TransactionRepository
+	transferRepo    *repository.TransferLinkRepository
+	categoryRepo    *repository.CategoryRepository
+	feeCategory     string
+	logger          *zap.Logger
+}
+
+func NewTransferService(
+	transactionRepo *repository.TransactionRepository,
+	transferRepo *repository.TransferLinkRepository,
+	categoryRepo *repository.CategoryRepository,
+	feeCategory string,
+	logger *zap.Logger,
+) *TransferService {
+	return &TransferService{
+		transactionRepo: transactionRepo,
+		transferRepo:    transferRepo,
+		categoryRepo:    categoryRepo,
+		feeCategory:     feeCategory,
+		logger:          logger,
+	}
+}
+
+// LinkTransfer converts an expense and an income on two different accounts into
+// a linked transfer. The account balances already reflect both rows and stay as they are.
+// When more left the source account than arrived, the difference is split off the
+// outgoing half as a fee expense, and remembered to learn the fees of the bank pair.
+func (s *TransferService) LinkTransfer(userID uuid.UUID, fromID uuid.UUID, toID uuid.UUID) (*models.TransferLink, error) {
+	if fromID == toID {
+		return nil, fmt.Errorf("%w: a transaction cannot be transferred to itself", ErrInvalidTransferLink)
+	}
+
+	from, err := s.transactionRepo.GetByID(fromID, userID)
+	if err != nil {
+		return nil, err
+	}
+	to, err := s.transactionRepo.GetByID(toID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if from.Type != models.TransactionTypeExpense {
+		return nil, fmt.Errorf("%w: outgoing half must be an expense transaction", ErrInvalidTransferLink)
+	}
+	if to.Type != models.TransactionTypeIncome {
+		return nil, fmt.Errorf("%w: incoming half must be an income transaction", ErrInvalidTransferLink)
+	}
+	if from.AccountID == to.AccountID {
+		return nil, fmt.Errorf("%w: both halves are on the same account", ErrInvalidTransferLink)
+	}
+
+	var fee *models.Transaction
+	if amount := roundAmount(from.Amount - to.Amount); amount > feeAmountTolerance && from.Currency == to.Currency {
+		categoryID, err := s.feeCategoryID(userID)
+		if err != nil {
+			return nil, err
+		}
+		fee = &models.Transaction{
+			UserID:          userID,
+			AccountID:       from.AccountID,
+			CategoryID:      categoryID,
+			Amount:          amount,
+			Currency:        from.Currency,
+			Note:            "转账手续费",
+			TransactionDate: from.TransactionDate,
+		}
+	}
+
+	link, err := s.transferRepo.LinkTransactions(&models.TransferLink{
+		UserID:            userID,
+		FromTransactionID: fromID,
+		ToTransactionID:   toID,
+	}, fee)
+	if err != nil {
+		return nil, err
+	}
+
+	if fee != nil {
+		s.recordFee(userID, from.AccountID, to.AccountID, to.Amount, fee.Amount)
+	}
+	return link, nil
+}
+
+// LinkTransferGroup links one expense to several incomes, or several expenses to
+// one income, as the legs of one transfer. The amounts of both sides must add up.
+func (s *TransferService) LinkTransferGroup(userID uuid.UUID, fromIDs []uuid.UUID, toIDs []uuid.UUID) ([]models.TransferLink, error) {
+	if len(fromIDs) == 0 || len(toIDs) == 0 || (len(fromIDs) > 1 && len(toIDs) > 1) || len(fromIDs)+len(toIDs) < 3 {
+		return nil, fmt.Errorf("%w: a transfer group has one transaction on one side and several on the other", ErrInvalidTransferLink)
+	}
+
+	seen := make(map[uuid.UUID]bool)
+	load := func(ids []uuid.UUID, want models.TransactionType) ([]*models.Transaction, float64, error) {
+		var txs []*models.Transaction
+		var total float64
+		for _, id := range ids {
+			if seen[id] {
+				return nil, 0, fmt.Errorf("%w: a transaction appears twice in the group", ErrInvalidTransferLink)
+			}
+			seen[id] = true
+			tx, err := s.transactionRepo.GetByID(id, userID)
+			if err != nil {
+				return nil, 0, err
+			}
+			if tx.Type != want {
+				return nil, 0, fmt.Errorf("%w: outgoing legs must be expenses and incoming legs incomes", ErrInvalidTransferLink)
+			}
+			txs = append(txs, tx)
+			total += tx.Amount
+		}
+		return txs, total, nil
+	}
+
+	from, fromTotal, err := load(fromIDs, models.TransactionTypeExpense)
+	if err != nil {
+		return nil, err
+	}
+	to, toTotal, err := load(toIDs, models.TransactionTypeIncome)
+	if err != nil {
+		return nil, err
+	}
+	if math.Abs(fromTotal-toTotal) > feeAmountTolerance {
+		return nil, fmt.Errorf("%w: the legs add up to %.2f out and %.2f in", ErrInvalidTransferLink, fromTotal, toTotal)
+	}
+	for _, f := range from {
+		for _, t := range to {
+			if f.AccountID == t.AccountID {
+				return nil, fmt.Errorf("%w: both halves are on the same account", ErrInvalidTransferLink)
+			}
+		}
+	}
+
+	return s.transferRepo.LinkGroup(userID, fromIDs, toIDs)
+}
+
+// FeePatterns returns the fee patterns learned from the user's confirmed transfers
+func (s *TransferService) FeePatterns(userID uuid.UUID) ([]FeePattern, error) {
+	fees, err := s.transferRepo.GetRecentFees(userID, feePatternHistory)
+	if err != nil {
+		return nil, err
+	}
+	return LearnFeePatterns(fees), nil
+}
+
+// RecordMatchFeedback stores the user's confirmation or rejection of a proposed
+// transfer match, with the evidence it was scored on
+func (s *TransferService) RecordMatchFeedback(userID uuid.UUID, scores models.MatchScores, confidence float64, accepted bool) error {
+	return s.transferRepo.RecordMatchFeedback(&models.TransferMatchFeedback{
+		UserID:      userID,
+		MatchScores: scores,
+		Confidence:  confidence,
+		Accepted:    accepted,
+	})
+}
+
+// MatchModel returns the match weights and thresholds learned from the user's
+// latest confirmations and rejections; minConfidence is the configured threshold
+func (s *TransferService) MatchModel(userID uuid.UUID, minConfidence float64) (MatchModel, error) {
+	feedback, err := s.transferRepo.GetRecentMatchFeedback(userID, matchFeedbackHistory)
+	if err != nil {
+		return MatchModel{}, err
+	}
+	return LearnMatchModel(feedback, minConfidence), nil
+}
+
+// feeCategoryID returns the configured fee category, creating it on first use
+func (s *TransferService) feeCategoryID(userID uuid.UUID) (*uuid.UUID, error) {
+	if s.feeCategory == "" {
+		return nil, nil
+	}
+
+	categories, err := s.categoryRepo.GetByType(userID, models.CategoryTypeExpense)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range categories {
+		if strings.EqualFold(c.Name, s.feeCategory) {
+			id := c.ID
+			return &id, nil
+		}
+	}
+
+	category, err := s.categoryRepo.Create(userID, s.feeCategory, models.CategoryTypeExpense, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	return &category.ID, nil
+}
+
+// recordFee remembers the fee between two accounts. Failures are only logged,
+// the transfer itself is already linked.
+func (s *TransferService) recordFee(userID uuid.UUID, fromAccountID uuid.UUID, toAccountID uuid.UUID, amount float64, fee float64) {
+	if err := s.transferRepo.RecordFee(&models.TransferFee{
+		UserID:        userID,
+		FromAccountID: fromAccountID,
+		ToAccountID:   toAccountID,
+		Amount:        amount,
+		Fee:           fee,
+	}); err != nil {
+		s.logger.Warn("Failed to record transfer fee", zap.Error(err))
+	}
+}
@@ -0,0 +1,311 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"crypto/aes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"path"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+var (
+	ErrZipPasswordRequired = errors.New("zip archive is encrypted, password required")
+	ErrZipWrongPassword    = errors.New("wrong zip archive password")
+
+	// errZipMemberTooLarge is returned when a member inflates past maxZipMemberSize
+	// even though its header claims a smaller size
+	errZipMemberTooLarge = errors.New("zip member is too large")
+)
+
+const (
+	zipMethodAES       = 99     // WinZip AES marker compression method
+	zipExtraAES        = 0x9901 // WinZip AES extra field
+	zipFlagEncrypted   = 0x1
+	zipFlagDescriptor  = 0x8
+	zipFlagUTF8        = 0x800
+	maxZipMemberSize   = 64 << 20 // bill exports are far smaller; guards against zip bombs
+	maxZipArchiveFiles = 50
+)
+
+// ArchiveMember is one extracted file of an archive
+type ArchiveMember struct {
+	Name    string
+	Content []byte
+}
+
+// IsZipArchive reports whether data starts with a ZIP local file header
+func IsZipArchive(data []byte) bool {
+	return len(data) >= 4 && bytes.Equal(data[:4], []byte("PK\x03\x04"))
+}
+
+// ExtractZip extracts all regular files of a ZIP archive. Encrypted members
+// (traditional ZipCrypto as used by Alipay, or WinZip AES as used by WeChat)
+// are decrypted with password. Directories and macOS metadata are skipped.
+func ExtractZip(data []byte, password string) ([]ArchiveMember, error) {
+	var members []ArchiveMember
+	err := WalkZip(data, password, func(m ArchiveMember) error {
+		members = append(members, m)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+// WalkZip is ExtractZip for large archives: each member is passed to visit as
+// soon as it is extracted, so that only one member is held in memory at a time.
+// An error from visit stops the walk and is returned as is.
+func WalkZip(data []byte, password string, visit func(ArchiveMember) error) error {
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return fmt.Errorf("invalid zip archive: %w", err)
+	}
+
+	count := 0
+	for _, f := range reader.File {
+		name := zipMemberName(f)
+		if f.FileInfo().IsDir() || strings.HasPrefix(name, "__MACOSX/") || strings.HasPrefix(path.Base(name), ".") {
+			continue
+		}
+		if count >= maxZipArchiveFiles {
+			return fmt.Errorf("zip archive contains more than %d files", maxZipArchiveFiles)
+		}
+		if f.UncompressedSize64 > maxZipMemberSize {
+			return fmt.Errorf("zip member %s is too large", name)
+		}
+
+		content, err := readZipMember(f, password)
+		if err != nil {
+			if errors.Is(err, errZipMemberTooLarge) {
+				return fmt.Errorf("zip member %s is too large", name)
+			}
+			if errors.Is(err, ErrZipPasswordRequired) || errors.Is(err, ErrZipWrongPassword) {
+				return err
+			}
+			return fmt.Errorf("failed to extract %s: %w", name, err)
+		}
+		count++
+		if err := visit(ArchiveMember{Name: name, Content: content}); err != nil {
+			return err
+		}
+	}
+
+	if count == 0 {
+		return fmt.Errorf("zip archive contains no files")
+	}
+	return nil
+}
+
+// zipMemberName decodes the file name. Archives created on Chinese Windows
+// (including Alipay's) store GBK names without the UTF-8 flag.
+func zipMemberName(f *zip.File) string {
+	if f.Flags&zipFlagUTF8 != 0 || utf8.ValidString(f.Name) {
+		return f.Name
+	}
+	if decoded, err := simplifiedchinese.GB18030.NewDecoder().String(f.Name); err == nil {
+		return decoded
+	}
+	return f.Name
+}
+
+func readZipMember(f *zip.File, password string) ([]byte, error) {
+	if f.Flags&zipFlagEncrypted == 0 {
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		return io.ReadAll(io.LimitReader(rc, maxZipMemberSize+1))
+	}
+
+	if password == "" {
+		return nil, ErrZipPasswordRequired
+	}
+
+	raw, err := f.OpenRaw()
+	if err != nil {
+		return nil, err
+	}
+	encrypted, err := io.ReadAll(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	method := f.Method
+	var compressed []byte
+	checkCRC := true
+	if method == zipMethodAES {
+		aesMethod, strength, version, err := zipAESExtra(f.Extra)
+		if err != nil {
+			return nil, err
+		}
+		compressed, err = decryptZipAES(encrypted, password, strength)
+		if err != nil {
+			return nil, err
+		}
+		method = aesMethod
+		// AE-2 stores no CRC; integrity is covered by the HMAC
+		checkCRC = version == 1
+	} else {
+		// The check byte is the high byte of the CRC, or of the DOS time when a
+		// data descriptor follows (the CRC is not known when the header is written)
+		check := byte(f.CRC32 >> 24)
+		if f.Flags&zipFlagDescriptor != 0 {
+			check = byte(f.ModifiedTime >> 8)
+		}
+		compressed, err = decryptZipCrypto(encrypted, password, check)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var content []byte
+	switch method {
+	case zip.Store:
+		content = compressed
+	case zip.Deflate:
+		rc := flate.NewReader(bytes.NewReader(compressed))
+		defer rc.Close()
+		content, err = io.ReadAll(io.LimitReader(rc, maxZipMemberSize+1))
+		if err != nil {
+			return nil, err
+		}
+		if len(content) > maxZipMemberSize {
+			return nil, errZipMemberTooLarge
+		}
+	default:
+		return nil, fmt.Errorf("unsupported zip compression method %d", method)
+	}
+
+	if checkCRC && crc32.ChecksumIEEE(content) != f.CRC32 {
+		return nil, ErrZipWrongPassword
+	}
+	return content, nil
+}
+
+// zipCryptoKeys implements the traditional PKWARE stream cipher
+type zipCryptoKeys [3]uint32
+
+func newZipCryptoKeys(password string) *zipCryptoKeys {
+	k := &zipCryptoKeys{0x12345678, 0x23456789, 0x34567890}
+	for i := 0; i < len(password); i++ {
+		k.update(password[i])
+	}
+	return k
+}
+
+func (k *zipCryptoKeys) update(b byte) {
+	k[0] = crc32.IEEETable[byte(k[0])^b] ^ (k[0] >> 8)
+	k[1] = (k[1]+(k[0]&0xff))*134775813 + 1
+	k[2] = crc32.IEEETable[byte(k[2])^byte(k[1]>>24)] ^ (k[2] >> 8)
+}
+
+func (k *zipCryptoKeys) decrypt(c byte) byte {
+	temp := uint16(k[2]) | 2
+	p := c ^ byte((uint32(temp)*uint32(temp^1))>>8)
+	k.update(p)
+	return p
+}
+
+func decryptZipCrypto(data []byte, password string, check byte) ([]byte, error) {
+	if len(data) < 12 {
+		return nil, fmt.Errorf("truncated encrypted zip member")
+	}
+	keys := newZipCryptoKeys(password)
+	out := make([]byte, len(data))
+	for i, c := range data {
+		out[i] = keys.decrypt(c)
+	}
+	// The last byte of the 12-byte encryption header is the password check
+	if out[11] != check {
+		return nil, ErrZipWrongPassword
+	}
+	return out[12:], nil
+}
+
+// zipAESExtra reads the WinZip AES extra field: vendor version (AE-1/AE-2),
+// key strength and the actual compression method
+func zipAESExtra(extra []byte) (method uint16, strength byte, version uint16, err error) {
+	for len(extra) >= 4 {
+		id := binary.LittleEndian.Uint16(extra[0:2])
+		size := int(binary.LittleEndian.Uint16(extra[2:4]))
+		if len(extra) < 4+size {
+			break
+		}
+		if id == zipExtraAES && size >= 7 {
+			field := extra[4 : 4+size]
+			return binary.LittleEndian.Uint16(field[5:7]), field[4], binary.LittleEndian.Uint16(field[0:2]), nil
+		}
+		extra = extra[4+size:]
+	}
+	return 0, 0, 0, fmt.Errorf("missing WinZip AES extra field")
+}
+
+// decryptZipAES decrypts a WinZip AES member: salt, 2-byte password verifier,
+// AES-CTR data (little-endian counter starting at 1) and a 10-byte HMAC-SHA1
+func decryptZipAES(data []byte, password string, strength byte) ([]byte, error) {
+	keyLen := map[byte]int{1: 16, 2: 24, 3: 32}[strength]
+	if keyLen == 0 {
+		return nil, fmt.Errorf("unsupported AES strength %d", strength)
+	}
+	saltLen := keyLen / 2
+	if len(data) < saltLen+2+10 {
+		return nil, fmt.Errorf("truncated encrypted zip member")
+	}
+
+	salt := data[:saltLen]
+	verifier := data[saltLen : saltLen+2]
+	payload := data[saltLen+2 : len(data)-10]
+	authCode := data[len(data)-10:]
+
+	derived := pbkdf2.Key([]byte(password), salt, 1000, 2*keyLen+2, sha1.New)
+	encKey, macKey, pwv := derived[:keyLen], derived[keyLen:2*keyLen], derived[2*keyLen:]
+	if subtle.ConstantTimeCompare(pwv, verifier) != 1 {
+		return nil, ErrZipWrongPassword
+	}
+
+	mac := hmac.New(sha1.New, macKey)
+	mac.Write(payload)
+	if !hmac.Equal(mac.Sum(nil)[:10], authCode) {
+		return nil, fmt.Errorf("zip member failed authentication")
+	}
+
+	block, err := aes.NewCipher(encKey)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, len(payload))
+	var counter, stream [aes.BlockSize]byte
+	for i := 0; i < len(payload); i += aes.BlockSize {
+		// Little-endian increment; the first block uses counter 1
+		for j := 0; j < aes.BlockSize; j++ {
+			counter[j]++
+			if counter[j] != 0 {
+				break
+			}
+		}
+		block.Encrypt(stream[:], counter[:])
+		end := i + aes.BlockSize
+		if end > len(payload) {
+			end = len(payload)
+		}
+		for j := i; j < end; j++ {
+			out[j] = payload[j] ^ stream[j-i]
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,4 @@
+-- Drop import source and external ID from transactions
+DROP INDEX IF EXISTS idx_transactions_external_id;
+ALTER TABLE transactions DROP COLUMN IF EXISTS external_id;
+ALTER TABLE transactions DROP COLUMN IF EXISTS import_source;
@@ -0,0 +1,7 @@
+-- Add import source and external ID (Alipay 交易号, WeChat 交易单号, bank 流水号, ...) to transactions
+ALTER TABLE transactions ADD COLUMN import_source VARCHAR(20) NOT NULL DEFAULT '';
+ALTER TABLE transactions ADD COLUMN external_id VARCHAR(255) NOT NULL DEFAULT '';
+
+-- Re-importing the same row is idempotent; deleted rows may be imported again
+CREATE UNIQUE INDEX idx_transactions_external_id ON transactions(user_id, import_source, external_id)
+    WHERE external_id <> '' AND is_deleted = false;
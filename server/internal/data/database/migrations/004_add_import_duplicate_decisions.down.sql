@@ -0,0 +1,2 @@
+-- Drop import duplicate decisions
+DROP TABLE IF EXISTS import_duplicate_decisions;
@@ -0,0 +1,14 @@
+-- Decisions on purchases found in both a payment app bill and a bank statement
+CREATE TABLE import_duplicate_decisions (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    app_source VARCHAR(20) NOT NULL,
+    app_external_id VARCHAR(255) NOT NULL,
+    bank_source VARCHAR(20) NOT NULL,
+    bank_external_id VARCHAR(255) NOT NULL,
+    decision VARCHAR(20) NOT NULL, -- merge, keep_both
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (user_id, app_source, app_external_id, bank_source, bank_external_id)
+);
+
+CREATE INDEX idx_import_duplicate_decisions_bank ON import_duplicate_decisions(user_id, bank_source, bank_external_id);
@@ -0,0 +1,2 @@
+-- Drop category rules
+DROP TABLE IF EXISTS category_rules;
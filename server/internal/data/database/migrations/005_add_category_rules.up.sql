@@ -0,0 +1,16 @@
+-- Per-user categorisation rules applied on import and manual entry
+CREATE TABLE category_rules (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    priority INTEGER NOT NULL DEFAULT 100,
+    enabled BOOLEAN NOT NULL DEFAULT true,
+    match_mode VARCHAR(20) NOT NULL DEFAULT 'contains', -- contains, exact, regex
+    conditions JSONB NOT NULL DEFAULT '{}',
+    actions JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    is_deleted BOOLEAN NOT NULL DEFAULT false
+);
+
+CREATE INDEX idx_category_rules_user ON category_rules(user_id, priority) WHERE is_deleted = false;
@@ -0,0 +1,28 @@
+-- Merchants / payees that noisy counterparty strings are normalised to
+CREATE TABLE payees (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    is_deleted BOOLEAN NOT NULL DEFAULT false
+);
+
+CREATE UNIQUE INDEX idx_payees_user_name ON payees(user_id, lower(name)) WHERE is_deleted = false;
+
+-- User-defined spellings of a payee, matched on the normalised alias_key
+CREATE TABLE payee_aliases (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    payee_id UUID NOT NULL REFERENCES payees(id) ON DELETE CASCADE,
+    alias VARCHAR(255) NOT NULL,
+    alias_key VARCHAR(255) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE(user_id, alias_key)
+);
+
+CREATE INDEX idx_payee_aliases_payee ON payee_aliases(payee_id);
+
+ALTER TABLE transactions ADD COLUMN payee_id UUID REFERENCES payees(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_transactions_payee ON transactions(user_id, payee_id) WHERE payee_id IS NOT NULL AND is_deleted = false;
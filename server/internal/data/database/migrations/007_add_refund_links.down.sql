@@ -0,0 +1,4 @@
+-- Drop refund links and transaction order IDs
+DROP TABLE IF EXISTS refund_links;
+DROP INDEX IF EXISTS idx_transactions_order_id;
+ALTER TABLE transactions DROP COLUMN IF EXISTS order_id;
@@ -0,0 +1,16 @@
+-- Merchant order number shared by a purchase and its refunds (商家订单号, 商户单号, JD 订单号)
+ALTER TABLE transactions ADD COLUMN order_id VARCHAR(255) NOT NULL DEFAULT '';
+
+CREATE INDEX idx_transactions_order_id ON transactions(user_id, order_id) WHERE order_id <> '' AND is_deleted = false;
+
+-- Refunds linked to the purchase they return money for; a purchase may have several partial refunds
+CREATE TABLE refund_links (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    purchase_transaction_id UUID NOT NULL REFERENCES transactions(id) ON DELETE CASCADE,
+    refund_transaction_id UUID NOT NULL REFERENCES transactions(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE(refund_transaction_id)
+);
+
+CREATE INDEX idx_refund_links_purchase ON refund_links(purchase_transaction_id);
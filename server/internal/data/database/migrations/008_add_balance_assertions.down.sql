@@ -0,0 +1,2 @@
+-- Drop balance assertions
+DROP TABLE IF EXISTS balance_assertions;
@@ -0,0 +1,16 @@
+-- Statement balances the ledger was reconciled against; edits that change the
+-- ledger balance of a reconciled date mark the assertion as broken
+CREATE TABLE balance_assertions (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    account_id UUID NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    balance_date DATE NOT NULL,
+    balance DECIMAL(15,2) NOT NULL,
+    ledger_balance DECIMAL(15,2) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'reconciled', -- reconciled, broken
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    checked_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE(account_id, balance_date)
+);
+
+CREATE INDEX idx_balance_assertions_user ON balance_assertions(user_id, account_id, balance_date);
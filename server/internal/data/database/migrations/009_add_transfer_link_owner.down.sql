@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_transfer_links_to;
+DROP INDEX IF EXISTS idx_transfer_links_from;
+DROP INDEX IF EXISTS idx_transfer_links_user_id;
+
+ALTER TABLE transfer_links DROP COLUMN IF EXISTS user_id;
@@ -0,0 +1,12 @@
+-- Transfer links are created by the importer when a transfer pair is confirmed
+ALTER TABLE transfer_links ADD COLUMN user_id UUID REFERENCES users(id) ON DELETE CASCADE;
+
+UPDATE transfer_links l SET user_id = t.user_id
+FROM transactions t
+WHERE t.id = l.from_transaction_id AND l.user_id IS NULL;
+
+ALTER TABLE transfer_links ALTER COLUMN user_id SET NOT NULL;
+
+CREATE INDEX idx_transfer_links_user_id ON transfer_links(user_id);
+CREATE INDEX idx_transfer_links_from ON transfer_links(from_transaction_id);
+CREATE INDEX idx_transfer_links_to ON transfer_links(to_transaction_id);
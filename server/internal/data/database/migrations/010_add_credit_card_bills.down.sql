@@ -0,0 +1,3 @@
+-- Drop credit card bills
+DROP TABLE IF EXISTS credit_card_repayments;
+DROP TABLE IF EXISTS credit_card_bills;
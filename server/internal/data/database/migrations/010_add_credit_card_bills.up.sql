@@ -0,0 +1,28 @@
+-- Credit card statements (账单): what is owed for a billing cycle and by when
+CREATE TABLE credit_card_bills (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    account_id UUID NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    statement_date DATE NOT NULL,                -- 账单日
+    due_date DATE NOT NULL,                      -- 到期还款日
+    statement_balance DECIMAL(15,2) NOT NULL,    -- 本期应还金额
+    minimum_payment DECIMAL(15,2) NOT NULL DEFAULT 0, -- 最低还款额
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE(account_id, statement_date)
+);
+
+CREATE INDEX idx_credit_card_bills_user ON credit_card_bills(user_id, account_id, statement_date);
+
+-- Repayments applied to a bill; each repayment transaction pays one bill
+CREATE TABLE credit_card_repayments (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    bill_id UUID NOT NULL REFERENCES credit_card_bills(id) ON DELETE CASCADE,
+    transaction_id UUID NOT NULL REFERENCES transactions(id) ON DELETE CASCADE,
+    amount DECIMAL(15,2) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE(transaction_id)
+);
+
+CREATE INDEX idx_credit_card_repayments_bill ON credit_card_repayments(bill_id);
@@ -0,0 +1,3 @@
+-- Drop transfer fees
+DROP TABLE IF EXISTS transfer_fees;
+ALTER TABLE transfer_links DROP COLUMN IF EXISTS fee_transaction_id;
@@ -0,0 +1,16 @@
+-- Fee expense split off the outgoing half of a transfer whose halves differ in amount
+ALTER TABLE transfer_links ADD COLUMN fee_transaction_id UUID REFERENCES transactions(id) ON DELETE SET NULL;
+
+-- Fees observed between two banks, used to learn whether a bank pair charges
+-- a fixed fee or a percentage of the amount
+CREATE TABLE transfer_fees (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    from_bank VARCHAR(255) NOT NULL,
+    to_bank VARCHAR(255) NOT NULL,
+    amount DECIMAL(15,2) NOT NULL, -- 到账金额
+    fee DECIMAL(15,2) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_transfer_fees_user ON transfer_fees(user_id, from_bank, to_bank, created_at);
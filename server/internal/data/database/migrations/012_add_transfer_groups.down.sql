@@ -0,0 +1,3 @@
+-- Drop transfer groups
+DROP INDEX IF EXISTS idx_transfer_links_group;
+ALTER TABLE transfer_links DROP COLUMN IF EXISTS group_id;
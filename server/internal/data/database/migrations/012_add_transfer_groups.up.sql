@@ -0,0 +1,5 @@
+-- One-to-many and many-to-one transfers are stored as one link per leg; the
+-- legs of the same transfer share a group ID
+ALTER TABLE transfer_links ADD COLUMN group_id UUID;
+
+CREATE INDEX idx_transfer_links_group ON transfer_links(group_id) WHERE group_id IS NOT NULL;
@@ -0,0 +1,2 @@
+-- Drop transfer match feedback
+DROP TABLE IF EXISTS transfer_match_feedback;
@@ -0,0 +1,15 @@
+-- Confirmed and rejected transfer match proposals with the evidence they were
+-- scored on, used to learn each user's factor weights and thresholds
+CREATE TABLE transfer_match_feedback (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    tail_number_score DECIMAL(5,4) NOT NULL DEFAULT 0,
+    amount_score DECIMAL(5,4) NOT NULL DEFAULT 0,
+    time_score DECIMAL(5,4) NOT NULL DEFAULT 0,
+    name_score DECIMAL(5,4) NOT NULL DEFAULT 0,
+    confidence DECIMAL(5,4) NOT NULL,
+    accepted BOOLEAN NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_transfer_match_feedback_user ON transfer_match_feedback(user_id, created_at);
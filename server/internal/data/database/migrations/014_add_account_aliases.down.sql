@@ -0,0 +1,2 @@
+-- Drop account aliases
+DROP TABLE IF EXISTS account_aliases;
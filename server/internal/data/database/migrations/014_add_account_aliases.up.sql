@@ -0,0 +1,17 @@
+-- Names under which an account appears in imported bills and statements, such as
+-- "招行信用卡" or "CMB 1234", matched on the normalised alias_key. Learned
+-- aliases come from the accounts users pick in import previews; manual ones
+-- are added through the API and are never overwritten by learning.
+CREATE TABLE account_aliases (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    account_id UUID NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    alias VARCHAR(255) NOT NULL,
+    alias_key VARCHAR(255) NOT NULL,
+    source VARCHAR(20) NOT NULL DEFAULT 'manual', -- manual / learned
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE(user_id, alias_key)
+);
+
+CREATE INDEX idx_account_aliases_account ON account_aliases(account_id);
@@ -0,0 +1,3 @@
+-- Drop import history
+ALTER TABLE transactions DROP COLUMN IF EXISTS import_job_id;
+DROP TABLE IF EXISTS import_jobs;
@@ -0,0 +1,23 @@
+-- Import history: one row per executed import or batch import. Imported
+-- transactions carry the job ID so that a whole import can be rolled back.
+CREATE TABLE import_jobs (
+    id UUID PRIMARY KEY, -- 预览或批量导入任务的ID
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    kind VARCHAR(20) NOT NULL DEFAULT 'file', -- file / batch
+    source VARCHAR(50) NOT NULL DEFAULT '',
+    file_name VARCHAR(1000) NOT NULL DEFAULT '',
+    file_size BIGINT NOT NULL DEFAULT 0,
+    status VARCHAR(20) NOT NULL DEFAULT 'completed', -- completed / failed / rolled_back
+    total_rows INTEGER NOT NULL DEFAULT 0,
+    imported_rows INTEGER NOT NULL DEFAULT 0,
+    error_msg TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    rolled_back_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_import_jobs_user ON import_jobs(user_id, created_at);
+
+ALTER TABLE transactions ADD COLUMN import_job_id UUID REFERENCES import_jobs(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_transactions_import_job ON transactions(user_id, import_job_id) WHERE import_job_id IS NOT NULL;
@@ -0,0 +1,2 @@
+-- Drop stored import previews
+DROP TABLE IF EXISTS import_previews;
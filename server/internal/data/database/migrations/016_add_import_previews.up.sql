@@ -0,0 +1,18 @@
+-- Parsed import previews kept server-side until they are imported or expire,
+-- so that rows can be edited across sessions and imported by job ID
+CREATE TABLE import_previews (
+    id UUID PRIMARY KEY, -- 预览的 job_id，导入后成为 import_jobs 的ID
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    source VARCHAR(50) NOT NULL,
+    file_name VARCHAR(1000) NOT NULL DEFAULT '',
+    status VARCHAR(20) NOT NULL DEFAULT 'pending', -- pending / executed
+    transactions JSONB NOT NULL DEFAULT '[]',
+    account_hints JSONB NOT NULL DEFAULT '[]',
+    version INTEGER NOT NULL DEFAULT 1,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX idx_import_previews_user ON import_previews(user_id, created_at);
+CREATE INDEX idx_import_previews_expires ON import_previews(expires_at);
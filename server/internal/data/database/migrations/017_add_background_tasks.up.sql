@@ -0,0 +1,20 @@
+-- Durable queue of background work such as processing batch imports. A worker
+-- leases a task until locked_until; a task whose lease ran out (the server
+-- stopped mid-task) is picked up again.
+CREATE TABLE background_tasks (
+    id UUID PRIMARY KEY, -- 批量导入任务与其 job_id 相同
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    kind VARCHAR(50) NOT NULL,
+    payload JSONB NOT NULL DEFAULT '{}',
+    status VARCHAR(20) NOT NULL DEFAULT 'queued', -- queued / running / succeeded / failed / cancelled
+    attempts INTEGER NOT NULL DEFAULT 0,
+    max_attempts INTEGER NOT NULL DEFAULT 3,
+    run_at TIMESTAMPTZ NOT NULL DEFAULT NOW(), -- 重试时推迟到此时间之后
+    locked_until TIMESTAMPTZ,
+    error_msg TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_background_tasks_due ON background_tasks(run_at) WHERE status IN ('queued', 'running');
+CREATE INDEX idx_background_tasks_user ON background_tasks(user_id, status);
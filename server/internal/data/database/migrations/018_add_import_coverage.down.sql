@@ -0,0 +1,2 @@
+-- Drop import coverage
+DROP TABLE IF EXISTS import_coverage;
@@ -0,0 +1,17 @@
+-- The date range each executed import covered, per account and source, so that
+-- periods no statement was imported for can be found. A rolled back import no
+-- longer covers its range.
+CREATE TABLE import_coverage (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    account_id UUID NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    source VARCHAR(50) NOT NULL,
+    import_job_id UUID NOT NULL REFERENCES import_jobs(id) ON DELETE CASCADE,
+    start_date DATE NOT NULL,
+    end_date DATE NOT NULL, -- 含当天
+    row_count INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_import_coverage_user ON import_coverage(user_id, account_id, source, start_date);
+CREATE INDEX idx_import_coverage_job ON import_coverage(import_job_id);
@@ -0,0 +1,2 @@
+-- Drop parse diagnostics of import previews
+ALTER TABLE import_previews DROP COLUMN IF EXISTS diagnostics;
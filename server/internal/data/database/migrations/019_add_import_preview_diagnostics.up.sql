@@ -0,0 +1,3 @@
+-- Rows of the previewed file that were skipped or only partly parsed, so that
+-- they can be reported and downloaded with the preview
+ALTER TABLE import_previews ADD COLUMN diagnostics JSONB NOT NULL DEFAULT '[]';
@@ -0,0 +1,16 @@
+-- Key transfer fees by account name again
+ALTER TABLE transfer_fees ADD COLUMN from_bank VARCHAR(255);
+ALTER TABLE transfer_fees ADD COLUMN to_bank VARCHAR(255);
+
+UPDATE transfer_fees f SET
+    from_bank = (SELECT name FROM accounts WHERE id = f.from_account_id),
+    to_bank = (SELECT name FROM accounts WHERE id = f.to_account_id);
+
+ALTER TABLE transfer_fees ALTER COLUMN from_bank SET NOT NULL;
+ALTER TABLE transfer_fees ALTER COLUMN to_bank SET NOT NULL;
+
+DROP INDEX IF EXISTS idx_transfer_fees_user;
+ALTER TABLE transfer_fees DROP COLUMN from_account_id;
+ALTER TABLE transfer_fees DROP COLUMN to_account_id;
+
+CREATE INDEX idx_transfer_fees_user ON transfer_fees(user_id, from_bank, to_bank, created_at);
@@ -0,0 +1,22 @@
+-- Key transfer fees by account instead of account name: names are free text,
+-- renamed at will and spelled differently for the same bank
+ALTER TABLE transfer_fees ADD COLUMN from_account_id UUID REFERENCES accounts(id) ON DELETE CASCADE;
+ALTER TABLE transfer_fees ADD COLUMN to_account_id UUID REFERENCES accounts(id) ON DELETE CASCADE;
+
+-- Only names that still belong to exactly one account can be carried over
+UPDATE transfer_fees f SET
+    from_account_id = (SELECT a.id FROM accounts a WHERE a.user_id = f.user_id AND a.name = f.from_bank AND a.is_deleted = false),
+    to_account_id = (SELECT a.id FROM accounts a WHERE a.user_id = f.user_id AND a.name = f.to_bank AND a.is_deleted = false)
+WHERE (SELECT COUNT(*) FROM accounts a WHERE a.user_id = f.user_id AND a.name = f.from_bank AND a.is_deleted = false) = 1
+  AND (SELECT COUNT(*) FROM accounts a WHERE a.user_id = f.user_id AND a.name = f.to_bank AND a.is_deleted = false) = 1;
+
+DELETE FROM transfer_fees WHERE from_account_id IS NULL OR to_account_id IS NULL;
+
+ALTER TABLE transfer_fees ALTER COLUMN from_account_id SET NOT NULL;
+ALTER TABLE transfer_fees ALTER COLUMN to_account_id SET NOT NULL;
+
+DROP INDEX IF EXISTS idx_transfer_fees_user;
+ALTER TABLE transfer_fees DROP COLUMN from_bank;
+ALTER TABLE transfer_fees DROP COLUMN to_bank;
+
+CREATE INDEX idx_transfer_fees_user ON transfer_fees(user_id, from_account_id, to_account_id, created_at);
@@ -0,0 +1,3 @@
+-- Drop background task results
+DROP INDEX IF EXISTS idx_background_tasks_finished;
+ALTER TABLE background_tasks DROP COLUMN IF EXISTS result;
@@ -0,0 +1,6 @@
+-- What a task leaves behind for its owner: for a batch import the state of the
+-- job, so that finished and failed jobs outlive a restart and a retry knows
+-- what an earlier attempt already wrote
+ALTER TABLE background_tasks ADD COLUMN result JSONB;
+
+CREATE INDEX idx_background_tasks_finished ON background_tasks(kind, updated_at) WHERE status IN ('succeeded', 'failed');
@@ -12,7 +12,9 @@ import (
 )
 
 var (
-	ErrAccountNotFound = errors.New("account not found")
+	ErrAccountNotFound      = errors.New("account not found")
+	ErrAccountAliasNotFound = errors.New("account alias not found")
+	ErrAccountAliasExists   = errors.New("account alias already exists")
 )
 
 type AccountRepository struct {
@@ -209,3 +211,118 @@ func (r *AccountRepository) CreateMany(accounts []models.Account) error {
 
 	return nil
 }
+
+// GetAliases returns the aliases of the user's accounts that are not deleted
+func (r *AccountRepository) GetAliases(userID uuid.UUID) ([]models.AccountAlias, error) {
+	aliases := []models.AccountAlias{}
+
+	query := `
+		SELECT aa.id, aa.user_id, aa.account_id, aa.alias, aa.alias_key, aa.source, aa.created_at, aa.updated_at
+		FROM account_aliases aa
+		JOIN accounts a ON a.id = aa.account_id AND a.is_deleted = false
+		WHERE aa.user_id = $1
+		ORDER BY aa.created_at
+	`
+
+	if err := r.db.Select(&aliases, query, userID); err != nil {
+		return nil, fmt.Errorf("failed to get account aliases: %w", err)
+	}
+
+	return aliases, nil
+}
+
+// GetAliasesByAccount returns the aliases of one account
+func (r *AccountRepository) GetAliasesByAccount(accountID uuid.UUID, userID uuid.UUID) ([]models.AccountAlias, error) {
+	aliases := []models.AccountAlias{}
+
+	query := `
+		SELECT id, user_id, account_id, alias, alias_key, source, created_at, updated_at
+		FROM account_aliases
+		WHERE account_id = $1 AND user_id = $2
+		ORDER BY created_at
+	`
+
+	if err := r.db.Select(&aliases, query, accountID, userID); err != nil {
+		return nil, fmt.Errorf("failed to get account aliases: %w", err)
+	}
+
+	return aliases, nil
+}
+
+// AddAlias adds a manual alias; an alias learned earlier under the same key is
+// taken over, a manual one is reported as ErrAccountAliasExists
+func (r *AccountRepository) AddAlias(alias *models.AccountAlias) (*models.AccountAlias, error) {
+	now := time.Now().UTC()
+	alias.ID = uuid.New()
+	alias.Source = models.AccountAliasManual
+	alias.CreatedAt = now
+	alias.UpdatedAt = now
+
+	query := `
+		INSERT INTO account_aliases (id, user_id, account_id, alias, alias_key, source, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (user_id, alias_key) DO UPDATE
+		SET account_id = EXCLUDED.account_id,
+		    alias = EXCLUDED.alias,
+		    source = EXCLUDED.source,
+		    updated_at = EXCLUDED.updated_at
+		WHERE account_aliases.source = 'learned'
+		RETURNING id, created_at
+	`
+
+	err := r.db.QueryRowx(query,
+		alias.ID, alias.UserID, alias.AccountID, alias.Alias, alias.AliasKey, alias.Source, alias.CreatedAt, alias.UpdatedAt,
+	).Scan(&alias.ID, &alias.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrAccountAliasExists
+		}
+		return nil, fmt.Errorf("failed to create account alias: %w", err)
+	}
+
+	return alias, nil
+}
+
+// LearnAlias records the account the user picked for a name in an import. A
+// later pick moves a learned alias to the new account; manual aliases are kept.
+func (r *AccountRepository) LearnAlias(alias *models.AccountAlias) error {
+	now := time.Now().UTC()
+
+	query := `
+		INSERT INTO account_aliases (id, user_id, account_id, alias, alias_key, source, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (user_id, alias_key) DO UPDATE
+		SET account_id = EXCLUDED.account_id,
+		    alias = EXCLUDED.alias,
+		    updated_at = EXCLUDED.updated_at
+		WHERE account_aliases.source = 'learned' AND account_aliases.account_id <> EXCLUDED.account_id
+	`
+
+	_, err := r.db.Exec(query,
+		uuid.New(), alias.UserID, alias.AccountID, alias.Alias, alias.AliasKey, models.AccountAliasLearned, now, now,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to learn account alias: %w", err)
+	}
+
+	return nil
+}
+
+func (r *AccountRepository) DeleteAlias(id uuid.UUID, accountID uuid.UUID, userID uuid.UUID) error {
+	query := `DELETE FROM account_aliases WHERE id = $1 AND account_id = $2 AND user_id = $3`
+
+	result, err := r.db.Exec(query, id, accountID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete account alias: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrAccountAliasNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"account/internal/business/models"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	ErrBalanceAssertionNotFound = errors.New("balance assertion not found")
+)
+
+type BalanceAssertionRepository struct {
+	db *sqlx.DB
+}
+
+func NewBalanceAssertionRepository(db *sqlx.DB) *BalanceAssertionRepository {
+	return &BalanceAssertionRepository{db: db}
+}
+
+// Save records an assertion, replacing an earlier one for the same account and day
+func (r *BalanceAssertionRepository) Save(assertion *models.BalanceAssertion) (*models.BalanceAssertion, error) {
+	now := time.Now().UTC()
+	assertion.ID = uuid.New()
+	assertion.CreatedAt = now
+	assertion.CheckedAt = now
+
+	query := `
+		INSERT INTO balance_assertions (id, user_id, account_id, balance_date, balance, ledger_balance, status, created_at, checked_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (account_id, balance_date)
+		DO UPDATE SET balance = EXCLUDED.balance, ledger_balance = EXCLUDED.ledger_balance,
+		              status = EXCLUDED.status, checked_at = EXCLUDED.checked_at
+		RETURNING id, created_at
+	`
+
+	row := r.db.QueryRowx(query,
+		assertion.ID, assertion.UserID, assertion.AccountID, assertion.BalanceDate, assertion.Balance,
+		assertion.LedgerBalance, assertion.Status, assertion.CreatedAt, assertion.CheckedAt,
+	)
+	if err := row.Scan(&assertion.ID, &assertion.CreatedAt); err != nil {
+		return nil, fmt.Errorf("failed to save balance assertion: %w", err)
+	}
+
+	return assertion, nil
+}
+
+// GetByAccount returns an account's assertions, newest first
+func (r *BalanceAssertionRepository) GetByAccount(userID uuid.UUID, accountID uuid.UUID) ([]models.BalanceAssertion, error) {
+	assertions := []models.BalanceAssertion{}
+
+	query := `
+		SELECT id, user_id, account_id, balance_date, balance, ledger_balance, status, created_at, checked_at
+		FROM balance_assertions
+		WHERE user_id = $1 AND account_id = $2
+		ORDER BY balance_date DESC
+	`
+
+	if err := r.db.Select(&assertions, query, userID, accountID); err != nil {
+		return nil, fmt.Errorf("failed to get balance assertions: %w", err)
+	}
+
+	return assertions, nil
+}
+
+// GetFrom returns the account's assertions dated on or after the given day
+func (r *BalanceAssertionRepository) GetFrom(userID uuid.UUID, accountID uuid.UUID, from time.Time) ([]models.BalanceAssertion, error) {
+	var assertions []models.BalanceAssertion
+
+	query := `
+		SELECT id, user_id, account_id, balance_date, balance, ledger_balance, status, created_at, checked_at
+		FROM balance_assertions
+		WHERE user_id = $1 AND account_id = $2 AND balance_date >= $3::date
+		ORDER BY balance_date
+	`
+
+	if err := r.db.Select(&assertions, query, userID, accountID, from.Format("2006-01-02")); err != nil {
+		return nil, fmt.Errorf("failed to get balance assertions: %w", err)
+	}
+
+	return assertions, nil
+}
+
+// GetLatestReconciledBefore returns the last reconciled assertion before the given day
+func (r *BalanceAssertionRepository) GetLatestReconciledBefore(userID uuid.UUID, accountID uuid.UUID, before time.Time) (*models.BalanceAssertion, error) {
+	var assertion models.BalanceAssertion
+
+	query := `
+		SELECT id, user_id, account_id, balance_date, balance, ledger_balance, status, created_at, checked_at
+		FROM balance_assertions
+		WHERE user_id = $1 AND account_id = $2 AND balance_date < $3::date AND status = 'reconciled'
+		ORDER BY balance_date DESC
+		LIMIT 1
+	`
+
+	err := r.db.Get(&assertion, query, userID, accountID, before.Format("2006-01-02"))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrBalanceAssertionNotFound
+		}
+		return nil, fmt.Errorf("failed to get balance assertion: %w", err)
+	}
+
+	return &assertion, nil
+}
+
+// UpdateCheck stores the result of re-checking an assertion against the ledger
+func (r *BalanceAssertionRepository) UpdateCheck(id uuid.UUID, ledgerBalance float64, status models.BalanceAssertionStatus) error {
+	query := `
+		UPDATE balance_assertions
+		SET ledger_balance = $1, status = $2, checked_at = $3
+		WHERE id = $4
+	`
+
+	if _, err := r.db.Exec(query, ledgerBalance, status, time.Now().UTC(), id); err != nil {
+		return fmt.Errorf("failed to update balance assertion: %w", err)
+	}
+
+	return nil
+}
+
+func (r *BalanceAssertionRepository) Delete(id uuid.UUID, accountID uuid.UUID, userID uuid.UUID) error {
+	query := `DELETE FROM balance_assertions WHERE id = $1 AND account_id = $2 AND user_id = $3`
+
+	result, err := r.db.Exec(query, id, accountID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete balance assertion: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrBalanceAssertionNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,169 @@
+package repository
+
+import (
+	"account/internal/business/models"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	ErrCategoryRuleNotFound = errors.New("category rule not found")
+)
+
+type CategoryRuleRepository struct {
+	db *sqlx.DB
+}
+
+func NewCategoryRuleRepository(db *sqlx.DB) *CategoryRuleRepository {
+	return &CategoryRuleRepository{db: db}
+}
+
+func (r *CategoryRuleRepository) Create(rule *models.CategoryRule) (*models.CategoryRule, error) {
+	now := time.Now().UTC()
+	rule.ID = uuid.New()
+	rule.CreatedAt = now
+	rule.UpdatedAt = now
+	rule.IsDeleted = false
+
+	query := `
+		INSERT INTO category_rules (id, user_id, name, priority, enabled, match_mode, conditions, actions, created_at, updated_at, is_deleted)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	`
+
+	_, err := r.db.Exec(query,
+		rule.ID, rule.UserID, rule.Name, rule.Priority, rule.Enabled, rule.MatchMode,
+		rule.Conditions, rule.Actions, rule.CreatedAt, rule.UpdatedAt, rule.IsDeleted,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create category rule: %w", err)
+	}
+
+	return rule, nil
+}
+
+func (r *CategoryRuleRepository) GetByID(id uuid.UUID, userID uuid.UUID) (*models.CategoryRule, error) {
+	var rule models.CategoryRule
+
+	query := `
+		SELECT id, user_id, name, priority, enabled, match_mode, conditions, actions, created_at, updated_at, is_deleted
+		FROM category_rules
+		WHERE id = $1 AND user_id = $2 AND is_deleted = false
+	`
+
+	err := r.db.Get(&rule, query, id, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrCategoryRuleNotFound
+		}
+		return nil, fmt.Errorf("failed to get category rule: %w", err)
+	}
+
+	return &rule, nil
+}
+
+// GetAll returns the user's rules in evaluation order
+func (r *CategoryRuleRepository) GetAll(userID uuid.UUID) ([]models.CategoryRule, error) {
+	rules := []models.CategoryRule{}
+
+	query := `
+		SELECT id, user_id, name, priority, enabled, match_mode, conditions, actions, created_at, updated_at, is_deleted
+		FROM category_rules
+		WHERE user_id = $1 AND is_deleted = false
+		ORDER BY priority ASC, created_at ASC
+	`
+
+	err := r.db.Select(&rules, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get category rules: %w", err)
+	}
+
+	return rules, nil
+}
+
+func (r *CategoryRuleRepository) Update(rule *models.CategoryRule, userID uuid.UUID) (*models.CategoryRule, error) {
+	rule.UpdatedAt = time.Now().UTC()
+
+	query := `
+		UPDATE category_rules
+		SET name = $1, priority = $2, enabled = $3, match_mode = $4, conditions = $5, actions = $6, updated_at = $7
+		WHERE id = $8 AND user_id = $9 AND is_deleted = false
+	`
+
+	result, err := r.db.Exec(query,
+		rule.Name, rule.Priority, rule.Enabled, rule.MatchMode, rule.Conditions, rule.Actions,
+		rule.UpdatedAt, rule.ID, userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update category rule: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rows == 0 {
+		return nil, ErrCategoryRuleNotFound
+	}
+
+	return rule, nil
+}
+
+func (r *CategoryRuleRepository) Delete(id uuid.UUID, userID uuid.UUID) error {
+	query := `
+		UPDATE category_rules
+		SET is_deleted = true, updated_at = $1
+		WHERE id = $2 AND user_id = $3 AND is_deleted = false
+	`
+
+	result, err := r.db.Exec(query, time.Now().UTC(), id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete category rule: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrCategoryRuleNotFound
+	}
+
+	return nil
+}
+
+// Reorder sets the priority of the given rules to their position in ruleIDs
+func (r *CategoryRuleRepository) Reorder(userID uuid.UUID, ruleIDs []uuid.UUID) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	now := time.Now().UTC()
+	query := `
+		UPDATE category_rules
+		SET priority = $1, updated_at = $2
+		WHERE id = $3 AND user_id = $4 AND is_deleted = false
+	`
+
+	for i, id := range ruleIDs {
+		result, err := tx.Exec(query, (i+1)*10, now, id, userID)
+		if err != nil {
+			return fmt.Errorf("failed to reorder category rule %s: %w", id, err)
+		}
+		if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+			return ErrCategoryRuleNotFound
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
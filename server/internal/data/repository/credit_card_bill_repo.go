@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"account/internal/business/models"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	ErrCreditCardBillNotFound = errors.New("credit card bill not found")
+)
+
+type CreditCardBillRepository struct {
+	db *sqlx.DB
+}
+
+func NewCreditCardBillRepository(db *sqlx.DB) *CreditCardBillRepository {
+	return &CreditCardBillRepository{db: db}
+}
+
+// Save records a bill, replacing an earlier one for the same account and statement date
+func (r *CreditCardBillRepository) Save(bill *models.CreditCardBill) (*models.CreditCardBill, error) {
+	now := time.Now().UTC()
+	bill.ID = uuid.New()
+	bill.CreatedAt = now
+	bill.UpdatedAt = now
+
+	query := `
+		INSERT INTO credit_card_bills (id, user_id, account_id, statement_date, due_date, statement_balance, minimum_payment, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (account_id, statement_date)
+		DO UPDATE SET due_date = EXCLUDED.due_date, statement_balance = EXCLUDED.statement_balance,
+		              minimum_payment = EXCLUDED.minimum_payment, updated_at = EXCLUDED.updated_at
+		RETURNING id, created_at
+	`
+
+	row := r.db.QueryRowx(query,
+		bill.ID, bill.UserID, bill.AccountID, bill.StatementDate, bill.DueDate,
+		bill.StatementBalance, bill.MinimumPayment, bill.CreatedAt, bill.UpdatedAt,
+	)
+	if err := row.Scan(&bill.ID, &bill.CreatedAt); err != nil {
+		return nil, fmt.Errorf("failed to save credit card bill: %w", err)
+	}
+
+	return bill, nil
+}
+
+// GetByAccount returns an account's bills, newest first, with the amount repaid
+// so far. Repayments whose transaction has been deleted no longer count.
+func (r *CreditCardBillRepository) GetByAccount(userID uuid.UUID, accountID uuid.UUID) ([]models.CreditCardBill, error) {
+	bills := []models.CreditCardBill{}
+
+	query := `
+		SELECT b.id, b.user_id, b.account_id, b.statement_date, b.due_date, b.statement_balance,
+		       b.minimum_payment, b.created_at, b.updated_at,
+		       COALESCE((
+		           SELECT SUM(p.amount)
+		           FROM credit_card_repayments p
+		           JOIN transactions t ON t.id = p.transaction_id AND t.is_deleted = false
+		           WHERE p.bill_id = b.id
+		       ), 0) AS paid_amount
+		FROM credit_card_bills b
+		WHERE b.user_id = $1 AND b.account_id = $2
+		ORDER BY b.statement_date DESC
+	`
+
+	if err := r.db.Select(&bills, query, userID, accountID); err != nil {
+		return nil, fmt.Errorf("failed to get credit card bills: %w", err)
+	}
+
+	return bills, nil
+}
+
+// AddRepayment applies a repayment transaction to a bill, moving it if it was
+// applied to another bill before
+func (r *CreditCardBillRepository) AddRepayment(userID uuid.UUID, billID uuid.UUID, transactionID uuid.UUID, amount float64) error {
+	query := `
+		INSERT INTO credit_card_repayments (id, user_id, bill_id, transaction_id, amount, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (transaction_id)
+		DO UPDATE SET bill_id = EXCLUDED.bill_id, amount = EXCLUDED.amount
+	`
+
+	if _, err := r.db.Exec(query, uuid.New(), userID, billID, transactionID, amount, time.Now().UTC()); err != nil {
+		return fmt.Errorf("failed to add credit card repayment: %w", err)
+	}
+
+	return nil
+}
+
+func (r *CreditCardBillRepository) Delete(id uuid.UUID, accountID uuid.UUID, userID uuid.UUID) error {
+	query := `DELETE FROM credit_card_bills WHERE id = $1 AND account_id = $2 AND user_id = $3`
+
+	result, err := r.db.Exec(query, id, accountID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete credit card bill: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrCreditCardBillNotFound
+	}
+
+	return nil
+}
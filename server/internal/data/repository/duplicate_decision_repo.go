@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"account/internal/business/models"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+type DuplicateDecisionRepository struct {
+	db *sqlx.DB
+}
+
+func NewDuplicateDecisionRepository(db *sqlx.DB) *DuplicateDecisionRepository {
+	return &DuplicateDecisionRepository{db: db}
+}
+
+// Save records a decision for an app/bank pair, replacing an earlier decision for the same pair
+func (r *DuplicateDecisionRepository) Save(decision *models.ImportDuplicateDecision) error {
+	if decision.ID == uuid.Nil {
+		decision.ID = uuid.New()
+	}
+	decision.CreatedAt = time.Now().UTC()
+
+	query := `
+		INSERT INTO import_duplicate_decisions (id, user_id, app_source, app_external_id, bank_source, bank_external_id, decision, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (user_id, app_source, app_external_id, bank_source, bank_external_id)
+		DO UPDATE SET decision = EXCLUDED.decision, created_at = EXCLUDED.created_at
+		RETURNING id
+	`
+
+	err := r.db.Get(&decision.ID, query,
+		decision.ID, decision.UserID, decision.AppSource, decision.AppExternalID,
+		decision.BankSource, decision.BankExternalID, decision.Decision, decision.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save duplicate decision: %w", err)
+	}
+
+	return nil
+}
+
+// GetByBankExternalIDs returns the decisions recorded for the given bank statement rows
+func (r *DuplicateDecisionRepository) GetByBankExternalIDs(userID uuid.UUID, bankSource string, externalIDs []string) ([]models.ImportDuplicateDecision, error) {
+	decisions := []models.ImportDuplicateDecision{}
+	if len(externalIDs) == 0 {
+		return decisions, nil
+	}
+
+	query := `
+		SELECT id, user_id, app_source, app_external_id, bank_source, bank_external_id, decision, created_at
+		FROM import_duplicate_decisions
+		WHERE user_id = $1 AND bank_source = $2 AND bank_external_id = ANY($3)
+	`
+
+	err := r.db.Select(&decisions, query, userID, bankSource, pq.Array(externalIDs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get duplicate decisions: %w", err)
+	}
+
+	return decisions, nil
+}
@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"account/internal/business/models"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+type ImportCoverageRepository struct {
+	db *sqlx.DB
+}
+
+func NewImportCoverageRepository(db *sqlx.DB) *ImportCoverageRepository {
+	return &ImportCoverageRepository{db: db}
+}
+
+// Create records the periods an import covered
+func (r *ImportCoverageRepository) Create(periods []models.ImportCoveragePeriod) error {
+	if len(periods) == 0 {
+		return nil
+	}
+
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	query := `
+		INSERT INTO import_coverage (id, user_id, account_id, source, import_job_id, start_date, end_date, row_count, created_at)
+		VALUES (:id, :user_id, :account_id, :source, :import_job_id, :start_date, :end_date, :row_count, :created_at)
+	`
+	for i := range periods {
+		if _, err := tx.NamedExec(query, &periods[i]); err != nil {
+			return fmt.Errorf("failed to record import coverage: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// GetOverlapping returns the user's coverage periods that overlap the days from
+// start to end, of one account when accountID is set and of the given sources
+// when any are given. Periods of rolled back imports are left out.
+func (r *ImportCoverageRepository) GetOverlapping(userID uuid.UUID, accountID *uuid.UUID, sources []string, start, end time.Time) ([]models.ImportCoveragePeriod, error) {
+	periods := []models.ImportCoveragePeriod{}
+
+	query := `
+		SELECT c.id, c.user_id, c.account_id, a.name AS account_name, c.source, c.import_job_id,
+		       c.start_date, c.end_date, c.row_count, c.created_at
+		FROM import_coverage c
+		JOIN import_jobs j ON j.id = c.import_job_id
+		JOIN accounts a ON a.id = c.account_id
+		WHERE c.user_id = $1 AND j.status <> 'rolled_back'
+		  AND c.start_date <= $2::date AND c.end_date >= $3::date
+		  AND ($4::uuid IS NULL OR c.account_id = $4)
+		  AND (COALESCE(cardinality($5::text[]), 0) = 0 OR c.source = ANY($5))
+		ORDER BY c.account_id, c.source, c.start_date
+	`
+
+	err := r.db.Select(&periods, query, userID, end.Format("2006-01-02"), start.Format("2006-01-02"), accountID, pq.Array(sources))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get import coverage: %w", err)
+	}
+
+	return periods, nil
+}
@@ -0,0 +1,207 @@
+package repository
+
+import (
+	"account/internal/business/models"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+var (
+	ErrImportJobNotFound = errors.New("import job not found")
+	// ErrImportJobRolledBack is returned when an import job was already undone
+	ErrImportJobRolledBack = errors.New("import job has been rolled back")
+)
+
+type ImportJobRepository struct {
+	db *sqlx.DB
+}
+
+func NewImportJobRepository(db *sqlx.DB) *ImportJobRepository {
+	return &ImportJobRepository{db: db}
+}
+
+// Start records an import job before its transactions are created. Executing the
+// same preview again adds to the existing job, unless it was rolled back or
+// belongs to another user.
+func (r *ImportJobRepository) Start(job *models.ImportJob) error {
+	now := time.Now().UTC()
+	job.Status = models.ImportStatusImporting
+	job.CreatedAt, job.UpdatedAt = now, now
+
+	query := `
+		INSERT INTO import_jobs (id, user_id, kind, source, file_name, file_size, status, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (id) DO UPDATE
+		SET status = EXCLUDED.status, updated_at = EXCLUDED.updated_at
+		WHERE import_jobs.user_id = EXCLUDED.user_id AND import_jobs.status <> 'rolled_back'
+	`
+
+	result, err := r.db.Exec(query,
+		job.ID, job.UserID, job.Kind, job.Source, job.FileName, job.FileSize,
+		job.Status, job.CreatedAt, job.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to start import job: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrImportJobRolledBack
+	}
+
+	return nil
+}
+
+// Finish adds the outcome of one execution to an import job
+func (r *ImportJobRepository) Finish(id uuid.UUID, userID uuid.UUID, totalRows, importedRows int, errorMsg string) error {
+	status := models.ImportStatusCompleted
+	if importedRows == 0 && errorMsg != "" {
+		status = models.ImportStatusFailed
+	}
+
+	query := `
+		UPDATE import_jobs
+		SET status = $1, total_rows = total_rows + $2, imported_rows = imported_rows + $3, error_msg = $4, updated_at = $5
+		WHERE id = $6 AND user_id = $7
+	`
+
+	_, err := r.db.Exec(query, status, totalRows, importedRows, errorMsg, time.Now().UTC(), id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to finish import job: %w", err)
+	}
+
+	return nil
+}
+
+// GetAll returns the user's import history, newest first
+func (r *ImportJobRepository) GetAll(userID uuid.UUID, limit int, offset int) ([]models.ImportJob, error) {
+	jobs := []models.ImportJob{}
+
+	if limit <= 0 {
+		limit = 50
+	}
+
+	query := `
+		SELECT id, user_id, kind, source, file_name, file_size, status, total_rows, imported_rows, error_msg, created_at, updated_at, rolled_back_at
+		FROM import_jobs
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2 OFFSET $3
+	`
+
+	if err := r.db.Select(&jobs, query, userID, limit, offset); err != nil {
+		return nil, fmt.Errorf("failed to get import jobs: %w", err)
+	}
+
+	return jobs, nil
+}
+
+func (r *ImportJobRepository) GetByID(id uuid.UUID, userID uuid.UUID) (*models.ImportJob, error) {
+	var job models.ImportJob
+
+	query := `
+		SELECT id, user_id, kind, source, file_name, file_size, status, total_rows, imported_rows, error_msg, created_at, updated_at, rolled_back_at
+		FROM import_jobs
+		WHERE id = $1 AND user_id = $2
+	`
+
+	err := r.db.Get(&job, query, id, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrImportJobNotFound
+		}
+		return nil, fmt.Errorf("failed to get import job: %w", err)
+	}
+
+	return &job, nil
+}
+
+// Rollback applies a rollback plan in one transaction and marks the job rolled
+// back. Every changed transaction and account gets a new version so that
+// clients pick up the change on the next sync.
+func (r *ImportJobRepository) Rollback(userID uuid.UUID, rollback *models.ImportRollback) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// Lock the job so that a concurrent rollback of it waits and then fails
+	var status models.ImportStatus
+	err = tx.Get(&status, `
+		SELECT status FROM import_jobs WHERE id = $1 AND user_id = $2 FOR UPDATE
+	`, rollback.JobID, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrImportJobNotFound
+		}
+		return fmt.Errorf("failed to get import job: %w", err)
+	}
+	if status == models.ImportStatusRolledBack {
+		return ErrImportJobRolledBack
+	}
+
+	now := time.Now().UTC()
+	if len(rollback.RemovedTransferLinkIDs) > 0 {
+		_, err = tx.Exec(`
+			DELETE FROM transfer_links WHERE id = ANY($1::uuid[]) AND user_id = $2
+		`, pq.Array(uuidStrings(rollback.RemovedTransferLinkIDs)), userID)
+		if err != nil {
+			return fmt.Errorf("failed to remove transfer links: %w", err)
+		}
+	}
+
+	if len(rollback.DeletedTransactionIDs) > 0 {
+		_, err = tx.Exec(`
+			UPDATE transactions
+			SET is_deleted = true, updated_at = $1, last_modified_at = $2, version = version + 1
+			WHERE id = ANY($3::uuid[]) AND user_id = $4 AND is_deleted = false
+		`, now, now, pq.Array(uuidStrings(rollback.DeletedTransactionIDs)), userID)
+		if err != nil {
+			return fmt.Errorf("failed to delete imported transactions: %w", err)
+		}
+	}
+
+	for _, restored := range rollback.RestoredTransactions {
+		_, err = tx.Exec(`
+			UPDATE transactions
+			SET type = $1, amount = $2, updated_at = $3, last_modified_at = $4, version = version + 1
+			WHERE id = $5 AND user_id = $6 AND is_deleted = false
+		`, restored.Type, restored.Amount, now, now, restored.TransactionID, userID)
+		if err != nil {
+			return fmt.Errorf("failed to restore transaction %s: %w", restored.TransactionID, err)
+		}
+	}
+
+	for _, change := range rollback.BalanceChanges {
+		_, err = tx.Exec(`
+			UPDATE accounts
+			SET balance = balance + $1, updated_at = $2, last_modified_at = $3, version = version + 1
+			WHERE id = $4 AND user_id = $5
+		`, change.Change, now, now, change.AccountID, userID)
+		if err != nil {
+			return fmt.Errorf("failed to update account balance: %w", err)
+		}
+	}
+
+	_, err = tx.Exec(`
+		UPDATE import_jobs SET status = $1, rolled_back_at = $2, updated_at = $3 WHERE id = $4
+	`, models.ImportStatusRolledBack, now, now, rollback.JobID)
+	if err != nil {
+		return fmt.Errorf("failed to mark import job rolled back: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
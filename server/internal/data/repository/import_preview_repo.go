@@ -0,0 +1,159 @@
+package repository
+
+import (
+	"account/internal/business/models"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	ErrImportPreviewNotFound = errors.New("import preview not found")
+	// ErrImportPreviewConflict is returned when the preview changed since it was read
+	ErrImportPreviewConflict = errors.New("import preview was modified concurrently")
+	// ErrImportPreviewExecuted is returned when the preview was imported already
+	ErrImportPreviewExecuted = errors.New("import preview has already been imported")
+)
+
+type ImportPreviewRepository struct {
+	db *sqlx.DB
+}
+
+func NewImportPreviewRepository(db *sqlx.DB) *ImportPreviewRepository {
+	return &ImportPreviewRepository{db: db}
+}
+
+// Create stores a parsed preview that expires after ttl
+func (r *ImportPreviewRepository) Create(preview *models.ImportPreview, ttl time.Duration) error {
+	now := time.Now().UTC()
+	preview.Status = models.ImportPreviewPending
+	preview.Version = 1
+	preview.CreatedAt, preview.UpdatedAt = now, now
+	preview.ExpiresAt = now.Add(ttl)
+
+	query := `
+		INSERT INTO import_previews (id, user_id, source, file_name, status, transactions, account_hints, diagnostics, version, created_at, updated_at, expires_at)
+		VALUES (:id, :user_id, :source, :file_name, :status, :transactions, :account_hints, :diagnostics, :version, :created_at, :updated_at, :expires_at)
+	`
+
+	if _, err := r.db.NamedExec(query, preview); err != nil {
+		return fmt.Errorf("failed to create import preview: %w", err)
+	}
+
+	return nil
+}
+
+// GetByID returns a preview that has not expired
+func (r *ImportPreviewRepository) GetByID(id uuid.UUID, userID uuid.UUID) (*models.ImportPreview, error) {
+	var preview models.ImportPreview
+
+	query := `
+		SELECT id, user_id, source, file_name, status, transactions, account_hints, diagnostics, version, created_at, updated_at, expires_at
+		FROM import_previews
+		WHERE id = $1 AND user_id = $2 AND expires_at > NOW()
+	`
+
+	err := r.db.Get(&preview, query, id, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrImportPreviewNotFound
+		}
+		return nil, fmt.Errorf("failed to get import preview: %w", err)
+	}
+
+	return &preview, nil
+}
+
+// UpdateRows saves edited rows. The update only applies to the version that was
+// read, so that two concurrent edits cannot overwrite each other.
+func (r *ImportPreviewRepository) UpdateRows(preview *models.ImportPreview) error {
+	now := time.Now().UTC()
+
+	query := `
+		UPDATE import_previews
+		SET transactions = $1, version = version + 1, updated_at = $2
+		WHERE id = $3 AND user_id = $4 AND version = $5 AND status = 'pending' AND expires_at > NOW()
+	`
+
+	result, err := r.db.Exec(query, preview.Transactions, now, preview.JobID, preview.UserID, preview.Version)
+	if err != nil {
+		return fmt.Errorf("failed to update import preview: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rows == 0 {
+		return r.updateFailure(preview.JobID, preview.UserID)
+	}
+
+	preview.Version++
+	preview.UpdatedAt = now
+	return nil
+}
+
+// MarkExecuted claims a pending preview for import at the given version; only
+// one caller succeeds, and an edit made since the version was read fails it
+func (r *ImportPreviewRepository) MarkExecuted(id uuid.UUID, userID uuid.UUID, version int) error {
+	query := `
+		UPDATE import_previews
+		SET status = 'executed', version = version + 1, updated_at = $1
+		WHERE id = $2 AND user_id = $3 AND version = $4 AND status = 'pending' AND expires_at > NOW()
+	`
+
+	result, err := r.db.Exec(query, time.Now().UTC(), id, userID, version)
+	if err != nil {
+		return fmt.Errorf("failed to mark import preview executed: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rows == 0 {
+		return r.updateFailure(id, userID)
+	}
+
+	return nil
+}
+
+// updateFailure tells why a conditional update matched no row
+func (r *ImportPreviewRepository) updateFailure(id uuid.UUID, userID uuid.UUID) error {
+	preview, err := r.GetByID(id, userID)
+	if err != nil {
+		return err
+	}
+	if preview.Status == models.ImportPreviewExecuted {
+		return ErrImportPreviewExecuted
+	}
+	return ErrImportPreviewConflict
+}
+
+// Delete discards a preview
+func (r *ImportPreviewRepository) Delete(id uuid.UUID, userID uuid.UUID) error {
+	result, err := r.db.Exec(`DELETE FROM import_previews WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete import preview: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrImportPreviewNotFound
+	}
+
+	return nil
+}
+
+// DeleteExpired removes previews past their expiry
+func (r *ImportPreviewRepository) DeleteExpired() (int64, error) {
+	result, err := r.db.Exec(`DELETE FROM import_previews WHERE expires_at <= NOW()`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired import previews: %w", err)
+	}
+	return result.RowsAffected()
+}
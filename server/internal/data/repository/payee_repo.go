@@ -0,0 +1,256 @@
+package repository
+
+import (
+	"account/internal/business/models"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	ErrPayeeNotFound      = errors.New("payee not found")
+	ErrPayeeNameExists    = errors.New("payee name already exists")
+	ErrPayeeAliasNotFound = errors.New("payee alias not found")
+	ErrPayeeAliasExists   = errors.New("payee alias already exists")
+)
+
+type PayeeRepository struct {
+	db *sqlx.DB
+}
+
+func NewPayeeRepository(db *sqlx.DB) *PayeeRepository {
+	return &PayeeRepository{db: db}
+}
+
+func (r *PayeeRepository) Create(payee *models.Payee) (*models.Payee, error) {
+	now := time.Now().UTC()
+	payee.ID = uuid.New()
+	payee.CreatedAt = now
+	payee.UpdatedAt = now
+	payee.IsDeleted = false
+	payee.Aliases = []models.PayeeAlias{}
+
+	query := `
+		INSERT INTO payees (id, user_id, name, created_at, updated_at, is_deleted)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+
+	_, err := r.db.Exec(query, payee.ID, payee.UserID, payee.Name, payee.CreatedAt, payee.UpdatedAt, payee.IsDeleted)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, ErrPayeeNameExists
+		}
+		return nil, fmt.Errorf("failed to create payee: %w", err)
+	}
+
+	return payee, nil
+}
+
+// GetOrCreate returns the user's payee with the given name (case-insensitive), creating it if missing
+func (r *PayeeRepository) GetOrCreate(userID uuid.UUID, name string) (*models.Payee, error) {
+	var payee models.Payee
+	now := time.Now().UTC()
+
+	query := `
+		INSERT INTO payees (id, user_id, name, created_at, updated_at, is_deleted)
+		VALUES ($1, $2, $3, $4, $5, false)
+		ON CONFLICT (user_id, lower(name)) WHERE is_deleted = false
+		DO UPDATE SET name = payees.name
+		RETURNING id, user_id, name, created_at, updated_at, is_deleted
+	`
+
+	err := r.db.Get(&payee, query, uuid.New(), userID, name, now, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get or create payee: %w", err)
+	}
+
+	return &payee, nil
+}
+
+func (r *PayeeRepository) GetByID(id uuid.UUID, userID uuid.UUID) (*models.Payee, error) {
+	var payee models.Payee
+
+	query := `
+		SELECT id, user_id, name, created_at, updated_at, is_deleted
+		FROM payees
+		WHERE id = $1 AND user_id = $2 AND is_deleted = false
+	`
+
+	err := r.db.Get(&payee, query, id, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrPayeeNotFound
+		}
+		return nil, fmt.Errorf("failed to get payee: %w", err)
+	}
+
+	payee.Aliases = []models.PayeeAlias{}
+	err = r.db.Select(&payee.Aliases, `
+		SELECT id, user_id, payee_id, alias, alias_key, created_at
+		FROM payee_aliases
+		WHERE payee_id = $1 AND user_id = $2
+		ORDER BY created_at
+	`, id, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get payee aliases: %w", err)
+	}
+
+	return &payee, nil
+}
+
+// GetAll returns the user's payees with their aliases, ordered by name
+func (r *PayeeRepository) GetAll(userID uuid.UUID) ([]models.Payee, error) {
+	payees := []models.Payee{}
+
+	query := `
+		SELECT id, user_id, name, created_at, updated_at, is_deleted
+		FROM payees
+		WHERE user_id = $1 AND is_deleted = false
+		ORDER BY name
+	`
+
+	if err := r.db.Select(&payees, query, userID); err != nil {
+		return nil, fmt.Errorf("failed to get payees: %w", err)
+	}
+
+	var aliases []models.PayeeAlias
+	err := r.db.Select(&aliases, `
+		SELECT id, user_id, payee_id, alias, alias_key, created_at
+		FROM payee_aliases
+		WHERE user_id = $1
+		ORDER BY created_at
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get payee aliases: %w", err)
+	}
+
+	byPayee := make(map[uuid.UUID][]models.PayeeAlias)
+	for _, a := range aliases {
+		byPayee[a.PayeeID] = append(byPayee[a.PayeeID], a)
+	}
+	for i := range payees {
+		payees[i].Aliases = byPayee[payees[i].ID]
+		if payees[i].Aliases == nil {
+			payees[i].Aliases = []models.PayeeAlias{}
+		}
+	}
+
+	return payees, nil
+}
+
+func (r *PayeeRepository) Update(payee *models.Payee, userID uuid.UUID) (*models.Payee, error) {
+	payee.UpdatedAt = time.Now().UTC()
+
+	query := `
+		UPDATE payees
+		SET name = $1, updated_at = $2
+		WHERE id = $3 AND user_id = $4 AND is_deleted = false
+	`
+
+	result, err := r.db.Exec(query, payee.Name, payee.UpdatedAt, payee.ID, userID)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, ErrPayeeNameExists
+		}
+		return nil, fmt.Errorf("failed to update payee: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rows == 0 {
+		return nil, ErrPayeeNotFound
+	}
+
+	return payee, nil
+}
+
+// Delete soft-deletes a payee, drops its aliases and detaches its transactions
+func (r *PayeeRepository) Delete(id uuid.UUID, userID uuid.UUID) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	now := time.Now().UTC()
+	result, err := tx.Exec(`
+		UPDATE payees
+		SET is_deleted = true, updated_at = $1
+		WHERE id = $2 AND user_id = $3 AND is_deleted = false
+	`, now, id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete payee: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrPayeeNotFound
+	}
+
+	if _, err := tx.Exec(`DELETE FROM payee_aliases WHERE payee_id = $1 AND user_id = $2`, id, userID); err != nil {
+		return fmt.Errorf("failed to delete payee aliases: %w", err)
+	}
+
+	// Bump the version so clients pick up the cleared payee on the next sync
+	_, err = tx.Exec(`
+		UPDATE transactions
+		SET payee_id = NULL, updated_at = $1, last_modified_at = $2, version = version + 1
+		WHERE payee_id = $3 AND user_id = $4
+	`, now, now, id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to detach payee transactions: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (r *PayeeRepository) AddAlias(alias *models.PayeeAlias) (*models.PayeeAlias, error) {
+	alias.ID = uuid.New()
+	alias.CreatedAt = time.Now().UTC()
+
+	query := `
+		INSERT INTO payee_aliases (id, user_id, payee_id, alias, alias_key, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+
+	_, err := r.db.Exec(query, alias.ID, alias.UserID, alias.PayeeID, alias.Alias, alias.AliasKey, alias.CreatedAt)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, ErrPayeeAliasExists
+		}
+		return nil, fmt.Errorf("failed to create payee alias: %w", err)
+	}
+
+	return alias, nil
+}
+
+func (r *PayeeRepository) DeleteAlias(id uuid.UUID, payeeID uuid.UUID, userID uuid.UUID) error {
+	query := `DELETE FROM payee_aliases WHERE id = $1 AND payee_id = $2 AND user_id = $3`
+
+	result, err := r.db.Exec(query, id, payeeID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete payee alias: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrPayeeAliasNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"account/internal/business/models"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+var (
+	ErrRefundLinkNotFound = errors.New("refund link not found")
+)
+
+type RefundLinkRepository struct {
+	db *sqlx.DB
+}
+
+func NewRefundLinkRepository(db *sqlx.DB) *RefundLinkRepository {
+	return &RefundLinkRepository{db: db}
+}
+
+// Save links a refund to a purchase, replacing any earlier link of the refund
+func (r *RefundLinkRepository) Save(link *models.RefundLink) (*models.RefundLink, error) {
+	link.ID = uuid.New()
+	link.CreatedAt = time.Now().UTC()
+
+	query := `
+		INSERT INTO refund_links (id, user_id, purchase_transaction_id, refund_transaction_id, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (refund_transaction_id)
+		DO UPDATE SET purchase_transaction_id = EXCLUDED.purchase_transaction_id, created_at = EXCLUDED.created_at
+		RETURNING id
+	`
+
+	err := r.db.Get(&link.ID, query, link.ID, link.UserID, link.PurchaseTransactionID, link.RefundTransactionID, link.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save refund link: %w", err)
+	}
+
+	return link, nil
+}
+
+func (r *RefundLinkRepository) GetByRefund(userID uuid.UUID, refundID uuid.UUID) (*models.RefundLink, error) {
+	var link models.RefundLink
+
+	query := `
+		SELECT id, user_id, purchase_transaction_id, refund_transaction_id, created_at
+		FROM refund_links
+		WHERE user_id = $1 AND refund_transaction_id = $2
+	`
+
+	err := r.db.Get(&link, query, userID, refundID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRefundLinkNotFound
+		}
+		return nil, fmt.Errorf("failed to get refund link: %w", err)
+	}
+
+	return &link, nil
+}
+
+// GetByPurchase returns the links of a purchase's live refunds, oldest first
+func (r *RefundLinkRepository) GetByPurchase(userID uuid.UUID, purchaseID uuid.UUID) ([]models.RefundLink, error) {
+	links := []models.RefundLink{}
+
+	query := `
+		SELECT l.id, l.user_id, l.purchase_transaction_id, l.refund_transaction_id, l.created_at
+		FROM refund_links l
+		JOIN transactions t ON t.id = l.refund_transaction_id AND t.is_deleted = false
+		WHERE l.user_id = $1 AND l.purchase_transaction_id = $2
+		ORDER BY t.transaction_date, l.created_at
+	`
+
+	if err := r.db.Select(&links, query, userID, purchaseID); err != nil {
+		return nil, fmt.Errorf("failed to get refund links: %w", err)
+	}
+
+	return links, nil
+}
+
+// GetRefundedAmounts returns the total of live refunds linked to each purchase.
+// The refund being relinked can be excluded so it is not counted twice.
+func (r *RefundLinkRepository) GetRefundedAmounts(userID uuid.UUID, purchaseIDs []uuid.UUID, excludeRefundID *uuid.UUID) (map[uuid.UUID]float64, error) {
+	amounts := make(map[uuid.UUID]float64)
+	if len(purchaseIDs) == 0 {
+		return amounts, nil
+	}
+
+	ids := make([]string, len(purchaseIDs))
+	for i, id := range purchaseIDs {
+		ids[i] = id.String()
+	}
+
+	query := `
+		SELECT l.purchase_transaction_id, SUM(t.amount) as total
+		FROM refund_links l
+		JOIN transactions t ON t.id = l.refund_transaction_id AND t.is_deleted = false
+		WHERE l.user_id = $1 AND l.purchase_transaction_id = ANY($2::uuid[])
+		AND ($3::uuid IS NULL OR l.refund_transaction_id <> $3)
+		GROUP BY l.purchase_transaction_id
+	`
+
+	rows, err := r.db.Query(query, userID, pq.Array(ids), excludeRefundID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get refunded amounts: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id uuid.UUID
+		var total float64
+		if err := rows.Scan(&id, &total); err != nil {
+			return nil, fmt.Errorf("failed to scan refunded amount: %w", err)
+		}
+		amounts[id] = total
+	}
+
+	return amounts, rows.Err()
+}
+
+func (r *RefundLinkRepository) DeleteByRefund(userID uuid.UUID, refundID uuid.UUID) error {
+	query := `DELETE FROM refund_links WHERE user_id = $1 AND refund_transaction_id = $2`
+
+	result, err := r.db.Exec(query, userID, refundID)
+	if err != nil {
+		return fmt.Errorf("failed to delete refund link: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrRefundLinkNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,291 @@
+package repository
+
+import (
+	"account/internal/business/models"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+var (
+	ErrTaskNotFound = errors.New("background task not found")
+	// ErrTaskNotRunning is returned when a task was cancelled or finished while a worker held it
+	ErrTaskNotRunning = errors.New("background task is not running")
+)
+
+const taskColumns = `id, user_id, kind, payload, status, attempts, max_attempts, run_at, locked_until, error_msg, result, created_at, updated_at`
+
+type TaskRepository struct {
+	db *sqlx.DB
+}
+
+func NewTaskRepository(db *sqlx.DB) *TaskRepository {
+	return &TaskRepository{db: db}
+}
+
+// Enqueue adds a task that is due right away
+func (r *TaskRepository) Enqueue(task *models.BackgroundTask) error {
+	now := time.Now().UTC()
+	task.Status = models.TaskStatusQueued
+	task.RunAt, task.CreatedAt, task.UpdatedAt = now, now, now
+
+	query := `
+		INSERT INTO background_tasks (id, user_id, kind, payload, status, max_attempts, run_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`
+
+	_, err := r.db.Exec(query,
+		task.ID, task.UserID, task.Kind, string(task.Payload), task.Status,
+		task.MaxAttempts, task.RunAt, task.CreatedAt, task.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to enqueue task: %w", err)
+	}
+
+	return nil
+}
+
+// Claim leases the task of the given kinds that is due first, skipping users
+// who already have perUserLimit tasks running. A running task whose lease
+// expired was abandoned by a stopped server and is claimed again. Claims are
+// serialised with an advisory lock so that concurrent workers, also those of
+// other servers, cannot together exceed the per-user limit. Returns nil when
+// no task is due.
+func (r *TaskRepository) Claim(kinds []string, perUserLimit int, lease time.Duration) (*models.BackgroundTask, error) {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext('background_tasks'))`); err != nil {
+		return nil, fmt.Errorf("failed to lock task queue: %w", err)
+	}
+
+	var task models.BackgroundTask
+	err = tx.Get(&task, `
+		UPDATE background_tasks
+		SET status = 'running', attempts = attempts + 1, locked_until = NOW() + $1::float8 * INTERVAL '1 second', updated_at = NOW()
+		WHERE id = (
+			SELECT t.id FROM background_tasks t
+			WHERE t.kind = ANY($2)
+			  AND ((t.status = 'queued' AND t.run_at <= NOW()) OR (t.status = 'running' AND t.locked_until < NOW()))
+			  AND (
+				SELECT COUNT(*) FROM background_tasks running
+				WHERE running.user_id = t.user_id AND running.status = 'running' AND running.locked_until >= NOW()
+			  ) < $3
+			ORDER BY t.run_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+taskColumns,
+		lease.Seconds(), pq.Array(kinds), perUserLimit)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to claim task: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return &task, nil
+}
+
+// Heartbeat extends the lease of a running task. ErrTaskNotRunning tells the
+// worker that the task was cancelled in the meantime.
+func (r *TaskRepository) Heartbeat(id uuid.UUID, lease time.Duration) error {
+	query := `
+		UPDATE background_tasks
+		SET locked_until = NOW() + $1::float8 * INTERVAL '1 second', updated_at = NOW()
+		WHERE id = $2 AND status = 'running'
+	`
+
+	return r.execRunning(query, "failed to extend task lease", lease.Seconds(), id)
+}
+
+// Complete marks a running task succeeded
+func (r *TaskRepository) Complete(id uuid.UUID) error {
+	query := `
+		UPDATE background_tasks
+		SET status = 'succeeded', locked_until = NULL, error_msg = '', updated_at = NOW()
+		WHERE id = $1 AND status = 'running'
+	`
+
+	return r.execRunning(query, "failed to complete task", id)
+}
+
+// Retry puts a failed attempt back in the queue, due at runAt
+func (r *TaskRepository) Retry(id uuid.UUID, runAt time.Time, errorMsg string) error {
+	query := `
+		UPDATE background_tasks
+		SET status = 'queued', run_at = $1, locked_until = NULL, error_msg = $2, updated_at = NOW()
+		WHERE id = $3 AND status = 'running'
+	`
+
+	return r.execRunning(query, "failed to retry task", runAt.UTC(), errorMsg, id)
+}
+
+// Fail marks a running task failed for good
+func (r *TaskRepository) Fail(id uuid.UUID, errorMsg string) error {
+	query := `
+		UPDATE background_tasks
+		SET status = 'failed', locked_until = NULL, error_msg = $1, updated_at = NOW()
+		WHERE id = $2 AND status = 'running'
+	`
+
+	return r.execRunning(query, "failed to fail task", errorMsg, id)
+}
+
+// Release returns a running task to the queue without counting the attempt,
+// for a server that stops before the task is done
+func (r *TaskRepository) Release(id uuid.UUID) error {
+	query := `
+		UPDATE background_tasks
+		SET status = 'queued', attempts = GREATEST(attempts - 1, 0), run_at = NOW(), locked_until = NULL, updated_at = NOW()
+		WHERE id = $1 AND status = 'running'
+	`
+
+	return r.execRunning(query, "failed to release task", id)
+}
+
+// Cancel cancels a queued or running task of the user. A worker running the
+// task notices on its next heartbeat.
+func (r *TaskRepository) Cancel(id uuid.UUID, userID uuid.UUID) error {
+	query := `
+		UPDATE background_tasks
+		SET status = 'cancelled', locked_until = NULL, updated_at = NOW()
+		WHERE id = $1 AND user_id = $2 AND status IN ('queued', 'running')
+	`
+
+	result, err := r.db.Exec(query, id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to cancel task: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrTaskNotFound
+	}
+
+	return nil
+}
+
+// GetByID returns a task of the user
+func (r *TaskRepository) GetByID(id uuid.UUID, userID uuid.UUID) (*models.BackgroundTask, error) {
+	var task models.BackgroundTask
+
+	query := `SELECT ` + taskColumns + ` FROM background_tasks WHERE id = $1 AND user_id = $2`
+
+	err := r.db.Get(&task, query, id, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrTaskNotFound
+		}
+		return nil, fmt.Errorf("failed to get task: %w", err)
+	}
+
+	return &task, nil
+}
+
+// GetUnfinished returns the queued and running tasks of a kind, oldest first
+func (r *TaskRepository) GetUnfinished(kind string) ([]models.BackgroundTask, error) {
+	tasks := []models.BackgroundTask{}
+
+	query := `
+		SELECT ` + taskColumns + `
+		FROM background_tasks
+		WHERE kind = $1 AND status IN ('queued', 'running')
+		ORDER BY created_at
+	`
+
+	if err := r.db.Select(&tasks, query, kind); err != nil {
+		return nil, fmt.Errorf("failed to get unfinished tasks: %w", err)
+	}
+
+	return tasks, nil
+}
+
+// GetFinishedSince returns the succeeded and failed tasks of a kind that
+// finished after since, oldest first
+func (r *TaskRepository) GetFinishedSince(kind string, since time.Time) ([]models.BackgroundTask, error) {
+	tasks := []models.BackgroundTask{}
+
+	query := `
+		SELECT ` + taskColumns + `
+		FROM background_tasks
+		WHERE kind = $1 AND status IN ('succeeded', 'failed') AND updated_at >= $2
+		ORDER BY created_at
+	`
+
+	if err := r.db.Select(&tasks, query, kind, since.UTC()); err != nil {
+		return nil, fmt.Errorf("failed to get finished tasks: %w", err)
+	}
+
+	return tasks, nil
+}
+
+// SaveResult stores the result of a task of the user
+func (r *TaskRepository) SaveResult(id uuid.UUID, userID uuid.UUID, result []byte) error {
+	query := `UPDATE background_tasks SET result = $1, updated_at = NOW() WHERE id = $2 AND user_id = $3`
+
+	res, err := r.db.Exec(query, result, id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to save task result: %w", err)
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrTaskNotFound
+	}
+
+	return nil
+}
+
+// Delete removes a task of the user that is no longer queued or running
+func (r *TaskRepository) Delete(id uuid.UUID, userID uuid.UUID) error {
+	query := `DELETE FROM background_tasks WHERE id = $1 AND user_id = $2 AND status NOT IN ('queued', 'running')`
+
+	res, err := r.db.Exec(query, id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete task: %w", err)
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrTaskNotFound
+	}
+
+	return nil
+}
+
+// execRunning runs an update of a running task, returning ErrTaskNotRunning
+// when the task is no longer running
+func (r *TaskRepository) execRunning(query string, errPrefix string, args ...interface{}) error {
+	result, err := r.db.Exec(query, args...)
+	if err != nil {
+		return fmt.Errorf("%s: %w", errPrefix, err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrTaskNotRunning
+	}
+
+	return nil
+}
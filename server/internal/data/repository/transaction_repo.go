@@ -5,14 +5,17 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
 var (
 	ErrTransactionNotFound = errors.New("transaction not found")
+	ErrDuplicateExternalID = errors.New("transaction with this external id already exists")
 )
 
 type TransactionRepository struct {
@@ -27,6 +30,7 @@ func (r *TransactionRepository) Create(
 	userID uuid.UUID,
 	accountID uuid.UUID,
 	categoryID *uuid.UUID,
+	payeeID *uuid.UUID,
 	transactionType models.TransactionType,
 	amount float64,
 	currency string,
@@ -39,6 +43,7 @@ func (r *TransactionRepository) Create(
 		UserID:          userID,
 		AccountID:       accountID,
 		CategoryID:      categoryID,
+		PayeeID:         payeeID,
 		Type:            transactionType,
 		Amount:          amount,
 		Currency:        currency,
@@ -52,12 +57,12 @@ func (r *TransactionRepository) Create(
 	}
 
 	query := `
-		INSERT INTO transactions (id, user_id, account_id, category_id, type, amount, currency, note, transaction_date, created_at, updated_at, last_modified_at, version, is_deleted)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+		INSERT INTO transactions (id, user_id, account_id, category_id, payee_id, type, amount, currency, note, transaction_date, created_at, updated_at, last_modified_at, version, is_deleted)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
 	`
 
 	_, err := r.db.Exec(query,
-		transaction.ID, transaction.UserID, transaction.AccountID, transaction.CategoryID,
+		transaction.ID, transaction.UserID, transaction.AccountID, transaction.CategoryID, transaction.PayeeID,
 		transaction.Type, transaction.Amount, transaction.Currency, transaction.Note,
 		transaction.TransactionDate, transaction.CreatedAt, transaction.UpdatedAt,
 		transaction.LastModifiedAt, transaction.Version, transaction.IsDeleted,
@@ -69,11 +74,102 @@ func (r *TransactionRepository) Create(
 	return transaction, nil
 }
 
+// CreateImported creates a transaction that originates from an import file.
+// The (user, source, external ID) triple is unique, so importing the same row
+// twice returns ErrDuplicateExternalID instead of creating a second copy.
+func (r *TransactionRepository) CreateImported(
+	userID uuid.UUID,
+	accountID uuid.UUID,
+	categoryID *uuid.UUID,
+	payeeID *uuid.UUID,
+	transactionType models.TransactionType,
+	amount float64,
+	currency string,
+	note string,
+	transactionDate time.Time,
+	importSource string,
+	externalID string,
+	orderID string,
+	importJobID *uuid.UUID,
+) (*models.Transaction, error) {
+	now := time.Now().UTC()
+	transaction := &models.Transaction{
+		ID:              uuid.New(),
+		UserID:          userID,
+		AccountID:       accountID,
+		CategoryID:      categoryID,
+		PayeeID:         payeeID,
+		Type:            transactionType,
+		Amount:          amount,
+		Currency:        currency,
+		Note:            note,
+		TransactionDate: transactionDate.UTC(),
+		ImportSource:    importSource,
+		ExternalID:      externalID,
+		OrderID:         orderID,
+		ImportJobID:     importJobID,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+		LastModifiedAt:  now,
+		Version:         1,
+		IsDeleted:       false,
+	}
+
+	query := `
+		INSERT INTO transactions (id, user_id, account_id, category_id, payee_id, type, amount, currency, note, transaction_date, import_source, external_id, order_id, import_job_id, created_at, updated_at, last_modified_at, version, is_deleted)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19)
+	`
+
+	_, err := r.db.Exec(query,
+		transaction.ID, transaction.UserID, transaction.AccountID, transaction.CategoryID, transaction.PayeeID,
+		transaction.Type, transaction.Amount, transaction.Currency, transaction.Note,
+		transaction.TransactionDate, transaction.ImportSource, transaction.ExternalID, transaction.OrderID, transaction.ImportJobID,
+		transaction.CreatedAt, transaction.UpdatedAt, transaction.LastModifiedAt,
+		transaction.Version, transaction.IsDeleted,
+	)
+	if err != nil {
+		var pqErr *pq.Error
+		// Only the external ID index means the row was imported before
+		if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation && pqErr.Constraint == externalIDIndex {
+			return nil, ErrDuplicateExternalID
+		}
+		return nil, fmt.Errorf("failed to create transaction: %w", err)
+	}
+
+	return transaction, nil
+}
+
+// GetExistingExternalIDs returns which of the given external IDs are already
+// stored for the user and import source
+func (r *TransactionRepository) GetExistingExternalIDs(userID uuid.UUID, importSource string, externalIDs []string) (map[string]bool, error) {
+	existing := make(map[string]bool)
+	if len(externalIDs) == 0 {
+		return existing, nil
+	}
+
+	var ids []string
+	query := `
+		SELECT external_id
+		FROM transactions
+		WHERE user_id = $1 AND import_source = $2 AND external_id = ANY($3) AND is_deleted = false
+	`
+
+	err := r.db.Select(&ids, query, userID, importSource, pq.Array(externalIDs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get external ids: %w", err)
+	}
+
+	for _, id := range ids {
+		existing[id] = true
+	}
+	return existing, nil
+}
+
 func (r *TransactionRepository) GetByID(id uuid.UUID, userID uuid.UUID) (*models.Transaction, error) {
 	var transaction models.Transaction
 
 	query := `
-		SELECT id, user_id, account_id, category_id, type, amount, currency, note, transaction_date, created_at, updated_at, last_modified_at, version, is_deleted
+		SELECT id, user_id, account_id, category_id, payee_id, type, amount, currency, note, transaction_date, import_source, external_id, order_id, import_job_id, created_at, updated_at, last_modified_at, version, is_deleted
 		FROM transactions
 		WHERE id = $1 AND user_id = $2 AND is_deleted = false
 	`
@@ -89,6 +185,46 @@ func (r *TransactionRepository) GetByID(id uuid.UUID, userID uuid.UUID) (*models
 	return &transaction, nil
 }
 
+// GetByIDs returns the user's live transactions among the given IDs
+func (r *TransactionRepository) GetByIDs(userID uuid.UUID, ids []uuid.UUID) ([]models.Transaction, error) {
+	transactions := []models.Transaction{}
+	if len(ids) == 0 {
+		return transactions, nil
+	}
+
+	query := `
+		SELECT id, user_id, account_id, category_id, payee_id, type, amount, currency, note, transaction_date, import_source, external_id, order_id, import_job_id, created_at, updated_at, last_modified_at, version, is_deleted
+		FROM transactions
+		WHERE user_id = $1 AND id = ANY($2::uuid[]) AND is_deleted = false
+	`
+
+	err := r.db.Select(&transactions, query, userID, pq.Array(uuidStrings(ids)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transactions by ids: %w", err)
+	}
+
+	return transactions, nil
+}
+
+// GetByImportJob returns the live transactions created by an import job, oldest first
+func (r *TransactionRepository) GetByImportJob(userID uuid.UUID, jobID uuid.UUID) ([]models.Transaction, error) {
+	transactions := []models.Transaction{}
+
+	query := `
+		SELECT id, user_id, account_id, category_id, payee_id, type, amount, currency, note, transaction_date, import_source, external_id, order_id, import_job_id, created_at, updated_at, last_modified_at, version, is_deleted
+		FROM transactions
+		WHERE user_id = $1 AND import_job_id = $2 AND is_deleted = false
+		ORDER BY transaction_date ASC, created_at ASC
+	`
+
+	err := r.db.Select(&transactions, query, userID, jobID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transactions by import job: %w", err)
+	}
+
+	return transactions, nil
+}
+
 func (r *TransactionRepository) GetAll(userID uuid.UUID, limit int, offset int) ([]models.Transaction, error) {
 	var transactions []models.Transaction
 
@@ -97,7 +233,7 @@ func (r *TransactionRepository) GetAll(userID uuid.UUID, limit int, offset int)
 	}
 
 	query := `
-		SELECT id, user_id, account_id, category_id, type, amount, currency, note, transaction_date, created_at, updated_at, last_modified_at, version, is_deleted
+		SELECT id, user_id, account_id, category_id, payee_id, type, amount, currency, note, transaction_date, import_source, external_id, order_id, import_job_id, created_at, updated_at, last_modified_at, version, is_deleted
 		FROM transactions
 		WHERE user_id = $1 AND is_deleted = false
 		ORDER BY transaction_date DESC, created_at DESC
@@ -112,11 +248,35 @@ func (r *TransactionRepository) GetAll(userID uuid.UUID, limit int, offset int)
 	return transactions, nil
 }
 
+// GetByPayee returns the user's transactions with a payee, newest first
+func (r *TransactionRepository) GetByPayee(userID uuid.UUID, payeeID uuid.UUID, limit int, offset int) ([]models.Transaction, error) {
+	var transactions []models.Transaction
+
+	if limit <= 0 {
+		limit = 50
+	}
+
+	query := `
+		SELECT id, user_id, account_id, category_id, payee_id, type, amount, currency, note, transaction_date, import_source, external_id, order_id, import_job_id, created_at, updated_at, last_modified_at, version, is_deleted
+		FROM transactions
+		WHERE user_id = $1 AND payee_id = $2 AND is_deleted = false
+		ORDER BY transaction_date DESC, created_at DESC
+		LIMIT $3 OFFSET $4
+	`
+
+	err := r.db.Select(&transactions, query, userID, payeeID, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transactions by payee: %w", err)
+	}
+
+	return transactions, nil
+}
+
 func (r *TransactionRepository) GetByDateRange(userID uuid.UUID, start, end time.Time) ([]models.Transaction, error) {
 	var transactions []models.Transaction
 
 	query := `
-		SELECT id, user_id, account_id, category_id, type, amount, currency, note, transaction_date, created_at, updated_at, last_modified_at, version, is_deleted
+		SELECT id, user_id, account_id, category_id, payee_id, type, amount, currency, note, transaction_date, import_source, external_id, order_id, import_job_id, created_at, updated_at, last_modified_at, version, is_deleted
 		FROM transactions
 		WHERE user_id = $1 AND is_deleted = false
 		AND transaction_date >= $2 AND transaction_date <= $3
@@ -139,7 +299,7 @@ func (r *TransactionRepository) GetByAccount(userID uuid.UUID, accountID uuid.UU
 	}
 
 	query := `
-		SELECT id, user_id, account_id, category_id, type, amount, currency, note, transaction_date, created_at, updated_at, last_modified_at, version, is_deleted
+		SELECT id, user_id, account_id, category_id, payee_id, type, amount, currency, note, transaction_date, import_source, external_id, order_id, import_job_id, created_at, updated_at, last_modified_at, version, is_deleted
 		FROM transactions
 		WHERE user_id = $1 AND account_id = $2 AND is_deleted = false
 		ORDER BY transaction_date DESC, created_at DESC
@@ -154,6 +314,51 @@ func (r *TransactionRepository) GetByAccount(userID uuid.UUID, accountID uuid.UU
 	return transactions, nil
 }
 
+// GetByAccountAndDateRange returns all transactions of an account in a date range, oldest first
+func (r *TransactionRepository) GetByAccountAndDateRange(userID uuid.UUID, accountID uuid.UUID, start, end time.Time) ([]models.Transaction, error) {
+	var transactions []models.Transaction
+
+	query := `
+		SELECT id, user_id, account_id, category_id, payee_id, type, amount, currency, note, transaction_date, import_source, external_id, order_id, import_job_id, created_at, updated_at, last_modified_at, version, is_deleted
+		FROM transactions
+		WHERE user_id = $1 AND account_id = $2 AND is_deleted = false
+		AND transaction_date >= $3 AND transaction_date <= $4
+		ORDER BY transaction_date ASC, created_at ASC
+	`
+
+	err := r.db.Select(&transactions, query, userID, accountID, start.UTC(), end.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transactions by account and date range: %w", err)
+	}
+
+	return transactions, nil
+}
+
+// GetBalanceChangeSince returns the net effect on an account's balance of its
+// transactions dated at or after since. Linked transfers count by direction.
+func (r *TransactionRepository) GetBalanceChangeSince(userID uuid.UUID, accountID uuid.UUID, since time.Time) (float64, error) {
+	var change float64
+
+	query := `
+		SELECT COALESCE(SUM(CASE
+			WHEN t.type = 'income' THEN t.amount
+			WHEN t.type = 'expense' THEN -t.amount
+			WHEN EXISTS (SELECT 1 FROM transfer_links l WHERE l.to_transaction_id = t.id) THEN t.amount
+			WHEN EXISTS (SELECT 1 FROM transfer_links l WHERE l.from_transaction_id = t.id) THEN -t.amount
+			ELSE 0 END), 0)
+		FROM transactions t
+		WHERE t.user_id = $1 AND t.account_id = $2 AND t.is_deleted = false
+		AND t.transaction_date >= $3
+	`
+
+	err := r.db.Get(&change, query, userID, accountID, since.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("failed to get balance change: %w", err)
+	}
+
+	return change, nil
+}
+
 func (r *TransactionRepository) Update(transaction *models.Transaction, userID uuid.UUID) (*models.Transaction, error) {
 	now := time.Now().UTC()
 	transaction.UpdatedAt = now
@@ -162,12 +367,12 @@ func (r *TransactionRepository) Update(transaction *models.Transaction, userID u
 
 	query := `
 		UPDATE transactions
-		SET account_id = $1, category_id = $2, type = $3, amount = $4, currency = $5, note = $6, transaction_date = $7, updated_at = $8, last_modified_at = $9, version = $10
-		WHERE id = $11 AND user_id = $12 AND is_deleted = false
+		SET account_id = $1, category_id = $2, payee_id = $3, type = $4, amount = $5, currency = $6, note = $7, transaction_date = $8, updated_at = $9, last_modified_at = $10, version = $11
+		WHERE id = $12 AND user_id = $13 AND is_deleted = false
 	`
 
 	result, err := r.db.Exec(query,
-		transaction.AccountID, transaction.CategoryID, transaction.Type, transaction.Amount,
+		transaction.AccountID, transaction.CategoryID, transaction.PayeeID, transaction.Type, transaction.Amount,
 		transaction.Currency, transaction.Note, transaction.TransactionDate,
 		transaction.UpdatedAt, transaction.LastModifiedAt, transaction.Version,
 		transaction.ID, userID,
@@ -216,7 +421,7 @@ func (r *TransactionRepository) GetModifiedSince(userID uuid.UUID, since time.Ti
 	var transactions []models.Transaction
 
 	query := `
-		SELECT id, user_id, account_id, category_id, type, amount, currency, note, transaction_date, created_at, updated_at, last_modified_at, version, is_deleted
+		SELECT id, user_id, account_id, category_id, payee_id, type, amount, currency, note, transaction_date, import_source, external_id, order_id, import_job_id, created_at, updated_at, last_modified_at, version, is_deleted
 		FROM transactions
 		WHERE user_id = $1 AND last_modified_at > $2
 	`
@@ -241,11 +446,12 @@ func (r *TransactionRepository) CreateMany(transactions []models.Transaction) er
 	defer func() { _ = tx.Rollback() }()
 
 	query := `
-		INSERT INTO transactions (id, user_id, account_id, category_id, type, amount, currency, note, transaction_date, created_at, updated_at, last_modified_at, version, is_deleted)
-		VALUES (:id, :user_id, :account_id, :category_id, :type, :amount, :currency, :note, :transaction_date, :created_at, :updated_at, :last_modified_at, :version, :is_deleted)
+		INSERT INTO transactions (id, user_id, account_id, category_id, payee_id, type, amount, currency, note, transaction_date, created_at, updated_at, last_modified_at, version, is_deleted)
+		VALUES (:id, :user_id, :account_id, :category_id, :payee_id, :type, :amount, :currency, :note, :transaction_date, :created_at, :updated_at, :last_modified_at, :version, :is_deleted)
 		ON CONFLICT (id) DO UPDATE
 		SET account_id = EXCLUDED.account_id,
 		    category_id = EXCLUDED.category_id,
+		    payee_id = COALESCE(EXCLUDED.payee_id, transactions.payee_id),
 		    type = EXCLUDED.type,
 		    amount = EXCLUDED.amount,
 		    currency = EXCLUDED.currency,
@@ -272,14 +478,22 @@ func (r *TransactionRepository) CreateMany(transactions []models.Transaction) er
 	return nil
 }
 
+// refundJoin attaches the live purchase (p) of linked refunds. Stats count such
+// refunds as negative spending of the purchase instead of income.
+const refundJoin = `
+		LEFT JOIN refund_links rl ON rl.refund_transaction_id = t.id
+		LEFT JOIN transactions p ON p.id = rl.purchase_transaction_id AND p.is_deleted = false`
+
 func (r *TransactionRepository) GetStatsByDateRange(userID uuid.UUID, start, end time.Time) (incomeTotal float64, expenseTotal float64, err error) {
 	query := `
-		SELECT type, SUM(amount) as total
-		FROM transactions
-		WHERE user_id = $1 AND is_deleted = false
-		AND type IN ('income', 'expense')
-		AND transaction_date >= $2 AND transaction_date <= $3
-		GROUP BY type
+		SELECT
+			CASE WHEN p.id IS NOT NULL THEN 'expense' ELSE t.type END as type,
+			SUM(CASE WHEN p.id IS NOT NULL THEN -t.amount ELSE t.amount END) as total
+		FROM transactions t` + refundJoin + `
+		WHERE t.user_id = $1 AND t.is_deleted = false
+		AND t.type IN ('income', 'expense')
+		AND t.transaction_date >= $2 AND t.transaction_date <= $3
+		GROUP BY 1
 	`
 
 	rows, err := r.db.Query(query, userID, start.UTC(), end.UTC())
@@ -313,7 +527,7 @@ type CategoryStats struct {
 	TransactionCount int   `db:"transaction_count"`
 }
 
-// GetCategoryStats gets statistics grouped by category
+// GetCategoryStats gets statistics grouped by category; linked refunds reduce the purchase's category
 func (r *TransactionRepository) GetCategoryStats(userID uuid.UUID, start, end time.Time) ([]CategoryStats, error) {
 	var stats []CategoryStats
 
@@ -322,10 +536,10 @@ func (r *TransactionRepository) GetCategoryStats(userID uuid.UUID, start, end ti
 			c.id as category_id,
 			c.name as category_name,
 			c.type as category_type,
-			SUM(t.amount) as total_amount,
-			COUNT(t.id) as transaction_count
-		FROM transactions t
-		LEFT JOIN categories c ON t.category_id = c.id
+			SUM(CASE WHEN p.id IS NOT NULL THEN -t.amount ELSE t.amount END) as total_amount,
+			COUNT(t.id) FILTER (WHERE p.id IS NULL) as transaction_count
+		FROM transactions t` + refundJoin + `
+		LEFT JOIN categories c ON c.id = COALESCE(p.category_id, t.category_id)
 		WHERE t.user_id = $1
 			AND t.is_deleted = false
 			AND t.type IN ('income', 'expense')
@@ -343,6 +557,43 @@ func (r *TransactionRepository) GetCategoryStats(userID uuid.UUID, start, end ti
 	return stats, nil
 }
 
+// PayeeStats represents spending statistics for a single payee
+type PayeeStats struct {
+	PayeeID          uuid.UUID `db:"payee_id"`
+	PayeeName        string    `db:"payee_name"`
+	TotalAmount      float64   `db:"total_amount"`
+	TransactionCount int       `db:"transaction_count"`
+}
+
+// GetPayeeStats gets expense statistics grouped by payee, net of linked refunds; transactions without a payee are left out
+func (r *TransactionRepository) GetPayeeStats(userID uuid.UUID, start, end time.Time) ([]PayeeStats, error) {
+	var stats []PayeeStats
+
+	query := `
+		SELECT
+			py.id as payee_id,
+			py.name as payee_name,
+			SUM(CASE WHEN p.id IS NOT NULL THEN -t.amount ELSE t.amount END) as total_amount,
+			COUNT(t.id) FILTER (WHERE p.id IS NULL) as transaction_count
+		FROM transactions t` + refundJoin + `
+		JOIN payees py ON py.id = COALESCE(p.payee_id, t.payee_id)
+		WHERE t.user_id = $1
+			AND t.is_deleted = false
+			AND (t.type = 'expense' OR p.id IS NOT NULL)
+			AND t.transaction_date >= $2
+			AND t.transaction_date <= $3
+		GROUP BY py.id, py.name
+		ORDER BY total_amount DESC
+	`
+
+	err := r.db.Select(&stats, query, userID, start.UTC(), end.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get payee stats: %w", err)
+	}
+
+	return stats, nil
+}
+
 // MonthlyStats represents statistics for a single month
 type MonthlyStats struct {
 	Year         int     `db:"year"`
@@ -357,16 +608,16 @@ func (r *TransactionRepository) GetMonthlyTrend(userID uuid.UUID, start, end tim
 
 	query := `
 		SELECT
-			EXTRACT(YEAR FROM transaction_date)::int as year,
-			EXTRACT(MONTH FROM transaction_date)::int as month,
-			SUM(CASE WHEN type = 'income' THEN amount ELSE 0 END) as income_total,
-			SUM(CASE WHEN type = 'expense' THEN amount ELSE 0 END) as expense_total
-		FROM transactions
-		WHERE user_id = $1
-			AND is_deleted = false
-			AND type IN ('income', 'expense')
-			AND transaction_date >= $2
-			AND transaction_date <= $3
+			EXTRACT(YEAR FROM t.transaction_date)::int as year,
+			EXTRACT(MONTH FROM t.transaction_date)::int as month,
+			SUM(CASE WHEN t.type = 'income' AND p.id IS NULL THEN t.amount ELSE 0 END) as income_total,
+			SUM(CASE WHEN t.type = 'expense' THEN t.amount WHEN p.id IS NOT NULL THEN -t.amount ELSE 0 END) as expense_total
+		FROM transactions t` + refundJoin + `
+		WHERE t.user_id = $1
+			AND t.is_deleted = false
+			AND t.type IN ('income', 'expense')
+			AND t.transaction_date >= $2
+			AND t.transaction_date <= $3
 		GROUP BY year, month
 		ORDER BY year, month
 	`
@@ -379,6 +630,51 @@ func (r *TransactionRepository) GetMonthlyTrend(userID uuid.UUID, start, end tim
 	return stats, nil
 }
 
+// GetExpensesByOrderIDs returns the user's purchases carrying one of the given order IDs
+func (r *TransactionRepository) GetExpensesByOrderIDs(userID uuid.UUID, orderIDs []string) ([]models.Transaction, error) {
+	var transactions []models.Transaction
+	if len(orderIDs) == 0 {
+		return transactions, nil
+	}
+
+	query := `
+		SELECT id, user_id, account_id, category_id, payee_id, type, amount, currency, note, transaction_date, import_source, external_id, order_id, import_job_id, created_at, updated_at, last_modified_at, version, is_deleted
+		FROM transactions
+		WHERE user_id = $1 AND is_deleted = false AND type = 'expense'
+		AND order_id = ANY($2)
+		ORDER BY transaction_date DESC
+	`
+
+	err := r.db.Select(&transactions, query, userID, pq.Array(orderIDs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transactions by order id: %w", err)
+	}
+
+	return transactions, nil
+}
+
+// GetExpensesByPayee returns the user's purchases from a payee in a date range
+// of at least minAmount, newest first (refund candidates)
+func (r *TransactionRepository) GetExpensesByPayee(userID uuid.UUID, payeeID uuid.UUID, start, end time.Time, minAmount float64) ([]models.Transaction, error) {
+	var transactions []models.Transaction
+
+	query := `
+		SELECT id, user_id, account_id, category_id, payee_id, type, amount, currency, note, transaction_date, import_source, external_id, order_id, import_job_id, created_at, updated_at, last_modified_at, version, is_deleted
+		FROM transactions
+		WHERE user_id = $1 AND payee_id = $2 AND is_deleted = false AND type = 'expense'
+		AND transaction_date >= $3 AND transaction_date <= $4
+		AND amount >= $5
+		ORDER BY transaction_date DESC
+	`
+
+	err := r.db.Select(&transactions, query, userID, payeeID, start.UTC(), end.UTC(), minAmount)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transactions by payee: %w", err)
+	}
+
+	return transactions, nil
+}
+
 // GetByDateRangeAndAmount finds transactions within a date range and amount range (for duplicate detection)
 func (r *TransactionRepository) GetByDateRangeAndAmount(
 	userID uuid.UUID,
@@ -388,7 +684,7 @@ func (r *TransactionRepository) GetByDateRangeAndAmount(
 	var transactions []models.Transaction
 
 	query := `
-		SELECT id, user_id, account_id, category_id, type, amount, currency, note, transaction_date, created_at, updated_at, last_modified_at, version, is_deleted
+		SELECT id, user_id, account_id, category_id, payee_id, type, amount, currency, note, transaction_date, import_source, external_id, order_id, import_job_id, created_at, updated_at, last_modified_at, version, is_deleted
 		FROM transactions
 		WHERE user_id = $1 AND is_deleted = false
 		AND transaction_date >= $2 AND transaction_date <= $3
@@ -403,3 +699,38 @@ func (r *TransactionRepository) GetByDateRangeAndAmount(
 
 	return transactions, nil
 }
+
+// GetByDateRangeAndAmounts returns the transactions in a date range whose amount
+// is one of the given amounts, so that a batch of imported rows is checked for
+// duplicates with one query. Amounts are compared to the cent.
+func (r *TransactionRepository) GetByDateRangeAndAmounts(
+	userID uuid.UUID,
+	start, end time.Time,
+	amounts []float64,
+) ([]models.Transaction, error) {
+	var transactions []models.Transaction
+	if len(amounts) == 0 {
+		return transactions, nil
+	}
+
+	cents := make([]string, len(amounts))
+	for i, amount := range amounts {
+		cents[i] = strconv.FormatFloat(amount, 'f', 2, 64)
+	}
+
+	query := `
+		SELECT id, user_id, account_id, category_id, payee_id, type, amount, currency, note, transaction_date, import_source, external_id, order_id, import_job_id, created_at, updated_at, last_modified_at, version, is_deleted
+		FROM transactions
+		WHERE user_id = $1 AND is_deleted = false
+		AND transaction_date >= $2 AND transaction_date <= $3
+		AND amount = ANY($4::numeric[])
+		ORDER BY transaction_date DESC, created_at DESC
+	`
+
+	err := r.db.Select(&transactions, query, userID, start.UTC(), end.UTC(), pq.Array(cents))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transactions for duplicate check: %w", err)
+	}
+
+	return transactions, nil
+}
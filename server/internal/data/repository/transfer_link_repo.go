@@ -0,0 +1,297 @@
+package repository
+
+import (
+	"account/internal/business/models"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+var (
+	ErrTransferLinkNotFound = errors.New("transfer link not found")
+	// ErrTransferAlreadyLinked is returned when a transaction already belongs to a transfer
+	ErrTransferAlreadyLinked = errors.New("transaction is already part of a transfer")
+)
+
+type TransferLinkRepository struct {
+	db *sqlx.DB
+}
+
+func NewTransferLinkRepository(db *sqlx.DB) *TransferLinkRepository {
+	return &TransferLinkRepository{db: db}
+}
+
+// LinkTransactions turns an outgoing and an incoming transaction into the two
+// halves of one transfer. Both rows become transfers and get a new version so
+// that clients pick up the change on the next sync. A fee, if given, is split
+// off the outgoing half as an expense of its own, leaving the balance unchanged.
+func (r *TransferLinkRepository) LinkTransactions(link *models.TransferLink, fee *models.Transaction) (*models.TransferLink, error) {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	now := time.Now().UTC()
+	if err := convertToTransfers(tx, link.UserID, []uuid.UUID{link.FromTransactionID, link.ToTransactionID}, now); err != nil {
+		return nil, err
+	}
+
+	if fee != nil {
+		_, err = tx.Exec(`
+			UPDATE transactions SET amount = amount - $1 WHERE id = $2 AND user_id = $3
+		`, fee.Amount, link.FromTransactionID, link.UserID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to split transfer fee: %w", err)
+		}
+
+		fee.ID = uuid.New()
+		fee.Type = models.TransactionTypeExpense
+		fee.CreatedAt, fee.UpdatedAt, fee.LastModifiedAt = now, now, now
+		fee.Version = 1
+		_, err = tx.Exec(`
+			INSERT INTO transactions (id, user_id, account_id, category_id, type, amount, currency, note, transaction_date, created_at, updated_at, last_modified_at, version, is_deleted)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, false)
+		`, fee.ID, fee.UserID, fee.AccountID, fee.CategoryID, fee.Type, fee.Amount, fee.Currency, fee.Note,
+			fee.TransactionDate, fee.CreatedAt, fee.UpdatedAt, fee.LastModifiedAt, fee.Version)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create transfer fee: %w", err)
+		}
+		link.FeeTransactionID = &fee.ID
+	}
+
+	link.ID = uuid.New()
+	link.CreatedAt = now
+	_, err = tx.Exec(`
+		INSERT INTO transfer_links (id, user_id, from_transaction_id, to_transaction_id, fee_transaction_id, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, link.ID, link.UserID, link.FromTransactionID, link.ToTransactionID, link.FeeTransactionID, link.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create transfer link: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return link, nil
+}
+
+// LinkGroup links one outgoing transaction to several incoming ones, or several
+// outgoing transactions to one incoming one. Each leg is stored as a link of its
+// own between the single side and one transaction of the other side; the legs
+// share a group ID.
+func (r *TransferLinkRepository) LinkGroup(userID uuid.UUID, fromIDs []uuid.UUID, toIDs []uuid.UUID) ([]models.TransferLink, error) {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	now := time.Now().UTC()
+	if err := convertToTransfers(tx, userID, append(append([]uuid.UUID(nil), fromIDs...), toIDs...), now); err != nil {
+		return nil, err
+	}
+
+	groupID := uuid.New()
+	var links []models.TransferLink
+	for _, fromID := range fromIDs {
+		for _, toID := range toIDs {
+			links = append(links, models.TransferLink{
+				ID:                uuid.New(),
+				UserID:            userID,
+				FromTransactionID: fromID,
+				ToTransactionID:   toID,
+				GroupID:           &groupID,
+				CreatedAt:         now,
+			})
+		}
+	}
+	for _, link := range links {
+		_, err = tx.Exec(`
+			INSERT INTO transfer_links (id, user_id, from_transaction_id, to_transaction_id, group_id, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6)
+		`, link.ID, link.UserID, link.FromTransactionID, link.ToTransactionID, link.GroupID, link.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create transfer link: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return links, nil
+}
+
+// convertToTransfers checks that none of the transactions belongs to a transfer yet
+// and turns them all into transfers with a new version
+func convertToTransfers(tx *sqlx.Tx, userID uuid.UUID, transactionIDs []uuid.UUID, now time.Time) error {
+	ids := make([]string, len(transactionIDs))
+	for i, id := range transactionIDs {
+		ids[i] = id.String()
+	}
+
+	var linked int
+	err := tx.Get(&linked, `
+		SELECT COUNT(*) FROM transfer_links
+		WHERE user_id = $1 AND (from_transaction_id = ANY($2::uuid[]) OR to_transaction_id = ANY($2::uuid[]))
+	`, userID, pq.Array(ids))
+	if err != nil {
+		return fmt.Errorf("failed to check transfer links: %w", err)
+	}
+	if linked > 0 {
+		return ErrTransferAlreadyLinked
+	}
+
+	result, err := tx.Exec(`
+		UPDATE transactions
+		SET type = 'transfer', updated_at = $1, last_modified_at = $2, version = version + 1
+		WHERE id = ANY($3::uuid[]) AND user_id = $4 AND is_deleted = false
+	`, now, now, pq.Array(ids), userID)
+	if err != nil {
+		return fmt.Errorf("failed to convert transactions to transfer: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rows != int64(len(ids)) {
+		return ErrTransactionNotFound
+	}
+
+	return nil
+}
+
+// GetByTransactionIDs returns the links that either half of a transfer belongs to
+func (r *TransferLinkRepository) GetByTransactionIDs(userID uuid.UUID, transactionIDs []uuid.UUID) ([]models.TransferLink, error) {
+	links := []models.TransferLink{}
+	if len(transactionIDs) == 0 {
+		return links, nil
+	}
+
+	ids := make([]string, len(transactionIDs))
+	for i, id := range transactionIDs {
+		ids[i] = id.String()
+	}
+
+	query := `
+		SELECT id, user_id, from_transaction_id, to_transaction_id, fee_transaction_id, group_id, created_at
+		FROM transfer_links
+		WHERE user_id = $1 AND (from_transaction_id = ANY($2::uuid[]) OR to_transaction_id = ANY($2::uuid[]))
+		ORDER BY created_at
+	`
+
+	if err := r.db.Select(&links, query, userID, pq.Array(ids)); err != nil {
+		return nil, fmt.Errorf("failed to get transfer links: %w", err)
+	}
+
+	return links, nil
+}
+
+// GetByGroupIDs returns all legs of the given multi-leg transfers
+func (r *TransferLinkRepository) GetByGroupIDs(userID uuid.UUID, groupIDs []uuid.UUID) ([]models.TransferLink, error) {
+	links := []models.TransferLink{}
+	if len(groupIDs) == 0 {
+		return links, nil
+	}
+
+	query := `
+		SELECT id, user_id, from_transaction_id, to_transaction_id, fee_transaction_id, group_id, created_at
+		FROM transfer_links
+		WHERE user_id = $1 AND group_id = ANY($2::uuid[])
+		ORDER BY created_at
+	`
+
+	if err := r.db.Select(&links, query, userID, pq.Array(uuidStrings(groupIDs))); err != nil {
+		return nil, fmt.Errorf("failed to get transfer group links: %w", err)
+	}
+
+	return links, nil
+}
+
+// RecordFee stores a fee observed on a confirmed transfer
+func (r *TransferLinkRepository) RecordFee(fee *models.TransferFee) error {
+	fee.ID = uuid.New()
+	fee.CreatedAt = time.Now().UTC()
+
+	query := `
+		INSERT INTO transfer_fees (id, user_id, from_account_id, to_account_id, amount, fee, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+
+	if _, err := r.db.Exec(query, fee.ID, fee.UserID, fee.FromAccountID, fee.ToAccountID, fee.Amount, fee.Fee, fee.CreatedAt); err != nil {
+		return fmt.Errorf("failed to record transfer fee: %w", err)
+	}
+
+	return nil
+}
+
+// GetRecentFees returns up to perPair of the latest fees of each account pair, newest first
+func (r *TransferLinkRepository) GetRecentFees(userID uuid.UUID, perPair int) ([]models.TransferFee, error) {
+	fees := []models.TransferFee{}
+
+	query := `
+		SELECT id, user_id, from_account_id, to_account_id, amount, fee, created_at
+		FROM (
+			SELECT *, ROW_NUMBER() OVER (PARTITION BY from_account_id, to_account_id ORDER BY created_at DESC) AS rn
+			FROM transfer_fees
+			WHERE user_id = $1
+		) f
+		WHERE rn <= $2
+		ORDER BY from_account_id, to_account_id, created_at DESC
+	`
+
+	if err := r.db.Select(&fees, query, userID, perPair); err != nil {
+		return nil, fmt.Errorf("failed to get transfer fees: %w", err)
+	}
+
+	return fees, nil
+}
+
+// RecordMatchFeedback stores the user's verdict on a proposed transfer match
+func (r *TransferLinkRepository) RecordMatchFeedback(feedback *models.TransferMatchFeedback) error {
+	feedback.ID = uuid.New()
+	feedback.CreatedAt = time.Now().UTC()
+
+	query := `
+		INSERT INTO transfer_match_feedback (
+			id, user_id, tail_number_score, amount_score, time_score, name_score,
+			confidence, accepted, created_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`
+
+	_, err := r.db.Exec(query,
+		feedback.ID, feedback.UserID, feedback.TailNumber, feedback.Amount, feedback.Time, feedback.Name,
+		feedback.Confidence, feedback.Accepted, feedback.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record transfer match feedback: %w", err)
+	}
+
+	return nil
+}
+
+// GetRecentMatchFeedback returns the user's latest verdicts on transfer matches, newest first
+func (r *TransferLinkRepository) GetRecentMatchFeedback(userID uuid.UUID, limit int) ([]models.TransferMatchFeedback, error) {
+	feedback := []models.TransferMatchFeedback{}
+
+	query := `
+		SELECT id, user_id, tail_number_score, amount_score, time_score, name_score,
+			confidence, accepted, created_at
+		FROM transfer_match_feedback
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2
+	`
+
+	if err := r.db.Select(&feedback, query, userID, limit); err != nil {
+		return nil, fmt.Errorf("failed to get transfer match feedback: %w", err)
+	}
+
+	return feedback, nil
+}
@@ -3,11 +3,14 @@ package repository
 import (
 	"strings"
 
+	"github.com/google/uuid"
 	"github.com/lib/pq"
 )
 
 const (
 	uniqueViolation = "23505"
+	// externalIDIndex keeps a (user, source, external ID) triple unique among live transactions
+	externalIDIndex = "idx_transactions_external_id"
 )
 
 func isUniqueViolation(err error) bool {
@@ -16,3 +19,12 @@ func isUniqueViolation(err error) bool {
 	}
 	return strings.Contains(err.Error(), "duplicate key")
 }
+
+// uuidStrings converts IDs for use with pq.Array and ANY($n::uuid[])
+func uuidStrings(ids []uuid.UUID) []string {
+	strs := make([]string, len(ids))
+	for i, id := range ids {
+		strs[i] = id.String()
+	}
+	return strs
+}
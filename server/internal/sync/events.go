@@ -0,0 +1,80 @@
+package sync
+
+import (
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// Event is a message pushed to the connected devices of a user, such as the
+// progress of a batch import. Type becomes the WebSocket message type.
+type Event struct {
+	Type string
+	Data interface{}
+}
+
+// eventBuffer is how many events a slow device may fall behind before events
+// are dropped for it
+const eventBuffer = 64
+
+// EventNotifier fans events out to every connected device of a user
+type EventNotifier struct {
+	mu       sync.RWMutex
+	channels map[uuid.UUID]map[string]chan Event
+}
+
+func NewEventNotifier() *EventNotifier {
+	return &EventNotifier{
+		channels: make(map[uuid.UUID]map[string]chan Event),
+	}
+}
+
+// Subscribe registers a device for events
+func (n *EventNotifier) Subscribe(userID uuid.UUID, deviceID string) <-chan Event {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if _, ok := n.channels[userID]; !ok {
+		n.channels[userID] = make(map[string]chan Event)
+	}
+	// A device that reconnects replaces its earlier subscription
+	if old, ok := n.channels[userID][deviceID]; ok {
+		close(old)
+	}
+
+	ch := make(chan Event, eventBuffer)
+	n.channels[userID][deviceID] = ch
+	return ch
+}
+
+// Unsubscribe removes a device from events. ch is the channel Subscribe
+// returned, so that a connection that was replaced does not remove its successor.
+func (n *EventNotifier) Unsubscribe(userID uuid.UUID, deviceID string, ch <-chan Event) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if userChannels, ok := n.channels[userID]; ok {
+		if current, ok := userChannels[deviceID]; ok && (<-chan Event)(current) == ch {
+			close(current)
+			delete(userChannels, deviceID)
+		}
+		if len(userChannels) == 0 {
+			delete(n.channels, userID)
+		}
+	}
+}
+
+// Publish sends an event to all devices of the user. Devices that are not
+// keeping up miss the event rather than holding up the publisher.
+func (n *EventNotifier) Publish(userID uuid.UUID, event Event) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	for _, ch := range n.channels[userID] {
+		select {
+		case ch <- event:
+		default:
+			// Channel full, skip
+		}
+	}
+}
@@ -11,6 +11,8 @@ type Config struct {
 	Redis    RedisConfig
 	JWT      JWTConfig
 	Log      LogConfig
+	Import   ImportConfig
+	Tasks    TasksConfig
 }
 
 type ServerConfig struct {
@@ -46,6 +48,33 @@ type LogConfig struct {
 	Format string
 }
 
+// ImportConfig holds the thresholds for pairing transfers across imported files
+// and the category that fees split off confirmed transfers are booked to
+type ImportConfig struct {
+	TransferTimeWindowHours     int
+	TransferMaxAmountDifference float64
+	TransferMinConfidence       float64
+	TransferCandidateConfidence float64
+	TransferMaxCandidates       int
+	TransferMaxGroupSize        int
+	FeeCategory                 string
+	PreviewTTLHours             int
+	UploadMaxFileMB             int
+	UploadMaxTotalMB            int
+	UploadMaxFiles              int
+	UploadDir                   string
+}
+
+// TasksConfig tunes the background task queue that processes batch imports
+type TasksConfig struct {
+	Workers          int
+	PerUserLimit     int
+	MaxAttempts      int
+	LeaseSeconds     int
+	RetryBaseSeconds int
+	RetryMaxSeconds  int
+}
+
 func Load() *Config {
 	viper.SetDefault("server.port", "8080")
 	viper.SetDefault("server.mode", "debug")
@@ -62,6 +91,24 @@ func Load() *Config {
 	viper.SetDefault("jwt.expiry_hours", 24)
 	viper.SetDefault("log.level", "info")
 	viper.SetDefault("log.format", "json")
+	viper.SetDefault("import.transfer_time_window_hours", 24)
+	viper.SetDefault("import.transfer_max_amount_difference", 5.0)
+	viper.SetDefault("import.transfer_min_confidence", 0.8)
+	viper.SetDefault("import.transfer_candidate_confidence", 0.5)
+	viper.SetDefault("import.transfer_max_candidates", 3)
+	viper.SetDefault("import.transfer_max_group_size", 4)
+	viper.SetDefault("import.fee_category", "Bank Fees")
+	viper.SetDefault("import.preview_ttl_hours", 168)
+	viper.SetDefault("import.upload_max_file_mb", 50)
+	viper.SetDefault("import.upload_max_total_mb", 200)
+	viper.SetDefault("import.upload_max_files", 20)
+	viper.SetDefault("import.upload_dir", "")
+	viper.SetDefault("tasks.workers", 4)
+	viper.SetDefault("tasks.per_user_limit", 1)
+	viper.SetDefault("tasks.max_attempts", 3)
+	viper.SetDefault("tasks.lease_seconds", 300)
+	viper.SetDefault("tasks.retry_base_seconds", 30)
+	viper.SetDefault("tasks.retry_max_seconds", 1800)
 
 	viper.SetConfigName("config")
 	viper.SetConfigType("yaml")
@@ -100,6 +147,28 @@ func Load() *Config {
 			Level:  viper.GetString("log.level"),
 			Format: viper.GetString("log.format"),
 		},
+		Import: ImportConfig{
+			TransferTimeWindowHours:     viper.GetInt("import.transfer_time_window_hours"),
+			TransferMaxAmountDifference: viper.GetFloat64("import.transfer_max_amount_difference"),
+			TransferMinConfidence:       viper.GetFloat64("import.transfer_min_confidence"),
+			TransferCandidateConfidence: viper.GetFloat64("import.transfer_candidate_confidence"),
+			TransferMaxCandidates:       viper.GetInt("import.transfer_max_candidates"),
+			TransferMaxGroupSize:        viper.GetInt("import.transfer_max_group_size"),
+			FeeCategory:                 viper.GetString("import.fee_category"),
+			PreviewTTLHours:             viper.GetInt("import.preview_ttl_hours"),
+			UploadMaxFileMB:             viper.GetInt("import.upload_max_file_mb"),
+			UploadMaxTotalMB:            viper.GetInt("import.upload_max_total_mb"),
+			UploadMaxFiles:              viper.GetInt("import.upload_max_files"),
+			UploadDir:                   viper.GetString("import.upload_dir"),
+		},
+		Tasks: TasksConfig{
+			Workers:          viper.GetInt("tasks.workers"),
+			PerUserLimit:     viper.GetInt("tasks.per_user_limit"),
+			MaxAttempts:      viper.GetInt("tasks.max_attempts"),
+			LeaseSeconds:     viper.GetInt("tasks.lease_seconds"),
+			RetryBaseSeconds: viper.GetInt("tasks.retry_base_seconds"),
+			RetryMaxSeconds:  viper.GetInt("tasks.retry_max_seconds"),
+		},
 	}
 
 	log.Println("Configuration loaded successfully")
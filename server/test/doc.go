@@ -9,7 +9,30 @@ Test Structure:
   ├── mocks/              # Mock implementations
   │   └── repository_mocks.go  # Mock repositories for testing
   ├── unit/               # Unit tests
-  │   └── lww_strategy_test.go # LWW conflict resolution tests
+  │   ├── account_alias_test.go # Account alias resolution for imports
+  │   ├── batch_progress_test.go # Batch import progress reports and their delivery to devices
+  │   ├── batch_upload_test.go # Spooling and type sniffing of batch import uploads
+  │   ├── lww_strategy_test.go # LWW conflict resolution tests
+  │   ├── ofx_qif_parser_test.go # OFX/QIF import and export tests
+  │   ├── camt_mt940_parser_test.go # camt.053/MT940 statement import tests
+  │   ├── category_suggester_test.go # Learned category suggestion tests
+  │   ├── duplicate_detector_test.go # App bill / bank statement duplicate tests
+  │   ├── external_id_test.go # External IDs of imported rows across purchases, refunds and re-imports
+  │   ├── import_coverage_test.go # Import coverage periods, missing ranges and overlapping rows
+  │   ├── import_preview_test.go # Filtering and editing rows of stored import previews
+  │   ├── import_rollback_test.go # Undoing an import job: deletions, unlinked transfers, balances
+  │   ├── import_stream_test.go # Batched duplicate checks and streamed bill encoding detection
+  │   ├── parse_diagnostics_test.go # Skipped and partly parsed bill records and their CSV report
+  │   ├── payee_normalizer_test.go # Counterparty to payee normalisation tests
+  │   ├── reconciliation_test.go # Statement balance reconciliation culprit tests
+  │   ├── repayment_matcher_test.go # Credit card repayment and bill cycle tests
+  │   ├── refund_matcher_test.go # Refund detection and purchase linking tests
+  │   ├── rule_engine_test.go # Category rule engine tests
+  │   ├── task_queue_test.go # Background task retry backoff and permanent failures
+  │   ├── transfer_fee_test.go # Transfer fee pattern learning tests
+  │   ├── transfer_match_model_test.go # Learning match weights and thresholds from user feedback
+  │   ├── transfer_matcher_test.go # Global transfer pairing, candidate and grouping tests
+  │   └── zip_archive_test.go # Encrypted ZIP bill archive tests
   ├── api/                # API endpoint tests
   │   ├── auth_api_test.go      # Auth endpoint tests
   │   ├── account_api_test.go   # Account endpoint tests
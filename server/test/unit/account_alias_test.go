@@ -0,0 +1,81 @@
+package unit
+
+import (
+	"testing"
+
+	"account/internal/business/models"
+	"account/internal/business/services"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func aliasOf(account models.Account, alias string) models.AccountAlias {
+	return models.AccountAlias{
+		ID:        uuid.New(),
+		AccountID: account.ID,
+		Alias:     alias,
+		AliasKey:  services.NormalizeAccountAliasKey(alias),
+	}
+}
+
+func TestAccountAliasLabel(t *testing.T) {
+	assert.Equal(t, "招行信用卡(1234)", services.AccountAliasLabel("招行信用卡", "尾号1234"))
+	assert.Equal(t, "招商银行信用卡(1234)", services.AccountAliasLabel("招商银行信用卡(1234)", "1234"))
+	assert.Equal(t, "CMB 1234", services.AccountAliasLabel("CMB 1234", "****1234"))
+	assert.Equal(t, "", services.AccountAliasLabel("", "1234"))
+	assert.Equal(t, services.NormalizeAccountAliasKey("cmb1234"), services.NormalizeAccountAliasKey("ＣＭＢ 1234"))
+}
+
+func TestAccountAliasResolver(t *testing.T) {
+	card := models.Account{ID: uuid.New(), Name: "招商银行信用卡(1234)", Type: models.AccountTypeCredit, TailNumber: "1234"}
+	debit := models.Account{ID: uuid.New(), Name: "招商银行", Type: models.AccountTypeBank, TailNumber: "5678"}
+	deleted := models.Account{ID: uuid.New(), Name: "旧卡", IsDeleted: true}
+	resolver := services.NewAccountAliasResolver(
+		[]models.Account{card, debit, deleted},
+		[]models.AccountAlias{aliasOf(card, "招行信用卡"), aliasOf(card, "CMB 1234"), aliasOf(deleted, "旧卡别名")},
+	)
+
+	for _, c := range []struct{ name, tail string }{
+		{"招商银行信用卡(1234)", ""},
+		{"招行信用卡", "1234"},
+		{"招行信用卡", ""},
+		{"cmb 1234", ""},
+	} {
+		found := resolver.Resolve(c.name, c.tail)
+		require.NotNil(t, found, c.name)
+		assert.Equal(t, card.ID, found.ID, c.name)
+	}
+
+	// The account's own name with its tail number
+	found := resolver.Resolve("招商银行", "5678")
+	require.NotNil(t, found)
+	assert.Equal(t, debit.ID, found.ID)
+
+	// Another card of the same bank is not one of these accounts
+	assert.Nil(t, resolver.Resolve("招商银行", "9999"))
+	assert.Nil(t, resolver.Resolve("招行信用卡", "4321"))
+	// Deleted accounts and their aliases are ignored
+	assert.Nil(t, resolver.Resolve("旧卡别名", ""))
+	assert.Nil(t, resolver.Resolve("", "1234"))
+}
+
+func TestAccountAliasResolverApplyToParsed(t *testing.T) {
+	card := models.Account{ID: uuid.New(), Name: "招商银行信用卡", TailNumber: "1234"}
+	resolver := services.NewAccountAliasResolver([]models.Account{card}, []models.AccountAlias{aliasOf(card, "招行信用卡")})
+
+	row := models.ParsedTransaction{AccountName: "招行信用卡", ParsedAccountNumber: "1234"}
+	resolver.ApplyToParsed(&row)
+	require.NotNil(t, row.SelectedAccountID)
+	assert.Equal(t, card.ID, *row.SelectedAccountID)
+	require.NotNil(t, row.SuggestedAccountID, "the resolved account is recorded as a suggestion, not learned again")
+	assert.Equal(t, card.ID, *row.SuggestedAccountID)
+
+	// An account already chosen is kept
+	chosen := uuid.New()
+	row = models.ParsedTransaction{AccountName: "招行信用卡", SelectedAccountID: &chosen}
+	resolver.ApplyToParsed(&row)
+	assert.Equal(t, chosen, *row.SelectedAccountID)
+	assert.Nil(t, row.SuggestedAccountID)
+}
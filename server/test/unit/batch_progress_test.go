@@ -0,0 +1,85 @@
+package unit
+
+import (
+	"testing"
+
+	"account/internal/business/models"
+	"account/internal/business/services"
+	"account/internal/sync"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildBatchImportProgress(t *testing.T) {
+	state := &services.BatchJobDetail{
+		Job: models.BatchImportJob{
+			ID:          uuid.New(),
+			Status:      models.BatchImportStatusParsing,
+			TotalFiles:  4,
+			ParsedFiles: 1,
+		},
+		Files: []models.BatchImportFile{
+			{FileName: "alipay.csv", Status: models.FileImportStatusParsed, ParsedRows: 120},
+			{FileName: "photo.csv", Status: models.FileImportStatusFailed, ParseErrors: []string{"no data in file"}},
+			{FileName: "bank.csv", Status: models.FileImportStatusParsing, ParsedRows: 500, BytesRead: 300, TotalBytes: 600},
+			{FileName: "wechat.csv", Status: models.FileImportStatusPending},
+		},
+	}
+
+	progress := services.BuildBatchImportProgress(state, models.BatchImportEventRowsProcessed, "")
+
+	assert.Equal(t, "bank.csv", progress.CurrentFile)
+	assert.Equal(t, 3, progress.CurrentFileIndex)
+	assert.Equal(t, int64(300), progress.BytesRead)
+	assert.Equal(t, int64(600), progress.TotalBytes)
+	assert.Equal(t, 620, progress.RowsProcessed)
+	assert.Equal(t, 1, progress.ParsedFiles)
+	assert.Equal(t, 1, progress.FailedFiles)
+	assert.Equal(t, []models.BatchImportError{{FileName: "photo.csv", Message: "no data in file"}}, progress.Errors)
+	// Two and a half of four files through the parsing step, which is a sixth of the job
+	assert.InDelta(t, 10.4, progress.PercentComplete, 0.01)
+
+	// Once parsed, the step percentage is the job's
+	state.Job.Status = models.BatchImportStatusReadyToImport
+	state.Files[2].Status = models.FileImportStatusAnalyzed
+	state.Files[3].Status = models.FileImportStatusAnalyzed
+	progress = services.BuildBatchImportProgress(state, models.BatchImportEventCompleted, "")
+	assert.Empty(t, progress.CurrentFile)
+	assert.InDelta(t, 66.7, progress.PercentComplete, 0.01)
+}
+
+func TestEventNotifier(t *testing.T) {
+	notifier := sync.NewEventNotifier()
+	userID := uuid.New()
+
+	phone := notifier.Subscribe(userID, "phone")
+	laptop := notifier.Subscribe(userID, "laptop")
+	other := notifier.Subscribe(uuid.New(), "phone")
+
+	notifier.Publish(userID, sync.Event{Type: services.EventTypeImportProgress, Data: 1})
+	for _, ch := range []<-chan sync.Event{phone, laptop} {
+		select {
+		case event := <-ch:
+			assert.Equal(t, services.EventTypeImportProgress, event.Type)
+		default:
+			t.Fatal("event not delivered")
+		}
+	}
+	assert.Len(t, other, 0, "events go only to the user's devices")
+
+	// A reconnect replaces the old subscription; the old connection's unsubscribe
+	// leaves the new one in place
+	reconnected := notifier.Subscribe(userID, "phone")
+	_, open := <-phone
+	assert.False(t, open)
+	notifier.Unsubscribe(userID, "phone", phone)
+	notifier.Publish(userID, sync.Event{Type: services.EventTypeImportProgress})
+	require.Len(t, reconnected, 1)
+
+	<-reconnected
+	notifier.Unsubscribe(userID, "phone", reconnected)
+	_, open = <-reconnected
+	assert.False(t, open)
+}
@@ -0,0 +1,57 @@
+package unit
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"account/internal/business/services"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+func TestSpoolUpload(t *testing.T) {
+	path, size, err := services.SpoolUpload(t.TempDir(), strings.NewReader(alipayBillSample), 1024)
+	require.NoError(t, err)
+	defer os.Remove(path)
+
+	assert.Equal(t, int64(len(alipayBillSample)), size)
+	stored, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, alipayBillSample, string(stored))
+
+	// A file of exactly the limit is accepted, one byte more is not
+	path, _, err = services.SpoolUpload(t.TempDir(), strings.NewReader(alipayBillSample), int64(len(alipayBillSample)))
+	require.NoError(t, err)
+	os.Remove(path)
+	_, _, err = services.SpoolUpload(t.TempDir(), strings.NewReader(alipayBillSample), int64(len(alipayBillSample))-1)
+	assert.ErrorIs(t, err, services.ErrUploadTooLarge)
+}
+
+func TestSniffUpload(t *testing.T) {
+	gbk, err := simplifiedchinese.GBK.NewEncoder().String(alipayBillSample)
+	require.NoError(t, err)
+
+	accepted := map[string][]byte{
+		"alipay.csv": []byte(alipayBillSample),
+		"bank.csv":   []byte(gbk),
+		"camt.xml":   []byte(`<?xml version="1.0" encoding="UTF-8"?><Document>`),
+		"bank.ofx":   []byte("OFXHEADER:100\nDATA:OFXSGML\n<OFX>"),
+		"bills.zip":  []byte("PK\x03\x04\x14\x00\x00\x00\x08\x00"),
+		"empty.csv":  {},
+	}
+	for name, head := range accepted {
+		assert.NoError(t, services.SniffUpload(name, head), name)
+	}
+
+	rejected := map[string][]byte{
+		"photo.png": []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"),
+		"bill.pdf":  []byte("%PDF-1.7\n"),
+		"bill.xls":  []byte("\xd0\xcf\x11\xe0\xa1\xb1\x1a\xe1\x00\x00"),
+	}
+	for name, head := range rejected {
+		assert.ErrorIs(t, services.SniffUpload(name, head), services.ErrUnsupportedFileType, name)
+	}
+}
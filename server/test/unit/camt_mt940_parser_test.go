@@ -0,0 +1,168 @@
+package unit
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"account/internal/business/models"
+	"account/internal/business/services"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const camt053Sample = `<?xml version="1.0" encoding="UTF-8"?>
+<Document xmlns="urn:iso:std:iso:20022:tech:xsd:camt.053.001.02">
+  <BkToCstmrStmt>
+    <GrpHdr><MsgId>STMT-20240131</MsgId><CreDtTm>2024-01-31T18:00:00</CreDtTm></GrpHdr>
+    <Stmt>
+      <Id>STMT-1</Id>
+      <Acct>
+        <Id><IBAN>DE89370400440532013000</IBAN></Id>
+        <Ccy>EUR</Ccy>
+        <Svcr><FinInstnId><BIC>COBADEFFXXX</BIC></FinInstnId></Svcr>
+      </Acct>
+      <Bal>
+        <Tp><CdOrPrtry><Cd>OPBD</Cd></CdOrPrtry></Tp>
+        <Amt Ccy="EUR">1000.00</Amt><CdtDbtInd>CRDT</CdtDbtInd>
+        <Dt><Dt>2024-01-31</Dt></Dt>
+      </Bal>
+      <Bal>
+        <Tp><CdOrPrtry><Cd>CLBD</Cd></CdOrPrtry></Tp>
+        <Amt Ccy="EUR">2250.00</Amt><CdtDbtInd>CRDT</CdtDbtInd>
+        <Dt><Dt>2024-01-31</Dt></Dt>
+      </Bal>
+      <Ntry>
+        <Amt Ccy="EUR">1500.00</Amt><CdtDbtInd>CRDT</CdtDbtInd><Sts>BOOK</Sts>
+        <BookgDt><Dt>2024-01-31</Dt></BookgDt>
+        <ValDt><Dt>2024-02-01</Dt></ValDt>
+        <AcctSvcrRef>REF-0001</AcctSvcrRef>
+        <NtryDtls><TxDtls>
+          <RltdPties>
+            <Dbtr><Nm>Acme GmbH</Nm></Dbtr>
+            <DbtrAcct><Id><IBAN>FR1420041010050500013M02606</IBAN></Id></DbtrAcct>
+          </RltdPties>
+          <RmtInf><Ustrd>Invoice 2024-001</Ustrd></RmtInf>
+        </TxDtls></NtryDtls>
+      </Ntry>
+      <Ntry>
+        <Amt Ccy="EUR">250.00</Amt><CdtDbtInd>DBIT</CdtDbtInd><Sts>BOOK</Sts>
+        <BookgDt><Dt>2024-01-31</Dt></BookgDt>
+        <ValDt><Dt>2024-01-31</Dt></ValDt>
+        <AcctSvcrRef>REF-0002</AcctSvcrRef>
+        <NtryDtls><TxDtls>
+          <RltdPties>
+            <Cdtr><Nm>Office Supplies Ltd</Nm></Cdtr>
+            <CdtrAcct><Id><IBAN>GB29NWBK60161331926819</IBAN></Id></CdtrAcct>
+          </RltdPties>
+          <RmtInf><Ustrd>Order 7781</Ustrd></RmtInf>
+        </TxDtls></NtryDtls>
+      </Ntry>
+      <Ntry>
+        <Amt Ccy="EUR">99.00</Amt><CdtDbtInd>DBIT</CdtDbtInd><Sts>PDNG</Sts>
+        <BookgDt><Dt>2024-01-31</Dt></BookgDt>
+      </Ntry>
+    </Stmt>
+  </BkToCstmrStmt>
+</Document>
+`
+
+const mt940Sample = `{1:F01COBADEFFAXXX0000000000}{2:O9400000240131COBADEFFAXXX00000000002401311800N}{4:
+:20:STARTUMS
+:25:COBADEFF/0532013000
+:28C:00001/001
+:60F:C240130EUR1000,00
+:61:2401310131DR250,00NTRFNONREF//B4A31XY
+:86:177?00SEPA-UEBERWEISUNG?20EREF+7781?21SVWZ+Order 7781?30NWBKGB2L?31GB29NWBK60161331926819?32Office Supplies Ltd
+:61:2402010131CR1500,00NTRFINV2024001//B4A31XZ
+/OCMT/EUR1500,00/
+:86:/NAME/Acme GmbH/IBAN/FR1420041010050500013M02606/REMI/Invoice 2024-001/
+:62F:C240131EUR2250,00
+-}
+`
+
+func TestParseCamt053(t *testing.T) {
+	stmt, err := services.ParseCamt053(strings.NewReader(camt053Sample))
+	require.NoError(t, err)
+	require.Len(t, stmt.Transactions, 2, "pending entries are skipped")
+	require.Len(t, stmt.AccountHints, 2)
+
+	income := stmt.Transactions[0]
+	assert.Equal(t, models.TransactionTypeIncome, income.Type)
+	assert.Equal(t, 1500.00, income.Amount)
+	assert.Equal(t, "EUR", income.Currency)
+	assert.Equal(t, "Acme GmbH", income.Counterparty)
+	assert.Equal(t, "FR1420041010050500013M02606", income.RelatedAccountNumber)
+	assert.Equal(t, "Invoice 2024-001", income.Note)
+	assert.Equal(t, "DE89370400440532013000:REF-0001", income.ExternalID)
+	assert.Equal(t, "3000", income.ParsedAccountNumber)
+	assert.Equal(t, time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC), income.TransactionDate)
+	require.NotNil(t, income.ValueDate)
+	assert.Equal(t, "2024-02-01", income.ValueDate.Format("2006-01-02"))
+
+	expense := stmt.Transactions[1]
+	assert.Equal(t, models.TransactionTypeExpense, expense.Type)
+	assert.Equal(t, "Office Supplies Ltd", expense.Counterparty)
+	assert.Equal(t, "GB29NWBK60161331926819", expense.RelatedAccountNumber)
+	assert.Nil(t, expense.ValueDate, "value date equal to booking date is omitted")
+
+	assertStatementReconciles(t, stmt)
+}
+
+func TestParseMT940(t *testing.T) {
+	stmt, err := services.ParseMT940(strings.NewReader(mt940Sample))
+	require.NoError(t, err)
+	require.Len(t, stmt.Transactions, 2)
+	require.Len(t, stmt.AccountHints, 2)
+
+	expense := stmt.Transactions[0]
+	assert.Equal(t, models.TransactionTypeExpense, expense.Type)
+	assert.Equal(t, 250.00, expense.Amount)
+	assert.Equal(t, "EUR", expense.Currency)
+	assert.Equal(t, "Office Supplies Ltd", expense.Counterparty)
+	assert.Equal(t, "GB29NWBK60161331926819", expense.RelatedAccountNumber)
+	assert.Equal(t, "EREF+7781SVWZ+Order 7781", expense.Note)
+	assert.Equal(t, "0532013000:B4A31XY", expense.ExternalID)
+	assert.Equal(t, "COBADEFF", expense.ParsedBankName)
+
+	income := stmt.Transactions[1]
+	assert.Equal(t, models.TransactionTypeIncome, income.Type)
+	assert.Equal(t, "Acme GmbH", income.Counterparty)
+	assert.Equal(t, "Invoice 2024-001", income.Note)
+	assert.Equal(t, "0532013000:B4A31XZ", income.ExternalID)
+	assert.Equal(t, time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC), income.TransactionDate)
+	require.NotNil(t, income.ValueDate)
+	assert.Equal(t, "2024-02-01", income.ValueDate.Format("2006-01-02"))
+
+	assert.Equal(t, models.BalanceTypeOpening, stmt.AccountHints[0].BalanceType)
+	assert.Equal(t, models.BalanceTypeClosing, stmt.AccountHints[1].BalanceType)
+	assertStatementReconciles(t, stmt)
+}
+
+// assertStatementReconciles checks opening + entries = closing
+func assertStatementReconciles(t *testing.T, stmt *services.ParsedStatement) {
+	t.Helper()
+
+	var opening, closing *models.AccountHint
+	for i := range stmt.AccountHints {
+		switch stmt.AccountHints[i].BalanceType {
+		case models.BalanceTypeOpening:
+			opening = &stmt.AccountHints[i]
+		case models.BalanceTypeClosing:
+			closing = &stmt.AccountHints[i]
+		}
+	}
+	require.NotNil(t, opening)
+	require.NotNil(t, closing)
+
+	balance := opening.Balance
+	for _, tx := range stmt.Transactions {
+		if tx.Type == models.TransactionTypeIncome {
+			balance += tx.Amount
+		} else {
+			balance -= tx.Amount
+		}
+	}
+	assert.InDelta(t, closing.Balance, balance, 0.001)
+}
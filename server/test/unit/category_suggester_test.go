@@ -0,0 +1,87 @@
+package unit
+
+import (
+	"testing"
+	"time"
+
+	"account/internal/business/models"
+	"account/internal/business/services"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenizeText(t *testing.T) {
+	assert.Equal(t,
+		[]string{"瑞幸", "幸咖", "咖啡", "latte", "订单", "店"},
+		services.TokenizeText("瑞幸咖啡 Latte x2 订单20240115 店"),
+	)
+}
+
+func TestNaiveBayesClassifier_LearnAndForget(t *testing.T) {
+	food := uuid.New()
+	transport := uuid.New()
+	noon := time.Date(2024, 1, 15, 12, 30, 0, 0, time.UTC)
+	morning := time.Date(2024, 1, 15, 8, 10, 0, 0, time.UTC)
+
+	classifier := services.NewNaiveBayesClassifier()
+	learn := func(note string, amount float64, at time.Time, category uuid.UUID) {
+		classifier.Update(services.CategoryFeatures(services.CategoryExample{
+			Type: models.TransactionTypeExpense, Note: note, Amount: amount, Date: at,
+		}), category, 1)
+	}
+	learn("美团外卖 黄焖鸡米饭", 25, noon, food)
+	learn("饿了么 麻辣烫", 30, noon, food)
+	learn("瑞幸咖啡", 18, morning, food)
+	learn("滴滴出行 快车", 35, morning, transport)
+	learn("北京地铁", 5, morning, transport)
+	learn("滴滴出行 特惠快车", 28, morning, transport)
+
+	suggestions := classifier.Predict(services.CategoryFeatures(services.CategoryExample{
+		Type: models.TransactionTypeExpense, Note: "滴滴出行", Amount: 40, Date: morning,
+	}), 3)
+	require.Len(t, suggestions, 2)
+	assert.Equal(t, transport, suggestions[0].CategoryID)
+	assert.Greater(t, suggestions[0].Confidence, 0.8)
+	assert.InDelta(t, 1.0, suggestions[0].Confidence+suggestions[1].Confidence, 0.002)
+
+	// Correcting the category moves the example to the other class
+	features := services.CategoryFeatures(services.CategoryExample{
+		Type: models.TransactionTypeExpense, Note: "北京地铁", Amount: 5, Date: morning,
+	})
+	classifier.Update(features, transport, -1)
+	classifier.Update(features, food, 1)
+	suggestions = classifier.Predict(features, 1)
+	require.Len(t, suggestions, 1)
+	assert.Equal(t, food, suggestions[0].CategoryID)
+
+	// Text never seen in training gives no suggestion
+	assert.Empty(t, classifier.Predict(services.CategoryFeatures(services.CategoryExample{
+		Type: models.TransactionTypeExpense, Note: "物业费",
+	}), 3))
+}
+
+func TestCategoryFeatures_PayeeMatchesStoredTransactions(t *testing.T) {
+	food := uuid.New()
+	transport := uuid.New()
+	canteen := uuid.New()
+	noon := time.Date(2024, 1, 15, 12, 30, 0, 0, time.UTC)
+
+	classifier := services.NewNaiveBayesClassifier()
+	for i := 0; i < 3; i++ {
+		classifier.Update(services.CategoryFeatures(services.ExampleFromTransaction(models.Transaction{
+			Type: models.TransactionTypeExpense, PayeeID: &canteen, Note: "扫码付款", Amount: 15, TransactionDate: noon,
+		})), food, 1)
+		classifier.Update(services.CategoryFeatures(services.ExampleFromTransaction(models.Transaction{
+			Type: models.TransactionTypeExpense, Note: "扫码付款 公交", Amount: 15, TransactionDate: noon,
+		})), transport, 1)
+	}
+
+	// An imported row is predicted from the payee it resolved to, as stored transactions were learned
+	suggestions := classifier.Predict(services.CategoryFeatures(services.CategoryExample{
+		Type: models.TransactionTypeExpense, PayeeID: &canteen, Note: "扫码付款", Amount: 15, Date: noon,
+	}), 1)
+	require.Len(t, suggestions, 1)
+	assert.Equal(t, food, suggestions[0].CategoryID)
+}
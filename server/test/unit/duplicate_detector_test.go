@@ -0,0 +1,79 @@
+package unit
+
+import (
+	"testing"
+	"time"
+
+	"account/internal/business/models"
+	"account/internal/business/services"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindDuplicates_AppBillAndBankStatement(t *testing.T) {
+	lunch := time.Date(2024, 1, 15, 12, 3, 0, 0, time.UTC)
+	txs := []models.ParsedTransaction{
+		{
+			Source: models.ImportSourceAlipay, LineNumber: 1, ExternalID: "2024011522001",
+			TransactionDate: lunch, Type: models.TransactionTypeExpense, Amount: 35.00,
+			Counterparty: "瑞幸咖啡", Note: "生椰拿铁 x2", AccountName: "招商银行储蓄卡(1234)",
+		},
+		{
+			// Paid from Alipay balance: never reaches the bank statement
+			Source: models.ImportSourceAlipay, LineNumber: 2, ExternalID: "2024011522002",
+			TransactionDate: lunch, Type: models.TransactionTypeExpense, Amount: 88.00,
+			Counterparty: "永辉超市", AccountName: "余额",
+		},
+		{
+			Source: models.ImportSourceBank, LineNumber: 1, ExternalID: "B0001",
+			TransactionDate: lunch.Add(2 * time.Hour), Type: models.TransactionTypeExpense, Amount: 35.00,
+			Counterparty: "支付宝-瑞幸咖啡", Note: "快捷支付", AccountName: "招商银行借记卡",
+			ParsedAccountNumber: "1234", ParsedBankName: "招商银行", ParsedAccountType: "debit_card",
+		},
+		{
+			Source: models.ImportSourceBank, LineNumber: 2, ExternalID: "B0002",
+			TransactionDate: lunch, Type: models.TransactionTypeExpense, Amount: 88.00,
+			Counterparty: "支付宝-永辉超市", ParsedAccountNumber: "1234",
+		},
+		{
+			// Same amount and day, but a different card
+			Source: models.ImportSourceBank, LineNumber: 1, ExternalID: "C0001",
+			TransactionDate: lunch, Type: models.TransactionTypeExpense, Amount: 35.00,
+			Counterparty: "支付宝-瑞幸咖啡", ParsedAccountNumber: "9876",
+		},
+	}
+
+	duplicates := services.NewDuplicateDetector().FindDuplicates(txs)
+	require.Len(t, duplicates, 1)
+
+	dup := duplicates[0]
+	assert.Equal(t, "2024011522001", dup.AppTransaction.ExternalID)
+	assert.Equal(t, "B0001", dup.BankTransaction.ExternalID)
+	assert.GreaterOrEqual(t, dup.Confidence, 0.9)
+	assert.Contains(t, dup.MatchFactors, "支付方式尾号与银行账户尾号一致")
+
+	// Merchant detail from the app, account from the bank
+	assert.Equal(t, "瑞幸咖啡", dup.Merged.Counterparty)
+	assert.Equal(t, "1234", dup.Merged.ParsedAccountNumber)
+	assert.Equal(t, "招商银行", dup.Merged.ParsedBankName)
+	assert.Equal(t, "招商银行借记卡", dup.Merged.AccountName)
+	assert.Contains(t, dup.Merged.Note, "生椰拿铁")
+	assert.Equal(t, dup.NoteMerge.MergedNote, dup.Merged.Note)
+}
+
+func TestFindDuplicates_OutsideTimeWindow(t *testing.T) {
+	day := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	txs := []models.ParsedTransaction{
+		{
+			Source: models.ImportSourceWeChat, TransactionDate: day, Type: models.TransactionTypeExpense,
+			Amount: 20.00, Counterparty: "美团外卖", AccountName: "工商银行信用卡(5678)",
+		},
+		{
+			Source: models.ImportSourceBank, TransactionDate: day.AddDate(0, 0, 5), Type: models.TransactionTypeExpense,
+			Amount: 20.00, Counterparty: "财付通-美团外卖", ParsedAccountNumber: "5678",
+		},
+	}
+
+	assert.Empty(t, services.NewDuplicateDetector().FindDuplicates(txs))
+}
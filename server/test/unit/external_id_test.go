@@ -0,0 +1,65 @@
+package unit
+
+import (
+	"strings"
+	"testing"
+
+	"account/internal/business/models"
+	"account/internal/business/services"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func jdExternalIDs(t *testing.T, lines ...string) []string {
+	t.Helper()
+	content := strings.Join(append([]string{"订单号,消费时间,金额,商品名称,订单状态"}, lines...), "\n")
+	rows, _ := parseCSVRecords(t, models.ImportSourceJD, content)
+	require.Len(t, rows, len(lines))
+	services.AssignExternalIDs(models.ImportSourceJD, rows)
+
+	ids := make([]string, len(rows))
+	for i, tx := range rows {
+		ids[i] = tx.ExternalID
+	}
+	return ids
+}
+
+func TestAssignExternalIDs(t *testing.T) {
+	purchase := "\t100200300,2026-03-01 10:00:00,199.00,耳机,已完成"
+	refund := "\t100200300,2026-03-05 15:30:00,199.00,耳机,退款成功"
+	other := "=\"100200399\",2026-03-02 09:00:00,12.50,数据线,已完成"
+
+	full := jdExternalIDs(t, purchase, other, refund)
+
+	t.Run("an order's purchase and refund get distinct IDs", func(t *testing.T) {
+		assert.True(t, strings.HasPrefix(full[0], "100200300#"), full[0])
+		assert.True(t, strings.HasPrefix(full[1], "100200399#"), "padding and spreadsheet quoting are trimmed: %s", full[1])
+		assert.NotEqual(t, full[0], full[2])
+	})
+
+	t.Run("IDs do not depend on the other rows of the file", func(t *testing.T) {
+		assert.Equal(t, full, jdExternalIDs(t, purchase, other, refund))
+		assert.Equal(t, []string{full[2]}, jdExternalIDs(t, refund), "a later file with only the refund must not collide with the purchase")
+		assert.Equal(t, []string{full[2], full[0]}, jdExternalIDs(t, refund, purchase))
+	})
+
+	t.Run("identical rows are numbered", func(t *testing.T) {
+		ids := jdExternalIDs(t, purchase, purchase)
+		assert.Equal(t, full[0], ids[0])
+		assert.Equal(t, full[0]+"#2", ids[1])
+	})
+}
+
+func TestAssignExternalIDsKeepsStatementIDs(t *testing.T) {
+	rows := []models.ParsedTransaction{
+		{ExternalID: "ofx:1", Type: models.TransactionTypeExpense, Amount: 10, TransactionDate: coverageDate(2026, 3, 1)},
+		{ExternalID: "ofx:2", Type: models.TransactionTypeExpense, Amount: 10, TransactionDate: coverageDate(2026, 3, 1)},
+		{ExternalID: "ofx:1", Type: models.TransactionTypeIncome, Amount: 10, TransactionDate: coverageDate(2026, 3, 2)},
+	}
+	services.AssignExternalIDs(models.ImportSourceOFX, rows)
+
+	assert.Equal(t, "ofx:1", rows[0].ExternalID)
+	assert.Equal(t, "ofx:2", rows[1].ExternalID)
+	assert.Equal(t, "ofx:1#income:+10.00@20260302T000000", rows[2].ExternalID, "a repeated statement ID is told apart by the row")
+}
@@ -0,0 +1,125 @@
+package unit
+
+import (
+	"testing"
+	"time"
+
+	"account/internal/business/models"
+	"account/internal/business/services"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func coverageDate(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func coveragePeriod(accountID uuid.UUID, name string, source models.ImportSource, start, end time.Time) models.ImportCoveragePeriod {
+	return models.ImportCoveragePeriod{
+		ID:          uuid.New(),
+		AccountID:   accountID,
+		AccountName: name,
+		Source:      source,
+		ImportJobID: uuid.New(),
+		StartDate:   start,
+		EndDate:     end,
+	}
+}
+
+func TestBuildCoverageReport(t *testing.T) {
+	checking, savings := uuid.New(), uuid.New()
+	periods := []models.ImportCoveragePeriod{
+		coveragePeriod(checking, "Checking", models.ImportSourceAlipay, coverageDate(2026, 2, 1), coverageDate(2026, 2, 28)),
+		coveragePeriod(checking, "Checking", models.ImportSourceAlipay, coverageDate(2026, 1, 1), coverageDate(2026, 1, 31)),
+		coveragePeriod(checking, "Checking", models.ImportSourceAlipay, coverageDate(2026, 4, 1), coverageDate(2026, 4, 30)),
+		coveragePeriod(checking, "Checking", models.ImportSourceAlipay, coverageDate(2026, 5, 4), coverageDate(2026, 5, 31)),
+		coveragePeriod(savings, "Savings", models.ImportSourceWeChat, coverageDate(2025, 6, 1), coverageDate(2026, 1, 15)),
+	}
+
+	report := services.BuildCoverageReport(periods, coverageDate(2025, 12, 1), coverageDate(2026, 6, 10), 7)
+	require.Len(t, report.Accounts, 2)
+
+	t.Run("adjacent imports merge and short gaps are ignored", func(t *testing.T) {
+		coverage := report.Accounts[0]
+		assert.Equal(t, checking, coverage.AccountID)
+		require.Len(t, coverage.Covered, 3)
+		assert.Equal(t, coverageDate(2026, 1, 1), coverage.Covered[0].Start)
+		assert.Equal(t, coverageDate(2026, 2, 28), coverage.Covered[0].End)
+		assert.ElementsMatch(t, []uuid.UUID{periods[0].ImportJobID, periods[1].ImportJobID}, coverage.Covered[0].ImportJobIDs)
+		assert.Equal(t, coverageDate(2026, 5, 4), coverage.Covered[2].Start)
+		assert.Equal(t, coverageDate(2026, 5, 31), coverage.LastCovered)
+	})
+
+	t.Run("nothing before the first import is missing", func(t *testing.T) {
+		assert.Equal(t, []models.MissingRange{
+			{Start: coverageDate(2026, 3, 1), End: coverageDate(2026, 3, 31), Days: 31},
+			{Start: coverageDate(2026, 6, 1), End: coverageDate(2026, 6, 10), Days: 10},
+		}, report.Accounts[0].Missing)
+	})
+
+	t.Run("earlier coverage is clamped to the window", func(t *testing.T) {
+		coverage := report.Accounts[1]
+		assert.Equal(t, savings, coverage.AccountID)
+		require.Len(t, coverage.Covered, 1)
+		assert.Equal(t, coverageDate(2025, 12, 1), coverage.Covered[0].Start)
+		assert.Equal(t, coverageDate(2026, 1, 15), coverage.Covered[0].End)
+		assert.Equal(t, []models.MissingRange{
+			{Start: coverageDate(2026, 1, 16), End: coverageDate(2026, 6, 10), Days: 146},
+		}, coverage.Missing)
+	})
+}
+
+func TestCoveragePeriodsWidenedByStatementBalances(t *testing.T) {
+	userID, jobID, accountID := uuid.New(), uuid.New(), uuid.New()
+	opening, closing, other := coverageDate(2026, 3, 1), coverageDate(2026, 3, 31), coverageDate(2026, 2, 1)
+
+	rows := []models.ParsedTransaction{
+		{Source: models.ImportSourceBank, SelectedAccountID: &accountID, AccountNumber: "6222", TransactionDate: coverageDate(2026, 3, 20)},
+		{Source: models.ImportSourceBank, SelectedAccountID: &accountID, AccountNumber: "6222", TransactionDate: coverageDate(2026, 3, 5)},
+		{Source: models.ImportSourceBank, AccountNumber: "6222", TransactionDate: coverageDate(2026, 1, 2)}, // no account chosen
+	}
+	hints := []models.AccountHint{
+		{AccountNumber: "6222", BalanceDate: &opening, BalanceType: models.BalanceTypeOpening},
+		{AccountNumber: "6222", BalanceDate: &closing, BalanceType: models.BalanceTypeClosing},
+		{AccountNumber: "9999", BalanceDate: &other, BalanceType: models.BalanceTypeOpening},
+	}
+
+	periods := services.CoveragePeriods(userID, jobID, rows, hints)
+	require.Len(t, periods, 1)
+	assert.Equal(t, accountID, periods[0].AccountID)
+	assert.Equal(t, jobID, periods[0].ImportJobID)
+	assert.Equal(t, opening, periods[0].StartDate)
+	assert.Equal(t, closing, periods[0].EndDate)
+	assert.Equal(t, 2, periods[0].RowCount)
+}
+
+func TestMarkCoveredRows(t *testing.T) {
+	accountID, otherAccountID := uuid.New(), uuid.New()
+	periods := []models.ImportCoveragePeriod{
+		coveragePeriod(accountID, "Checking", models.ImportSourceBank, coverageDate(2026, 3, 1), coverageDate(2026, 3, 31)),
+	}
+	rows := []models.ParsedTransaction{
+		{LineNumber: 1, Source: models.ImportSourceBank, SelectedAccountID: &accountID, TransactionDate: coverageDate(2026, 3, 10), IsDuplicate: true},
+		{LineNumber: 2, Source: models.ImportSourceBank, TransactionDate: coverageDate(2026, 3, 15)},
+		{LineNumber: 3, Source: models.ImportSourceBank, SelectedAccountID: &accountID, TransactionDate: coverageDate(2026, 4, 2)},
+		{LineNumber: 4, Source: models.ImportSourceAlipay, SelectedAccountID: &accountID, TransactionDate: coverageDate(2026, 3, 12)},
+		{LineNumber: 5, Source: models.ImportSourceBank, SelectedAccountID: &otherAccountID, TransactionDate: coverageDate(2026, 3, 12)},
+	}
+
+	coverage := services.MarkCoveredRows(rows, periods)
+
+	var covered []int
+	for _, tx := range rows {
+		if tx.InCoveredPeriod {
+			covered = append(covered, tx.LineNumber)
+		}
+	}
+	assert.Equal(t, []int{1, 2}, covered)
+	assert.Equal(t, 2, coverage.CoveredRows)
+	assert.Equal(t, 1, coverage.NewRows)
+	require.Len(t, coverage.Overlaps, 1)
+	assert.Equal(t, coverageDate(2026, 3, 1), coverage.Overlaps[0].Start)
+	assert.Equal(t, coverageDate(2026, 3, 31), coverage.Overlaps[0].End)
+}
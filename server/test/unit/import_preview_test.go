@@ -0,0 +1,111 @@
+package unit
+
+import (
+	"testing"
+	"time"
+
+	"account/internal/business/models"
+	"account/internal/business/services"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func previewRows() []models.ParsedTransaction {
+	day := func(d int) time.Time { return time.Date(2024, 7, d, 12, 0, 0, 0, time.UTC) }
+	return []models.ParsedTransaction{
+		{LineNumber: 1, Type: models.TransactionTypeExpense, Amount: 35, TransactionDate: day(1), Counterparty: "美团外卖", Note: "午餐", CanBeImported: true},
+		{LineNumber: 2, Type: models.TransactionTypeExpense, Amount: 42, TransactionDate: day(2), Counterparty: "美团外卖", Note: "晚餐", CanBeImported: true},
+		{LineNumber: 3, Type: models.TransactionTypeIncome, Amount: 8000, TransactionDate: day(5), Counterparty: "某某公司", Note: "工资", CanBeImported: true},
+		{LineNumber: 4, Type: models.TransactionTypeExpense, Amount: 42, TransactionDate: day(2), Counterparty: "美团外卖", Note: "晚餐", IsDuplicate: true},
+		{LineNumber: 5, Type: models.TransactionTypeExpense, Amount: 0, TransactionDate: day(6), Counterparty: "美团外卖", Note: "优惠券"},
+	}
+}
+
+func lineNumbers(rows []models.ParsedTransaction) []int {
+	var lines []int
+	for _, row := range rows {
+		lines = append(lines, row.LineNumber)
+	}
+	return lines
+}
+
+func TestImportPreviewFilter(t *testing.T) {
+	rows := previewRows()
+	match := func(f services.ImportPreviewFilter) []int {
+		var lines []int
+		for i := range rows {
+			if f.Matches(&rows[i]) {
+				lines = append(lines, rows[i].LineNumber)
+			}
+		}
+		return lines
+	}
+
+	assert.Len(t, match(services.ImportPreviewFilter{}), 5, "an empty filter selects every row")
+	assert.Equal(t, []int{1, 2, 4, 5}, match(services.ImportPreviewFilter{Counterparty: "美团"}))
+	assert.Equal(t, []int{2, 4}, match(services.ImportPreviewFilter{Keyword: "晚餐"}))
+	assert.Equal(t, []int{3}, match(services.ImportPreviewFilter{Type: models.TransactionTypeIncome}))
+
+	included := true
+	minAmount := 40.0
+	assert.Equal(t, []int{2, 3}, match(services.ImportPreviewFilter{Included: &included, MinAmount: &minAmount}))
+
+	from := time.Date(2024, 7, 2, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 7, 5, 23, 59, 0, 0, time.UTC)
+	duplicate := false
+	assert.Equal(t, []int{2, 3}, match(services.ImportPreviewFilter{From: &from, To: &to, Duplicate: &duplicate}))
+	assert.Equal(t, []int{1, 3}, match(services.ImportPreviewFilter{LineNumbers: []int{1, 3}}))
+}
+
+func TestEditPreviewRows(t *testing.T) {
+	rows := previewRows()
+	account := uuid.New()
+	category := uuid.New()
+
+	edit := &services.ImportPreviewEdit{AccountID: &account, CategoryID: &category}
+	changed := services.EditPreviewRows(rows, &services.ImportPreviewFilter{Counterparty: "美团外卖"}, edit)
+	assert.Equal(t, []int{1, 2, 4, 5}, lineNumbers(changed))
+	require.NotNil(t, rows[0].SelectedCategoryID)
+	assert.Equal(t, category, *rows[0].SelectedCategoryID)
+	assert.Nil(t, rows[2].SelectedAccountID, "rows outside the filter are untouched")
+
+	// The same edit again changes nothing
+	assert.Empty(t, services.EditPreviewRows(rows, &services.ImportPreviewFilter{Counterparty: "美团外卖"}, edit))
+
+	// Including a group skips rows that cannot be imported
+	include := true
+	changed = services.EditPreviewRows(rows, &services.ImportPreviewFilter{}, &services.ImportPreviewEdit{Include: &include})
+	assert.Equal(t, []int{4}, lineNumbers(changed), "the duplicate can be included, the zero amount row cannot")
+	assert.False(t, rows[4].CanBeImported)
+}
+
+func TestImportPreviewEditApply(t *testing.T) {
+	rows := previewRows()
+
+	include := true
+	_, err := (&services.ImportPreviewEdit{Include: &include}).Apply(&rows[4])
+	assert.ErrorIs(t, err, services.ErrPreviewRowNotImportable)
+
+	exclude := false
+	note := "和同事聚餐"
+	changed, err := (&services.ImportPreviewEdit{Include: &exclude, Note: &note}).Apply(&rows[1])
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.False(t, rows[1].CanBeImported)
+	assert.Equal(t, note, rows[1].Note)
+
+	category := uuid.New()
+	rows[0].SelectedCategoryID = &category
+	changed, err = (&services.ImportPreviewEdit{ClearCategory: true}).Apply(&rows[0])
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.Nil(t, rows[0].SelectedCategoryID)
+
+	preview := models.ImportPreview{Transactions: rows}
+	preview.Recount()
+	assert.Equal(t, 5, preview.TotalRows)
+	assert.Equal(t, 2, preview.ValidRows)
+	assert.Equal(t, 1, preview.DuplicateRows)
+}
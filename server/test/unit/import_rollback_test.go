@@ -0,0 +1,128 @@
+package unit
+
+import (
+	"testing"
+	"time"
+
+	"account/internal/business/models"
+	"account/internal/business/services"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func storedTx(accountID uuid.UUID, tType models.TransactionType, amount float64) models.Transaction {
+	return models.Transaction{
+		ID:              uuid.New(),
+		AccountID:       accountID,
+		Type:            tType,
+		Amount:          amount,
+		TransactionDate: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC),
+	}
+}
+
+func balanceChange(rollback *models.ImportRollback, accountID uuid.UUID) float64 {
+	for _, c := range rollback.BalanceChanges {
+		if c.AccountID == accountID {
+			return c.Change
+		}
+	}
+	return 0
+}
+
+func TestPlanImportRollbackIncomeAndExpense(t *testing.T) {
+	jobID := uuid.New()
+	account := uuid.New()
+	salary := storedTx(account, models.TransactionTypeIncome, 8000)
+	rent := storedTx(account, models.TransactionTypeExpense, 3000)
+
+	rollback := services.PlanImportRollback(jobID, []models.Transaction{salary, rent}, nil, nil)
+	assert.Equal(t, jobID, rollback.JobID)
+	assert.ElementsMatch(t, []uuid.UUID{salary.ID, rent.ID}, rollback.DeletedTransactionIDs)
+	assert.Empty(t, rollback.RemovedTransferLinkIDs)
+	assert.Empty(t, rollback.RestoredTransactions)
+	require.Len(t, rollback.BalanceChanges, 1)
+	assert.Equal(t, -5000.0, balanceChange(rollback, account))
+}
+
+func TestPlanImportRollbackTransferWithLedger(t *testing.T) {
+	jobID := uuid.New()
+	bank, wallet := uuid.New(), uuid.New()
+
+	// The imported outgoing half was linked to an incoming transaction already in
+	// the ledger; a fee of 2 was split off the imported half
+	out := storedTx(bank, models.TransactionTypeTransfer, 1000)
+	fee := storedTx(bank, models.TransactionTypeExpense, 2)
+	in := storedTx(wallet, models.TransactionTypeTransfer, 1000)
+	link := models.TransferLink{ID: uuid.New(), FromTransactionID: out.ID, ToTransactionID: in.ID, FeeTransactionID: &fee.ID}
+
+	rollback := services.PlanImportRollback(jobID,
+		[]models.Transaction{out},
+		[]models.TransferLink{link},
+		[]models.Transaction{out, in, fee})
+
+	assert.ElementsMatch(t, []uuid.UUID{out.ID, fee.ID}, rollback.DeletedTransactionIDs)
+	assert.Equal(t, []uuid.UUID{link.ID}, rollback.RemovedTransferLinkIDs)
+	require.Len(t, rollback.RestoredTransactions, 1)
+	assert.Equal(t, in.ID, rollback.RestoredTransactions[0].TransactionID)
+	assert.Equal(t, models.TransactionTypeIncome, rollback.RestoredTransactions[0].Type)
+	// The bank gets back the whole 1002 it paid; the wallet keeps its income
+	assert.Equal(t, 1002.0, balanceChange(rollback, bank))
+	assert.Equal(t, 0.0, balanceChange(rollback, wallet))
+}
+
+func TestPlanImportRollbackRestoresLedgerFee(t *testing.T) {
+	jobID := uuid.New()
+	bank, wallet := uuid.New(), uuid.New()
+
+	// The imported row is the incoming half; the fee was split off the ledger's outgoing half
+	out := storedTx(bank, models.TransactionTypeTransfer, 500)
+	fee := storedTx(bank, models.TransactionTypeExpense, 0.5)
+	in := storedTx(wallet, models.TransactionTypeTransfer, 500)
+	link := models.TransferLink{ID: uuid.New(), FromTransactionID: out.ID, ToTransactionID: in.ID, FeeTransactionID: &fee.ID}
+
+	rollback := services.PlanImportRollback(jobID,
+		[]models.Transaction{in},
+		[]models.TransferLink{link},
+		[]models.Transaction{out, in, fee})
+
+	assert.ElementsMatch(t, []uuid.UUID{in.ID, fee.ID}, rollback.DeletedTransactionIDs)
+	require.Len(t, rollback.RestoredTransactions, 1)
+	restored := rollback.RestoredTransactions[0]
+	assert.Equal(t, out.ID, restored.TransactionID)
+	assert.Equal(t, models.TransactionTypeExpense, restored.Type)
+	assert.Equal(t, 500.5, restored.Amount, "the fee is merged back into the ledger expense")
+	assert.Equal(t, -500.0, balanceChange(rollback, wallet))
+	assert.Equal(t, 0.0, balanceChange(rollback, bank))
+}
+
+func TestPlanImportRollbackTransferGroup(t *testing.T) {
+	jobID := uuid.New()
+	bank, card, wallet := uuid.New(), uuid.New(), uuid.New()
+	groupID := uuid.New()
+
+	// One imported outgoing transfer split into an imported and a ledger incoming leg
+	out := storedTx(bank, models.TransactionTypeTransfer, 300)
+	importedIn := storedTx(card, models.TransactionTypeTransfer, 100)
+	ledgerIn := storedTx(wallet, models.TransactionTypeTransfer, 200)
+	links := []models.TransferLink{
+		{ID: uuid.New(), FromTransactionID: out.ID, ToTransactionID: importedIn.ID, GroupID: &groupID},
+		{ID: uuid.New(), FromTransactionID: out.ID, ToTransactionID: ledgerIn.ID, GroupID: &groupID},
+	}
+	// Links found through both imported legs are passed twice
+	links = append(links, links...)
+
+	rollback := services.PlanImportRollback(jobID,
+		[]models.Transaction{out, importedIn},
+		links,
+		[]models.Transaction{out, importedIn, ledgerIn})
+
+	assert.Len(t, rollback.RemovedTransferLinkIDs, 2)
+	assert.ElementsMatch(t, []uuid.UUID{out.ID, importedIn.ID}, rollback.DeletedTransactionIDs)
+	require.Len(t, rollback.RestoredTransactions, 1)
+	assert.Equal(t, ledgerIn.ID, rollback.RestoredTransactions[0].TransactionID)
+	assert.Equal(t, 300.0, balanceChange(rollback, bank))
+	assert.Equal(t, -100.0, balanceChange(rollback, card))
+	assert.Equal(t, 0.0, balanceChange(rollback, wallet))
+}
@@ -0,0 +1,102 @@
+package unit
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"account/internal/business/models"
+	"account/internal/business/services"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+func TestMarkStoredDuplicates(t *testing.T) {
+	noon := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	existing := []models.Transaction{
+		{Type: models.TransactionTypeExpense, Amount: 35.00, TransactionDate: noon},
+		{Type: models.TransactionTypeIncome, Amount: 88.00, TransactionDate: noon},
+	}
+	rows := []models.ParsedTransaction{
+		{LineNumber: 1, Type: models.TransactionTypeExpense, Amount: 35.00, TransactionDate: noon.Add(3 * time.Hour)},
+		{LineNumber: 2, Type: models.TransactionTypeExpense, Amount: 35.01, TransactionDate: noon.Add(-20 * time.Hour)},
+		{LineNumber: 3, Type: models.TransactionTypeExpense, Amount: 35.02, TransactionDate: noon},
+		{LineNumber: 4, Type: models.TransactionTypeExpense, Amount: 35.00, TransactionDate: noon.Add(25 * time.Hour)},
+		{LineNumber: 5, Type: models.TransactionTypeExpense, Amount: 88.00, TransactionDate: noon},
+		{LineNumber: 6, Type: models.TransactionTypeIncome, Amount: 88.00, TransactionDate: noon},
+	}
+
+	services.MarkStoredDuplicates(rows, existing)
+
+	var duplicates []int
+	for _, row := range rows {
+		if row.IsDuplicate {
+			duplicates = append(duplicates, row.LineNumber)
+		}
+	}
+	// Within a cent and a day, of the same type; a refund of 88 is not a copy of the income
+	assert.Equal(t, []int{1, 2, 6}, duplicates)
+}
+
+func TestMarkStoredDuplicatesComparesDescriptions(t *testing.T) {
+	noon := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	luckin, starbucks := uuid.New(), uuid.New()
+	existing := []models.Transaction{
+		{Type: models.TransactionTypeExpense, Amount: 15.00, TransactionDate: noon, Note: "瑞幸咖啡 生椰拿铁"},
+		{Type: models.TransactionTypeExpense, Amount: 32.00, TransactionDate: noon, PayeeID: &luckin},
+	}
+	rows := []models.ParsedTransaction{
+		{LineNumber: 1, Type: models.TransactionTypeExpense, Amount: 15.00, TransactionDate: noon, Counterparty: "瑞幸咖啡", Note: "生椰拿铁"},
+		{LineNumber: 2, Type: models.TransactionTypeExpense, Amount: 15.00, TransactionDate: noon, Counterparty: "北京地铁", Note: "乘车码"},
+		{LineNumber: 3, Type: models.TransactionTypeExpense, Amount: 32.00, TransactionDate: noon, PayeeID: &luckin, Note: "订单"},
+		{LineNumber: 4, Type: models.TransactionTypeExpense, Amount: 32.00, TransactionDate: noon, PayeeID: &starbucks},
+		{LineNumber: 5, Type: models.TransactionTypeExpense, Amount: 15.00, TransactionDate: noon},
+	}
+
+	services.MarkStoredDuplicates(rows, existing)
+
+	var duplicates []int
+	for _, row := range rows {
+		if row.IsDuplicate {
+			duplicates = append(duplicates, row.LineNumber)
+		}
+	}
+	// Same payee or note; a row without any description is not told apart
+	assert.Equal(t, []int{1, 3, 5}, duplicates)
+}
+
+func TestPeekBillHeadDetectsGBKBill(t *testing.T) {
+	// A long GBK export whose first 8 KiB end inside a two-byte character
+	var sb strings.Builder
+	sb.WriteString("支付宝交易记录明细查询\n交易号,交易时间,交易对方,金额（元）,收/支\n")
+	for sb.Len() < 20000 {
+		sb.WriteString("2024011522001,2024-01-15 12:00:00,瑞幸咖啡,15.00,支出\n")
+	}
+	gbk, err := simplifiedchinese.GBK.NewEncoder().String(sb.String())
+	require.NoError(t, err)
+
+	reader := services.NewBillReader(strings.NewReader(gbk))
+	head, err := services.PeekBillHead(reader)
+	require.NoError(t, err)
+	source, ok := services.DetectImportSource("bill.csv", head)
+	require.True(t, ok)
+	assert.Equal(t, models.ImportSourceAlipay, source)
+
+	// Peeking leaves the whole file to be parsed
+	var rest bytes.Buffer
+	_, err = rest.ReadFrom(reader)
+	require.NoError(t, err)
+	assert.Equal(t, gbk, rest.String())
+}
+
+func TestPeekBillHeadKeepsUTF8(t *testing.T) {
+	// A UTF-8 head cut inside a three-byte character is still UTF-8
+	content := "\xef\xbb\xbf" + strings.Repeat("微信支付账单明细,", 1000)
+	head, err := services.PeekBillHead(services.NewBillReader(strings.NewReader(content)))
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(head), "微信支付账单明细"), "the byte order mark is stripped and nothing is re-encoded")
+}
@@ -0,0 +1,233 @@
+package unit
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"account/internal/business/models"
+	"account/internal/business/services"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const ofxSGMLSample = `OFXHEADER:100
+DATA:OFXSGML
+VERSION:102
+SECURITY:NONE
+ENCODING:USASCII
+CHARSET:1252
+COMPRESSION:NONE
+OLDFILEUID:NONE
+NEWFILEUID:NONE
+
+<OFX>
+<SIGNONMSGSRSV1><SONRS><STATUS><CODE>0<SEVERITY>INFO</STATUS>
+<DTSERVER>20240201120000<LANGUAGE>ENG<FI><ORG>HSBC<FID>1234</FI></SONRS></SIGNONMSGSRSV1>
+<BANKMSGSRSV1><STMTTRNRS><TRNUID>1<STATUS><CODE>0<SEVERITY>INFO</STATUS>
+<STMTRS><CURDEF>GBP
+<BANKACCTFROM><BANKID>400000<ACCTID>12345678<ACCTTYPE>CHECKING</BANKACCTFROM>
+<BANKTRANLIST><DTSTART>20240101<DTEND>20240131
+<STMTTRN><TRNTYPE>DEBIT<DTPOSTED>20240115120000.000[0:GMT]<TRNAMT>-12.50<FITID>2024011501<NAME>PRET A MANGER<MEMO>Coffee &amp; sandwich</STMTTRN>
+<STMTTRN><TRNTYPE>CREDIT<DTPOSTED>20240125<TRNAMT>2500.00<FITID>2024012501<NAME>ACME LTD SALARY</STMTTRN>
+</BANKTRANLIST>
+<LEDGERBAL><BALAMT>3120.75<DTASOF>20240131</LEDGERBAL>
+</STMTRS></STMTTRNRS></BANKMSGSRSV1>
+</OFX>
+`
+
+const ofxXMLSample = `<?xml version="1.0" encoding="UTF-8"?>
+<?OFX OFXHEADER="200" VERSION="220" SECURITY="NONE" OLDFILEUID="NONE" NEWFILEUID="NONE"?>
+<OFX>
+  <CREDITCARDMSGSRSV1>
+    <CCSTMTTRNRS>
+      <CCSTMTRS>
+        <CURDEF>USD</CURDEF>
+        <CCACCTFROM><ACCTID>4111111111119876</ACCTID></CCACCTFROM>
+        <BANKTRANLIST>
+          <STMTTRN>
+            <TRNTYPE>DEBIT</TRNTYPE>
+            <DTPOSTED>20240310083000[-5:EST]</DTPOSTED>
+            <TRNAMT>-45.10</TRNAMT>
+            <FITID>T-1</FITID>
+            <PAYEE><NAME>Whole Foods</NAME></PAYEE>
+            <MEMO></MEMO>
+          </STMTTRN>
+        </BANKTRANLIST>
+        <LEDGERBAL><BALAMT>-845.10</BALAMT><DTASOF>20240331</DTASOF></LEDGERBAL>
+      </CCSTMTRS>
+    </CCSTMTTRNRS>
+  </CREDITCARDMSGSRSV1>
+</OFX>
+`
+
+func TestParseOFX_SGML(t *testing.T) {
+	stmt, err := services.ParseOFX(strings.NewReader(ofxSGMLSample))
+	require.NoError(t, err)
+	require.Len(t, stmt.Transactions, 2)
+	require.Len(t, stmt.AccountHints, 1)
+
+	expense := stmt.Transactions[0]
+	assert.Equal(t, models.TransactionTypeExpense, expense.Type)
+	assert.Equal(t, 12.50, expense.Amount)
+	assert.Equal(t, "GBP", expense.Currency)
+	assert.Equal(t, "PRET A MANGER", expense.Counterparty)
+	assert.Equal(t, "Coffee & sandwich", expense.Note)
+	assert.Equal(t, "12345678:2024011501", expense.ExternalID)
+	assert.Equal(t, "5678", expense.ParsedAccountNumber)
+	assert.Equal(t, time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC), expense.TransactionDate)
+
+	income := stmt.Transactions[1]
+	assert.Equal(t, models.TransactionTypeIncome, income.Type)
+	assert.Equal(t, 2500.00, income.Amount)
+
+	hint := stmt.AccountHints[0]
+	assert.Equal(t, "HSBC", hint.BankName)
+	assert.Equal(t, "5678", hint.AccountNumber)
+	assert.Equal(t, 3120.75, hint.Balance)
+	assert.Equal(t, "GBP", hint.Currency)
+	require.NotNil(t, hint.BalanceDate)
+	assert.Equal(t, "2024-01-31", hint.BalanceDate.Format("2006-01-02"))
+}
+
+func TestParseOFX_XMLCreditCard(t *testing.T) {
+	stmt, err := services.ParseOFX(strings.NewReader(ofxXMLSample))
+	require.NoError(t, err)
+	require.Len(t, stmt.Transactions, 1)
+
+	tx := stmt.Transactions[0]
+	assert.Equal(t, "USD", tx.Currency)
+	assert.Equal(t, "Whole Foods", tx.Counterparty)
+	assert.Equal(t, "Whole Foods", tx.Note)
+	assert.Equal(t, 45.10, tx.Amount)
+	assert.Equal(t, "credit_card", tx.ParsedAccountType)
+	assert.Equal(t, time.Date(2024, 3, 10, 13, 30, 0, 0, time.UTC), tx.TransactionDate)
+
+	assert.Equal(t, models.AccountTypeCredit, stmt.AccountHints[0].AccountType)
+	assert.Equal(t, -845.10, stmt.AccountHints[0].Balance)
+}
+
+const ofxTwoStatementsSample = `<OFX>
+<BANKMSGSRSV1><STMTTRNRS><STMTRS><CURDEF>GBP
+<BANKACCTFROM><BANKID>400000<ACCTID>12345678<ACCTTYPE>CHECKING</BANKACCTFROM>
+<BANKTRANLIST>
+<STMTTRN><TRNTYPE>DEBIT<DTPOSTED>20240115<TRNAMT>-12.50<FITID>B-1<NAME>PRET A MANGER</STMTTRN>
+<STMTTRN><TRNTYPE>CREDIT<DTPOSTED>20240125<TRNAMT>2500.00<FITID>B-2<NAME>ACME LTD SALARY</STMTTRN>
+</BANKTRANLIST>
+</STMTRS></STMTTRNRS></BANKMSGSRSV1>
+<CREDITCARDMSGSRSV1><CCSTMTTRNRS><CCSTMTRS><CURDEF>GBP
+<CCACCTFROM><ACCTID>4111111111119876</CCACCTFROM>
+<BANKTRANLIST>
+<STMTTRN><TRNTYPE>DEBIT<DTPOSTED>20240118<TRNAMT>-45.10<FITID>C-1<NAME>TESCO</STMTTRN>
+</BANKTRANLIST>
+</CCSTMTRS></CCSTMTTRNRS></CREDITCARDMSGSRSV1>
+</OFX>
+`
+
+func TestParseOFX_LineNumbersSpanStatements(t *testing.T) {
+	stmt, err := services.ParseOFX(strings.NewReader(ofxTwoStatementsSample))
+	require.NoError(t, err)
+	require.Len(t, stmt.Transactions, 3)
+	require.Len(t, stmt.AccountHints, 2)
+
+	var lines []int
+	for _, tx := range stmt.Transactions {
+		lines = append(lines, tx.LineNumber)
+	}
+	assert.Equal(t, []int{1, 2, 3}, lines, "rows of the credit card statement must not reuse the bank statement's numbers")
+	assert.Equal(t, "TESCO", stmt.Transactions[2].Counterparty)
+}
+
+func TestParseQIF_DuplicateKeysKeepRepeatsApart(t *testing.T) {
+	qif := "!Type:Bank\nD01/15/2024\nT-4.50\nPStarbucks\n^\nD01/15/2024\nT-4.50\nPStarbucks\n^\nD1/20'24\nT1,200.00\nPEmployer\nLSalary\n^\n"
+
+	stmt, err := services.ParseQIF(strings.NewReader(qif))
+	require.NoError(t, err)
+	require.Len(t, stmt.Transactions, 3)
+
+	first, second := stmt.Transactions[0], stmt.Transactions[1]
+	assert.NotEmpty(t, first.ExternalID)
+	assert.NotEqual(t, first.ExternalID, second.ExternalID)
+	assert.Equal(t, models.TransactionTypeExpense, first.Type)
+
+	salary := stmt.Transactions[2]
+	assert.Equal(t, models.TransactionTypeIncome, salary.Type)
+	assert.Equal(t, 1200.00, salary.Amount)
+	assert.Equal(t, "Salary", salary.CategoryHint)
+	assert.Equal(t, time.Date(2024, 1, 20, 0, 0, 0, 0, time.UTC), salary.TransactionDate)
+
+	// Re-parsing the same file yields the same keys
+	again, err := services.ParseQIF(strings.NewReader(qif))
+	require.NoError(t, err)
+	assert.Equal(t, first.ExternalID, again.Transactions[0].ExternalID)
+	assert.Equal(t, second.ExternalID, again.Transactions[1].ExternalID)
+}
+
+func TestParseQIF_AmountSeparatorsAndInvalidDates(t *testing.T) {
+	qif := "!Type:Bank\n" +
+		"D01/02/2024\nT1,234\n^\n" +
+		"D01/03/2024\nT-1,234,567\n^\n" +
+		"D01/04/2024\nT-1.234,56\n^\n" +
+		"D01/05/2024\nT12,5\n^\n" +
+		"D02/31/2024\nT-10.00\n^\n" +
+		"D31.04.2024\nT-20.00\n^\n"
+
+	stmt, err := services.ParseQIF(strings.NewReader(qif))
+	require.NoError(t, err)
+	// 02/31 and 31.04 are not real dates and are skipped instead of rolling over
+	require.Len(t, stmt.Transactions, 4)
+
+	assert.Equal(t, 1234.0, stmt.Transactions[0].Amount)
+	assert.Equal(t, 1234567.0, stmt.Transactions[1].Amount)
+	assert.Equal(t, 1234.56, stmt.Transactions[2].Amount)
+	assert.Equal(t, 12.5, stmt.Transactions[3].Amount)
+}
+
+func TestExportOFXAndQIF_RoundTrip(t *testing.T) {
+	account := &models.Account{
+		ID:         uuid.New(),
+		Name:       "Wise USD",
+		Type:       models.AccountTypeBank,
+		TailNumber: "4321",
+		Currency:   "USD",
+		Balance:    980.00,
+	}
+	transactions := []models.Transaction{
+		{ID: uuid.New(), Type: models.TransactionTypeExpense, Amount: 20, Currency: "USD", Note: "Groceries <market>",
+			TransactionDate: time.Date(2024, 2, 1, 10, 0, 0, 0, time.UTC)},
+		{ID: uuid.New(), Type: models.TransactionTypeIncome, Amount: 1000, Currency: "USD", Note: "Salary",
+			TransactionDate: time.Date(2024, 2, 2, 10, 0, 0, 0, time.UTC)},
+		{ID: uuid.New(), Type: models.TransactionTypeTransfer, Amount: 50, Currency: "USD", Note: "From savings",
+			TransactionDate: time.Date(2024, 2, 3, 10, 0, 0, 0, time.UTC)},
+		{ID: uuid.New(), Type: models.TransactionTypeTransfer, Amount: 30, Currency: "USD", Note: "To savings",
+			TransactionDate: time.Date(2024, 2, 4, 10, 0, 0, 0, time.UTC)},
+	}
+	incoming := map[uuid.UUID]bool{transactions[2].ID: true}
+
+	var ofx bytes.Buffer
+	require.NoError(t, services.WriteOFX(&ofx, account, transactions, incoming, time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)))
+	stmt, err := services.ParseOFX(&ofx)
+	require.NoError(t, err)
+	require.Len(t, stmt.Transactions, 4)
+	assert.Equal(t, "Groceries <market>", stmt.Transactions[0].Note)
+	assert.Equal(t, models.TransactionTypeExpense, stmt.Transactions[0].Type)
+	assert.Equal(t, "USD", stmt.Transactions[0].Currency)
+	assert.Equal(t, 980.00, stmt.AccountHints[0].Balance)
+	assert.Equal(t, 50.0, stmt.Transactions[2].Amount)
+	assert.Equal(t, 30.0, stmt.Transactions[3].Amount)
+
+	var qif bytes.Buffer
+	require.NoError(t, services.WriteQIF(&qif, account, transactions, incoming, nil))
+	parsed, err := services.ParseQIF(&qif)
+	require.NoError(t, err)
+	require.Len(t, parsed.Transactions, 4)
+	assert.Equal(t, "Wise USD", parsed.Transactions[0].AccountName)
+	assert.Equal(t, 20.0, parsed.Transactions[0].Amount)
+	assert.Equal(t, models.TransactionTypeIncome, parsed.Transactions[1].Type)
+	// The receiving half of a transfer is a credit, the sending half a debit
+	assert.Equal(t, models.TransactionTypeIncome, parsed.Transactions[2].Type)
+	assert.Equal(t, models.TransactionTypeExpense, parsed.Transactions[3].Type)
+}
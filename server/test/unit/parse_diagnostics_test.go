@@ -0,0 +1,113 @@
+package unit
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strings"
+	"testing"
+
+	"account/internal/business/models"
+	"account/internal/business/services"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parseCSVRecords(t *testing.T, source models.ImportSource, content string) ([]models.ParsedTransaction, []models.ParseDiagnostic) {
+	t.Helper()
+	var rows []models.ParsedTransaction
+	var diagnostics []models.ParseDiagnostic
+	err := (&services.ImportService{}).ParseCSVRecords(source, strings.NewReader(content),
+		func(tx models.ParsedTransaction) { rows = append(rows, tx) },
+		func(d models.ParseDiagnostic) { diagnostics = append(diagnostics, d) },
+	)
+	require.NoError(t, err)
+	return rows, diagnostics
+}
+
+func TestParseDiagnosticsAlipay(t *testing.T) {
+	content := strings.Join([]string{
+		"支付宝交易记录明细查询",
+		"交易时间,交易分类,交易对方,金额,收/支,支付方式",
+		"2026-03-01 10:00:00,餐饮,面馆,25.00,支出,余额",
+		"2026-13-45,餐饮,面馆,25.00,支出,余额",
+		"2026-03-02 10:00:00,餐饮,面馆,abc,支出,余额",
+		"2026-03-02 11:00:00,餐饮,面馆,0.00,支出,余额",
+		"------------------------------------",
+		",,,,,",
+		"2026-03-03 09:00:00,餐饮,\"多行\n商户\",12x,支出,余额",
+		"2026-03-04 09:00:00,交通,地铁,4.00,支出,余额",
+	}, "\n")
+
+	rows, diagnostics := parseCSVRecords(t, models.ImportSourceAlipay, content)
+
+	require.Len(t, rows, 2)
+	assert.Equal(t, "地铁", rows[1].Counterparty)
+
+	var lines []int
+	for _, d := range diagnostics {
+		lines = append(lines, d.LineNumber)
+		assert.True(t, d.Skipped, "line %d", d.LineNumber)
+	}
+	assert.Equal(t, []int{4, 5, 6, 7, 9}, lines, "blank records are not reported; a quoted line break keeps later line numbers right")
+
+	assert.Equal(t, "交易时间", diagnostics[0].Field)
+	assert.Equal(t, "2026-13-45", diagnostics[0].Value)
+	assert.Equal(t, []string{"2026-13-45", "餐饮", "面馆", "25.00", "支出", "余额"}, diagnostics[0].Record)
+	assert.Equal(t, "金额", diagnostics[1].Field)
+	assert.Equal(t, "unable to parse amount", diagnostics[1].Reason)
+	assert.Equal(t, "amount is zero", diagnostics[2].Reason)
+	assert.Equal(t, "", diagnostics[3].Field)
+	assert.Equal(t, "expected at least 3 columns, found 1", diagnostics[3].Reason)
+	assert.Equal(t, "12x", diagnostics[4].Value)
+}
+
+func TestParseDiagnosticsBankAmountColumns(t *testing.T) {
+	content := strings.Join([]string{
+		"交易日期,摘要,收入,支出",
+		"2026-03-01,工资,1000.00,--",
+		"2026-03-02,转账,--,--",
+		"2026-03-03,转账,abc,--",
+		"someday,转账,10.00,--",
+	}, "\n")
+
+	rows, diagnostics := parseCSVRecords(t, models.ImportSourceBank, content)
+
+	require.Len(t, rows, 1, "a placeholder in the unused column is not a problem")
+	assert.Equal(t, models.TransactionTypeIncome, rows[0].Type)
+
+	require.Len(t, diagnostics, 3)
+	assert.Equal(t, 3, diagnostics[0].LineNumber)
+	assert.Equal(t, "no amount", diagnostics[0].Reason)
+	assert.Equal(t, 4, diagnostics[1].LineNumber)
+	assert.Equal(t, "收入", diagnostics[1].Field)
+	assert.Equal(t, "unable to parse amount", diagnostics[1].Reason)
+	assert.Equal(t, 5, diagnostics[2].LineNumber)
+	assert.Equal(t, "交易日期", diagnostics[2].Field)
+	assert.Equal(t, "unable to parse date", diagnostics[2].Reason)
+}
+
+func TestParseDiagnosticsFile(t *testing.T) {
+	diagnostics := models.ParseDiagnostics{
+		{LineNumber: 4, Record: []string{"2026-13-45", "面馆", "25.00"}, Field: "交易时间", Value: "2026-13-45", Reason: "unable to parse date: 2026-13-45", Skipped: true},
+		{LineNumber: 4, Record: []string{"2026-13-45", "面馆", "25.00"}, Reason: "negative amount", Skipped: true},
+		{LineNumber: 7, Record: []string{"---"}, Reason: "expected at least 3 columns, found 1", Skipped: true},
+	}
+	assert.Equal(t, 2, diagnostics.Rejected(), "a record with two diagnostics is rejected once")
+	assert.Equal(t, `line 4: 交易时间 "2026-13-45": unable to parse date: 2026-13-45`, diagnostics[0].String())
+	assert.Equal(t, "line 7: expected at least 3 columns, found 1", diagnostics[2].String())
+
+	file, err := services.ParseDiagnosticsFile("uploads/alipay 2026-03.csv", diagnostics)
+	require.NoError(t, err)
+	assert.Equal(t, "alipay 2026-03_rejected.csv", file.FileName)
+	assert.True(t, bytes.HasPrefix(file.Content, []byte("\xef\xbb\xbf")))
+
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(file.Content, []byte("\xef\xbb\xbf"))))
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 4)
+	assert.Equal(t, []string{"line_number", "skipped", "field", "value", "reason", "record"}, records[0])
+	assert.Equal(t, []string{"4", "true", "交易时间", "2026-13-45", "unable to parse date: 2026-13-45", "2026-13-45", "面馆", "25.00"}, records[1])
+	assert.Equal(t, []string{"7", "true", "", "", "expected at least 3 columns, found 1", "---"}, records[3])
+}
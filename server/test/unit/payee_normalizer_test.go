@@ -0,0 +1,65 @@
+package unit
+
+import (
+	"testing"
+
+	"account/internal/business/models"
+	"account/internal/business/services"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCanonicalPayeeName(t *testing.T) {
+	cases := map[string]string{
+		"美团外卖-XX店":         "美团外卖",
+		"财付通-美团":           "美团",
+		"北京三快在线科技有限公司":     "美团",
+		"上海拉扎斯信息科技有限公司":    "饿了么",
+		"星巴克（国贸店）":         "星巴克",
+		"Starbucks Coffee": "星巴克",
+		"老王烧烤-万达店":         "老王烧烤",
+		"杭州小笼包餐饮管理有限公司":    "杭州小笼包餐饮管理",
+		"全家便利店北京东路店":       "全家便利店北京东路店",
+		"扫二维码付款":           "",
+		"支付宝（中国）网络技术有限公司":  "",
+		"财付通-扫二维码付款":       "",
+		"  ":               "",
+	}
+	for input, want := range cases {
+		assert.Equal(t, want, services.CanonicalPayeeName(input), input)
+	}
+}
+
+func TestPayeeNormalizer_UserAliases(t *testing.T) {
+	canteen := models.Payee{ID: uuid.New(), Name: "公司食堂", Aliases: []models.PayeeAlias{
+		{Alias: "XX科技有限公司-食堂", AliasKey: services.NormalizePayeeKey("XX科技有限公司-食堂")},
+	}}
+	meituan := models.Payee{ID: uuid.New(), Name: "美团"}
+	normalizer := services.NewPayeeNormalizer([]models.Payee{canteen, meituan})
+
+	// A user alias matches the raw counterparty, ignoring width and punctuation
+	tx := models.ParsedTransaction{Counterparty: "ＸＸ科技有限公司 食堂"}
+	normalizer.ApplyToParsed(&tx)
+	require.NotNil(t, tx.PayeeID)
+	assert.Equal(t, canteen.ID, *tx.PayeeID)
+	assert.Equal(t, "公司食堂", tx.PayeeName)
+
+	// The built-in pipeline resolves to an existing payee by name
+	tx = models.ParsedTransaction{Counterparty: "财付通-美团"}
+	normalizer.ApplyToParsed(&tx)
+	require.NotNil(t, tx.PayeeID)
+	assert.Equal(t, meituan.ID, *tx.PayeeID)
+
+	// Unknown merchants only get a name; the payee is created on import
+	tx = models.ParsedTransaction{Counterparty: "瑞幸咖啡(国贸店)"}
+	normalizer.ApplyToParsed(&tx)
+	assert.Nil(t, tx.PayeeID)
+	assert.Equal(t, "瑞幸咖啡", tx.PayeeName)
+
+	tx = models.ParsedTransaction{Counterparty: "扫二维码付款"}
+	normalizer.ApplyToParsed(&tx)
+	assert.Nil(t, tx.PayeeID)
+	assert.Empty(t, tx.PayeeName)
+}
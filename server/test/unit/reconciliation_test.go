@@ -0,0 +1,108 @@
+package unit
+
+import (
+	"testing"
+	"time"
+
+	"account/internal/business/models"
+	"account/internal/business/services"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func reconcileDay(d int) time.Time { return time.Date(2024, 3, d, 10, 0, 0, 0, time.UTC) }
+
+func ledgerTx(typ models.TransactionType, amount float64, d int, note string) models.Transaction {
+	return models.Transaction{Type: typ, Amount: amount, TransactionDate: reconcileDay(d), Note: note}
+}
+
+func statementRow(typ models.TransactionType, amount float64, d int) models.ParsedTransaction {
+	return models.ParsedTransaction{Type: typ, Amount: amount, TransactionDate: reconcileDay(d)}
+}
+
+func TestFindReconciliationCulpritsWithStatement(t *testing.T) {
+	ledger := []models.Transaction{
+		ledgerTx(models.TransactionTypeExpense, 35, 2, "午餐"), // booked a day earlier than the bank
+		ledgerTx(models.TransactionTypeExpense, 120, 5, "加油"),
+		ledgerTx(models.TransactionTypeExpense, 120, 5, "加油"),   // imported twice
+		ledgerTx(models.TransactionTypeExpense, 500, 8, "退款"),   // refund entered as expense
+		ledgerTx(models.TransactionTypeTransfer, 1000, 9, "转出"), // transfers do not move the balance
+	}
+	statement := []models.ParsedTransaction{
+		statementRow(models.TransactionTypeExpense, 35, 3),
+		statementRow(models.TransactionTypeExpense, 120, 5),
+		statementRow(models.TransactionTypeIncome, 500, 8),
+		statementRow(models.TransactionTypeExpense, 18.5, 10), // never recorded
+	}
+
+	// Ledger: -35 -120 -120 -500 = -775; statement: -35 -120 +500 -18.5 = 326.5
+	culprits := services.FindReconciliationCulprits(1101.5, ledger, statement)
+	require.Len(t, culprits, 3)
+
+	byKind := make(map[models.CulpritKind]models.ReconciliationCulprit)
+	for _, c := range culprits {
+		byKind[c.Kind] = c
+	}
+	assert.InDelta(t, 1000, byKind[models.CulpritMisSigned].Impact, 0.001)
+	assert.InDelta(t, -18.5, byKind[models.CulpritMissing].Impact, 0.001)
+	assert.InDelta(t, 120, byKind[models.CulpritDuplicate].Impact, 0.001)
+	assert.Equal(t, "加油", byKind[models.CulpritDuplicate].Transaction.Note)
+	for _, c := range culprits {
+		assert.False(t, c.ExplainsDifference, "no single row explains %.2f", 1101.5)
+	}
+}
+
+func TestFindReconciliationCulpritsExplainingRowFirst(t *testing.T) {
+	ledger := []models.Transaction{
+		ledgerTx(models.TransactionTypeExpense, 60, 4, "超市"),
+		ledgerTx(models.TransactionTypeIncome, 88, 6, "红包"),
+	}
+	statement := []models.ParsedTransaction{
+		statementRow(models.TransactionTypeExpense, 60, 4),
+		statementRow(models.TransactionTypeIncome, 88, 6),
+		statementRow(models.TransactionTypeIncome, 5, 7),
+		statementRow(models.TransactionTypeExpense, 42, 7),
+	}
+
+	culprits := services.FindReconciliationCulprits(-42, ledger, statement)
+	require.Len(t, culprits, 2)
+	assert.True(t, culprits[0].ExplainsDifference)
+	assert.Equal(t, models.CulpritMissing, culprits[0].Kind)
+	assert.InDelta(t, 42, culprits[0].StatementRow.Amount, 0.001)
+	assert.False(t, culprits[1].ExplainsDifference)
+}
+
+func TestFindReconciliationCulpritsMatchesExternalID(t *testing.T) {
+	ledger := []models.Transaction{
+		{Type: models.TransactionTypeExpense, Amount: 20, TransactionDate: reconcileDay(1), ExternalID: "F1"},
+	}
+	// Same bank reference, so a late booking date is not reported as missing plus extra
+	statement := []models.ParsedTransaction{
+		{Type: models.TransactionTypeExpense, Amount: 20, TransactionDate: reconcileDay(20), ExternalID: "F1"},
+	}
+
+	assert.Empty(t, services.FindReconciliationCulprits(0, ledger, statement))
+}
+
+func TestFindReconciliationCulpritsWithoutStatement(t *testing.T) {
+	ledger := []models.Transaction{
+		ledgerTx(models.TransactionTypeExpense, 30, 1, "咖啡"),
+		ledgerTx(models.TransactionTypeExpense, 30, 1, "咖啡"),
+		ledgerTx(models.TransactionTypeExpense, 30, 1, "早餐"),  // same amount, different note
+		ledgerTx(models.TransactionTypeExpense, 200, 3, "工资"), // salary entered as expense
+		ledgerTx(models.TransactionTypeIncome, 75, 4, "报销"),
+	}
+
+	culprits := services.FindReconciliationCulprits(400, ledger, nil)
+	require.Len(t, culprits, 2)
+
+	assert.Equal(t, models.CulpritMisSigned, culprits[0].Kind)
+	assert.True(t, culprits[0].ExplainsDifference)
+	assert.Equal(t, "工资", culprits[0].Transaction.Note)
+	assert.InDelta(t, 400, culprits[0].Impact, 0.001)
+
+	assert.Equal(t, models.CulpritDuplicate, culprits[1].Kind)
+	assert.Equal(t, "咖啡", culprits[1].Transaction.Note)
+	assert.InDelta(t, 30, culprits[1].Impact, 0.001)
+}
@@ -0,0 +1,67 @@
+package unit
+
+import (
+	"testing"
+	"time"
+
+	"account/internal/business/models"
+	"account/internal/business/services"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectRefund(t *testing.T) {
+	cases := []struct {
+		name string
+		tx   models.ParsedTransaction
+		want bool
+	}{
+		{"alipay status", models.ParsedTransaction{Type: models.TransactionTypeIncome,
+			RawData: map[string]string{"交易状态  ": "退款成功"}}, true},
+		{"wechat type", models.ParsedTransaction{Type: models.TransactionTypeIncome,
+			RawData: map[string]string{"交易类型": "美团平台商户-退款"}}, true},
+		{"jd cancelled order", models.ParsedTransaction{Type: models.TransactionTypeIncome,
+			RawData: map[string]string{"订单状态": "已取消"}}, true},
+		{"bank memo", models.ParsedTransaction{Type: models.TransactionTypeIncome, Note: "POS REFUND 0115"}, true},
+		{"salary", models.ParsedTransaction{Type: models.TransactionTypeIncome, Note: "工资",
+			RawData: map[string]string{"交易类型": "转账"}}, false},
+		{"expense is never a refund", models.ParsedTransaction{Type: models.TransactionTypeExpense,
+			RawData: map[string]string{"交易状态": "退款成功"}}, false},
+	}
+	for _, c := range cases {
+		tx := c.tx
+		services.DetectRefund(&tx)
+		assert.Equal(t, c.want, tx.IsRefund, c.name)
+	}
+}
+
+func TestMatchRefundsInFile(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 1, d, 12, 0, 0, 0, time.UTC) }
+	row := func(line int, typ models.TransactionType, amount float64, date time.Time, orderID, payee string) models.ParsedTransaction {
+		return models.ParsedTransaction{
+			LineNumber: line, Type: typ, Amount: amount, TransactionDate: date,
+			OrderID: orderID, PayeeName: payee, CanBeImported: true,
+			IsRefund: typ == models.TransactionTypeIncome,
+		}
+	}
+
+	transactions := []models.ParsedTransaction{
+		// Refunds are matched in date order, whatever the row order
+		row(1, models.TransactionTypeIncome, 30, day(20), "", "优衣库"),    // second partial refund
+		row(2, models.TransactionTypeIncome, 50, day(18), "", "优衣库"),    // first partial refund
+		row(3, models.TransactionTypeIncome, 99, day(16), "A-2", "京东"),  // full refund by order ID
+		row(4, models.TransactionTypeIncome, 40, day(22), "", "优衣库"),    // nothing left to refund
+		row(5, models.TransactionTypeExpense, 99, day(12), "A-1", "京东"), // same amount, other order
+		row(6, models.TransactionTypeExpense, 99, day(10), "A-2", "京东"),
+		row(7, models.TransactionTypeExpense, 100, day(5), "", "优衣库"),
+		row(8, models.TransactionTypeIncome, 10, day(2), "", "瑞幸咖啡"), // no purchase before the refund
+		row(9, models.TransactionTypeExpense, 10, day(3), "", "瑞幸咖啡"),
+	}
+	services.MatchRefundsInFile(transactions)
+
+	assert.Equal(t, 7, transactions[1].RefundOfLineNumber, "first partial refund")
+	assert.Equal(t, 7, transactions[0].RefundOfLineNumber, "second partial refund fits the remaining 50")
+	assert.Equal(t, 6, transactions[2].RefundOfLineNumber, "order ID wins over a closer purchase")
+	assert.Zero(t, transactions[3].RefundOfLineNumber, "refunds may not exceed the purchase")
+	assert.Zero(t, transactions[7].RefundOfLineNumber)
+}
@@ -0,0 +1,100 @@
+package unit
+
+import (
+	"testing"
+	"time"
+
+	"account/internal/business/models"
+	"account/internal/business/services"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func billDay(m time.Month, d int) time.Time { return time.Date(2024, m, d, 0, 0, 0, 0, time.UTC) }
+
+func cardBill(m time.Month, balance, minimum, paid float64) models.CreditCardBill {
+	return models.CreditCardBill{
+		ID:               uuid.New(),
+		StatementDate:    billDay(m, 5),
+		DueDate:          billDay(m, 25),
+		StatementBalance: balance,
+		MinimumPayment:   minimum,
+		PaidAmount:       paid,
+	}
+}
+
+func TestMatchBillRepaymentKinds(t *testing.T) {
+	bills := []models.CreditCardBill{cardBill(time.March, 3000, 300, 0)}
+
+	full := services.MatchBillRepayment(bills, billDay(time.March, 20), 3000)
+	require.NotNil(t, full)
+	assert.Equal(t, models.RepaymentFull, full.Kind)
+	assert.False(t, full.Overdue)
+
+	minimum := services.MatchBillRepayment(bills, billDay(time.March, 20), 300)
+	require.NotNil(t, minimum)
+	assert.Equal(t, models.RepaymentMinimum, minimum.Kind)
+
+	partial := services.MatchBillRepayment(bills, billDay(time.March, 28), 100)
+	require.NotNil(t, partial)
+	assert.Equal(t, models.RepaymentPartial, partial.Kind)
+	assert.True(t, partial.Overdue)
+
+	// Earlier repayments count towards the minimum and the full amount
+	bills[0].PaidAmount = 250
+	topUp := services.MatchBillRepayment(bills, billDay(time.March, 21), 50)
+	require.NotNil(t, topUp)
+	assert.Equal(t, models.RepaymentMinimum, topUp.Kind)
+	assert.Equal(t, 250.0, topUp.PaidBefore)
+}
+
+func TestMatchBillRepaymentCycle(t *testing.T) {
+	march := cardBill(time.March, 3000, 300, 300) // only the minimum was paid
+	april := cardBill(time.April, 1200, 120, 0)
+	paid := cardBill(time.February, 800, 80, 800)
+	bills := []models.CreditCardBill{april, march, paid}
+
+	// Before any statement date the repayment pays spending not yet billed
+	assert.Nil(t, services.MatchBillRepayment(bills, billDay(time.January, 20), 500))
+
+	// The oldest bill still owed is repaid first
+	r := services.MatchBillRepayment(bills, billDay(time.April, 10), 500)
+	require.NotNil(t, r)
+	assert.Equal(t, march.ID, r.BillID)
+	assert.Equal(t, models.RepaymentMinimum, r.Kind, "300 paid before plus 500 is past the minimum but short of the bill")
+
+	// Unless the amount is exactly what a later bill asks for
+	r = services.MatchBillRepayment(bills, billDay(time.April, 10), 1200)
+	require.NotNil(t, r)
+	assert.Equal(t, april.ID, r.BillID)
+	assert.Equal(t, models.RepaymentFull, r.Kind)
+
+	// The April bill is not issued yet on 1 April
+	r = services.MatchBillRepayment(bills, billDay(time.April, 1), 1200)
+	require.NotNil(t, r)
+	assert.Equal(t, march.ID, r.BillID)
+}
+
+func TestTransferMatcherPairsCardRepayment(t *testing.T) {
+	at := func(d, h int) time.Time { return time.Date(2024, 4, d, h, 0, 0, 0, time.UTC) }
+	bank := services.FileTransactions{FileID: uuid.New(), Transactions: []models.ParsedTransaction{
+		{LineNumber: 1, Type: models.TransactionTypeExpense, Amount: 1200, TransactionDate: at(10, 9), Note: "信用卡还款", ParsedAccountNumber: "6789", RelatedAccountNumber: "4321"},
+	}}
+	card := services.FileTransactions{FileID: uuid.New(), Transactions: []models.ParsedTransaction{
+		{LineNumber: 1, Type: models.TransactionTypeIncome, Amount: 1200, TransactionDate: at(10, 11), Note: "还款-感谢您按时还款", ParsedAccountNumber: "4321"},
+	}}
+
+	matcher := services.NewTransferMatcher()
+	assert.True(t, services.IsRepayment(bank.Transactions[0]))
+	assert.True(t, matcher.IsTransfer(card.Transactions[0]))
+
+	result := matcher.FindMatches([]services.FileTransactions{bank, card})
+	require.Len(t, result.Matches, 1)
+	match := result.Matches[0]
+	assert.Equal(t, bank.FileID, match.OutTx.FileID)
+	assert.Equal(t, card.FileID, match.InTx.FileID)
+	assert.True(t, match.OutTx.Repayment && match.InTx.Repayment)
+	assert.Contains(t, match.MatchFactors, "双方均为信用卡还款")
+}
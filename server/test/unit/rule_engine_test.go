@@ -0,0 +1,103 @@
+package unit
+
+import (
+	"testing"
+
+	"account/internal/business/models"
+	"account/internal/business/services"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRuleEngine_PriorityAndActions(t *testing.T) {
+	coffee := uuid.New()
+	dining := uuid.New()
+	card := uuid.New()
+	maxAmount := 50.0
+
+	rules := []models.CategoryRule{
+		{
+			// Lower priority: generic dining rule
+			ID: uuid.New(), Name: "餐饮", Priority: 20, Enabled: true, MatchMode: models.RuleMatchContains,
+			Conditions: models.RuleConditions{RawCategory: "餐饮美食"},
+			Actions:    models.RuleActions{CategoryID: &dining, AccountID: &card},
+		},
+		{
+			ID: uuid.New(), Name: "咖啡", Priority: 10, Enabled: true, MatchMode: models.RuleMatchRegex,
+			Conditions: models.RuleConditions{Counterparty: "瑞幸|星巴克", MaxAmount: &maxAmount, TransactionType: models.TransactionTypeExpense},
+			Actions:    models.RuleActions{CategoryID: &coffee, SetNote: "咖啡 - {counterparty}"},
+		},
+		{
+			ID: uuid.New(), Name: "停用", Priority: 1, Enabled: false, MatchMode: models.RuleMatchContains,
+			Conditions: models.RuleConditions{Counterparty: "瑞幸"},
+			Actions:    models.RuleActions{SetNote: "never"},
+		},
+	}
+	engine := services.NewRuleEngine(rules)
+
+	tx := models.ParsedTransaction{
+		Source: models.ImportSourceAlipay, Type: models.TransactionTypeExpense, Amount: 18,
+		Counterparty: "瑞幸咖啡", Note: "生椰拿铁", RawData: map[string]string{"交易分类": "餐饮美食"},
+	}
+	engine.ApplyToParsed(&tx)
+
+	require.NotNil(t, tx.SelectedCategoryID)
+	assert.Equal(t, coffee, *tx.SelectedCategoryID, "higher priority rule sets the category")
+	assert.Equal(t, "咖啡 - 瑞幸咖啡", tx.Note)
+	require.NotNil(t, tx.SelectedAccountID)
+	assert.Equal(t, card, *tx.SelectedAccountID, "lower priority rule fills the remaining action")
+	assert.Equal(t, []uuid.UUID{rules[1].ID, rules[0].ID}, tx.AppliedRuleIDs)
+
+	// Over the amount limit only the dining rule matches
+	big := models.ParsedTransaction{
+		Source: models.ImportSourceAlipay, Type: models.TransactionTypeExpense, Amount: 300,
+		Counterparty: "星巴克", Note: "团购", RawData: map[string]string{"交易分类": "餐饮美食"},
+	}
+	engine.ApplyToParsed(&big)
+	require.NotNil(t, big.SelectedCategoryID)
+	assert.Equal(t, dining, *big.SelectedCategoryID)
+	assert.Equal(t, "团购", big.Note)
+}
+
+func TestRuleInputFromTransaction_MatchesLikeImports(t *testing.T) {
+	coffee := uuid.New()
+	card := uuid.New()
+	rules := []models.CategoryRule{{
+		ID: uuid.New(), Name: "咖啡", Priority: 10, Enabled: true, MatchMode: models.RuleMatchContains,
+		Conditions: models.RuleConditions{Counterparty: "瑞幸", AccountID: &card, AccountName: "招行"},
+		Actions:    models.RuleActions{CategoryID: &coffee},
+	}}
+	engine := services.NewRuleEngine(rules)
+
+	tx := models.Transaction{AccountID: card, Type: models.TransactionTypeExpense, Amount: 18, Note: "瑞幸咖啡"}
+	outcome := engine.Evaluate(services.RuleInputFromTransaction(tx, "招行信用卡"))
+	require.NotNil(t, outcome.CategoryID, "counterparty falls back to the note and the account is known")
+	assert.Equal(t, coffee, *outcome.CategoryID)
+
+	tx.AccountID = uuid.New()
+	outcome = engine.Evaluate(services.RuleInputFromTransaction(tx, "招行信用卡"))
+	assert.Empty(t, outcome.RuleIDs)
+}
+
+func TestValidateRule(t *testing.T) {
+	category := uuid.New()
+
+	assert.Error(t, services.ValidateRule(models.CategoryRule{
+		MatchMode: models.RuleMatchContains,
+		Actions:   models.RuleActions{CategoryID: &category},
+	}), "a rule without conditions would match everything")
+
+	assert.Error(t, services.ValidateRule(models.CategoryRule{
+		MatchMode:  models.RuleMatchRegex,
+		Conditions: models.RuleConditions{Note: "("},
+		Actions:    models.RuleActions{CategoryID: &category},
+	}))
+
+	assert.NoError(t, services.ValidateRule(models.CategoryRule{
+		MatchMode:  models.RuleMatchExact,
+		Conditions: models.RuleConditions{Sources: []models.ImportSource{models.ImportSourceWeChat}},
+		Actions:    models.RuleActions{CategoryID: &category},
+	}))
+}
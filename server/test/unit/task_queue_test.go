@@ -0,0 +1,48 @@
+package unit
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"account/internal/business/models"
+	"account/internal/business/services"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTaskRetryBackoff(t *testing.T) {
+	config := services.DefaultTaskQueueConfig()
+	config.RetryBase = 30 * time.Second
+	config.RetryMax = 5 * time.Minute
+
+	assert.Equal(t, 30*time.Second, config.RetryBackoff(1))
+	assert.Equal(t, time.Minute, config.RetryBackoff(2))
+	assert.Equal(t, 2*time.Minute, config.RetryBackoff(3))
+	assert.Equal(t, 4*time.Minute, config.RetryBackoff(4))
+	assert.Equal(t, 5*time.Minute, config.RetryBackoff(5), "capped at RetryMax")
+	assert.Equal(t, 5*time.Minute, config.RetryBackoff(50))
+}
+
+func TestPermanentTaskError(t *testing.T) {
+	cause := errors.New("no file could be parsed")
+	err := fmt.Errorf("batch job: %w", services.Permanent(cause))
+
+	assert.True(t, services.IsPermanent(err))
+	assert.ErrorIs(t, err, cause)
+	assert.Equal(t, "batch job: no file could be parsed", err.Error())
+	assert.False(t, services.IsPermanent(cause))
+}
+
+func TestTaskLastAttempt(t *testing.T) {
+	task := &models.BackgroundTask{Attempts: 2, MaxAttempts: 3}
+	assert.False(t, task.LastAttempt())
+
+	task.Attempts = 3
+	assert.True(t, task.LastAttempt())
+
+	// A task reclaimed after its server stopped during the last attempt gets one more
+	task.Attempts = 4
+	assert.True(t, task.LastAttempt())
+}
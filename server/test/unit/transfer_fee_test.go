@@ -0,0 +1,88 @@
+package unit
+
+import (
+	"testing"
+	"time"
+
+	"account/internal/business/models"
+	"account/internal/business/services"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func observedFee(from, to uuid.UUID, amount, fee float64) models.TransferFee {
+	return models.TransferFee{FromAccountID: from, ToAccountID: to, Amount: amount, Fee: fee}
+}
+
+func TestLearnFeePatterns(t *testing.T) {
+	icbc, ccb, cmb, alipay, abc, boc, bocom, wechat := uuid.New(), uuid.New(), uuid.New(), uuid.New(), uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	fees := []models.TransferFee{
+		observedFee(icbc, ccb, 1000, 2),
+		observedFee(icbc, ccb, 5000, 2),
+		observedFee(cmb, alipay, 1000, 1),
+		observedFee(cmb, alipay, 3333, 3.33),
+		observedFee(cmb, alipay, 200, 0.2),
+		observedFee(abc, boc, 1000, 5),
+		observedFee(abc, boc, 1000, 1),       // no rule explains both
+		observedFee(bocom, wechat, 800, 0.8), // a single fee is not a pattern yet
+	}
+
+	patterns := services.LearnFeePatterns(fees)
+	require.Len(t, patterns, 2)
+
+	fixed := patterns[0]
+	assert.Equal(t, icbc, fixed.FromAccountID)
+	assert.Equal(t, services.FeeFixed, fixed.Kind)
+	assert.Equal(t, 2.0, fixed.Expected(12000))
+
+	rate := patterns[1]
+	assert.Equal(t, alipay, rate.ToAccountID)
+	assert.Equal(t, services.FeePercentage, rate.Kind)
+	assert.InDelta(t, 0.001, rate.Rate, 1e-6)
+	assert.Equal(t, 2.5, rate.Expected(2500))
+	assert.Equal(t, 3, rate.Samples)
+}
+
+func TestTransferMatcherUsesLearnedFee(t *testing.T) {
+	at := time.Date(2024, 5, 8, 10, 0, 0, 0, time.UTC)
+	icbc, ccb, abc := uuid.New(), uuid.New(), uuid.New()
+	out := models.ParsedTransaction{LineNumber: 1, Type: models.TransactionTypeExpense, Amount: 3003, TransactionDate: at, Note: "跨行转账", AccountName: "工商银行", SelectedAccountID: &icbc}
+	in := models.ParsedTransaction{LineNumber: 1, Type: models.TransactionTypeIncome, Amount: 3000, TransactionDate: at.Add(time.Hour), Note: "转入", AccountName: "建设银行", SelectedAccountID: &ccb}
+	files := []services.FileTransactions{
+		{FileID: uuid.New(), Transactions: []models.ParsedTransaction{out}},
+		{FileID: uuid.New(), Transactions: []models.ParsedTransaction{in}},
+	}
+	// No tail numbers or counterparties, so only amount and time score
+	config := services.DefaultTransferMatchConfig()
+	config.MinConfidence = 0.3
+	config.CandidateConfidence = 0.3
+
+	plain := services.NewTransferMatcherWithConfig(config).FindMatches(files)
+	require.Len(t, plain.Matches, 1)
+
+	learned := services.NewTransferMatcherWithConfig(config).WithFeePatterns([]services.FeePattern{
+		{FromAccountID: icbc, ToAccountID: ccb, Kind: services.FeeFixed, Fee: 3, Samples: 4},
+	}).FindMatches(files)
+	require.Len(t, learned.Matches, 1)
+	assert.Greater(t, learned.Matches[0].Confidence, plain.Matches[0].Confidence)
+	assert.Contains(t, learned.Matches[0].MatchFactors, "金额差符合这两个账户间的固定手续费(3.00)")
+
+	// A pattern of another account pair does not apply
+	other := services.NewTransferMatcherWithConfig(config).WithFeePatterns([]services.FeePattern{
+		{FromAccountID: abc, ToAccountID: ccb, Kind: services.FeeFixed, Fee: 3, Samples: 4},
+	}).FindMatches(files)
+	require.Len(t, other.Matches, 1)
+	assert.Equal(t, plain.Matches[0].Confidence, other.Matches[0].Confidence)
+
+	// Nor does one of the right pair to a row whose account is not known yet
+	unresolved := files[0]
+	unresolved.Transactions = []models.ParsedTransaction{out}
+	unresolved.Transactions[0].SelectedAccountID = nil
+	pending := services.NewTransferMatcherWithConfig(config).WithFeePatterns([]services.FeePattern{
+		{FromAccountID: icbc, ToAccountID: ccb, Kind: services.FeeFixed, Fee: 3, Samples: 4},
+	}).FindMatches([]services.FileTransactions{unresolved, files[1]})
+	require.Len(t, pending.Matches, 1)
+	assert.Equal(t, plain.Matches[0].Confidence, pending.Matches[0].Confidence)
+}
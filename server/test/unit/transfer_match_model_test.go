@@ -0,0 +1,110 @@
+package unit
+
+import (
+	"testing"
+	"time"
+
+	"account/internal/business/models"
+	"account/internal/business/services"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func verdict(tail, amount, when, name float64, accepted bool) models.TransferMatchFeedback {
+	return models.TransferMatchFeedback{
+		MatchScores: models.MatchScores{TailNumber: tail, Amount: amount, Time: when, Name: name},
+		Accepted:    accepted,
+	}
+}
+
+// A user whose transfers always carry tail numbers: pairs without a matching
+// tail are rejected however close in time they are
+func tailDrivenFeedback() []models.TransferMatchFeedback {
+	var feedback []models.TransferMatchFeedback
+	for i := 0; i < 30; i++ {
+		when := []float64{1, 0.9, 0.6}[i%3]
+		feedback = append(feedback, verdict(1, 1, when, 0, true))
+		if i%2 == 0 {
+			feedback = append(feedback, verdict(0, 1, when, 1, false))
+		}
+	}
+	return feedback
+}
+
+func TestLearnMatchModel(t *testing.T) {
+	model := services.LearnMatchModel(tailDrivenFeedback(), 0.8)
+	defaults := services.DefaultMatchWeights()
+
+	assert.Equal(t, 30, model.Accepted)
+	assert.Equal(t, 15, model.Rejected)
+	assert.Greater(t, model.Weights.TailNumber, defaults.TailNumber)
+	assert.Less(t, model.Weights.Name, defaults.Name)
+	w := model.Weights
+	assert.InDelta(t, 1, w.TailNumber+w.Amount+w.Time+w.Name, 1e-9)
+
+	// The threshold separates the confirmed pairs from the rejected ones
+	accepted := model.Weights.Confidence(models.MatchScores{TailNumber: 1, Amount: 1, Time: 0.6})
+	rejected := model.Weights.Confidence(models.MatchScores{Amount: 1, Time: 1, Name: 1})
+	assert.LessOrEqual(t, model.MinConfidence, accepted)
+	assert.Greater(t, model.MinConfidence, rejected)
+	assert.GreaterOrEqual(t, model.AutoConfirm, model.MinConfidence)
+	assert.LessOrEqual(t, model.AutoConfirm, accepted)
+}
+
+func TestLearnMatchModelNeedsFeedback(t *testing.T) {
+	// Too few decisions keep the defaults and confirm nothing automatically
+	few := []models.TransferMatchFeedback{verdict(1, 1, 1, 0, true), verdict(0, 1, 1, 1, false)}
+	model := services.LearnMatchModel(few, 0.8)
+	assert.Equal(t, services.DefaultMatchWeights(), model.Weights)
+	assert.Zero(t, model.MinConfidence)
+	assert.Zero(t, model.AutoConfirm)
+
+	// A user who confirmed every match has its matches confirmed above the configured threshold
+	var confirmed []models.TransferMatchFeedback
+	for i := 0; i < 12; i++ {
+		confirmed = append(confirmed, verdict(1, 1, 0.9, 0, true))
+	}
+	model = services.LearnMatchModel(confirmed, 0.8)
+	assert.Equal(t, services.DefaultMatchWeights(), model.Weights)
+	assert.InDelta(t, 0.88, model.AutoConfirm, 1e-9)
+
+	// But not if those matches are below it
+	assert.Zero(t, services.LearnMatchModel(confirmed, 0.9).AutoConfirm)
+}
+
+func TestTransferMatcherUsesLearnedModel(t *testing.T) {
+	at := time.Date(2024, 5, 8, 10, 0, 0, 0, time.UTC)
+	files := []services.FileTransactions{
+		{FileID: uuid.New(), Transactions: []models.ParsedTransaction{
+			{LineNumber: 1, Type: models.TransactionTypeExpense, Amount: 500, TransactionDate: at, Note: "转账", ParsedAccountNumber: "1234"},
+		}},
+		{FileID: uuid.New(), Transactions: []models.ParsedTransaction{
+			{LineNumber: 1, Type: models.TransactionTypeIncome, Amount: 500, TransactionDate: at.Add(3 * time.Hour), Note: "转入", RelatedAccountNumber: "1234"},
+		}},
+	}
+
+	plain := services.NewTransferMatcher().FindMatches(files)
+	require.Len(t, plain.Matches, 1)
+	match := plain.Matches[0]
+	assert.Equal(t, models.MatchScores{TailNumber: 1, Amount: 1, Time: 0.6}, match.Scores)
+	assert.InDelta(t, 0.82, match.Confidence, 1e-9)
+
+	// Explaining the score adds up to the confidence
+	var total float64
+	for _, c := range services.DefaultMatchWeights().Explain(match.Scores) {
+		total += c.Contribution
+	}
+	assert.InDelta(t, match.Confidence, total, 1e-9)
+
+	// A user for whom time barely matters trusts the same pair more
+	model := services.MatchModel{Weights: models.MatchWeights{TailNumber: 0.6, Amount: 0.35, Time: 0.02, Name: 0.03}}
+	learned := services.NewTransferMatcher().WithModel(model).FindMatches(files)
+	require.Len(t, learned.Matches, 1)
+	assert.Greater(t, learned.Matches[0].Confidence, match.Confidence)
+
+	// A learned threshold above the pair's confidence leaves it unpaired
+	model.MinConfidence = 0.99
+	assert.Empty(t, services.NewTransferMatcher().WithModel(model).FindMatches(files).Matches)
+}
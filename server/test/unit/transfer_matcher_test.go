@@ -0,0 +1,197 @@
+package unit
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"account/internal/business/models"
+	"account/internal/business/services"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func transferRow(line int, typ models.TransactionType, amount float64, at time.Time, account, related string) models.ParsedTransaction {
+	note := "转账"
+	if typ == models.TransactionTypeIncome {
+		note = "转入"
+	}
+	return models.ParsedTransaction{
+		LineNumber:           line,
+		Type:                 typ,
+		Amount:               amount,
+		TransactionDate:      at,
+		Note:                 note,
+		ParsedAccountNumber:  account,
+		RelatedAccountNumber: related,
+	}
+}
+
+func TestTransferMatcherPrefersGlobalAssignment(t *testing.T) {
+	at := func(h, m int) time.Time { return time.Date(2024, 5, 1, h, m, 0, 0, time.UTC) }
+	bank := services.FileTransactions{FileID: uuid.New(), Transactions: []models.ParsedTransaction{
+		// Rent from card 9999 and a savings top-up from card 8888, same amount, same morning
+		transferRow(1, models.TransactionTypeExpense, 3000, at(10, 0), "9999", ""),
+		transferRow(2, models.TransactionTypeExpense, 3000, at(10, 5), "8888", "1111"),
+	}}
+	other := services.FileTransactions{FileID: uuid.New(), Transactions: []models.ParsedTransaction{
+		// Savings account 1111 names card 9999 as payer, so both transfers fit it by tail number
+		transferRow(1, models.TransactionTypeIncome, 3000, at(10, 10), "1111", "9999"),
+		// Landlord's account 2222, paid from 9999
+		transferRow(2, models.TransactionTypeIncome, 3000, at(11, 30), "2222", "9999"),
+	}}
+
+	result := services.NewTransferMatcher().FindMatches([]services.FileTransactions{bank, other})
+	require.Len(t, result.Matches, 2, "greedy pairing would leave the savings top-up unmatched")
+
+	pairs := make(map[int]int)
+	for _, m := range result.Matches {
+		pairs[m.OutTx.LineNumber] = m.InTx.LineNumber
+	}
+	assert.Equal(t, 2, pairs[1], "rent goes to the landlord")
+	assert.Equal(t, 1, pairs[2], "top-up goes to savings")
+	assert.Empty(t, result.UnmatchedOut)
+	assert.Empty(t, result.UnmatchedIn)
+
+	// The rent transfer lists both incoming rows, the chosen one marked
+	var rent *services.TransferCandidates
+	for i := range result.Candidates {
+		c := &result.Candidates[i]
+		if c.Tx.FileID == bank.FileID && c.Tx.LineNumber == 1 {
+			rent = c
+		}
+	}
+	require.NotNil(t, rent)
+	require.Len(t, rent.Candidates, 2)
+	assert.Equal(t, 1, rent.Candidates[0].Tx.LineNumber)
+	assert.False(t, rent.Candidates[0].Selected)
+	assert.Equal(t, 2, rent.Candidates[1].Tx.LineNumber)
+	assert.True(t, rent.Candidates[1].Selected)
+}
+
+func TestTransferMatcherThresholds(t *testing.T) {
+	at := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	files := []services.FileTransactions{{FileID: uuid.New(), Transactions: []models.ParsedTransaction{
+		transferRow(1, models.TransactionTypeExpense, 100, at, "", ""),
+		transferRow(2, models.TransactionTypeIncome, 100, at.Add(10*time.Minute), "", ""),
+	}}}
+
+	// Amount and time alone score 0.5: a candidate, but below the default pairing threshold
+	result := services.NewTransferMatcher().FindMatches(files)
+	assert.Empty(t, result.Matches)
+	require.Len(t, result.Candidates, 2)
+
+	config := services.DefaultTransferMatchConfig()
+	config.MinConfidence = 0.5
+	result = services.NewTransferMatcherWithConfig(config).FindMatches(files)
+	assert.Len(t, result.Matches, 1)
+
+	config.TimeWindow = 5 * time.Minute
+	result = services.NewTransferMatcherWithConfig(config).FindMatches(files)
+	assert.Empty(t, result.Matches)
+	assert.Empty(t, result.Candidates)
+}
+
+func TestTransferMatcherLargeBatch(t *testing.T) {
+	const pairs = 20000
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	out := services.FileTransactions{FileID: uuid.New()}
+	in := services.FileTransactions{FileID: uuid.New()}
+	for i := 0; i < pairs; i++ {
+		at := start.Add(time.Duration(i) * 7 * time.Minute)
+		card := fmt.Sprintf("%04d", i%50)
+		out.Transactions = append(out.Transactions, transferRow(i+1, models.TransactionTypeExpense, float64(100+i%40), at, card, ""))
+		in.Transactions = append(in.Transactions, transferRow(i+1, models.TransactionTypeIncome, float64(100+i%40), at.Add(time.Minute), "", card))
+	}
+
+	began := time.Now()
+	result := services.NewTransferMatcher().FindMatches([]services.FileTransactions{out, in})
+	assert.Less(t, time.Since(began), 10*time.Second)
+
+	require.Len(t, result.Matches, pairs)
+	for _, m := range result.Matches {
+		assert.Equal(t, m.OutTx.LineNumber, m.InTx.LineNumber)
+	}
+}
+
+func TestTransferMatcherPairsWithLedger(t *testing.T) {
+	at := func(d, h int) time.Time { return time.Date(2024, 6, d, h, 0, 0, 0, time.UTC) }
+	top := transferRow(0, models.TransactionTypeIncome, 500, at(3, 10), "", "6789")
+	top.Note = "余额充值"
+	ledger := services.FileTransactions{FileID: uuid.Nil, Existing: true, Transactions: []models.ParsedTransaction{
+		top, // Alipay top-up imported last week
+		transferRow(0, models.TransactionTypeExpense, 800, at(4, 9), "6789", ""),
+		transferRow(0, models.TransactionTypeIncome, 800, at(4, 9), "", "6789"), // ledger rows never pair with each other
+	}}
+
+	duplicate := transferRow(2, models.TransactionTypeExpense, 800, at(4, 9), "6789", "")
+	duplicate.IsDuplicate = true
+	statement := services.FileTransactions{FileID: uuid.New(), Transactions: []models.ParsedTransaction{
+		transferRow(1, models.TransactionTypeExpense, 500, at(3, 9), "6789", ""),
+		duplicate, // already in the ledger, so its stored copy stands in for it
+	}}
+
+	result := services.NewTransferMatcher().FindMatches([]services.FileTransactions{statement, ledger})
+	require.Len(t, result.Matches, 1)
+	match := result.Matches[0]
+	assert.Equal(t, statement.FileID, match.OutTx.FileID)
+	assert.Equal(t, 1, match.OutTx.LineNumber)
+	assert.True(t, match.InTx.Existing)
+	assert.Equal(t, 0, match.InTx.TransactionIndex)
+
+	for _, c := range result.Candidates {
+		if c.Tx.Existing {
+			assert.NotEqual(t, 800.0, c.Tx.Amount, "stored rows are not offered each other")
+		}
+	}
+}
+
+func TestTransferMatcherProposesGroups(t *testing.T) {
+	at := func(d, h int) time.Time { return time.Date(2024, 7, d, h, 0, 0, 0, time.UTC) }
+	bank := services.FileTransactions{FileID: uuid.New(), Transactions: []models.ParsedTransaction{
+		transferRow(1, models.TransactionTypeExpense, 10000, at(1, 9), "1111", ""), // salary split into three pockets
+		transferRow(2, models.TransactionTypeIncome, 500, at(5, 12), "1111", ""),   // two friends paid back into the bank
+		transferRow(3, models.TransactionTypeExpense, 800, at(9, 9), "1111", ""),   // nothing adds up to this
+	}}
+	pockets := services.FileTransactions{FileID: uuid.New(), Transactions: []models.ParsedTransaction{
+		transferRow(1, models.TransactionTypeIncome, 6000, at(1, 9), "2222", "1111"),
+		transferRow(2, models.TransactionTypeIncome, 3000, at(1, 9), "3333", "1111"),
+		transferRow(3, models.TransactionTypeIncome, 1000, at(1, 10), "4444", "1111"),
+		transferRow(4, models.TransactionTypeIncome, 500, at(9, 9), "2222", "1111"),
+		transferRow(5, models.TransactionTypeIncome, 200, at(9, 10), "3333", "1111"),
+	}}
+	wechat := services.FileTransactions{FileID: uuid.New(), Transactions: []models.ParsedTransaction{
+		transferRow(1, models.TransactionTypeExpense, 200, at(5, 10), "5555", "1111"),
+		transferRow(2, models.TransactionTypeExpense, 300, at(5, 11), "5555", "1111"),
+	}}
+
+	result := services.NewTransferMatcher().FindMatches([]services.FileTransactions{bank, pockets, wechat})
+	require.Empty(t, result.Matches)
+	require.Len(t, result.Groups, 2)
+
+	split := result.Groups[0]
+	require.Len(t, split.Outs, 1)
+	require.Len(t, split.Ins, 3)
+	assert.Equal(t, 10000.0, split.Outs[0].Amount)
+	var total float64
+	for _, in := range split.Ins {
+		assert.Equal(t, pockets.FileID, in.FileID)
+		total += in.Amount
+	}
+	assert.Equal(t, 10000.0, total)
+	assert.Equal(t, "3笔合计金额匹配", split.MatchFactors[0])
+	assert.GreaterOrEqual(t, split.Confidence, 0.5)
+
+	collected := result.Groups[1]
+	require.Len(t, collected.Outs, 2)
+	require.Len(t, collected.Ins, 1)
+	assert.Equal(t, 500.0, collected.Ins[0].Amount)
+	assert.Equal(t, 200.0, collected.Outs[0].Amount, "legs are ordered by date")
+
+	// Grouping can be switched off
+	config := services.DefaultTransferMatchConfig()
+	config.MaxGroupSize = 1
+	assert.Empty(t, services.NewTransferMatcherWithConfig(config).FindMatches([]services.FileTransactions{bank, pockets, wechat}).Groups)
+}
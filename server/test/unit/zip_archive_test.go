@@ -0,0 +1,179 @@
+package unit
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/aes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/binary"
+	"hash/crc32"
+	"testing"
+
+	"account/internal/business/models"
+	"account/internal/business/services"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+const alipayBillSample = "支付宝交易记录明细查询\n交易号,交易时间,交易对方,金额（元）,收/支\n2024011522001,2024-01-15 12:00:00,瑞幸咖啡,15.00,支出\n"
+
+// zipCryptoEncrypt encrypts a stored member with the traditional PKWARE cipher
+func zipCryptoEncrypt(plain []byte, password string, check byte) []byte {
+	keys := [3]uint32{0x12345678, 0x23456789, 0x34567890}
+	update := func(b byte) {
+		keys[0] = crc32.IEEETable[byte(keys[0])^b] ^ (keys[0] >> 8)
+		keys[1] = (keys[1]+(keys[0]&0xff))*134775813 + 1
+		keys[2] = crc32.IEEETable[byte(keys[2])^byte(keys[1]>>24)] ^ (keys[2] >> 8)
+	}
+	for i := 0; i < len(password); i++ {
+		update(password[i])
+	}
+
+	header := make([]byte, 12)
+	header[11] = check
+	out := make([]byte, 0, 12+len(plain))
+	for _, p := range append(header, plain...) {
+		temp := uint16(keys[2]) | 2
+		out = append(out, p^byte((uint32(temp)*uint32(temp^1))>>8))
+		update(p)
+	}
+	return out
+}
+
+// winZipAESEncrypt encrypts a stored member with AES-256 (AE-2)
+func winZipAESEncrypt(plain []byte, password string) []byte {
+	salt := bytes.Repeat([]byte{0x42}, 16)
+	derived := pbkdf2.Key([]byte(password), salt, 1000, 66, sha1.New)
+	block, _ := aes.NewCipher(derived[:32])
+
+	cipherText := make([]byte, len(plain))
+	var counter, stream [aes.BlockSize]byte
+	for i := 0; i < len(plain); i += aes.BlockSize {
+		binary.LittleEndian.PutUint64(counter[:8], uint64(i/aes.BlockSize+1))
+		block.Encrypt(stream[:], counter[:])
+		for j := i; j < len(plain) && j < i+aes.BlockSize; j++ {
+			cipherText[j] = plain[j] ^ stream[j-i]
+		}
+	}
+	mac := hmac.New(sha1.New, derived[32:64])
+	mac.Write(cipherText)
+
+	out := append(append([]byte{}, salt...), derived[64:66]...)
+	out = append(out, cipherText...)
+	return append(out, mac.Sum(nil)[:10]...)
+}
+
+func buildEncryptedZip(t *testing.T, name string, plain []byte, password string, useAES bool) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	header := &zip.FileHeader{
+		Name:               name,
+		Flags:              0x1,
+		Method:             zip.Store,
+		CRC32:              crc32.ChecksumIEEE(plain),
+		UncompressedSize64: uint64(len(plain)),
+	}
+
+	var data []byte
+	if useAES {
+		header.Method = 99
+		header.CRC32 = 0
+		// vendor version 2, vendor "AE", strength 3 (AES-256), actual method 0 (stored)
+		header.Extra = []byte{0x01, 0x99, 0x07, 0x00, 0x02, 0x00, 'A', 'E', 0x03, 0x00, 0x00}
+		data = winZipAESEncrypt(plain, password)
+	} else {
+		data = zipCryptoEncrypt(plain, password, byte(header.CRC32>>24))
+	}
+	header.CompressedSize64 = uint64(len(data))
+
+	fw, err := w.CreateRaw(header)
+	require.NoError(t, err)
+	_, err = fw.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestExtractZip_ZipCryptoAndAES(t *testing.T) {
+	gbk, err := simplifiedchinese.GBK.NewEncoder().Bytes([]byte(alipayBillSample))
+	require.NoError(t, err)
+
+	for _, useAES := range []bool{false, true} {
+		archive := buildEncryptedZip(t, "alipay_record_20240131.csv", gbk, "123456", useAES)
+		require.True(t, services.IsZipArchive(archive))
+
+		_, err := services.ExtractZip(archive, "")
+		assert.ErrorIs(t, err, services.ErrZipPasswordRequired)
+
+		_, err = services.ExtractZip(archive, "654321")
+		assert.ErrorIs(t, err, services.ErrZipWrongPassword)
+
+		members, err := services.ExtractZip(archive, "123456")
+		require.NoError(t, err)
+		require.Len(t, members, 1)
+		assert.Equal(t, "alipay_record_20240131.csv", members[0].Name)
+
+		text := services.NormalizeBillText(members[0].Content)
+		assert.Equal(t, alipayBillSample, string(text))
+
+		source, ok := services.DetectImportSource(members[0].Name, text)
+		assert.True(t, ok)
+		assert.Equal(t, models.ImportSourceAlipay, source)
+	}
+}
+
+func TestExtractZip_PlainArchiveSkipsMetadata(t *testing.T) {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, content := range map[string]string{
+		"微信支付账单.csv":                "微信支付账单明细\n交易时间,交易类型,交易对方,商品,收/支,金额(元),支付方式,当前状态,交易单号\n",
+		"__MACOSX/._微信支付账单.csv":     "junk",
+		"statements/ofx_export.ofx": "OFXHEADER:100\n<OFX></OFX>",
+	} {
+		fw, err := w.Create(name)
+		require.NoError(t, err)
+		_, _ = fw.Write([]byte(content))
+	}
+	require.NoError(t, w.Close())
+
+	members, err := services.ExtractZip(buf.Bytes(), "")
+	require.NoError(t, err)
+	require.Len(t, members, 2)
+
+	sources := make(map[string]models.ImportSource)
+	for _, m := range members {
+		source, ok := services.DetectImportSource(m.Name, m.Content)
+		require.True(t, ok)
+		sources[m.Name] = source
+	}
+	assert.Equal(t, models.ImportSourceWeChat, sources["微信支付账单.csv"])
+	assert.Equal(t, models.ImportSourceOFX, sources["statements/ofx_export.ofx"])
+}
+
+func TestWalkZip_StopsOnVisitError(t *testing.T) {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, name := range []string{"a.csv", "b.csv", "c.csv"} {
+		fw, err := w.Create(name)
+		require.NoError(t, err)
+		_, _ = fw.Write([]byte("交易时间,金额\n"))
+	}
+	require.NoError(t, w.Close())
+
+	var visited []string
+	err := services.WalkZip(buf.Bytes(), "", func(m services.ArchiveMember) error {
+		visited = append(visited, m.Name)
+		if len(visited) == 2 {
+			return services.ErrUploadTooLarge
+		}
+		return nil
+	})
+	assert.ErrorIs(t, err, services.ErrUploadTooLarge)
+	assert.Equal(t, []string{"a.csv", "b.csv"}, visited)
+}